@@ -0,0 +1,74 @@
+// Package bhttp encodes and decodes Binary HTTP messages (RFC 9292), the
+// self-contained message format carried inside Oblivious HTTP.
+//
+// Requests and responses are available in known-length and
+// indeterminate-length framing, both of which carry field sections,
+// content, trailers and, for responses, any number of informational (1xx)
+// responses. Request and Response convert to and from their net/http
+// counterparts.
+package bhttp
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Framing selects the encoding of a message.
+type Framing int
+
+// Framing indicators of RFC 9292 section 3.3, without the request and
+// response distinction.
+const (
+	KnownLength         Framing = iota // sections prefixed with their length
+	IndeterminateLength                // sections terminated by a zero marker
+)
+
+// Framing indicator values on the wire.
+const (
+	knownLengthRequest          = 0
+	knownLengthResponse         = 1
+	indeterminateLengthRequest  = 2
+	indeterminateLengthResponse = 3
+)
+
+var (
+	// ErrMalformed is returned for messages that violate the encoding of
+	// RFC 9292.
+	ErrMalformed = errors.New("bhttp: malformed message")
+	// ErrUnknownFraming is returned for framing indicators that are not
+	// defined by RFC 9292, or that select a response where a request is
+	// expected and vice versa.
+	ErrUnknownFraming = errors.New("bhttp: unknown framing indicator")
+	// ErrInvalidField is returned for field names that are empty, contain
+	// upper-case or non-token characters, or are pseudo-header fields.
+	ErrInvalidField = errors.New("bhttp: invalid field name")
+	// ErrInvalidStatus is returned for status codes outside 100-599, or a
+	// final status code in the informational range.
+	ErrInvalidStatus = errors.New("bhttp: invalid status code")
+)
+
+// Request is a binary HTTP request.
+type Request struct {
+	Method    string
+	Scheme    string
+	Authority string // may be empty when Header holds a Host field
+	Path      string // request target in origin or asterisk form
+	Header    http.Header
+	Body      []byte
+	Trailer   http.Header
+}
+
+// Informational is an interim (1xx) response preceding the final response.
+type Informational struct {
+	Status int
+	Header http.Header
+}
+
+// Response is a binary HTTP response.
+type Response struct {
+	Informational []Informational
+	Status        int
+	Header        http.Header
+	Body          []byte
+	Trailer       http.Header
+}
@@ -0,0 +1,310 @@
+package bhttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Examples from RFC 9292 section 5, as printed there.
+const (
+	knownLengthRequestExample = `
+		00034745 54056874 74707300 0a2f6865
+		6c6c6f2e 74787440 6c0a7573 65722d61
+		67656e74 34637572 6c2f372e 31362e33
+		206c6962 6375726c 2f372e31 362e3320
+		4f70656e 53534c2f 302e392e 376c207a
+		6c69622f 312e322e 3304686f 73740f77
+		77772e65 78616d70 6c652e63 6f6d0f61
+		63636570 742d6c61 6e677561 67650665
+		6e2c206d 69000000 00000000 00000000`
+	indeterminateLengthRequestExample = `
+		02034745 54056874 74707300 0a2f6865
+		6c6c6f2e 7478740a 75736572 2d616765
+		6e743463 75726c2f 372e3136 2e33206c
+		69626375 726c2f37 2e31362e 33204f70
+		656e5353 4c2f302e 392e376c 207a6c69
+		622f312e 322e3304 686f7374 0f777777
+		2e657861 6d706c65 2e636f6d 0f616363
+		6570742d 6c616e67 75616765 06656e2c
+		206d6900 00000000 00000000 00000000`
+	indeterminateLengthResponseExample = `
+		03406607 72756e6e 696e670a 22736c65
+		65702031 35220040 67046c69 6e6b233c
+		2f737479 6c652e63 73733e3b 2072656c
+		3d707265 6c6f6164 3b206173 3d737479
+		6c65046c 696e6b24 3c2f7363 72697074
+		2e6a733e 3b207265 6c3d7072 656c6f61
+		643b2061 733d7363 72697074 0040c804
+		64617465 1d4d6f6e 2c203237 204a756c
+		20323030 39203132 3a32383a 35332047
+		4d540673 65727665 72064170 61636865
+		0d6c6173 742d6d6f 64696669 65641d57
+		65642c20 3232204a 756c2032 30303920
+		31393a31 353a3536 20474d54 04657461
+		67142233 34616133 38372d64 2d313536
+		38656230 30220d61 63636570 742d7261
+		6e676573 05627974 65730e63 6f6e7465
+		6e742d6c 656e6774 68023531 04766172
+		790f4163 63657074 2d456e63 6f64696e
+		670c636f 6e74656e 742d7479 70650a74
+		6578742f 706c6169 6e003348 656c6c6f
+		20576f72 6c642120 4d792063 6f6e7465
+		6e742069 6e636c75 64657320 61207472
+		61696c69 6e672043 524c462e 0d0a0000`
+	knownLengthTrailerExample = `
+		0140c800 1d546869 7320636f 6e74656e
+		7420636f 6e746169 6e732043 524c462e
+		0d0a0d07 74726169 6c657204 74657874`
+)
+
+// fromHex decodes hex digits, ignoring white space.
+func fromHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func exampleRequest() *Request {
+	return &Request{
+		Method: "GET",
+		Scheme: "https",
+		Path:   "/hello.txt",
+		Header: http.Header{
+			"User-Agent":      {"curl/7.16.3 libcurl/7.16.3 OpenSSL/0.9.7l zlib/1.2.3"},
+			"Host":            {"www.example.com"},
+			"Accept-Language": {"en, mi"},
+		},
+	}
+}
+
+func TestParseRequestExamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     string
+		framing Framing
+	}{
+		{"known length", knownLengthRequestExample, KnownLength},
+		{"indeterminate length", indeterminateLengthRequestExample, IndeterminateLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRequest(fromHex(t, tt.msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := exampleRequest(); !reflect.DeepEqual(r, want) {
+				t.Errorf("ParseRequest = %+v, want %+v", r, want)
+			}
+			// Re-encoding drops the padding and orders fields by name,
+			// so compare after another decode.
+			b, err := r.Marshal(tt.framing)
+			if err != nil {
+				t.Fatal(err)
+			}
+			again, err := ParseRequest(b)
+			if err != nil || !reflect.DeepEqual(again, r) {
+				t.Errorf("round trip = %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestParseResponseExample(t *testing.T) {
+	msg := fromHex(t, indeterminateLengthResponseExample)
+	r, err := ParseResponse(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Response{
+		Informational: []Informational{
+			{Status: 102, Header: http.Header{"Running": {`"sleep 15"`}}},
+			{Status: 103, Header: http.Header{"Link": {
+				"</style.css>; rel=preload; as=style",
+				"</script.js>; rel=preload; as=script",
+			}}},
+		},
+		Status: 200,
+		Header: http.Header{
+			"Date":           {"Mon, 27 Jul 2009 12:28:53 GMT"},
+			"Server":         {"Apache"},
+			"Last-Modified":  {"Wed, 22 Jul 2009 19:15:56 GMT"},
+			"Etag":           {`"34aa387-d-1568eb00"`},
+			"Accept-Ranges":  {"bytes"},
+			"Content-Length": {"51"},
+			"Vary":           {"Accept-Encoding"},
+			"Content-Type":   {"text/plain"},
+		},
+		Body: []byte("Hello World! My content includes a trailing CRLF.\r\n"),
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("ParseResponse = %+v, want %+v", r, want)
+	}
+	b, err := r.Marshal(IndeterminateLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := ParseResponse(b); err != nil || !reflect.DeepEqual(again, r) {
+		t.Errorf("round trip = %+v, %v", again, err)
+	}
+}
+
+func TestResponseTrailerExample(t *testing.T) {
+	msg := fromHex(t, knownLengthTrailerExample)
+	r := &Response{
+		Status:  200,
+		Header:  http.Header{},
+		Body:    []byte("This content contains CRLF.\r\n"),
+		Trailer: http.Header{"Trailer": {"text"}},
+	}
+	b, err := r.Marshal(KnownLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, msg) {
+		t.Errorf("Marshal = %x, want %x", b, msg)
+	}
+	got, err := ParseResponse(msg)
+	if err != nil || !reflect.DeepEqual(got, r) {
+		t.Errorf("ParseResponse = %+v, %v; want %+v", got, err, r)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := &Response{
+		Informational: []Informational{{Status: 103, Header: http.Header{"Link": {"</a.css>; rel=preload"}}}},
+		Status:        404,
+		Header:        http.Header{"Content-Type": {"text/plain"}, "Set-Cookie": {"a=1", "b=2"}},
+		Body:          bytes.Repeat([]byte("x"), 20000), // two-byte length prefix
+		Trailer:       http.Header{"Server-Timing": {"db;dur=53"}},
+	}
+	for _, f := range []Framing{KnownLength, IndeterminateLength} {
+		b, err := r.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseResponse(b)
+		if err != nil || !reflect.DeepEqual(got, r) {
+			t.Errorf("framing %d: round trip = %v", f, err)
+		}
+		if _, err := ParseRequest(b); err != ErrUnknownFraming {
+			t.Errorf("framing %d: ParseRequest(response) = %v", f, err)
+		}
+	}
+}
+
+func TestTruncationAndPadding(t *testing.T) {
+	// A request truncated after the header section.
+	full := fromHex(t, knownLengthRequestExample)
+	r, err := ParseRequest(full[:len(full)-11])
+	if err != nil || r.Body != nil || r.Trailer != nil {
+		t.Errorf("truncated request = %+v, %v", r, err)
+	}
+	padded := append(append([]byte(nil), full...), 1)
+	if _, err := ParseRequest(padded); err != ErrMalformed {
+		t.Errorf("non-zero padding: %v", err)
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+		err  error
+	}{
+		{"empty", nil, ErrMalformed},
+		{"unknown framing", []byte{4}, ErrUnknownFraming},
+		{"short varint", []byte{0, 0x40}, ErrMalformed},
+		{"method not a token", []byte{0, 1, ' ', 0, 0, 1, '/'}, ErrMalformed},
+		{"string past end", []byte{0, 5, 'G'}, ErrMalformed},
+		{"upper-case field", append([]byte{0, 3, 'G', 'E', 'T', 0, 0, 1, '/', 4}, 1, 'A', 1, 'b'), ErrInvalidField},
+		{"empty field name", append([]byte{0, 3, 'G', 'E', 'T', 0, 0, 1, '/', 2}, 0, 0), ErrInvalidField},
+		{"pseudo field", append([]byte{0, 3, 'G', 'E', 'T', 0, 0, 1, '/', 4}, 1, ':', 1, 'b'), ErrInvalidField},
+		{"newline in value", append([]byte{0, 3, 'G', 'E', 'T', 0, 0, 1, '/', 4}, 1, 'a', 1, '\n'), ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseRequest(tt.msg); err != tt.err {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	for _, status := range []int{99, 600} {
+		if _, err := ParseResponse([]byte{1, 0x40 | byte(status>>8), byte(status)}); err != ErrInvalidStatus {
+			t.Errorf("status %d: %v", status, err)
+		}
+	}
+	bad := []*Response{
+		{Status: 103},
+		{Status: 200, Informational: []Informational{{Status: 200}}},
+		{Status: 200, Header: http.Header{"Bad Name": {"x"}}},
+		{Status: 200, Header: http.Header{"A": {"x\r\ny"}}},
+	}
+	for _, r := range bad {
+		if _, err := r.Marshal(KnownLength); err == nil {
+			t.Errorf("Marshal(%+v) succeeded", r)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www.example.com" || r.URL.Path != "/hello.txt" || r.Header.Get("Host") != "" {
+			t.Errorf("request %s %s, Host %q", r.Method, r.URL, r.Header.Get("Host"))
+		}
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Del("Link")
+		w.Header().Set("Trailer", "Server-Timing")
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("hello"))
+		w.Header().Set("Server-Timing", "total;dur=1")
+		w.Header().Set(http.TrailerPrefix+"Digest", "x")
+	})
+	tests := []struct {
+		name    string
+		msg     string
+		framing byte
+	}{
+		{"known length", knownLengthRequestExample, knownLengthResponse},
+		{"indeterminate length", indeterminateLengthRequestExample, indeterminateLengthResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := Serve(context.Background(), h, fromHex(t, tt.msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b[0] != tt.framing {
+				t.Errorf("framing indicator %d, want %d", b[0], tt.framing)
+			}
+			r, err := ParseResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := &Response{
+				Informational: []Informational{{Status: 103, Header: http.Header{"Link": {"</style.css>; rel=preload; as=style"}}}},
+				Status:        200,
+				Header:        http.Header{"Content-Type": {"text/plain"}},
+				Body:          []byte("hello"),
+				Trailer:       http.Header{"Server-Timing": {"total;dur=1"}, "Digest": {"x"}},
+			}
+			if !reflect.DeepEqual(r, want) {
+				t.Errorf("response = %+v, want %+v", r, want)
+			}
+		})
+	}
+
+	if _, err := Serve(context.Background(), h, []byte{1}); err != ErrUnknownFraming {
+		t.Errorf("Serve(response) = %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+package bhttp
+
+import (
+	"net/http"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// ParseRequest decodes a binary HTTP request in either framing. Messages
+// truncated before the content or trailer section decode with those
+// sections empty, and trailing zero padding is ignored.
+func ParseRequest(b []byte) (*Request, error) {
+	d := &decoder{b: b}
+	framing, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	var f Framing
+	switch framing {
+	case knownLengthRequest:
+		f = KnownLength
+	case indeterminateLengthRequest:
+		f = IndeterminateLength
+	default:
+		return nil, ErrUnknownFraming
+	}
+	r := &Request{}
+	for _, s := range []*string{&r.Method, &r.Scheme, &r.Authority, &r.Path} {
+		if *s, err = d.string(); err != nil {
+			return nil, err
+		}
+	}
+	if !httpfields.IsToken(r.Method) {
+		return nil, ErrMalformed
+	}
+	if r.Header, r.Body, r.Trailer, err = d.sections(f); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParseResponse decodes a binary HTTP response in either framing, with the
+// same treatment of truncation and padding as ParseRequest.
+func ParseResponse(b []byte) (*Response, error) {
+	d := &decoder{b: b}
+	framing, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	var f Framing
+	switch framing {
+	case knownLengthResponse:
+		f = KnownLength
+	case indeterminateLengthResponse:
+		f = IndeterminateLength
+	default:
+		return nil, ErrUnknownFraming
+	}
+	r := &Response{}
+	for {
+		status, err := d.varint()
+		if err != nil {
+			return nil, err
+		}
+		if status < 100 || status > 599 {
+			return nil, ErrInvalidStatus
+		}
+		if status >= 200 {
+			r.Status = int(status)
+			break
+		}
+		h, err := d.fields(f)
+		if err != nil {
+			return nil, err
+		}
+		r.Informational = append(r.Informational, Informational{Status: int(status), Header: h})
+	}
+	if r.Header, r.Body, r.Trailer, err = d.sections(f); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type decoder struct {
+	b   []byte
+	off int
+}
+
+func (d *decoder) eof() bool { return d.off >= len(d.b) }
+
+// varint reads a QUIC variable-length integer.
+func (d *decoder) varint() (uint64, error) {
+	if d.eof() {
+		return 0, ErrMalformed
+	}
+	n := 1 << (d.b[d.off] >> 6)
+	if len(d.b)-d.off < n {
+		return 0, ErrMalformed
+	}
+	v := uint64(d.b[d.off] & 0x3f)
+	for i := 1; i < n; i++ {
+		v = v<<8 | uint64(d.b[d.off+i])
+	}
+	d.off += n
+	return v, nil
+}
+
+// bytes reads a length-prefixed byte string.
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)-d.off) {
+		return nil, ErrMalformed
+	}
+	b := d.b[d.off : d.off+int(n)]
+	d.off += int(n)
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+// sections reads the header section, content and trailer section, allowing
+// truncation before the content and before the trailers, and checks that
+// only padding follows.
+func (d *decoder) sections(f Framing) (header http.Header, body []byte, trailer http.Header, err error) {
+	if header, err = d.fields(f); err != nil {
+		return nil, nil, nil, err
+	}
+	if d.eof() {
+		return header, nil, nil, nil
+	}
+	if f == KnownLength {
+		b, err := d.bytes()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		body = append([]byte(nil), b...)
+	} else {
+		for {
+			chunk, err := d.bytes()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if len(chunk) == 0 {
+				break
+			}
+			body = append(body, chunk...)
+		}
+	}
+	if !d.eof() {
+		if trailer, err = d.fields(f); err != nil {
+			return nil, nil, nil, err
+		}
+		if len(trailer) == 0 {
+			trailer = nil
+		}
+	}
+	for ; !d.eof(); d.off++ {
+		if d.b[d.off] != 0 {
+			return nil, nil, nil, ErrMalformed
+		}
+	}
+	return header, body, trailer, nil
+}
+
+// fields reads a field section.
+func (d *decoder) fields(f Framing) (http.Header, error) {
+	h := make(http.Header)
+	fd := d
+	if f == KnownLength {
+		section, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		fd = &decoder{b: section}
+	}
+	for {
+		if f == KnownLength && fd.eof() {
+			return h, nil
+		}
+		name, err := fd.string()
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			if f == KnownLength {
+				return nil, ErrInvalidField
+			}
+			return h, nil
+		}
+		if !isFieldName(name) {
+			return nil, ErrInvalidField
+		}
+		value, err := fd.string()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < len(value); i++ {
+			if c := value[i]; c == '\r' || c == '\n' || c == 0 {
+				return nil, ErrMalformed
+			}
+		}
+		key := http.CanonicalHeaderKey(name)
+		h[key] = append(h[key], value)
+	}
+}
+
+// isFieldName reports whether name is a lower-case token.
+func isFieldName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; c >= 'A' && c <= 'Z' {
+			return false
+		}
+	}
+	return httpfields.IsToken(name)
+}
@@ -0,0 +1,127 @@
+package bhttp
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// Marshal encodes r with the given framing.
+func (r *Request) Marshal(f Framing) ([]byte, error) {
+	var b []byte
+	switch f {
+	case KnownLength:
+		b = appendVarint(b, knownLengthRequest)
+	case IndeterminateLength:
+		b = appendVarint(b, indeterminateLengthRequest)
+	default:
+		return nil, ErrUnknownFraming
+	}
+	if !httpfields.IsToken(r.Method) {
+		return nil, ErrMalformed
+	}
+	for _, s := range []string{r.Method, r.Scheme, r.Authority, r.Path} {
+		b = appendString(b, s)
+	}
+	return appendSections(b, f, r.Header, r.Body, r.Trailer)
+}
+
+// Marshal encodes r with the given framing.
+func (r *Response) Marshal(f Framing) ([]byte, error) {
+	var b []byte
+	switch f {
+	case KnownLength:
+		b = appendVarint(b, knownLengthResponse)
+	case IndeterminateLength:
+		b = appendVarint(b, indeterminateLengthResponse)
+	default:
+		return nil, ErrUnknownFraming
+	}
+	var err error
+	for _, info := range r.Informational {
+		if info.Status < 100 || info.Status > 199 {
+			return nil, ErrInvalidStatus
+		}
+		b = appendVarint(b, uint64(info.Status))
+		if b, err = appendFields(b, f, info.Header); err != nil {
+			return nil, err
+		}
+	}
+	if r.Status < 200 || r.Status > 599 {
+		return nil, ErrInvalidStatus
+	}
+	b = appendVarint(b, uint64(r.Status))
+	return appendSections(b, f, r.Header, r.Body, r.Trailer)
+}
+
+// appendSections appends the header section, content and trailer section
+// shared by requests and responses.
+func appendSections(b []byte, f Framing, header http.Header, body []byte, trailer http.Header) ([]byte, error) {
+	b, err := appendFields(b, f, header)
+	if err != nil {
+		return nil, err
+	}
+	if f == KnownLength {
+		b = appendVarint(b, uint64(len(body)))
+		b = append(b, body...)
+	} else {
+		if len(body) > 0 {
+			b = appendVarint(b, uint64(len(body)))
+			b = append(b, body...)
+		}
+		b = appendVarint(b, 0)
+	}
+	return appendFields(b, f, trailer)
+}
+
+// appendFields appends a field section. Names are lower-cased and sorted so
+// that encoding is deterministic; the order of values per name is kept.
+func appendFields(b []byte, f Framing, h http.Header) ([]byte, error) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []byte
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		if !httpfields.IsToken(lower) {
+			return nil, ErrInvalidField
+		}
+		for _, v := range h[name] {
+			if strings.ContainsAny(v, "\r\n\x00") {
+				return nil, ErrMalformed
+			}
+			lines = appendString(lines, lower)
+			lines = appendString(lines, v)
+		}
+	}
+	if f == KnownLength {
+		b = appendVarint(b, uint64(len(lines)))
+		return append(b, lines...), nil
+	}
+	b = append(b, lines...)
+	return appendVarint(b, 0), nil
+}
+
+func appendString(b []byte, s string) []byte {
+	b = appendVarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendVarint appends v as a QUIC variable-length integer (RFC 9000
+// section 16), using the shortest encoding.
+func appendVarint(b []byte, v uint64) []byte {
+	switch {
+	case v < 1<<6:
+		return append(b, byte(v))
+	case v < 1<<14:
+		return append(b, byte(v>>8)|0x40, byte(v))
+	case v < 1<<30:
+		return append(b, byte(v>>24)|0x80, byte(v>>16), byte(v>>8), byte(v))
+	}
+	return append(b, byte(v>>56)|0xc0, byte(v>>48), byte(v>>40), byte(v>>32),
+		byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
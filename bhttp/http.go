@@ -0,0 +1,282 @@
+package bhttp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// hopByHop lists connection-specific fields, which have no meaning inside a
+// binary message and are dropped when converting from net/http.
+var hopByHop = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer",
+	"Transfer-Encoding", "Upgrade", "Host", "Content-Length",
+}
+
+// messageHeader returns a copy of h without hop-by-hop fields and fields
+// nominated by Connection.
+func messageHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		out[name] = append([]string(nil), values...)
+	}
+	for _, v := range h["Connection"] {
+		for _, name := range httpfields.SplitList(v) {
+			out.Del(name)
+		}
+	}
+	for _, name := range hopByHop {
+		out.Del(name)
+	}
+	return out
+}
+
+// trailerHeader copies the non-empty trailer fields of t, returning nil if
+// there are none.
+func trailerHeader(t http.Header) http.Header {
+	var out http.Header
+	for name, values := range t {
+		if len(values) == 0 {
+			continue
+		}
+		if out == nil {
+			out = make(http.Header)
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// NewRequest converts req, reading and closing its body. The scheme
+// defaults to https for requests received over TLS and http otherwise, and
+// the authority is taken from req.Host.
+func NewRequest(req *http.Request) (*Request, error) {
+	r := &Request{
+		Method:    req.Method,
+		Scheme:    req.URL.Scheme,
+		Authority: req.Host,
+		Path:      req.URL.RequestURI(),
+		Header:    messageHeader(req.Header),
+	}
+	if r.Method == "" {
+		r.Method = http.MethodGet
+	}
+	if r.Scheme == "" {
+		r.Scheme = "http"
+		if req.TLS != nil {
+			r.Scheme = "https"
+		}
+	}
+	if r.Authority == "" {
+		r.Authority = req.URL.Host
+	}
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	r.Trailer = trailerHeader(req.Trailer)
+	return r, nil
+}
+
+// HTTPRequest converts r to a request suitable for an http.Handler. The
+// authority falls back to the Host field when empty.
+func (r *Request) HTTPRequest() (*http.Request, error) {
+	header := make(http.Header, len(r.Header))
+	for name, values := range r.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	host := r.Authority
+	if host == "" {
+		host = header.Get("Host")
+	}
+	header.Del("Host")
+	u := &url.URL{Scheme: r.Scheme, Host: host}
+	switch {
+	case r.Path == "*":
+		u.Opaque = "*"
+	case strings.HasPrefix(r.Path, "/"):
+		ref, err := url.ParseRequestURI(r.Path)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		u.Path, u.RawPath, u.RawQuery = ref.Path, ref.RawPath, ref.RawQuery
+	default:
+		return nil, ErrMalformed
+	}
+	body := r.Body
+	req := &http.Request{
+		Method:        r.Method,
+		URL:           u,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Host:          host,
+		RequestURI:    r.Path,
+		Trailer:       trailerHeader(r.Trailer),
+	}
+	if len(body) == 0 {
+		req.Body = http.NoBody
+	}
+	return req, nil
+}
+
+// NewResponse converts resp, reading and closing its body. Informational
+// responses are not part of http.Response; callers that observe them, for
+// example with httptrace, can add them to the result.
+func NewResponse(resp *http.Response) (*Response, error) {
+	r := &Response{
+		Status: resp.StatusCode,
+		Header: messageHeader(resp.Header),
+	}
+	if resp.Body != nil {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	r.Trailer = trailerHeader(resp.Trailer)
+	return r, nil
+}
+
+// HTTPResponse converts r to an http.Response for req, dropping any
+// informational responses.
+func (r *Response) HTTPResponse(req *http.Request) *http.Response {
+	header := make(http.Header, len(r.Header))
+	for name, values := range r.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(r.Status) + " " + http.StatusText(r.Status),
+		StatusCode:    r.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Trailer:       trailerHeader(r.Trailer),
+		Request:       req,
+	}
+}
+
+// ResponseWriter is an http.ResponseWriter that records a handler's
+// response, including informational responses written with a 1xx status.
+type ResponseWriter struct {
+	header   http.Header
+	resp     Response
+	declared []string
+	wrote    bool
+	body     bytes.Buffer
+}
+
+// NewResponseWriter returns an empty ResponseWriter.
+func NewResponseWriter() *ResponseWriter {
+	return &ResponseWriter{header: make(http.Header)}
+}
+
+// Header returns the header map the handler modifies.
+func (w *ResponseWriter) Header() http.Header { return w.header }
+
+// WriteHeader records an informational response for 1xx codes and the
+// final status otherwise. Like net/http, fields set before an informational
+// response remain set for the final response.
+func (w *ResponseWriter) WriteHeader(status int) {
+	if w.wrote {
+		return
+	}
+	if status >= 100 && status <= 199 {
+		w.resp.Informational = append(w.resp.Informational, Informational{
+			Status: status,
+			Header: messageHeader(w.header),
+		})
+		return
+	}
+	w.wrote = true
+	w.resp.Status = status
+	w.resp.Header = messageHeader(w.header)
+	for _, v := range w.header["Trailer"] {
+		w.declared = append(w.declared, httpfields.SplitList(v)...)
+	}
+	for name := range w.resp.Header {
+		if strings.HasPrefix(name, http.TrailerPrefix) {
+			delete(w.resp.Header, name)
+		}
+	}
+}
+
+// Write records content, writing a 200 status first if needed. As with
+// net/http, a missing Content-Type is sniffed from the first write.
+func (w *ResponseWriter) Write(p []byte) (int, error) {
+	if !w.wrote {
+		if _, ok := w.header["Content-Type"]; !ok && len(p) > 0 {
+			w.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(p)
+}
+
+// Response returns the recorded response. Trailers are the fields declared
+// in the Trailer field and those set with http.TrailerPrefix.
+func (w *ResponseWriter) Response() *Response {
+	if !w.wrote {
+		w.WriteHeader(http.StatusOK)
+	}
+	r := w.resp
+	r.Body = w.body.Bytes()
+	r.Trailer = nil
+	add := func(name string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		if r.Trailer == nil {
+			r.Trailer = make(http.Header)
+		}
+		r.Trailer[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	for _, name := range w.declared {
+		add(name, w.header[http.CanonicalHeaderKey(name)])
+	}
+	for name, values := range w.header {
+		if strings.HasPrefix(name, http.TrailerPrefix) {
+			add(strings.TrimPrefix(name, http.TrailerPrefix), values)
+		}
+	}
+	return &r
+}
+
+// Serve decodes a binary request, serves it with h and returns the encoded
+// response in the framing of the request. It is the core of an Oblivious
+// HTTP gateway once the encapsulation has been removed.
+func Serve(ctx context.Context, h http.Handler, msg []byte) ([]byte, error) {
+	r, err := ParseRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := r.HTTPRequest()
+	if err != nil {
+		return nil, err
+	}
+	w := NewResponseWriter()
+	h.ServeHTTP(w, req.WithContext(ctx))
+	f := KnownLength
+	if msg[0] == indeterminateLengthRequest {
+		f = IndeterminateLength
+	}
+	return w.Response().Marshal(f)
+}
@@ -0,0 +1,215 @@
+// Package byterange serves an io.ReaderAt of known size with support for
+// range requests (RFC 9110 section 14): Range and If-Range, single range 206
+// responses, multipart/byteranges bodies for multiple ranges, and the
+// conditional request fields.
+//
+// Unlike http.ServeContent, validators are supplied explicitly and the
+// number and overlap of ranges a client may request is bounded, so that a
+// small request cannot make the server send many copies of the same bytes.
+package byterange
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// Default limits applied when the corresponding Handler field is zero.
+const (
+	DefaultMaxRanges   = 16
+	DefaultMaxOverlaps = 2
+)
+
+// Handler serves Content. Requests whose ranges exceed the limits are
+// answered with the full representation, as RFC 9110 section 14.2 permits.
+type Handler struct {
+	Content      io.ReaderAt
+	Size         int64
+	ContentType  string // defaults to application/octet-stream
+	ETag         *httpfields.EntityTag
+	LastModified time.Time
+
+	// MaxRanges bounds the number of ranges served. A zero value selects
+	// DefaultMaxRanges and a negative value disables the limit.
+	MaxRanges int
+
+	// MaxOverlaps bounds the number of ranges that overlap a range with a
+	// lower start offset. A zero value selects DefaultMaxOverlaps and a
+	// negative value disables the limit.
+	MaxOverlaps int
+
+	// Coalesce merges overlapping and adjacent ranges before the limits
+	// are checked. Merged ranges are served in ascending order.
+	Coalesce bool
+}
+
+// ServeHTTP answers GET and HEAD requests.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	header := w.Header()
+	v := httpfields.Validators{ETag: h.ETag, LastModified: h.LastModified}
+	if h.ETag != nil {
+		header.Set("ETag", h.ETag.String())
+	}
+	if !h.LastModified.IsZero() {
+		header.Set("Last-Modified", h.LastModified.UTC().Format(http.TimeFormat))
+	}
+	header.Set("Accept-Ranges", "bytes")
+	switch httpfields.EvaluatePreconditions(r.Method, r.Header, v) {
+	case http.StatusNotModified:
+		w.WriteHeader(http.StatusNotModified)
+		return
+	case http.StatusPreconditionFailed:
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	contentType := h.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var ranges []httpfields.ByteRange
+	if rh := r.Header.Get("Range"); rh != "" && r.Method == http.MethodGet && httpfields.IfRange(r.Header, v) {
+		var err error
+		ranges, err = httpfields.ParseRange(rh, h.Size)
+		switch {
+		case err == httpfields.ErrUnsatisfiableRange:
+			header.Set("Content-Range", "bytes */"+strconv.FormatInt(h.Size, 10))
+			http.Error(w, http.StatusText(http.StatusRequestedRangeNotSatisfiable), http.StatusRequestedRangeNotSatisfiable)
+			return
+		case err != nil:
+			// A malformed Range field is ignored.
+			ranges = nil
+		default:
+			ranges = h.limit(ranges)
+		}
+	}
+
+	var boundary string
+	if len(ranges) > 1 {
+		var err error
+		if boundary, err = newBoundary(); err != nil {
+			// Without a boundary the ranges cannot be framed; the full
+			// representation is still a valid answer.
+			ranges = nil
+		}
+	}
+
+	switch len(ranges) {
+	case 0:
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Length", strconv.FormatInt(h.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			io.Copy(w, io.NewSectionReader(h.Content, 0, h.Size))
+		}
+	case 1:
+		rg := ranges[0]
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Range", rg.ContentRange(h.Size))
+		header.Set("Content-Length", strconv.FormatInt(rg.Length(), 10))
+		w.WriteHeader(http.StatusPartialContent)
+		io.Copy(w, io.NewSectionReader(h.Content, rg.Start, rg.Length()))
+	default:
+		parts := make([]string, len(ranges))
+		length := int64(len("--" + boundary + "--\r\n"))
+		for i, rg := range ranges {
+			parts[i] = "--" + boundary + "\r\nContent-Type: " + contentType +
+				"\r\nContent-Range: " + rg.ContentRange(h.Size) + "\r\n\r\n"
+			length += int64(len(parts[i])) + rg.Length() + 2
+		}
+		header.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+		header.Set("Content-Length", strconv.FormatInt(length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		for i, rg := range ranges {
+			if _, err := io.WriteString(w, parts[i]); err != nil {
+				return
+			}
+			if _, err := io.Copy(w, io.NewSectionReader(h.Content, rg.Start, rg.Length())); err != nil {
+				return
+			}
+			if _, err := io.WriteString(w, "\r\n"); err != nil {
+				return
+			}
+		}
+		io.WriteString(w, "--"+boundary+"--\r\n")
+	}
+}
+
+// limit applies Coalesce and the limits to ranges, returning nil when the
+// full representation should be served instead.
+func (h *Handler) limit(ranges []httpfields.ByteRange) []httpfields.ByteRange {
+	if h.Coalesce {
+		ranges = coalesce(ranges)
+	}
+	maxRanges := h.MaxRanges
+	if maxRanges == 0 {
+		maxRanges = DefaultMaxRanges
+	}
+	if maxRanges > 0 && len(ranges) > maxRanges {
+		return nil
+	}
+	maxOverlaps := h.MaxOverlaps
+	if maxOverlaps == 0 {
+		maxOverlaps = DefaultMaxOverlaps
+	}
+	if maxOverlaps > 0 && overlaps(ranges) > maxOverlaps {
+		return nil
+	}
+	return ranges
+}
+
+// sorted returns a copy of ranges ordered by start offset.
+func sorted(ranges []httpfields.ByteRange) []httpfields.ByteRange {
+	s := append([]httpfields.ByteRange(nil), ranges...)
+	sort.Slice(s, func(i, j int) bool { return s[i].Start < s[j].Start })
+	return s
+}
+
+// overlaps counts the ranges that overlap a range starting before them.
+func overlaps(ranges []httpfields.ByteRange) int {
+	n := 0
+	end := int64(-1)
+	for _, rg := range sorted(ranges) {
+		if rg.Start <= end {
+			n++
+		}
+		if rg.End > end {
+			end = rg.End
+		}
+	}
+	return n
+}
+
+// coalesce merges overlapping and adjacent ranges.
+func coalesce(ranges []httpfields.ByteRange) []httpfields.ByteRange {
+	var out []httpfields.ByteRange
+	for _, rg := range sorted(ranges) {
+		if n := len(out); n > 0 && rg.Start <= out[n-1].End+1 {
+			if rg.End > out[n-1].End {
+				out[n-1].End = rg.End
+			}
+			continue
+		}
+		out = append(out, rg)
+	}
+	return out
+}
+
+func newBoundary() (string, error) {
+	var b [15]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,274 @@
+package byterange
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+const content = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+var modified = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func newHandler() *Handler {
+	return &Handler{
+		Content:      strings.NewReader(content),
+		Size:         int64(len(content)),
+		ContentType:  "text/plain",
+		ETag:         &httpfields.EntityTag{Opaque: "v1"},
+		LastModified: modified,
+	}
+}
+
+func serve(h http.Handler, method string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/file", nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+type part struct {
+	contentType  string
+	contentRange string
+	body         string
+}
+
+// readParts decodes a multipart/byteranges response.
+func readParts(t *testing.T, rec *httptest.ResponseRecorder) []part {
+	t.Helper()
+	mt, params, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil || mt != "multipart/byteranges" || params["boundary"] == "" {
+		t.Fatalf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+	if cl := rec.Header().Get("Content-Length"); cl != strconv.Itoa(rec.Body.Len()) {
+		t.Errorf("Content-Length = %s, body has %d bytes", cl, rec.Body.Len())
+	}
+	if !strings.HasSuffix(rec.Body.String(), "\r\n--"+params["boundary"]+"--\r\n") {
+		t.Errorf("body does not end with the close delimiter: %q", rec.Body.String())
+	}
+	mr := multipart.NewReader(strings.NewReader(rec.Body.String()), params["boundary"])
+	var parts []part
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part{p.Header.Get("Content-Type"), p.Header.Get("Content-Range"), string(b)})
+	}
+}
+
+func TestFull(t *testing.T) {
+	rec := serve(newHandler(), "GET", nil)
+	if rec.Code != 200 || rec.Body.String() != content {
+		t.Fatalf("%d %q", rec.Code, rec.Body.String())
+	}
+	want := map[string]string{
+		"Content-Type":   "text/plain",
+		"Content-Length": "36",
+		"Accept-Ranges":  "bytes",
+		"Etag":           `"v1"`,
+		"Last-Modified":  "Fri, 01 Mar 2024 12:00:00 GMT",
+	}
+	for name, v := range want {
+		if got := rec.Header().Get(name); got != v {
+			t.Errorf("%s = %q, want %q", name, got, v)
+		}
+	}
+
+	rec = serve(newHandler(), "HEAD", http.Header{"Range": {"bytes=0-1"}})
+	if rec.Code != 200 || rec.Body.Len() != 0 || rec.Header().Get("Content-Length") != "36" {
+		t.Errorf("HEAD: %d, %d bytes, %v", rec.Code, rec.Body.Len(), rec.Header())
+	}
+
+	rec = serve(newHandler(), "POST", nil)
+	if rec.Code != 405 || rec.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST: %d %v", rec.Code, rec.Header())
+	}
+
+	h := newHandler()
+	h.ContentType = ""
+	if rec := serve(h, "GET", nil); rec.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("default Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestSingleRange(t *testing.T) {
+	tests := []struct {
+		rng          string
+		contentRange string
+		body         string
+	}{
+		{"bytes=0-3", "bytes 0-3/36", "0123"},
+		{"bytes=-4", "bytes 32-35/36", "wxyz"},
+		{"bytes=30-", "bytes 30-35/36", "uvwxyz"},
+		{"bytes=34-100", "bytes 34-35/36", "yz"},
+		{"bytes=100-, 10-11", "bytes 10-11/36", "ab"},
+	}
+	for _, tt := range tests {
+		rec := serve(newHandler(), "GET", http.Header{"Range": {tt.rng}})
+		if rec.Code != 206 || rec.Body.String() != tt.body {
+			t.Errorf("%s: %d %q, want 206 %q", tt.rng, rec.Code, rec.Body.String(), tt.body)
+		}
+		if got := rec.Header().Get("Content-Range"); got != tt.contentRange {
+			t.Errorf("%s: Content-Range = %q, want %q", tt.rng, got, tt.contentRange)
+		}
+		if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(tt.body)) {
+			t.Errorf("%s: Content-Length = %s", tt.rng, got)
+		}
+	}
+}
+
+func TestUnsatisfiableAndMalformed(t *testing.T) {
+	rec := serve(newHandler(), "GET", http.Header{"Range": {"bytes=36-"}})
+	if rec.Code != 416 || rec.Header().Get("Content-Range") != "bytes */36" {
+		t.Errorf("unsatisfiable: %d %v", rec.Code, rec.Header())
+	}
+	for _, rng := range []string{"bytes=5-1", "items=0-1", "bytes=x"} {
+		if rec := serve(newHandler(), "GET", http.Header{"Range": {rng}}); rec.Code != 200 || rec.Body.String() != content {
+			t.Errorf("%s: %d, want the full representation", rng, rec.Code)
+		}
+	}
+}
+
+func TestIfRange(t *testing.T) {
+	tests := []struct {
+		ifRange string
+		code    int
+	}{
+		{`"v1"`, 206},
+		{`"v0"`, 200},
+		{`W/"v1"`, 200},
+		{"Fri, 01 Mar 2024 12:00:00 GMT", 206},
+		{"Fri, 01 Mar 2024 12:00:01 GMT", 200},
+		{"garbage", 200},
+	}
+	for _, tt := range tests {
+		rec := serve(newHandler(), "GET", http.Header{"Range": {"bytes=0-3"}, "If-Range": {tt.ifRange}})
+		if rec.Code != tt.code {
+			t.Errorf("If-Range %s: %d, want %d", tt.ifRange, rec.Code, tt.code)
+		}
+		if tt.code == 200 && rec.Body.String() != content {
+			t.Errorf("If-Range %s: body %q", tt.ifRange, rec.Body.String())
+		}
+	}
+}
+
+func TestPreconditions(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		code   int
+	}{
+		{http.Header{"If-None-Match": {`"v1"`}, "Range": {"bytes=0-3"}}, 304},
+		{http.Header{"If-Modified-Since": {"Fri, 01 Mar 2024 12:00:00 GMT"}}, 304},
+		{http.Header{"If-Match": {`"v0"`}, "Range": {"bytes=0-3"}}, 412},
+		{http.Header{"If-Unmodified-Since": {"Fri, 01 Mar 2024 11:00:00 GMT"}}, 412},
+		{http.Header{"If-Match": {`"v1"`}, "Range": {"bytes=0-3"}}, 206},
+	}
+	for _, tt := range tests {
+		rec := serve(newHandler(), "GET", tt.header)
+		if rec.Code != tt.code {
+			t.Errorf("%v: %d, want %d", tt.header, rec.Code, tt.code)
+		}
+		if tt.code != 206 && rec.Body.Len() != 0 {
+			t.Errorf("%v: body %q", tt.header, rec.Body.String())
+		}
+		if tt.code == 304 && rec.Header().Get("Etag") != `"v1"` {
+			t.Errorf("304 without ETag")
+		}
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	rec := serve(newHandler(), "GET", http.Header{"Range": {"bytes=0-1, 30-31, -2"}})
+	if rec.Code != 206 {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Range") != "" {
+		t.Errorf("Content-Range on multipart response")
+	}
+	want := []part{
+		{"text/plain", "bytes 0-1/36", "01"},
+		{"text/plain", "bytes 30-31/36", "uv"},
+		{"text/plain", "bytes 34-35/36", "yz"},
+	}
+	if got := readParts(t, rec); !reflect.DeepEqual(got, want) {
+		t.Errorf("parts = %+v, want %+v", got, want)
+	}
+
+	// Each response has its own boundary.
+	other := serve(newHandler(), "GET", http.Header{"Range": {"bytes=0-1, 30-31, -2"}})
+	if other.Header().Get("Content-Type") == rec.Header().Get("Content-Type") {
+		t.Error("boundary reused")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		h     func(h *Handler)
+		rng   string
+		parts []string // contents of the parts, nil for the full representation
+	}{
+		{"within range limit", func(h *Handler) { h.MaxRanges = 3 }, "bytes=0-0,2-2,4-4", []string{"0", "2", "4"}},
+		{"over range limit", func(h *Handler) { h.MaxRanges = 2 }, "bytes=0-0,2-2,4-4", nil},
+		{"default range limit", func(h *Handler) {}, "bytes=" + strings.Repeat("0-0,", 16) + "1-1", nil},
+		{"range limit disabled", func(h *Handler) { h.MaxRanges, h.MaxOverlaps = -1, -1 }, "bytes=" + strings.Repeat("0-0,", 20) + "1-1", make([]string, 21)},
+		{"overlaps within limit", func(h *Handler) {}, "bytes=0-9,5-14,8-9", []string{"0123456789", "56789abcde", "89"}},
+		{"overlaps over default limit", func(h *Handler) {}, "bytes=0-9,5-14,8-9,1-1", nil},
+		{"overlaps counted in any order", func(h *Handler) {}, "bytes=8-9,1-1,5-14,0-9", nil},
+		{"adjacent ranges do not overlap", func(h *Handler) { h.MaxOverlaps = 1 }, "bytes=0-4,5-9,10-14", []string{"01234", "56789", "abcde"}},
+		{"overlaps limit disabled", func(h *Handler) { h.MaxOverlaps = -1 }, "bytes=0-9,0-9,0-9,0-9", []string{"0123456789", "0123456789", "0123456789", "0123456789"}},
+		{"coalesced before limits", func(h *Handler) { h.Coalesce = true; h.MaxRanges = 2 }, "bytes=20-24,0-4,3-9,10-11", []string{"0123456789ab", "klmno"}},
+		{"coalesced to one range", func(h *Handler) { h.Coalesce = true }, "bytes=0-9,0-9,0-9,10-12", []string{"0123456789abc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHandler()
+			tt.h(h)
+			rec := serve(h, "GET", http.Header{"Range": {tt.rng}})
+			switch {
+			case tt.parts == nil:
+				if rec.Code != 200 || rec.Body.String() != content {
+					t.Errorf("%d %q, want the full representation", rec.Code, rec.Body.String())
+				}
+			case len(tt.parts) == 1:
+				if rec.Code != 206 || rec.Body.String() != tt.parts[0] {
+					t.Errorf("%d %q, want %q", rec.Code, rec.Body.String(), tt.parts[0])
+				}
+			default:
+				if rec.Code != 206 {
+					t.Fatalf("status %d", rec.Code)
+				}
+				parts := readParts(t, rec)
+				if len(parts) != len(tt.parts) {
+					t.Fatalf("%d parts, want %d", len(parts), len(tt.parts))
+				}
+				for i, p := range parts {
+					if tt.parts[i] != "" && p.body != tt.parts[i] {
+						t.Errorf("part %d = %q, want %q", i, p.body, tt.parts[i])
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package httpfields
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache directives defined by RFC 9111 section 5.2 and RFC 5861.
+const (
+	DirectiveMaxAge               = "max-age"
+	DirectiveMaxStale             = "max-stale"
+	DirectiveMinFresh             = "min-fresh"
+	DirectiveMustRevalidate       = "must-revalidate"
+	DirectiveMustUnderstand       = "must-understand"
+	DirectiveNoCache              = "no-cache"
+	DirectiveNoStore              = "no-store"
+	DirectiveNoTransform          = "no-transform"
+	DirectiveOnlyIfCached         = "only-if-cached"
+	DirectivePrivate              = "private"
+	DirectiveProxyRevalidate      = "proxy-revalidate"
+	DirectivePublic               = "public"
+	DirectiveSMaxAge              = "s-maxage"
+	DirectiveImmutable            = "immutable"
+	DirectiveStaleWhileRevalidate = "stale-while-revalidate"
+	DirectiveStaleIfError         = "stale-if-error"
+)
+
+// CacheControl holds the directives of a Cache-Control field keyed by
+// lower-cased name. Directives without an argument map to the empty string.
+type CacheControl map[string]string
+
+// ParseCacheControl parses the Cache-Control field values. Following RFC 9111
+// section 4.2.1, malformed members are skipped rather than failing the whole
+// field, and the first occurrence of a repeated directive wins.
+func ParseCacheControl(values ...string) CacheControl {
+	cc := make(CacheControl)
+	for _, v := range values {
+		for _, member := range SplitList(v) {
+			name, rest := consumeToken(member)
+			if name == "" {
+				continue
+			}
+			name = strings.ToLower(name)
+			rest = skipOWS(rest)
+			var arg string
+			if rest != "" {
+				if rest[0] != '=' {
+					continue
+				}
+				var err error
+				arg, rest, err = consumeValue(skipOWS(rest[1:]))
+				if err != nil || skipOWS(rest) != "" {
+					continue
+				}
+			}
+			if _, ok := cc[name]; !ok {
+				cc[name] = arg
+			}
+		}
+	}
+	return cc
+}
+
+// Has reports whether the directive is present.
+func (cc CacheControl) Has(directive string) bool {
+	_, ok := cc[directive]
+	return ok
+}
+
+// Seconds returns the delta-seconds argument of the directive. It reports
+// false if the directive is absent or its argument is not a non-negative
+// integer. Values too large to represent are capped.
+func (cc CacheControl) Seconds(directive string) (time.Duration, bool) {
+	v, ok := cc[directive]
+	if !ok || v == "" {
+		return 0, false
+	}
+	for i := 0; i < len(v); i++ {
+		if v[i] < '0' || v[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n > int64(maxDuration/time.Second) {
+		// RFC 9111 section 1.2.2 requires treating overflows as the
+		// largest representable value.
+		return maxDuration, true
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+const maxDuration = time.Duration(1<<63 - 1)
+
+// String formats the directives in name order.
+func (cc CacheControl) String() string {
+	names := make([]string, 0, len(cc))
+	for name := range cc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(name)
+		if v := cc[name]; v != "" {
+			b.WriteByte('=')
+			b.WriteString(Quote(v))
+		}
+	}
+	return b.String()
+}
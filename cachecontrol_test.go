@@ -0,0 +1,72 @@
+package httpfields
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   CacheControl
+	}{
+		{[]string{"max-age=60"}, CacheControl{"max-age": "60"}},
+		{[]string{"Max-Age=60, PUBLIC"}, CacheControl{"max-age": "60", "public": ""}},
+		{[]string{"no-cache", "max-age=5"}, CacheControl{"no-cache": "", "max-age": "5"}},
+		{[]string{`private="Set-Cookie, Authorization"`}, CacheControl{"private": "Set-Cookie, Authorization"}},
+		{[]string{`no-cache="a\"b"`}, CacheControl{"no-cache": `a"b`}},
+		{[]string{"max-age = 10"}, CacheControl{"max-age": "10"}},
+		// The first occurrence wins, across field lines too.
+		{[]string{"max-age=1, max-age=2"}, CacheControl{"max-age": "1"}},
+		{[]string{"max-age=1", "max-age=2"}, CacheControl{"max-age": "1"}},
+		// Malformed members are skipped.
+		{[]string{"max-age=, public"}, CacheControl{"public": ""}},
+		{[]string{`max-age="1" x, no-store`}, CacheControl{"no-store": ""}},
+		{[]string{"max-age:1, no-store"}, CacheControl{"no-store": ""}},
+		{[]string{`no-cache="unterminated, no-store`}, CacheControl{}},
+		{[]string{", ,,"}, CacheControl{}},
+		{nil, CacheControl{}},
+	}
+	for _, tt := range tests {
+		if got := ParseCacheControl(tt.values...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCacheControl(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestCacheControlSeconds(t *testing.T) {
+	cc := ParseCacheControl(`max-age=60, s-maxage="30", min-fresh=-1, max-stale, stale-if-error=1.5, stale-while-revalidate=99999999999999999999`)
+	tests := []struct {
+		directive string
+		want      time.Duration
+		ok        bool
+	}{
+		{DirectiveMaxAge, time.Minute, true},
+		{DirectiveSMaxAge, 30 * time.Second, true},
+		{DirectiveMinFresh, 0, false},
+		{DirectiveMaxStale, 0, false},
+		{DirectiveStaleIfError, 0, false},
+		{DirectiveStaleWhileRevalidate, maxDuration, true},
+		{DirectiveNoStore, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := cc.Seconds(tt.directive)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Seconds(%q) = %v, %v; want %v, %v", tt.directive, got, ok, tt.want, tt.ok)
+		}
+	}
+	if !cc.Has(DirectiveMaxStale) || cc.Has(DirectiveNoStore) {
+		t.Errorf("Has: max-stale %v, no-store %v", cc.Has(DirectiveMaxStale), cc.Has(DirectiveNoStore))
+	}
+}
+
+func TestCacheControlString(t *testing.T) {
+	cc := CacheControl{"public": "", "max-age": "60", "no-cache": "Set-Cookie, Date"}
+	if got, want := cc.String(), `max-age=60, no-cache="Set-Cookie, Date", public`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := ParseCacheControl(cc.String()); !reflect.DeepEqual(got, cc) {
+		t.Errorf("round trip = %v, want %v", got, cc)
+	}
+}
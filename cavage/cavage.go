@@ -0,0 +1,228 @@
+// Package cavage parses and verifies HTTP message signatures in the format
+// of draft-cavage-http-signatures, the predecessor of RFC 9421 that
+// ActivityPub servers still use.
+//
+// A signature is sent in the Signature field or as the credentials of an
+// Authorization field with the Signature scheme:
+//
+//	Signature: keyId="https://example.com/actor#main-key",
+//		algorithm="rsa-sha256",headers="(request-target) host date digest",
+//		signature="Base64(...)"
+//
+// Verifier checks rsa-sha256, hmac-sha256 and hs2019 signatures, resolving
+// the key through a callback.
+package cavage
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm names.
+const (
+	AlgorithmRSASHA256  = "rsa-sha256"
+	AlgorithmHMACSHA256 = "hmac-sha256"
+	AlgorithmHS2019     = "hs2019" // derived from the key
+)
+
+// Pseudo-headers that may appear in the headers parameter.
+const (
+	RequestTarget = "(request-target)"
+	Created       = "(created)"
+	Expires       = "(expires)"
+)
+
+var (
+	// ErrMissingSignature is returned for requests with neither a
+	// Signature field nor an Authorization field of the Signature scheme.
+	ErrMissingSignature = errors.New("cavage: missing signature")
+	// ErrMalformedSignature is returned for signature parameters that
+	// cannot be parsed or lack keyId or signature.
+	ErrMalformedSignature = errors.New("cavage: malformed signature")
+	// ErrMissingHeader is returned when a signed field is not present in
+	// the request.
+	ErrMissingHeader = errors.New("cavage: signed field missing from request")
+)
+
+// Signature holds the parameters of a signature.
+type Signature struct {
+	KeyID     string
+	Algorithm string   // lowercase; empty if not given
+	Headers   []string // lowercase field names and pseudo-headers
+	Signature []byte
+	Created   time.Time // zero if not given
+	Expires   time.Time // zero if not given
+}
+
+// ParseSignature parses a Signature field value. A missing headers
+// parameter defaults to "date", as most implementations expect.
+func ParseSignature(s string) (Signature, error) {
+	var sig Signature
+	params, err := parseParams(s)
+	if err != nil {
+		return sig, err
+	}
+	sig.KeyID = params["keyid"]
+	sig.Algorithm = strings.ToLower(params["algorithm"])
+	if v, ok := params["headers"]; ok {
+		sig.Headers = strings.Fields(strings.ToLower(v))
+	} else {
+		sig.Headers = []string{"date"}
+	}
+	if sig.Signature, err = base64.StdEncoding.DecodeString(params["signature"]); err != nil {
+		return sig, ErrMalformedSignature
+	}
+	if v, ok := params["created"]; ok {
+		if sig.Created, err = parseTimestamp(v); err != nil {
+			return sig, err
+		}
+	}
+	if v, ok := params["expires"]; ok {
+		if sig.Expires, err = parseTimestamp(v); err != nil {
+			return sig, err
+		}
+	}
+	if sig.KeyID == "" || len(sig.Signature) == 0 || len(sig.Headers) == 0 {
+		return sig, ErrMalformedSignature
+	}
+	return sig, nil
+}
+
+// ParseAuthorization parses an Authorization field value of the Signature
+// scheme.
+func ParseAuthorization(s string) (Signature, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexByte(s, ' ')
+	if i < 0 || !strings.EqualFold(s[:i], "Signature") {
+		return Signature{}, ErrMissingSignature
+	}
+	return ParseSignature(s[i+1:])
+}
+
+// FromRequest parses the signature of r from its Signature field or, if
+// absent, its Authorization field.
+func FromRequest(r *http.Request) (Signature, error) {
+	if v := r.Header.Get("Signature"); v != "" {
+		return ParseSignature(v)
+	}
+	if v := r.Header.Get("Authorization"); v != "" {
+		return ParseAuthorization(v)
+	}
+	return Signature{}, ErrMissingSignature
+}
+
+// String formats the signature parameters as a Signature field value.
+// Prefix it with "Signature " for an Authorization field.
+func (sig Signature) String() string {
+	var b strings.Builder
+	b.WriteString(`keyId="` + sig.KeyID + `"`)
+	if sig.Algorithm != "" {
+		b.WriteString(`,algorithm="` + sig.Algorithm + `"`)
+	}
+	if !sig.Created.IsZero() {
+		b.WriteString(",created=" + strconv.FormatInt(sig.Created.Unix(), 10))
+	}
+	if !sig.Expires.IsZero() {
+		b.WriteString(",expires=" + strconv.FormatInt(sig.Expires.Unix(), 10))
+	}
+	b.WriteString(`,headers="` + strings.Join(sig.Headers, " ") + `"`)
+	b.WriteString(`,signature="` + base64.StdEncoding.EncodeToString(sig.Signature) + `"`)
+	return b.String()
+}
+
+// SigningString returns the string covered by sig for request r: one
+// "name: value" line per entry of sig.Headers. Repeated fields are joined
+// with ", ".
+func SigningString(r *http.Request, sig Signature) (string, error) {
+	lines := make([]string, len(sig.Headers))
+	for i, name := range sig.Headers {
+		var value string
+		switch name {
+		case RequestTarget:
+			value = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case Created:
+			if sig.Created.IsZero() {
+				return "", ErrMissingHeader
+			}
+			value = strconv.FormatInt(sig.Created.Unix(), 10)
+		case Expires:
+			if sig.Expires.IsZero() {
+				return "", ErrMissingHeader
+			}
+			value = strconv.FormatInt(sig.Expires.Unix(), 10)
+		case "host":
+			value = r.Host
+			if value == "" {
+				value = r.URL.Host
+			}
+		default:
+			values := r.Header[http.CanonicalHeaderKey(name)]
+			if len(values) == 0 {
+				return "", ErrMissingHeader
+			}
+			trimmed := make([]string, len(values))
+			for j, v := range values {
+				trimmed[j] = strings.TrimSpace(v)
+			}
+			value = strings.Join(trimmed, ", ")
+		}
+		lines[i] = name + ": " + value
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// parseParams parses comma-separated name=value pairs. Values are quoted
+// strings or bare numbers; names are returned lowercase.
+func parseParams(s string) (map[string]string, error) {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t")
+		i := strings.IndexByte(s, '=')
+		if i <= 0 {
+			return nil, ErrMalformedSignature
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = strings.TrimLeft(s[i+1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, ErrMalformedSignature
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		if _, dup := params[name]; dup {
+			return nil, ErrMalformedSignature
+		}
+		params[name] = value
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return params, nil
+		}
+		if s[0] != ',' {
+			return nil, ErrMalformedSignature
+		}
+		s = s[1:]
+	}
+}
+
+// parseTimestamp parses a Unix time, which may have a fractional part.
+func parseTimestamp(s string) (time.Time, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 || math.IsInf(f, 0) || math.IsNaN(f) {
+		return time.Time{}, ErrMalformedSignature
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)), nil
+}
@@ -0,0 +1,263 @@
+package cavage
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// DefaultTolerance is the clock skew allowed when Verifier.Tolerance is
+// zero.
+const DefaultTolerance = time.Hour
+
+// DefaultRequiredHeaders are the entries that must be signed when
+// Verifier.RequiredHeaders is nil.
+var DefaultRequiredHeaders = []string{RequestTarget, "host"}
+
+var (
+	// ErrUnsupportedAlgorithm is returned for algorithms other than
+	// rsa-sha256, hmac-sha256 and hs2019, and for keys of a type the
+	// algorithm cannot use.
+	ErrUnsupportedAlgorithm = errors.New("cavage: unsupported algorithm or key type")
+	// ErrSignatureMismatch is returned when the signature does not match
+	// the request.
+	ErrSignatureMismatch = errors.New("cavage: signature mismatch")
+	// ErrRequiredHeader is returned when a required entry is not signed,
+	// or when neither date nor (created) is.
+	ErrRequiredHeader = errors.New("cavage: required field not signed")
+	// ErrExpired is returned when the signature's Date or created time is
+	// outside the tolerance window or its expires time has passed.
+	ErrExpired = errors.New("cavage: signature expired")
+)
+
+// Verifier checks request signatures.
+type Verifier struct {
+	// Key returns the key of a keyId: an *rsa.PublicKey, an
+	// *ecdsa.PublicKey (hs2019 only) or an HMAC secret as []byte. Its
+	// errors are returned by Verify unchanged.
+	Key func(keyID string) (crypto.PublicKey, error)
+
+	// RequiredHeaders lists the entries that must be signed in addition to
+	// date or (created). Nil selects DefaultRequiredHeaders.
+	RequiredHeaders []string
+
+	// Tolerance is the allowed difference between the Date field or
+	// created parameter and the current time. Zero selects
+	// DefaultTolerance; a negative value disables the checks.
+	Tolerance time.Duration
+
+	// Now returns the current time. Nil selects time.Now.
+	Now func() time.Time
+}
+
+// Verify checks the signature of r and returns it. The body is not covered
+// unless a Digest field is signed; checking it against the body is left to
+// the caller.
+func (v *Verifier) Verify(r *http.Request) (Signature, error) {
+	sig, err := FromRequest(r)
+	if err != nil {
+		return sig, err
+	}
+	required := v.RequiredHeaders
+	if required == nil {
+		required = DefaultRequiredHeaders
+	}
+	for _, name := range required {
+		if !contains(sig.Headers, name) {
+			return sig, ErrRequiredHeader
+		}
+	}
+	if !contains(sig.Headers, "date") && !contains(sig.Headers, Created) {
+		return sig, ErrRequiredHeader
+	}
+	if err := v.checkTime(r, sig); err != nil {
+		return sig, err
+	}
+	key, err := v.Key(sig.KeyID)
+	if err != nil {
+		return sig, err
+	}
+	s, err := SigningString(r, sig)
+	if err != nil {
+		return sig, err
+	}
+	return sig, verify(sig.Algorithm, key, []byte(s), sig.Signature)
+}
+
+func (v *Verifier) checkTime(r *http.Request, sig Signature) error {
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	if tolerance < 0 {
+		return nil
+	}
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	within := func(t time.Time) bool {
+		d := now.Sub(t)
+		return d <= tolerance && d >= -tolerance
+	}
+	if contains(sig.Headers, "date") {
+		t, err := http.ParseTime(r.Header.Get("Date"))
+		if err != nil || !within(t) {
+			return ErrExpired
+		}
+	}
+	if !sig.Created.IsZero() && !within(sig.Created) {
+		return ErrExpired
+	}
+	if !sig.Expires.IsZero() && now.After(sig.Expires) {
+		return ErrExpired
+	}
+	return nil
+}
+
+// verify checks sig over data. For hs2019 the scheme follows from the
+// key: RSASSA-PSS with SHA-512, falling back to PKCS #1 v1.5 with SHA-256
+// as sent by most ActivityPub servers, ECDSA with SHA-512 or HMAC-SHA512.
+func verify(algorithm string, key crypto.PublicKey, data, sig []byte) error {
+	switch algorithm {
+	case AlgorithmRSASHA256:
+		if k, ok := key.(*rsa.PublicKey); ok {
+			return verifyPKCS1(k, data, sig)
+		}
+	case AlgorithmHMACSHA256:
+		if k, ok := key.([]byte); ok {
+			return verifyHMAC(sha256.New, k, data, sig)
+		}
+	case AlgorithmHS2019, "":
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			digest := sha512.Sum512(data)
+			if rsa.VerifyPSS(k, crypto.SHA512, digest[:], sig, nil) == nil {
+				return nil
+			}
+			return verifyPKCS1(k, data, sig)
+		case *ecdsa.PublicKey:
+			var rs struct{ R, S *big.Int }
+			if rest, err := asn1.Unmarshal(sig, &rs); err != nil || len(rest) > 0 {
+				return ErrSignatureMismatch
+			}
+			digest := sha512.Sum512(data)
+			if !ecdsa.Verify(k, digest[:], rs.R, rs.S) {
+				return ErrSignatureMismatch
+			}
+			return nil
+		case []byte:
+			return verifyHMAC(sha512.New, k, data, sig)
+		}
+	}
+	return ErrUnsupportedAlgorithm
+}
+
+func verifyPKCS1(k *rsa.PublicKey, data, sig []byte) error {
+	digest := sha256.Sum256(data)
+	if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) != nil {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+func verifyHMAC(h func() hash.Hash, key, data, sig []byte) error {
+	mac := hmac.New(h, key)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// Signer signs outgoing requests.
+type Signer struct {
+	KeyID string
+	// Algorithm is AlgorithmRSASHA256 with an *rsa.PrivateKey or
+	// AlgorithmHMACSHA256 with a []byte secret.
+	Algorithm string
+	Key       crypto.PrivateKey
+	// Headers lists the entries to sign. Nil selects (request-target),
+	// host and date. Fields that are missing from the request are skipped.
+	Headers []string
+	// Authorization sends the signature in an Authorization field instead
+	// of a Signature field.
+	Authorization bool
+	// Now returns the signing time. Nil selects time.Now.
+	Now func() time.Time
+}
+
+// Sign signs r, setting its Date field if missing and date is signed.
+func (s *Signer) Sign(r *http.Request) error {
+	headers := s.Headers
+	if headers == nil {
+		headers = []string{RequestTarget, "host", "date"}
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	sig := Signature{KeyID: s.KeyID, Algorithm: s.Algorithm}
+	for _, name := range headers {
+		switch name {
+		case "date":
+			if r.Header.Get("Date") == "" {
+				r.Header.Set("Date", now().UTC().Format(http.TimeFormat))
+			}
+		case RequestTarget, "host":
+		default:
+			if len(r.Header[http.CanonicalHeaderKey(name)]) == 0 {
+				continue
+			}
+		}
+		sig.Headers = append(sig.Headers, name)
+	}
+	data, err := SigningString(r, sig)
+	if err != nil {
+		return err
+	}
+	switch k := s.Key.(type) {
+	case *rsa.PrivateKey:
+		if s.Algorithm != AlgorithmRSASHA256 {
+			return ErrUnsupportedAlgorithm
+		}
+		digest := sha256.Sum256([]byte(data))
+		if sig.Signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			return err
+		}
+	case []byte:
+		if s.Algorithm != AlgorithmHMACSHA256 {
+			return ErrUnsupportedAlgorithm
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(data))
+		sig.Signature = mac.Sum(nil)
+	default:
+		return ErrUnsupportedAlgorithm
+	}
+	if s.Authorization {
+		r.Header.Set("Authorization", "Signature "+sig.String())
+	} else {
+		r.Header.Set("Signature", sig.String())
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
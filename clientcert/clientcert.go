@@ -0,0 +1,224 @@
+// Package clientcert conveys TLS client certificates from a TLS terminating
+// reverse proxy to a backend using the Client-Cert and Client-Cert-Chain
+// fields of RFC 9440.
+//
+// At the terminator, Forward replaces any incoming values of the fields with
+// the certificates of the TLS connection. At the backend, an Extractor
+// accepts the fields only from trusted proxies and strips them otherwise.
+package clientcert
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/palsivertsen/gohttpfields/sfv"
+)
+
+// Field names defined by RFC 9440.
+const (
+	HeaderClientCert      = "Client-Cert"
+	HeaderClientCertChain = "Client-Cert-Chain"
+)
+
+var (
+	// ErrNoCertificate is returned when a request carries no Client-Cert
+	// field.
+	ErrNoCertificate = errors.New("clientcert: no client certificate")
+	// ErrMalformed is returned for field values that are not byte
+	// sequences as required by RFC 9440.
+	ErrMalformed = errors.New("clientcert: malformed field value")
+)
+
+// Strip removes the Client-Cert and Client-Cert-Chain fields from h.
+func Strip(h http.Header) {
+	h.Del(HeaderClientCert)
+	h.Del(HeaderClientCertChain)
+}
+
+// SetHeader replaces the fields in h with the given certificates: certs[0]
+// is the end-entity certificate and the remaining ones its chain, in the
+// order sent by the client. With no certificates the fields are removed.
+func SetHeader(h http.Header, certs []*x509.Certificate) error {
+	Strip(h)
+	if len(certs) == 0 {
+		return nil
+	}
+	leaf, err := sfv.MarshalItem(sfv.Item{Value: certs[0].Raw})
+	if err != nil {
+		return err
+	}
+	h.Set(HeaderClientCert, leaf)
+	if len(certs) == 1 {
+		return nil
+	}
+	chain := make(sfv.List, len(certs)-1)
+	for i, c := range certs[1:] {
+		chain[i] = sfv.Item{Value: c.Raw}
+	}
+	v, err := sfv.MarshalList(chain)
+	if err != nil {
+		return err
+	}
+	h.Set(HeaderClientCertChain, v)
+	return nil
+}
+
+// Forward is middleware for a TLS terminating proxy. It strips client
+// supplied Client-Cert fields from every request and, for requests received
+// over TLS with a client certificate, sets them from the connection state.
+func Forward(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var certs []*x509.Certificate
+		if r.TLS != nil {
+			certs = r.TLS.PeerCertificates
+		}
+		if err := SetHeader(r.Header, certs); err != nil {
+			http.Error(w, "invalid client certificate", http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Parse decodes the fields in h. It returns ErrNoCertificate if there is no
+// Client-Cert field. A Client-Cert-Chain without Client-Cert is ignored.
+func Parse(h http.Header) (leaf *x509.Certificate, chain []*x509.Certificate, err error) {
+	values := h[http.CanonicalHeaderKey(HeaderClientCert)]
+	if len(values) == 0 {
+		return nil, nil, ErrNoCertificate
+	}
+	if len(values) > 1 {
+		return nil, nil, ErrMalformed
+	}
+	it, err := sfv.ParseItem(values[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	der, ok := it.Value.([]byte)
+	if !ok {
+		return nil, nil, ErrMalformed
+	}
+	if leaf, err = x509.ParseCertificate(der); err != nil {
+		return nil, nil, err
+	}
+	if v := h[http.CanonicalHeaderKey(HeaderClientCertChain)]; len(v) > 0 {
+		l, err := sfv.ParseList(strings.Join(v, ","))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, m := range l {
+			it, ok := m.(sfv.Item)
+			if !ok {
+				return nil, nil, ErrMalformed
+			}
+			der, ok := it.Value.([]byte)
+			if !ok {
+				return nil, nil, ErrMalformed
+			}
+			c, err := x509.ParseCertificate(der)
+			if err != nil {
+				return nil, nil, err
+			}
+			chain = append(chain, c)
+		}
+	}
+	return leaf, chain, nil
+}
+
+// Extractor reads client certificates at a backend behind trusted proxies.
+type Extractor struct {
+	// TrustedProxies lists the networks of the proxies allowed to send
+	// the fields. Requests from other peers have the fields stripped.
+	TrustedProxies []*net.IPNet
+}
+
+// NewExtractor returns an Extractor trusting the given CIDR blocks or
+// addresses.
+func NewExtractor(trusted ...string) (*Extractor, error) {
+	e := &Extractor{}
+	for _, s := range trusted {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, errors.New("clientcert: invalid address " + s)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			e.TrustedProxies = append(e.TrustedProxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		e.TrustedProxies = append(e.TrustedProxies, n)
+	}
+	return e, nil
+}
+
+// Trusted reports whether the request was received directly from a trusted
+// proxy.
+func (e *Extractor) Trusted(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range e.TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Certificates returns the client certificates conveyed by a trusted proxy.
+// Requests from untrusted peers yield ErrNoCertificate.
+func (e *Extractor) Certificates(r *http.Request) (leaf *x509.Certificate, chain []*x509.Certificate, err error) {
+	if !e.Trusted(r) {
+		return nil, nil, ErrNoCertificate
+	}
+	return Parse(r.Header)
+}
+
+// Middleware strips the fields from requests of untrusted peers and makes the
+// certificates of trusted ones available through FromContext. Requests with
+// malformed fields are rejected with 400 Bad Request.
+func (e *Extractor) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !e.Trusted(r) {
+			Strip(r.Header)
+			next.ServeHTTP(w, r)
+			return
+		}
+		leaf, chain, err := Parse(r.Header)
+		switch err {
+		case nil:
+			ctx := context.WithValue(r.Context(), contextKey{}, append([]*x509.Certificate{leaf}, chain...))
+			r = r.WithContext(ctx)
+		case ErrNoCertificate:
+		default:
+			http.Error(w, "invalid client certificate", http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type contextKey struct{}
+
+// FromContext returns the certificates stored by Extractor.Middleware, with
+// the end-entity certificate first, or nil if there are none.
+func FromContext(ctx context.Context) []*x509.Certificate {
+	certs, _ := ctx.Value(contextKey{}).([]*x509.Certificate)
+	return certs
+}
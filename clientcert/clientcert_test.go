@@ -0,0 +1,214 @@
+package clientcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newCertificate(t *testing.T, name string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRoundTrip(t *testing.T) {
+	leaf := newCertificate(t, "leaf")
+	inter := newCertificate(t, "intermediate")
+	root := newCertificate(t, "root")
+
+	h := http.Header{HeaderClientCert: {":c3Bvb2ZlZA==:"}}
+	if err := SetHeader(h, []*x509.Certificate{leaf, inter, root}); err != nil {
+		t.Fatal(err)
+	}
+	if want := ":" + base64.StdEncoding.EncodeToString(leaf.Raw) + ":"; h.Get(HeaderClientCert) != want {
+		t.Errorf("Client-Cert = %q, want %q", h.Get(HeaderClientCert), want)
+	}
+	got, chain, err := Parse(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(leaf) || len(chain) != 2 || !chain[0].Equal(inter) || !chain[1].Equal(root) {
+		t.Errorf("Parse = %v, %v", got.Subject, chain)
+	}
+
+	// The chain may be split across field lines.
+	split := http.Header{
+		HeaderClientCert:      h[HeaderClientCert],
+		HeaderClientCertChain: {":" + base64.StdEncoding.EncodeToString(inter.Raw) + ":", ":" + base64.StdEncoding.EncodeToString(root.Raw) + ":"},
+	}
+	if _, chain, err := Parse(split); err != nil || len(chain) != 2 {
+		t.Errorf("split chain: %d certificates, %v", len(chain), err)
+	}
+
+	if err := SetHeader(h, []*x509.Certificate{leaf}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := h[HeaderClientCertChain]; ok {
+		t.Error("Client-Cert-Chain kept for a single certificate")
+	}
+	if err := SetHeader(h, nil); err != nil || len(h) != 0 {
+		t.Errorf("SetHeader(nil) left %v, %v", h, err)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	leaf := ":" + base64.StdEncoding.EncodeToString(newCertificate(t, "leaf").Raw) + ":"
+	tests := []struct {
+		name   string
+		header http.Header
+		err    error // nil means any error
+	}{
+		{"missing", http.Header{}, ErrNoCertificate},
+		{"chain without leaf", http.Header{HeaderClientCertChain: {leaf}}, ErrNoCertificate},
+		{"repeated", http.Header{HeaderClientCert: {leaf, leaf}}, ErrMalformed},
+		{"string", http.Header{HeaderClientCert: {`"MIIB"`}}, ErrMalformed},
+		{"token", http.Header{HeaderClientCert: {"MIIB"}}, ErrMalformed},
+		{"PEM", http.Header{HeaderClientCert: {"-----BEGIN CERTIFICATE-----"}}, nil},
+		{"invalid base64", http.Header{HeaderClientCert: {":not base64!:"}}, nil},
+		{"unterminated", http.Header{HeaderClientCert: {":AAAA"}}, nil},
+		{"not DER", http.Header{HeaderClientCert: {":AAAA:"}}, nil},
+		{"chain member not bytes", http.Header{HeaderClientCert: {leaf}, HeaderClientCertChain: {leaf + ", abc"}}, ErrMalformed},
+		{"chain inner list", http.Header{HeaderClientCert: {leaf}, HeaderClientCertChain: {"(" + leaf + ")"}}, ErrMalformed},
+		{"chain not DER", http.Header{HeaderClientCert: {leaf}, HeaderClientCertChain: {":AAAA:"}}, nil},
+		{"chain syntax", http.Header{HeaderClientCert: {leaf}, HeaderClientCertChain: {leaf + ";"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, chain, err := Parse(tt.header)
+			if err == nil || tt.err != nil && err != tt.err {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if got != nil || chain != nil {
+				t.Errorf("certificates returned with error: %v, %v", got, chain)
+			}
+		})
+	}
+}
+
+func TestNewExtractor(t *testing.T) {
+	e, err := NewExtractor("10.0.0.0/8", "192.0.2.1", "2001:db8::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"10.1.2.3:1234":     true,
+		"192.0.2.1:443":     true,
+		"192.0.2.2:443":     false,
+		"[2001:db8::1]:443": true,
+		"[2001:db8::2]:443": false,
+		"192.0.2.1":         true,
+		"unix":              false,
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = addr
+		if got := e.Trusted(r); got != want {
+			t.Errorf("Trusted(%s) = %v", addr, got)
+		}
+	}
+	for _, s := range []string{"10.0.0.0/33", "not-an-ip"} {
+		if _, err := NewExtractor(s); err == nil {
+			t.Errorf("NewExtractor(%q) succeeded", s)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	leaf := newCertificate(t, "leaf")
+	e, err := NewExtractor("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var certs []*x509.Certificate
+	var header http.Header
+	handler := e.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		certs = FromContext(r.Context())
+		header = r.Header
+	}))
+
+	tests := []struct {
+		name   string
+		remote string
+		value  string
+		status int
+		certs  int
+		kept   bool
+	}{
+		{"trusted", "192.0.2.1:1", ":" + base64.StdEncoding.EncodeToString(leaf.Raw) + ":", 200, 1, true},
+		{"trusted without field", "192.0.2.1:1", "", 200, 0, false},
+		{"trusted malformed", "192.0.2.1:1", `"x"`, 400, 0, false},
+		{"untrusted", "198.51.100.1:1", ":" + base64.StdEncoding.EncodeToString(leaf.Raw) + ":", 200, 0, false},
+		{"untrusted malformed", "198.51.100.1:1", `"x"`, 200, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			certs, header = nil, nil
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.value != "" {
+				r.Header.Set(HeaderClientCert, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d", rec.Code, tt.status)
+			}
+			if len(certs) != tt.certs {
+				t.Errorf("%d certificates in context, want %d", len(certs), tt.certs)
+			}
+			if header != nil && (header.Get(HeaderClientCert) != "") != tt.kept {
+				t.Errorf("field kept = %v, want %v", !tt.kept, tt.kept)
+			}
+		})
+	}
+}
+
+func TestForward(t *testing.T) {
+	leaf := newCertificate(t, "leaf")
+	var got http.Header
+	handler := Forward(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(HeaderClientCert, ":c3Bvb2ZlZA==:")
+	r.Header.Set(HeaderClientCertChain, ":c3Bvb2ZlZA==:")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if len(got[HeaderClientCert]) != 0 || len(got[HeaderClientCertChain]) != 0 {
+		t.Errorf("spoofed fields forwarded: %v", got)
+	}
+
+	r = httptest.NewRequest("GET", "https://example.com/", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}
+	r.Header.Set(HeaderClientCert, ":c3Bvb2ZlZA==:")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if c, _, err := Parse(got); err != nil || !c.Equal(leaf) {
+		t.Errorf("forwarded certificate: %v", err)
+	}
+}
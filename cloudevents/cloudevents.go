@@ -0,0 +1,290 @@
+// Package cloudevents implements the binary content mode of the CloudEvents
+// HTTP protocol binding (version 1.0): context attributes travel in "ce-"
+// prefixed fields, the datacontenttype attribute in Content-Type, and the
+// event data in the message body.
+package cloudevents
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+	"github.com/palsivertsen/gohttpfields/internal/ascii"
+)
+
+// SpecVersion is the CloudEvents specification version this package
+// implements.
+const SpecVersion = "1.0"
+
+// HeaderPrefix starts the field names of context attributes.
+const HeaderPrefix = "Ce-"
+
+// contentTypeStructured starts the media types of structured mode events.
+const contentTypeStructured = "application/cloudevents"
+
+var (
+	// ErrMissingAttribute is returned when a required attribute (id,
+	// source, specversion or type) is absent or empty.
+	ErrMissingAttribute = errors.New("cloudevents: missing required attribute")
+	// ErrSpecVersion is returned for events of other specification
+	// versions.
+	ErrSpecVersion = errors.New("cloudevents: unsupported specversion")
+	// ErrInvalidAttribute is returned for attribute names with characters
+	// other than lower-case letters and digits, for malformed
+	// percent-encoding or UTF-8, and for malformed time values.
+	ErrInvalidAttribute = errors.New("cloudevents: invalid attribute")
+	// ErrStructuredMode is returned when a message carries a structured
+	// mode event, which this package does not decode.
+	ErrStructuredMode = errors.New("cloudevents: structured content mode")
+	// ErrNotEvent is returned for messages without ce- fields.
+	ErrNotEvent = errors.New("cloudevents: message is not a binary mode event")
+)
+
+// Event is a CloudEvent with its context attributes and data.
+type Event struct {
+	ID              string
+	Source          string // URI-reference
+	SpecVersion     string
+	Type            string
+	DataContentType string
+	DataSchema      string // URI
+	Subject         string
+	Time            time.Time         // zero when absent
+	Extensions      map[string]string // extension attributes by name
+	Data            []byte
+}
+
+// Validate checks that the required attributes are set and that all
+// attribute names are valid.
+func (e *Event) Validate() error {
+	if e.ID == "" || e.Source == "" || e.SpecVersion == "" || e.Type == "" {
+		return ErrMissingAttribute
+	}
+	if e.SpecVersion != SpecVersion {
+		return ErrSpecVersion
+	}
+	for name := range e.Extensions {
+		if !ValidName(name) || isContextAttribute(name) {
+			return ErrInvalidAttribute
+		}
+	}
+	return nil
+}
+
+// ValidName reports whether name is a valid attribute name: lower-case
+// ASCII letters and digits.
+func ValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isContextAttribute(name string) bool {
+	switch name {
+	case "id", "source", "specversion", "type", "datacontenttype",
+		"dataschema", "subject", "time", "data":
+		return true
+	}
+	return false
+}
+
+// IsBinaryMode reports whether h describes a binary mode event.
+func IsBinaryMode(h http.Header) bool {
+	return h.Get(HeaderPrefix+"Specversion") != ""
+}
+
+// FromHeader decodes the context attributes of a binary mode event from h.
+// Repeated fields count with their first value. The event is validated.
+func FromHeader(h http.Header) (*Event, error) {
+	ct := h.Get("Content-Type")
+	if mt, err := httpfields.ParseMediaType(ct); err == nil && strings.HasPrefix(mt.Essence(), contentTypeStructured) {
+		return nil, ErrStructuredMode
+	}
+	if !IsBinaryMode(h) {
+		return nil, ErrNotEvent
+	}
+	e := &Event{DataContentType: ct}
+	for name, values := range h {
+		if len(values) == 0 || !ascii.HasPrefixFold(name, HeaderPrefix) {
+			continue
+		}
+		attr := strings.ToLower(name[len(HeaderPrefix):])
+		if !ValidName(attr) {
+			return nil, ErrInvalidAttribute
+		}
+		value, err := DecodeValue(values[0])
+		if err != nil {
+			return nil, err
+		}
+		switch attr {
+		case "id":
+			e.ID = value
+		case "source":
+			e.Source = value
+		case "specversion":
+			e.SpecVersion = value
+		case "type":
+			e.Type = value
+		case "dataschema":
+			e.DataSchema = value
+		case "subject":
+			e.Subject = value
+		case "time":
+			if e.Time, err = time.Parse(time.RFC3339Nano, value); err != nil {
+				return nil, ErrInvalidAttribute
+			}
+		case "datacontenttype", "data":
+			// Carried by Content-Type and the body in binary mode.
+		default:
+			if e.Extensions == nil {
+				e.Extensions = make(map[string]string)
+			}
+			e.Extensions[attr] = value
+		}
+	}
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ReadRequest decodes the binary mode event carried by req, reading and
+// closing its body.
+func ReadRequest(req *http.Request) (*Event, error) {
+	e, err := FromHeader(req.Header)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		defer req.Body.Close()
+		if e.Data, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// SetHeader validates e and replaces the event fields in h with its
+// context attributes. Content-Type is set from DataContentType, or removed
+// if it is empty.
+func (e *Event) SetHeader(h http.Header) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	for name := range h {
+		if ascii.HasPrefixFold(name, HeaderPrefix) {
+			delete(h, name)
+		}
+	}
+	set := func(attr, value string) {
+		if value != "" {
+			h[http.CanonicalHeaderKey(HeaderPrefix+attr)] = []string{EncodeValue(value)}
+		}
+	}
+	set("id", e.ID)
+	set("source", e.Source)
+	set("specversion", e.SpecVersion)
+	set("type", e.Type)
+	set("dataschema", e.DataSchema)
+	set("subject", e.Subject)
+	if !e.Time.IsZero() {
+		set("time", e.Time.Format(time.RFC3339Nano))
+	}
+	names := make([]string, 0, len(e.Extensions))
+	for name := range e.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		set(name, e.Extensions[name])
+	}
+	if e.DataContentType != "" {
+		h.Set("Content-Type", e.DataContentType)
+	} else {
+		h.Del("Content-Type")
+	}
+	return nil
+}
+
+// NewRequest returns a POST request delivering e to url in binary mode.
+func NewRequest(url string, e *Event) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(e.Data))
+	if err != nil {
+		return nil, err
+	}
+	if err := e.SetHeader(req.Header); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// WriteResponse writes e as a binary mode response with the given status.
+func WriteResponse(w http.ResponseWriter, status int, e *Event) error {
+	if err := e.SetHeader(w.Header()); err != nil {
+		return err
+	}
+	w.WriteHeader(status)
+	_, err := w.Write(e.Data)
+	return err
+}
+
+// EncodeValue percent-encodes an attribute value for a ce- field: space,
+// double quote, percent and all bytes outside printable ASCII are encoded.
+func EncodeValue(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c > '~' || c == '"' || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(ascii.UpperHex[c>>4])
+			b.WriteByte(ascii.UpperHex[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// DecodeValue decodes a percent-encoded attribute value, which must be
+// valid UTF-8 once decoded.
+func DecodeValue(s string) (string, error) {
+	if strings.IndexByte(s, '%') < 0 {
+		if !utf8.ValidString(s) {
+			return "", ErrInvalidAttribute
+		}
+		return s, nil
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", ErrInvalidAttribute
+		}
+		hi, ok1 := ascii.Unhex(s[i+1])
+		lo, ok2 := ascii.Unhex(s[i+2])
+		if !ok1 || !ok2 {
+			return "", ErrInvalidAttribute
+		}
+		b = append(b, hi<<4|lo)
+		i += 2
+	}
+	if !utf8.Valid(b) {
+		return "", ErrInvalidAttribute
+	}
+	return string(b), nil
+}
@@ -0,0 +1,204 @@
+package cloudevents
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newEvent() *Event {
+	return &Event{
+		ID:              "A234-1234-1234",
+		Source:          "https://github.com/cloudevents/spec/pull",
+		SpecVersion:     SpecVersion,
+		Type:            "com.github.pull_request.opened",
+		DataContentType: "text/xml",
+		Subject:         "123",
+		Time:            time.Date(2018, 4, 5, 17, 31, 0, 0, time.UTC),
+		Extensions:      map[string]string{"comexampleextension1": "value"},
+		Data:            []byte(`<much wow="xml"/>`),
+	}
+}
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		in, encoded string
+	}{
+		{"plain", "plain"},
+		{"a b", "a%20b"},
+		{`say "hi"`, "say%20%22hi%22"},
+		{"100%", "100%25"},
+		{"€", "%E2%82%AC"},
+		{"tab\tnl\n", "tab%09nl%0A"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := EncodeValue(tt.in); got != tt.encoded {
+			t.Errorf("EncodeValue(%q) = %q, want %q", tt.in, got, tt.encoded)
+		}
+		if got, err := DecodeValue(tt.encoded); err != nil || got != tt.in {
+			t.Errorf("DecodeValue(%q) = %q, %v; want %q", tt.encoded, got, err, tt.in)
+		}
+	}
+	if got, err := DecodeValue("%e2%82%ac"); err != nil || got != "€" {
+		t.Errorf("lower-case hex = %q, %v", got, err)
+	}
+	for _, s := range []string{"%", "%2", "a%2", "%zz", "%C3", "%FF", "\xff"} {
+		if _, err := DecodeValue(s); err != ErrInvalidAttribute {
+			t.Errorf("DecodeValue(%q) = %v, want %v", s, err, ErrInvalidAttribute)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(e *Event)
+		err    error
+	}{
+		{"valid", func(e *Event) {}, nil},
+		{"missing id", func(e *Event) { e.ID = "" }, ErrMissingAttribute},
+		{"missing source", func(e *Event) { e.Source = "" }, ErrMissingAttribute},
+		{"missing specversion", func(e *Event) { e.SpecVersion = "" }, ErrMissingAttribute},
+		{"missing type", func(e *Event) { e.Type = "" }, ErrMissingAttribute},
+		{"other specversion", func(e *Event) { e.SpecVersion = "0.3" }, ErrSpecVersion},
+		{"upper-case extension", func(e *Event) { e.Extensions["Ext"] = "x" }, ErrInvalidAttribute},
+		{"extension with dash", func(e *Event) { e.Extensions["my-ext"] = "x" }, ErrInvalidAttribute},
+		{"empty extension name", func(e *Event) { e.Extensions[""] = "x" }, ErrInvalidAttribute},
+		{"extension shadows attribute", func(e *Event) { e.Extensions["time"] = "x" }, ErrInvalidAttribute},
+		{"extension named data", func(e *Event) { e.Extensions["data"] = "x" }, ErrInvalidAttribute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEvent()
+			tt.modify(e)
+			if err := e.Validate(); err != tt.err {
+				t.Errorf("Validate = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSetHeader(t *testing.T) {
+	e := newEvent()
+	e.Subject = "new item"
+	h := http.Header{"Ce-Stale": {"x"}, "Content-Type": {"text/plain"}, "Other": {"kept"}}
+	if err := e.SetHeader(h); err != nil {
+		t.Fatal(err)
+	}
+	want := http.Header{
+		"Ce-Id":                   {"A234-1234-1234"},
+		"Ce-Source":               {"https://github.com/cloudevents/spec/pull"},
+		"Ce-Specversion":          {"1.0"},
+		"Ce-Type":                 {"com.github.pull_request.opened"},
+		"Ce-Subject":              {"new%20item"},
+		"Ce-Time":                 {"2018-04-05T17:31:00Z"},
+		"Ce-Comexampleextension1": {"value"},
+		"Content-Type":            {"text/xml"},
+		"Other":                   {"kept"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("SetHeader = %v, want %v", h, want)
+	}
+
+	e.DataContentType = ""
+	if err := e.SetHeader(h); err != nil || h.Get("Content-Type") != "" {
+		t.Errorf("Content-Type kept: %v, %v", h, err)
+	}
+	e.ID = ""
+	h = http.Header{}
+	if err := e.SetHeader(h); err != ErrMissingAttribute || len(h) != 0 {
+		t.Errorf("invalid event: %v, %v", h, err)
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	base := http.Header{
+		"Ce-Id":          {"1"},
+		"Ce-Source":      {"/src"},
+		"Ce-Specversion": {"1.0"},
+		"Ce-Type":        {"t"},
+	}
+	with := func(name, value string) http.Header {
+		h := http.Header{}
+		for k, v := range base {
+			h[k] = v
+		}
+		h[name] = []string{value}
+		return h
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		err    error
+	}{
+		{"no ce fields", http.Header{"Content-Type": {"text/plain"}}, ErrNotEvent},
+		{"structured mode", with("Content-Type", "application/cloudevents+json; charset=utf-8"), ErrStructuredMode},
+		{"batch mode", with("Content-Type", "application/cloudevents-batch+json"), ErrStructuredMode},
+		{"missing type", with("Ce-Type", ""), ErrMissingAttribute},
+		{"other specversion", with("Ce-Specversion", "0.3"), ErrSpecVersion},
+		{"invalid name", with("Ce-My_ext", "x"), ErrInvalidAttribute},
+		{"empty name", with("Ce-", "x"), ErrInvalidAttribute},
+		{"bad encoding", with("Ce-Subject", "%zz"), ErrInvalidAttribute},
+		{"bad UTF-8", with("Ce-Subject", "%C3%28"), ErrInvalidAttribute},
+		{"bad time", with("Ce-Time", "2018-04-05 17:31:00"), ErrInvalidAttribute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if e, err := FromHeader(tt.header); err != tt.err {
+				t.Errorf("FromHeader = %+v, %v; want %v", e, err, tt.err)
+			}
+		})
+	}
+
+	h := with("Ce-Subject", "a%20b%E2%82%AC")
+	h["Ce-Extone"] = []string{"first", "second"}
+	h["CE-TIME"] = []string{"2018-04-05T17:31:00.5+02:00"}
+	e, err := FromHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Subject != "a b€" || e.Extensions["extone"] != "first" {
+		t.Errorf("event = %+v", e)
+	}
+	if want := time.Date(2018, 4, 5, 15, 31, 0, 5e8, time.UTC); !e.Time.Equal(want) {
+		t.Errorf("time = %v, want %v", e.Time, want)
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+	e := newEvent()
+	e.Extensions["traceparent"] = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	e.Subject = `quoted "subject" 100%`
+	req, err := NewRequest("https://example.com/events", e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != http.MethodPost {
+		t.Errorf("method %s", req.Method)
+	}
+	got, err := ReadRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("ReadRequest = %+v, want %+v", got, e)
+	}
+
+	rec := httptest.NewRecorder()
+	if err := WriteResponse(rec, http.StatusAccepted, e); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(rec.Body)
+	if rec.Code != http.StatusAccepted || string(body) != string(e.Data) || !IsBinaryMode(rec.Header()) {
+		t.Errorf("response %d %q %v", rec.Code, body, rec.Header())
+	}
+
+	if _, err := ReadRequest(httptest.NewRequest("POST", "/", strings.NewReader("x"))); err != ErrNotEvent {
+		t.Errorf("ReadRequest without event = %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package compdict
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestParseUseAsDictionary(t *testing.T) {
+	tests := []struct {
+		in   string
+		want UseAsDictionary
+		err  error // nil with want zero means any error
+	}{
+		{`match="/app/*"`, UseAsDictionary{Match: "/app/*", Type: TypeRaw}, nil},
+		{`match="/app/*", match-dest=("script" "style"), id="v1", type=raw`, UseAsDictionary{Match: "/app/*", MatchDest: []string{"script", "style"}, ID: "v1", Type: TypeRaw}, nil},
+		{`match="/a", type=future, extra=1`, UseAsDictionary{Match: "/a", Type: "future"}, nil},
+		{`match="/a", match-dest=()`, UseAsDictionary{Match: "/a", Type: TypeRaw}, nil},
+		{`id="v1"`, UseAsDictionary{}, ErrNoMatch},
+		{`match=/app/`, UseAsDictionary{}, nil},
+		{`match="/a", match-dest="script"`, UseAsDictionary{}, ErrMalformed},
+		{`match="/a", match-dest=(script)`, UseAsDictionary{}, ErrMalformed},
+		{`match="/a", id=v1`, UseAsDictionary{}, ErrMalformed},
+		{`match="/a", type="raw"`, UseAsDictionary{}, ErrMalformed},
+		{`match="/a", id="` + strings.Repeat("x", 1025) + `"`, UseAsDictionary{}, ErrIDTooLong},
+		{`match="/a",`, UseAsDictionary{}, nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseUseAsDictionary(tt.in)
+		if reflect.DeepEqual(tt.want, UseAsDictionary{}) {
+			if err == nil || tt.err != nil && err != tt.err {
+				t.Errorf("ParseUseAsDictionary(%.40q): err = %v, want %v", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseUseAsDictionary(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestUseAsDictionaryString(t *testing.T) {
+	u := UseAsDictionary{Match: "/app/*", MatchDest: []string{"script"}, ID: `a"b`, Type: TypeRaw}
+	if got, want := u.String(), `match="/app/*", match-dest=("script"), id="a\"b"`; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	back, err := ParseUseAsDictionary(u.String())
+	if err != nil || !reflect.DeepEqual(back, u) {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+	if got := (UseAsDictionary{Match: "/ä"}).String(); got != "" {
+		t.Errorf("non-ASCII match formatted as %q", got)
+	}
+}
+
+func TestAvailableDictionary(t *testing.T) {
+	h := Sum([]byte("dictionary"))
+	s := h.String()
+	if !strings.HasPrefix(s, ":") || !strings.HasSuffix(s, ":") {
+		t.Errorf("String() = %q, want a byte sequence", s)
+	}
+	got, err := ParseAvailableDictionary(s)
+	if err != nil || got != h {
+		t.Errorf("ParseAvailableDictionary(%q) = %x, %v", s, got, err)
+	}
+	short := Hash{}.String()
+	short = short[:len(short)-5] + "=:"
+	for _, bad := range []string{":AAAA:", short, `"abc"`, "abc", s + ","} {
+		if _, err := ParseAvailableDictionary(bad); err == nil {
+			t.Errorf("ParseAvailableDictionary(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDictionaryID(t *testing.T) {
+	v, err := FormatDictionaryID(`id"1`)
+	if err != nil || v != `"id\"1"` {
+		t.Fatalf("FormatDictionaryID = %q, %v", v, err)
+	}
+	if id, err := ParseDictionaryID(v); err != nil || id != `id"1` {
+		t.Errorf("ParseDictionaryID(%q) = %q, %v", v, id, err)
+	}
+	if _, err := ParseDictionaryID("token"); err != ErrMalformed {
+		t.Errorf("token id: %v", err)
+	}
+	long := strings.Repeat("x", 1025)
+	if _, err := FormatDictionaryID(long); err != ErrIDTooLong {
+		t.Errorf("FormatDictionaryID(long): %v", err)
+	}
+	if _, err := ParseDictionaryID(`"` + long + `"`); err != ErrIDTooLong {
+		t.Errorf("ParseDictionaryID(long): %v", err)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	dict := Sum([]byte("dictionary"))
+	other := Sum([]byte("other"))
+	known := func(h Hash, id string) bool { return h == dict && (id == "" || id == "v1") }
+	tests := []struct {
+		name    string
+		header  http.Header
+		codings []string
+		coding  string
+	}{
+		{"dcz preferred by default", http.Header{HeaderAvailableDictionary: {dict.String()}, "Accept-Encoding": {"gzip, dcb, dcz"}}, nil, EncodingDCZ},
+		{"server order", http.Header{HeaderAvailableDictionary: {dict.String()}, "Accept-Encoding": {"dcb, dcz"}}, []string{EncodingDCB, EncodingDCZ}, EncodingDCB},
+		{"client weights win", http.Header{HeaderAvailableDictionary: {dict.String()}, "Accept-Encoding": {"dcz;q=0.5, dcb"}}, nil, EncodingDCB},
+		{"several field lines", http.Header{HeaderAvailableDictionary: {dict.String()}, "Accept-Encoding": {"gzip", "br, dcb"}}, nil, EncodingDCB},
+		{"refused coding", http.Header{HeaderAvailableDictionary: {dict.String()}, "Accept-Encoding": {"dcz;q=0, gzip"}}, []string{EncodingDCZ}, ""},
+		{"wildcard", http.Header{HeaderAvailableDictionary: {dict.String()}, "Accept-Encoding": {"*"}}, nil, EncodingDCZ},
+		{"no dictionary coding accepted", http.Header{HeaderAvailableDictionary: {dict.String()}, "Accept-Encoding": {"gzip, br"}}, nil, ""},
+		{"no Available-Dictionary", http.Header{"Accept-Encoding": {"dcb, dcz"}}, nil, ""},
+		{"unknown dictionary", http.Header{HeaderAvailableDictionary: {other.String()}, "Accept-Encoding": {"dcb, dcz"}}, nil, ""},
+		{"malformed Available-Dictionary", http.Header{HeaderAvailableDictionary: {`"x"`}, "Accept-Encoding": {"dcb, dcz"}}, nil, ""},
+		{"known id", http.Header{HeaderAvailableDictionary: {dict.String()}, HeaderDictionaryID: {`"v1"`}, "Accept-Encoding": {"dcb"}}, nil, EncodingDCB},
+		{"unknown id", http.Header{HeaderAvailableDictionary: {dict.String()}, HeaderDictionaryID: {`"v2"`}, "Accept-Encoding": {"dcb"}}, nil, ""},
+		{"malformed id", http.Header{HeaderAvailableDictionary: {dict.String()}, HeaderDictionaryID: {"v1"}, "Accept-Encoding": {"dcb"}}, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := make(http.Header)
+			for name, values := range tt.header {
+				header[http.CanonicalHeaderKey(name)] = values
+			}
+			coding, hash, ok := Negotiate(header, known, tt.codings...)
+			if coding != tt.coding || ok != (tt.coding != "") {
+				t.Errorf("Negotiate = %q, %v; want %q", coding, ok, tt.coding)
+			}
+			if ok && hash != dict {
+				t.Errorf("hash = %x", hash)
+			}
+		})
+	}
+}
+
+func TestSetRequestHeader(t *testing.T) {
+	d, err := NewDictionary(mustURL(t, "https://example.com/app/v1.js"), UseAsDictionary{Match: "/app/*", ID: "v1"}, []byte("dictionary"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		lines   []string
+		codings []string
+		want    []string
+	}{
+		{"no field", nil, nil, []string{"dcb, dcz"}},
+		{"one line", []string{"gzip, br"}, nil, []string{"gzip, br, dcb, dcz"}},
+		{"several lines", []string{"gzip", "br, dcb"}, nil, []string{"gzip, br, dcb, dcz"}},
+		{"empty line", []string{"", "gzip"}, []string{EncodingDCZ}, []string{"gzip, dcz"}},
+		{"already present", []string{"gzip", "DCZ"}, []string{EncodingDCZ}, []string{"gzip", "DCZ"}},
+		{"refused stays refused", []string{"dcb;q=0"}, []string{EncodingDCB}, []string{"dcb;q=0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := make(http.Header)
+			h.Set(HeaderDictionaryID, `"stale"`)
+			if tt.lines != nil {
+				h["Accept-Encoding"] = tt.lines
+			}
+			SetRequestHeader(h, d, tt.codings...)
+			if got := h["Accept-Encoding"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Accept-Encoding = %q, want %q", got, tt.want)
+			}
+			if h.Get(HeaderAvailableDictionary) != d.Hash.String() || h.Get(HeaderDictionaryID) != `"v1"` {
+				t.Errorf("header = %v", h)
+			}
+		})
+	}
+
+	// A dictionary without an id removes a stale Dictionary-ID.
+	d.Use.ID = ""
+	h := make(http.Header)
+	h.Set(HeaderDictionaryID, `"stale"`)
+	SetRequestHeader(h, d)
+	if h.Get(HeaderDictionaryID) != "" {
+		t.Error("stale Dictionary-ID kept")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	base := mustURL(t, "https://example.com/app/main.js")
+	now := time.Now()
+	newDict := func(use UseAsDictionary, content string, fetched time.Time) *Dictionary {
+		d, err := NewDictionary(base, use, []byte(content), fetched)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	broad := newDict(UseAsDictionary{Match: "/*"}, "broad", now)
+	narrow := newDict(UseAsDictionary{Match: "/app/*.js", MatchDest: []string{"script"}}, "narrow", now.Add(-time.Hour))
+	newer := newDict(UseAsDictionary{Match: "/app/*.js", MatchDest: []string{"script"}}, "newer", now)
+	future := newDict(UseAsDictionary{Match: "/app/*.js", Type: "future"}, "future", now.Add(time.Hour))
+	dicts := []*Dictionary{broad, narrow, newer, future}
+
+	tests := []struct {
+		url  string
+		dest string
+		want *Dictionary
+	}{
+		{"https://example.com/app/x.js", "script", newer},
+		{"https://example.com/app/x.js", "", newer},
+		{"https://example.com/app/x.js", "style", broad},
+		{"https://example.com/app/x.css", "script", broad},
+		{"https://other.example/app/x.js", "script", nil},
+		{"http://example.com/app/x.js", "script", nil},
+	}
+	for _, tt := range tests {
+		if got := Select(dicts, mustURL(t, tt.url), tt.dest); got != tt.want {
+			t.Errorf("Select(%s, %q) = %v, want %v", tt.url, tt.dest, got, tt.want)
+		}
+	}
+}
+
+func TestCompilePattern(t *testing.T) {
+	base := mustURL(t, "https://example.com/app/v1/main.js")
+	tests := []struct {
+		match string
+		err   error
+		yes   []string
+		no    []string
+	}{
+		{"/app/*", nil, []string{"https://example.com/app/", "https://example.com/app/x/y.js?q=1"}, []string{"https://example.com/other", "https://example.com:8443/app/x"}},
+		{"*.js", nil, []string{"https://example.com/app/v1/x.js"}, []string{"https://example.com/app/x.js"}},
+		{"/app/:version/main.js", nil, []string{"https://example.com/app/v2/main.js"}, []string{"https://example.com/app/v2/x/main.js"}},
+		{"/app/main{.min}?.js", nil, []string{"https://example.com/app/main.js", "https://example.com/app/main.min.js"}, []string{"https://example.com/app/main.max.js"}},
+		{"/app/index.html?lang=en", nil, []string{"https://example.com/app/index.html?lang=en"}, []string{"https://example.com/app/index.html?lang=de", "https://example.com/app/index.html"}},
+		// A "?" after a wildcard is a modifier, not the start of the query.
+		{"/app/*?", nil, []string{"https://example.com/app/x?lang=de"}, nil},
+		{"https://example.com:443/app/*", nil, []string{"https://example.com/app/x"}, nil},
+		{"https://cdn.example.com/app/*", ErrCrossOrigin, nil, nil},
+		{"http://example.com/app/*", ErrCrossOrigin, nil, nil},
+		{"/app/(\\d+)/*", ErrRegexpGroup, nil, nil},
+		{"/app/{*", ErrPattern, nil, nil},
+	}
+	for _, tt := range tests {
+		p, err := CompilePattern(tt.match, base)
+		if err != tt.err {
+			t.Errorf("CompilePattern(%q): err = %v, want %v", tt.match, err, tt.err)
+			continue
+		}
+		for _, s := range tt.yes {
+			if !p.Match(mustURL(t, s)) {
+				t.Errorf("%q does not match %s", tt.match, s)
+			}
+		}
+		for _, s := range tt.no {
+			if p.Match(mustURL(t, s)) {
+				t.Errorf("%q matches %s", tt.match, s)
+			}
+		}
+	}
+}
+
+func TestStreamHeader(t *testing.T) {
+	h := Sum([]byte("dictionary"))
+	for _, coding := range []string{EncodingDCB, EncodingDCZ} {
+		var buf bytes.Buffer
+		if err := WriteStreamHeader(&buf, coding, h); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteString("payload")
+		got, err := ReadStreamHeader(&buf, strings.ToUpper(coding))
+		if err != nil || got != h || buf.String() != "payload" {
+			t.Errorf("%s: ReadStreamHeader = %x, %v; rest %q", coding, got, err, buf.String())
+		}
+	}
+	var buf bytes.Buffer
+	WriteStreamHeader(&buf, EncodingDCB, h)
+	if _, err := ReadStreamHeader(&buf, EncodingDCZ); err != ErrStreamHeader {
+		t.Errorf("wrong magic: %v", err)
+	}
+	if _, err := ReadStreamHeader(bytes.NewReader(magicDCB), EncodingDCB); err != ErrStreamHeader {
+		t.Errorf("truncated hash: %v", err)
+	}
+	if err := WriteStreamHeader(&buf, "br", h); err != ErrUnknownCoding {
+		t.Errorf("unknown coding: %v", err)
+	}
+}
+
+func TestSetResponseHeader(t *testing.T) {
+	h := http.Header{"Vary": {"accept-encoding"}}
+	SetResponseHeader(h, EncodingDCZ)
+	if h.Get("Content-Encoding") != EncodingDCZ || !reflect.DeepEqual(h["Vary"], []string{"accept-encoding", HeaderAvailableDictionary}) {
+		t.Errorf("header = %v", h)
+	}
+	h = http.Header{"Vary": {"*"}}
+	SetResponseHeader(h, "")
+	if len(h["Vary"]) != 1 || h.Get("Content-Encoding") != "" {
+		t.Errorf("header = %v", h)
+	}
+}
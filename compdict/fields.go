@@ -0,0 +1,183 @@
+// Package compdict implements the fields and content codings of Compression
+// Dictionary Transport (RFC 9842).
+//
+// A server marks a response as usable as a dictionary with Use-As-Dictionary.
+// A client that later requests a URL matching the dictionary's pattern
+// announces the dictionary's SHA-256 hash in Available-Dictionary and
+// accepts the dcb (Brotli) and dcz (Zstandard) content codings, which
+// compress the response against that dictionary.
+package compdict
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/palsivertsen/gohttpfields/sfv"
+)
+
+// Field names defined by RFC 9842.
+const (
+	HeaderUseAsDictionary     = "Use-As-Dictionary"
+	HeaderAvailableDictionary = "Available-Dictionary"
+	HeaderDictionaryID        = "Dictionary-ID"
+)
+
+// TypeRaw is the only dictionary type defined by RFC 9842 and the default.
+const TypeRaw = "raw"
+
+// maxIDLength is the longest id accepted by RFC 9842 section 2.1.3.
+const maxIDLength = 1024
+
+var (
+	// ErrMalformed is returned for field values that do not follow the
+	// structured field types of RFC 9842.
+	ErrMalformed = errors.New("compdict: malformed field value")
+	// ErrNoMatch is returned for a Use-As-Dictionary field without the
+	// required match member.
+	ErrNoMatch = errors.New("compdict: use-as-dictionary without match")
+	// ErrIDTooLong is returned for dictionary ids over 1024 characters.
+	ErrIDTooLong = errors.New("compdict: dictionary id too long")
+)
+
+// UseAsDictionary is the value of a Use-As-Dictionary response field.
+type UseAsDictionary struct {
+	Match     string   // URLPattern of the URLs the dictionary applies to
+	MatchDest []string // request destinations; empty matches all
+	ID        string   // server chosen id echoed in Dictionary-ID
+	Type      string   // dictionary format, TypeRaw when empty
+}
+
+// ParseUseAsDictionary parses a Use-As-Dictionary field value. Unknown
+// members are ignored; members of the wrong type are errors.
+func ParseUseAsDictionary(s string) (UseAsDictionary, error) {
+	d, err := sfv.ParseDictionary(s)
+	if err != nil {
+		return UseAsDictionary{}, err
+	}
+	var u UseAsDictionary
+	m, ok := d.Get("match")
+	if !ok {
+		return u, ErrNoMatch
+	}
+	if u.Match, ok = itemString(m); !ok {
+		return u, ErrMalformed
+	}
+	if m, ok := d.Get("match-dest"); ok {
+		list, ok := m.(sfv.InnerList)
+		if !ok {
+			return u, ErrMalformed
+		}
+		for _, it := range list.Items {
+			dest, ok := it.Value.(string)
+			if !ok {
+				return u, ErrMalformed
+			}
+			u.MatchDest = append(u.MatchDest, dest)
+		}
+	}
+	if m, ok := d.Get("id"); ok {
+		if u.ID, ok = itemString(m); !ok {
+			return u, ErrMalformed
+		}
+		if len(u.ID) > maxIDLength {
+			return u, ErrIDTooLong
+		}
+	}
+	u.Type = TypeRaw
+	if m, ok := d.Get("type"); ok {
+		it, ok := m.(sfv.Item)
+		if !ok {
+			return u, ErrMalformed
+		}
+		t, ok := it.Value.(sfv.Token)
+		if !ok {
+			return u, ErrMalformed
+		}
+		u.Type = string(t)
+	}
+	return u, nil
+}
+
+// String formats u as a Use-As-Dictionary field value, omitting members
+// with default values. It returns an empty string if a member contains
+// characters outside printable ASCII, which the field cannot carry.
+func (u UseAsDictionary) String() string {
+	d := sfv.Dictionary{{Key: "match", Value: sfv.Item{Value: u.Match}}}
+	if len(u.MatchDest) > 0 {
+		list := sfv.InnerList{Items: make([]sfv.Item, len(u.MatchDest))}
+		for i, dest := range u.MatchDest {
+			list.Items[i] = sfv.Item{Value: dest}
+		}
+		d = append(d, sfv.DictMember{Key: "match-dest", Value: list})
+	}
+	if u.ID != "" {
+		d = append(d, sfv.DictMember{Key: "id", Value: sfv.Item{Value: u.ID}})
+	}
+	if u.Type != "" && u.Type != TypeRaw {
+		d = append(d, sfv.DictMember{Key: "type", Value: sfv.Item{Value: sfv.Token(u.Type)}})
+	}
+	s, _ := sfv.MarshalDictionary(d)
+	return s
+}
+
+// Hash is the SHA-256 hash identifying a dictionary.
+type Hash [sha256.Size]byte
+
+// Sum returns the hash of a dictionary's content.
+func Sum(dictionary []byte) Hash {
+	return sha256.Sum256(dictionary)
+}
+
+// String formats h as an Available-Dictionary field value.
+func (h Hash) String() string {
+	s, _ := sfv.MarshalItem(sfv.Item{Value: h[:]})
+	return s
+}
+
+// ParseAvailableDictionary parses an Available-Dictionary field value.
+func ParseAvailableDictionary(s string) (Hash, error) {
+	var h Hash
+	it, err := sfv.ParseItem(s)
+	if err != nil {
+		return h, err
+	}
+	b, ok := it.Value.([]byte)
+	if !ok || len(b) != len(h) {
+		return h, ErrMalformed
+	}
+	copy(h[:], b)
+	return h, nil
+}
+
+// ParseDictionaryID parses a Dictionary-ID field value.
+func ParseDictionaryID(s string) (string, error) {
+	it, err := sfv.ParseItem(s)
+	if err != nil {
+		return "", err
+	}
+	id, ok := it.Value.(string)
+	if !ok {
+		return "", ErrMalformed
+	}
+	if len(id) > maxIDLength {
+		return "", ErrIDTooLong
+	}
+	return id, nil
+}
+
+// FormatDictionaryID formats id as a Dictionary-ID field value.
+func FormatDictionaryID(id string) (string, error) {
+	if len(id) > maxIDLength {
+		return "", ErrIDTooLong
+	}
+	return sfv.MarshalItem(sfv.Item{Value: id})
+}
+
+func itemString(m sfv.Member) (string, bool) {
+	it, ok := m.(sfv.Item)
+	if !ok {
+		return "", false
+	}
+	s, ok := it.Value.(string)
+	return s, ok
+}
@@ -0,0 +1,252 @@
+package compdict
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// Dictionary-compressed content codings defined by RFC 9842 section 5.
+const (
+	EncodingDCB = "dcb" // Brotli with a dictionary
+	EncodingDCZ = "dcz" // Zstandard with a dictionary
+)
+
+var (
+	// ErrUnknownCoding is returned for content codings other than dcb and
+	// dcz.
+	ErrUnknownCoding = errors.New("compdict: unknown content coding")
+	// ErrStreamHeader is returned when a dcb or dcz stream does not start
+	// with the expected magic number.
+	ErrStreamHeader = errors.New("compdict: malformed stream header")
+)
+
+// Magic numbers preceding the dictionary hash in dcb and dcz streams.
+var (
+	magicDCB = []byte{0xff, 0x44, 0x43, 0x42}
+	magicDCZ = []byte{0x5e, 0x2a, 0x4d, 0x18, 0x20, 0x00, 0x00, 0x00}
+)
+
+func magic(coding string) ([]byte, error) {
+	switch strings.ToLower(coding) {
+	case EncodingDCB:
+		return magicDCB, nil
+	case EncodingDCZ:
+		return magicDCZ, nil
+	}
+	return nil, ErrUnknownCoding
+}
+
+// WriteStreamHeader writes the header that precedes the compressed data in
+// a dcb or dcz response body: the coding's magic number followed by the
+// hash of the dictionary.
+func WriteStreamHeader(w io.Writer, coding string, h Hash) error {
+	m, err := magic(coding)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m); err != nil {
+		return err
+	}
+	_, err = w.Write(h[:])
+	return err
+}
+
+// ReadStreamHeader consumes the header of a dcb or dcz response body and
+// returns the hash of the dictionary it was compressed with. The caller
+// must check the hash against the dictionary it announced.
+func ReadStreamHeader(r io.Reader, coding string) (Hash, error) {
+	var h Hash
+	m, err := magic(coding)
+	if err != nil {
+		return h, err
+	}
+	buf := make([]byte, len(m))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return h, ErrStreamHeader
+		}
+		return h, err
+	}
+	if !bytes.Equal(buf, m) {
+		return h, ErrStreamHeader
+	}
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return h, ErrStreamHeader
+		}
+		return h, err
+	}
+	return h, nil
+}
+
+// Dictionary is a response stored by a client for use as a compression
+// dictionary.
+type Dictionary struct {
+	URL     *url.URL // URL the dictionary was fetched from
+	Use     UseAsDictionary
+	Hash    Hash
+	Fetched time.Time
+
+	pattern *Pattern
+}
+
+// NewDictionary records the response body content fetched from u with the
+// given Use-As-Dictionary field. It fails if the match pattern makes the
+// dictionary unusable.
+func NewDictionary(u *url.URL, use UseAsDictionary, content []byte, fetched time.Time) (*Dictionary, error) {
+	p, err := CompilePattern(use.Match, u)
+	if err != nil {
+		return nil, err
+	}
+	return &Dictionary{URL: u, Use: use, Hash: Sum(content), Fetched: fetched, pattern: p}, nil
+}
+
+// Matches reports whether the dictionary may be announced for a request to
+// u with request destination dest, such as "script" or "document". An empty
+// dest means the destination is unknown and match-dest is ignored.
+// Dictionaries of unknown types never match.
+func (d *Dictionary) Matches(u *url.URL, dest string) bool {
+	if d.Use.Type != "" && d.Use.Type != TypeRaw {
+		return false
+	}
+	if dest != "" && len(d.Use.MatchDest) > 0 {
+		found := false
+		for _, md := range d.Use.MatchDest {
+			if md == dest {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return d.pattern.Match(u)
+}
+
+// Select returns the best dictionary to announce for a request, or nil if
+// none matches. Following RFC 9842 section 2.2.3, the dictionary with the
+// longest match pattern wins and ties go to the most recently fetched one.
+func Select(dicts []*Dictionary, u *url.URL, dest string) *Dictionary {
+	var best *Dictionary
+	for _, d := range dicts {
+		if !d.Matches(u, dest) {
+			continue
+		}
+		if best == nil || len(d.Use.Match) > len(best.Use.Match) ||
+			len(d.Use.Match) == len(best.Use.Match) && d.Fetched.After(best.Fetched) {
+			best = d
+		}
+	}
+	return best
+}
+
+// SetRequestHeader announces d in the request header h: it sets
+// Available-Dictionary, Dictionary-ID when the dictionary has an id, and
+// adds the given codings to Accept-Encoding. With no codings, both dcb and
+// dcz are added.
+func SetRequestHeader(h http.Header, d *Dictionary, codings ...string) {
+	h.Set(HeaderAvailableDictionary, d.Hash.String())
+	h.Del(HeaderDictionaryID)
+	if id, err := FormatDictionaryID(d.Use.ID); err == nil && d.Use.ID != "" {
+		h.Set(HeaderDictionaryID, id)
+	}
+	if len(codings) == 0 {
+		codings = []string{EncodingDCB, EncodingDCZ}
+	}
+	accepted := httpfields.ParseQualityList(strings.Join(h["Accept-Encoding"], ","))
+	var add []string
+	for _, c := range codings {
+		found := false
+		for _, qv := range accepted {
+			if strings.EqualFold(qv.Value, c) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			add = append(add, c)
+		}
+	}
+	if len(add) == 0 {
+		return
+	}
+	// Fold every existing field line into the new value; keeping only the
+	// first would drop the codings listed on the others.
+	var lines []string
+	for _, v := range h["Accept-Encoding"] {
+		if v = httpfields.TrimOWS(v); v != "" {
+			lines = append(lines, v)
+		}
+	}
+	h.Set("Accept-Encoding", strings.Join(append(lines, add...), ", "))
+}
+
+// Negotiate selects a dictionary-compressed coding for a request with
+// header h. known reports whether the server has a dictionary with the
+// given hash and id. codings lists the codings the server can produce in
+// order of preference, defaulting to dcz then dcb. The client's
+// Accept-Encoding weights take precedence over the server's order.
+func Negotiate(h http.Header, known func(hash Hash, id string) bool, codings ...string) (coding string, hash Hash, ok bool) {
+	v := h.Get(HeaderAvailableDictionary)
+	if v == "" {
+		return "", hash, false
+	}
+	hash, err := ParseAvailableDictionary(v)
+	if err != nil {
+		return "", hash, false
+	}
+	var id string
+	if v := h.Get(HeaderDictionaryID); v != "" {
+		if id, err = ParseDictionaryID(v); err != nil {
+			return "", hash, false
+		}
+	}
+	if !known(hash, id) {
+		return "", hash, false
+	}
+	if len(codings) == 0 {
+		codings = []string{EncodingDCZ, EncodingDCB}
+	}
+	accepted := httpfields.ParseQualityList(strings.Join(h["Accept-Encoding"], ","))
+	best := 0.0
+	for _, c := range codings {
+		if q := httpfields.Acceptable(accepted, c); q > best {
+			coding, best = c, q
+		}
+	}
+	if coding == "" {
+		return "", hash, false
+	}
+	return coding, hash, true
+}
+
+// SetResponseHeader prepares the response header h for a body encoded
+// with coding. Every response to a request that could carry a dictionary
+// must vary on Accept-Encoding and Available-Dictionary, so call it with an
+// empty coding for responses sent without dictionary compression.
+func SetResponseHeader(h http.Header, coding string) {
+	if coding != "" {
+		h.Set("Content-Encoding", coding)
+	}
+	vary := httpfields.SplitList(strings.Join(h["Vary"], ","))
+	for _, field := range []string{"Accept-Encoding", HeaderAvailableDictionary} {
+		found := false
+		for _, v := range vary {
+			if strings.EqualFold(v, field) || v == "*" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			h.Add("Vary", field)
+		}
+	}
+}
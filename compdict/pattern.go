@@ -0,0 +1,301 @@
+package compdict
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrRegexpGroup is returned for match patterns using regular
+	// expression groups, which make a dictionary unusable (RFC 9842
+	// section 2.1.1).
+	ErrRegexpGroup = errors.New("compdict: match pattern has regexp groups")
+	// ErrCrossOrigin is returned for match patterns that do not resolve to
+	// the origin of the dictionary.
+	ErrCrossOrigin = errors.New("compdict: match pattern is not same-origin")
+	// ErrPattern is returned for match patterns that cannot be parsed.
+	ErrPattern = errors.New("compdict: malformed match pattern")
+)
+
+// A Pattern is a compiled match pattern. It implements the subset of
+// URLPattern that RFC 9842 permits: literal text, named groups (":name"),
+// wildcards ("*"), non-capturing groups ("{...}") and the "?", "*" and "+"
+// modifiers. The origin components must resolve to the dictionary's origin.
+type Pattern struct {
+	scheme   string
+	hostname string
+	port     string
+	pathname *regexp.Regexp
+	search   *regexp.Regexp // nil matches any query
+}
+
+var schemePrefix = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*://`)
+
+// CompilePattern compiles the match member of a Use-As-Dictionary field,
+// resolving it against the URL the dictionary was fetched from.
+func CompilePattern(match string, base *url.URL) (*Pattern, error) {
+	p := &Pattern{
+		scheme:   strings.ToLower(base.Scheme),
+		hostname: strings.ToLower(base.Hostname()),
+		port:     normalizePort(base.Scheme, base.Port()),
+	}
+	rest := match
+	if loc := schemePrefix.FindStringIndex(match); loc != nil {
+		scheme := strings.ToLower(match[:loc[1]-3])
+		rest = match[loc[1]:]
+		end := strings.IndexAny(rest, "/?#")
+		if end < 0 {
+			end = len(rest)
+		}
+		u, err := url.Parse(scheme + "://" + rest[:end])
+		if err != nil || u.User != nil {
+			return nil, ErrCrossOrigin
+		}
+		if scheme != p.scheme || strings.ToLower(u.Hostname()) != p.hostname ||
+			normalizePort(scheme, u.Port()) != p.port {
+			return nil, ErrCrossOrigin
+		}
+		rest = rest[end:]
+		if rest == "" {
+			rest = "/"
+		}
+	}
+
+	pathname, search, err := splitPattern(rest)
+	if err != nil {
+		return nil, err
+	}
+	if pathname == "" {
+		pathname = escapePattern(base.EscapedPath())
+	} else if pathname[0] != '/' {
+		dir := base.EscapedPath()
+		dir = dir[:strings.LastIndexByte(dir, '/')+1]
+		if dir == "" {
+			dir = "/"
+		}
+		pathname = escapePattern(dir) + pathname
+	}
+	if p.pathname, err = compileComponent(pathname, '/', true); err != nil {
+		return nil, err
+	}
+	if search != nil {
+		if p.search, err = compileComponent(*search, 0, false); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Match reports whether u matches the pattern. The fragment of u is
+// ignored.
+func (p *Pattern) Match(u *url.URL) bool {
+	if strings.ToLower(u.Scheme) != p.scheme ||
+		strings.ToLower(u.Hostname()) != p.hostname ||
+		normalizePort(u.Scheme, u.Port()) != p.port {
+		return false
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if !p.pathname.MatchString(path) {
+		return false
+	}
+	return p.search == nil || p.search.MatchString(u.RawQuery)
+}
+
+func normalizePort(scheme, port string) string {
+	switch {
+	case port == "80" && strings.EqualFold(scheme, "http"),
+		port == "443" && strings.EqualFold(scheme, "https"):
+		return ""
+	}
+	return port
+}
+
+// splitPattern splits the path, query and fragment of a constructor string.
+// A "?" following a group, name or wildcard is a modifier, not the start of
+// the query. A nil search means the pattern does not restrict the query.
+func splitPattern(s string) (pathname string, search *string, err error) {
+	depth := 0
+	modifiable := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\':
+			i++
+			modifiable = false
+		case c == '{':
+			depth++
+			modifiable = false
+		case c == '}':
+			depth--
+			if depth < 0 {
+				return "", nil, ErrPattern
+			}
+			modifiable = true
+		case c == '*' || c == ')':
+			modifiable = true
+		case c == ':':
+			for i+1 < len(s) && isNameChar(s[i+1]) {
+				i++
+			}
+			modifiable = true
+		case c == '?' && modifiable:
+			modifiable = false
+		case depth == 0 && (c == '?' || c == '#'):
+			pathname = s[:i]
+			if c == '#' {
+				return pathname, nil, nil
+			}
+			q := s[i+1:]
+			if j := strings.IndexByte(q, '#'); j >= 0 {
+				q = q[:j]
+			}
+			return pathname, &q, nil
+		default:
+			modifiable = false
+		}
+	}
+	if depth != 0 {
+		return "", nil, ErrPattern
+	}
+	return s, nil, nil
+}
+
+func isNameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '$'
+}
+
+// compileComponent translates a pattern component to an anchored regular
+// expression. Named groups match up to the next separator; with a path
+// component, a "/" directly before a modified group or wildcard becomes
+// part of the optional or repeated portion as in URLPattern.
+func compileComponent(s string, separator byte, encode bool) (*regexp.Regexp, error) {
+	expr, err := compileParts(s, separator, encode)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^" + expr + "$")
+}
+
+func compileParts(s string, separator byte, encode bool) (string, error) {
+	segment := ".+?"
+	if separator != 0 {
+		segment = "[^" + regexp.QuoteMeta(string(separator)) + "]+?"
+	}
+	var (
+		b       strings.Builder
+		literal strings.Builder
+	)
+	flush := func(keepPrefix bool) string {
+		lit := literal.String()
+		literal.Reset()
+		prefix := ""
+		if keepPrefix && separator != 0 && strings.HasSuffix(lit, string(separator)) {
+			lit, prefix = lit[:len(lit)-1], string(separator)
+		}
+		b.WriteString(quoteLiteral(lit, encode))
+		return prefix
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		var part string
+		switch c {
+		case '\\':
+			if i+1 == len(s) {
+				return "", ErrPattern
+			}
+			i++
+			literal.WriteByte(s[i])
+			continue
+		case '(':
+			return "", ErrRegexpGroup
+		case ')', '}':
+			return "", ErrPattern
+		case '*':
+			part = ".*"
+		case ':':
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return "", ErrPattern
+			}
+			if j < len(s) && s[j] == '(' {
+				return "", ErrRegexpGroup
+			}
+			part, i = segment, j-1
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", ErrPattern
+			}
+			inner, err := compileParts(s[i+1:i+end], separator, encode)
+			if err != nil {
+				return "", err
+			}
+			part, i = "(?:"+inner+")", i+end
+		default:
+			literal.WriteByte(c)
+			continue
+		}
+		modifier := byte(0)
+		if i+1 < len(s) && (s[i+1] == '?' || s[i+1] == '*' || s[i+1] == '+') {
+			i++
+			modifier = s[i]
+		}
+		prefix := flush(modifier != 0 && c != '{')
+		if prefix != "" {
+			part = regexp.QuoteMeta(prefix) + part
+		}
+		switch modifier {
+		case '?':
+			b.WriteString("(?:" + part + ")?")
+		case '*':
+			b.WriteString("(?:" + part + ")*")
+		case '+':
+			b.WriteString("(?:" + part + ")+")
+		default:
+			b.WriteString(part)
+		}
+	}
+	flush(false)
+	return b.String(), nil
+}
+
+// quoteLiteral escapes literal pattern text for a regular expression,
+// percent-encoding spaces, controls and non-ASCII bytes first for path
+// components.
+func quoteLiteral(s string, encode bool) string {
+	if encode {
+		var b strings.Builder
+		for i := 0; i < len(s); i++ {
+			if c := s[i]; c <= ' ' || c >= 0x7f {
+				b.WriteByte('%')
+				b.WriteByte("0123456789ABCDEF"[c>>4])
+				b.WriteByte("0123456789ABCDEF"[c&15])
+			} else {
+				b.WriteByte(c)
+			}
+		}
+		s = b.String()
+	}
+	return regexp.QuoteMeta(s)
+}
+
+// escapePattern escapes URL text so that it is matched literally.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`\*:{}()?+`, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
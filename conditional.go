@@ -0,0 +1,66 @@
+package httpfields
+
+import (
+	"net/http"
+	"time"
+)
+
+// Validators are the validators of the selected representation used to
+// evaluate conditional requests. A nil ETag or zero LastModified means the
+// representation lacks that validator.
+type Validators struct {
+	ETag         *EntityTag
+	LastModified time.Time
+}
+
+// EvaluatePreconditions evaluates the If-Match, If-Unmodified-Since,
+// If-None-Match and If-Modified-Since fields of a request in the order given
+// by RFC 9110 section 13.2.2. It returns 0 when the request should proceed,
+// http.StatusNotModified when a GET or HEAD can be answered with 304, and
+// http.StatusPreconditionFailed otherwise. If-Range is evaluated separately
+// by IfRange.
+func EvaluatePreconditions(method string, h http.Header, v Validators) int {
+	lastModified := v.LastModified.Truncate(time.Second)
+	if im := h.Get("If-Match"); im != "" {
+		l, err := ParseEntityTagList(im)
+		if err != nil || !l.Any && (v.ETag == nil || !l.StrongMatch(*v.ETag)) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := h.Get("If-Unmodified-Since"); ius != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	safe := method == http.MethodGet || method == http.MethodHead
+	if inm := h.Get("If-None-Match"); inm != "" {
+		l, err := ParseEntityTagList(inm)
+		if err == nil && (l.Any || v.ETag != nil && l.WeakMatch(*v.ETag)) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := h.Get("If-Modified-Since"); ims != "" && safe && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// IfRange reports whether a Range field in h should be honoured: there is
+// no If-Range field, or it names the current representation. An entity-tag
+// must match strongly, and a date must equal the last modification time
+// exactly (RFC 9110 section 13.1.5).
+func IfRange(h http.Header, v Validators) bool {
+	ir := TrimOWS(h.Get("If-Range"))
+	if ir == "" {
+		return true
+	}
+	if ir[0] == '"' || len(ir) > 1 && ir[:2] == "W/" {
+		t, err := ParseEntityTag(ir)
+		return err == nil && v.ETag != nil && t.StrongMatch(*v.ETag)
+	}
+	t, err := http.ParseTime(ir)
+	return err == nil && !v.LastModified.IsZero() && v.LastModified.Truncate(time.Second).Equal(t)
+}
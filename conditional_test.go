@@ -0,0 +1,105 @@
+package httpfields
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+var (
+	testModified = time.Date(2024, 3, 1, 12, 0, 0, 500, time.UTC)
+	testModDate  = testModified.Format(http.TimeFormat)
+	testEarlier  = testModified.Add(-time.Hour).Format(http.TimeFormat)
+	testLater    = testModified.Add(time.Hour).Format(http.TimeFormat)
+)
+
+func TestEvaluatePreconditions(t *testing.T) {
+	strong := Validators{ETag: &EntityTag{Opaque: "v2"}, LastModified: testModified}
+	weak := Validators{ETag: &EntityTag{Weak: true, Opaque: "v2"}, LastModified: testModified}
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		v      Validators
+		want   int
+	}{
+		{"none", "GET", http.Header{}, strong, 0},
+
+		{"If-Match hit", "PUT", http.Header{"If-Match": {`"v1", "v2"`}}, strong, 0},
+		{"If-Match star", "PUT", http.Header{"If-Match": {"*"}}, strong, 0},
+		{"If-Match miss", "PUT", http.Header{"If-Match": {`"v1"`}}, strong, 412},
+		{"If-Match weak tag", "PUT", http.Header{"If-Match": {`W/"v2"`}}, strong, 412},
+		{"If-Match weak representation", "PUT", http.Header{"If-Match": {`"v2"`}}, weak, 412},
+		{"If-Match no etag", "PUT", http.Header{"If-Match": {`"v2"`}}, Validators{LastModified: testModified}, 412},
+		{"If-Match malformed", "PUT", http.Header{"If-Match": {"v2"}}, strong, 412},
+		// If-Match takes precedence over If-Unmodified-Since.
+		{"If-Match over If-Unmodified-Since", "PUT", http.Header{"If-Match": {`"v2"`}, "If-Unmodified-Since": {testEarlier}}, strong, 0},
+
+		{"If-Unmodified-Since same second", "PUT", http.Header{"If-Unmodified-Since": {testModDate}}, strong, 0},
+		{"If-Unmodified-Since later", "PUT", http.Header{"If-Unmodified-Since": {testLater}}, strong, 0},
+		{"If-Unmodified-Since earlier", "PUT", http.Header{"If-Unmodified-Since": {testEarlier}}, strong, 412},
+		{"If-Unmodified-Since malformed", "PUT", http.Header{"If-Unmodified-Since": {"yesterday"}}, strong, 0},
+		{"If-Unmodified-Since no date", "PUT", http.Header{"If-Unmodified-Since": {testEarlier}}, Validators{}, 0},
+
+		{"If-None-Match hit", "GET", http.Header{"If-None-Match": {`"v1", W/"v2"`}}, strong, 304},
+		{"If-None-Match weak hit", "HEAD", http.Header{"If-None-Match": {`"v2"`}}, weak, 304},
+		{"If-None-Match star", "GET", http.Header{"If-None-Match": {"*"}}, Validators{}, 304},
+		{"If-None-Match unsafe", "POST", http.Header{"If-None-Match": {"*"}}, strong, 412},
+		{"If-None-Match miss", "GET", http.Header{"If-None-Match": {`"v1"`}}, strong, 0},
+		{"If-None-Match malformed", "GET", http.Header{"If-None-Match": {"v2"}}, strong, 0},
+		// If-None-Match takes precedence over If-Modified-Since.
+		{"If-None-Match over If-Modified-Since", "GET", http.Header{"If-None-Match": {`"v1"`}, "If-Modified-Since": {testLater}}, strong, 0},
+
+		{"If-Modified-Since same second", "GET", http.Header{"If-Modified-Since": {testModDate}}, strong, 304},
+		{"If-Modified-Since later", "GET", http.Header{"If-Modified-Since": {testLater}}, strong, 304},
+		{"If-Modified-Since earlier", "GET", http.Header{"If-Modified-Since": {testEarlier}}, strong, 0},
+		{"If-Modified-Since unsafe", "POST", http.Header{"If-Modified-Since": {testLater}}, strong, 0},
+		{"If-Modified-Since no date", "GET", http.Header{"If-Modified-Since": {testLater}}, Validators{}, 0},
+
+		// A failed If-Match is not rescued by If-None-Match.
+		{"If-Match before If-None-Match", "GET", http.Header{"If-Match": {`"v1"`}, "If-None-Match": {`"v2"`}}, strong, 412},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EvaluatePreconditions(tt.method, tt.header, tt.v); got != tt.want {
+				t.Errorf("EvaluatePreconditions = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfRange(t *testing.T) {
+	strong := Validators{ETag: &EntityTag{Opaque: "v2"}, LastModified: testModified}
+	weak := Validators{ETag: &EntityTag{Weak: true, Opaque: "v2"}, LastModified: testModified}
+	tests := []struct {
+		name string
+		ir   string
+		v    Validators
+		want bool
+	}{
+		{"absent", "", strong, true},
+		{"etag match", `"v2"`, strong, true},
+		{"etag mismatch", `"v1"`, strong, false},
+		{"weak etag in field", `W/"v2"`, strong, false},
+		{"weak representation", `"v2"`, weak, false},
+		{"no etag", `"v2"`, Validators{LastModified: testModified}, false},
+		{"malformed etag", `"v2`, strong, false},
+		{"date match", testModDate, strong, true},
+		{"date with spaces", "  " + testModDate + " ", strong, true},
+		{"earlier date", testEarlier, strong, false},
+		{"later date", testLater, strong, false},
+		{"no date", testModDate, Validators{ETag: &EntityTag{Opaque: "v2"}}, false},
+		{"malformed date", "yesterday", strong, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			if tt.ir != "" {
+				h.Set("If-Range", tt.ir)
+			}
+			if got := IfRange(h, tt.v); got != tt.want {
+				t.Errorf("IfRange(%q) = %v, want %v", tt.ir, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package cookie models cookies the way RFC 6265bis user agents do: it parses
+// Set-Cookie fields into storage records and decides which records a browser
+// would attach to a request, taking SameSite and schemeful same-site rules
+// into account.
+package cookie
+
+import (
+	"strings"
+	"time"
+)
+
+// SameSite is the enforcement mode of the SameSite attribute.
+type SameSite int
+
+// SameSite modes. SameSiteDefault is used when the attribute is absent or has
+// an unrecognized value; browsers enforce it like SameSiteLax.
+const (
+	SameSiteDefault SameSite = iota
+	SameSiteNone
+	SameSiteLax
+	SameSiteStrict
+)
+
+func (s SameSite) String() string {
+	switch s {
+	case SameSiteNone:
+		return "None"
+	case SameSiteLax:
+		return "Lax"
+	case SameSiteStrict:
+		return "Strict"
+	}
+	return "Default"
+}
+
+// Cookie is a cookie storage record (RFC 6265bis section 5.7).
+type Cookie struct {
+	Name  string
+	Value string
+
+	// Domain is the canonicalized host the cookie belongs to. If HostOnly
+	// is set it is sent to that host only, otherwise also to subdomains.
+	Domain   string
+	HostOnly bool
+	Path     string
+
+	Secure      bool
+	HTTPOnly    bool
+	SameSite    SameSite
+	Partitioned bool
+
+	// PartitionKey is the site of the top-level document a Partitioned
+	// cookie was set under (CHIPS). It is assigned by the Jar; cookies
+	// with a partition key are only sent within the same partition.
+	PartitionKey string
+
+	// Expires is the expiry time of a persistent cookie. It is the zero
+	// time for session cookies.
+	Expires    time.Time
+	Persistent bool
+
+	Creation   time.Time
+	LastAccess time.Time
+}
+
+// Expired reports whether c has expired at now.
+func (c *Cookie) Expired(now time.Time) bool {
+	return c.Persistent && !c.Expires.After(now)
+}
+
+// String formats c as a cookie-pair, as sent in a Cookie field.
+func (c *Cookie) String() string {
+	if c.Name == "" {
+		return c.Value
+	}
+	return c.Name + "=" + c.Value
+}
+
+// Header formats cookies as the value of a Cookie field.
+func Header(cookies []*Cookie) string {
+	pairs := make([]string, len(cookies))
+	for i, c := range cookies {
+		pairs[i] = c.String()
+	}
+	return strings.Join(pairs, "; ")
+}
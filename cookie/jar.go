@@ -0,0 +1,268 @@
+package cookie
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Default jar limits, matching common browser behaviour.
+const (
+	DefaultMaxPerDomain    = 180
+	DefaultMaxPerPartition = 180
+	DefaultMaxTotal        = 3000
+)
+
+// JarOptions configures a Jar. A zero limit selects the default and a
+// negative one disables it.
+type JarOptions struct {
+	// MaxPerDomain bounds the unpartitioned cookies per registrable
+	// domain.
+	MaxPerDomain int
+
+	// MaxPerPartition bounds the partitioned cookies per registrable
+	// domain within one partition.
+	MaxPerPartition int
+
+	// MaxTotal bounds the number of cookies in the jar.
+	MaxTotal int
+
+	// Evaluator selects the cookies sent with requests.
+	Evaluator Evaluator
+
+	// Now returns the current time. It defaults to time.Now.
+	Now func() time.Time
+}
+
+// Jar is a cookie store following the RFC 6265bis storage model, including
+// cookie prefixes, SameSite and partitioned (CHIPS) cookies. It is safe for
+// concurrent use.
+//
+// Jar implements http.CookieJar for first-party use: every request is treated
+// as a top-level navigation. Use Partition to obtain a view for requests
+// made by a document embedded in another site, or Store and Select for full
+// control over the request context.
+type Jar struct {
+	opts JarOptions
+
+	mu      sync.Mutex
+	cookies map[cookieID]*Cookie
+}
+
+// cookieID identifies a cookie; storing a cookie with the same ID replaces
+// the previous one (RFC 6265bis section 5.7, extended by the partition key).
+type cookieID struct {
+	name, domain, path, partition string
+	hostOnly                      bool
+}
+
+func (c *Cookie) id() cookieID {
+	return cookieID{c.Name, c.Domain, c.Path, c.PartitionKey, c.HostOnly}
+}
+
+// NewJar returns an empty Jar.
+func NewJar(opts JarOptions) *Jar {
+	if opts.MaxPerDomain == 0 {
+		opts.MaxPerDomain = DefaultMaxPerDomain
+	}
+	if opts.MaxPerPartition == 0 {
+		opts.MaxPerPartition = DefaultMaxPerPartition
+	}
+	if opts.MaxTotal == 0 {
+		opts.MaxTotal = DefaultMaxTotal
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Jar{opts: opts, cookies: make(map[cookieID]*Cookie)}
+}
+
+// Store processes a Set-Cookie field received in response to req. It returns
+// the reason the cookie was ignored, if any. A cookie that is already expired
+// removes the stored cookie it would replace.
+func (j *Jar) Store(setCookie string, req Request) error {
+	now := j.opts.Now()
+	c, err := Parse(setCookie, req.URL, now)
+	if err != nil {
+		return err
+	}
+	sameSite := req.IsSameSiteRequest()
+	if c.SameSite != SameSiteNone && !sameSite && req.Navigation != TopLevelNavigation {
+		// RFC 6265bis section 5.7: cookies with SameSite enforcement
+		// cannot be set by cross-site subresource requests.
+		return ErrCrossSite
+	}
+	if c.Partitioned {
+		c.PartitionKey = req.PartitionKey()
+	} else if j.opts.Evaluator.BlockThirdParty && !sameSite && req.Navigation != TopLevelNavigation {
+		return ErrCrossSite
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !IsSecureScheme(req.URL.Scheme) && j.shadowsSecure(c) {
+		return ErrShadowsSecure
+	}
+	id := c.id()
+	if old, ok := j.cookies[id]; ok {
+		c.Creation = old.Creation
+	}
+	if c.Expired(now) {
+		delete(j.cookies, id)
+		return nil
+	}
+	j.cookies[id] = c
+	j.evict(c, now)
+	return nil
+}
+
+// shadowsSecure reports whether a cookie from an insecure origin would
+// overwrite or shadow a secure cookie (RFC 6265bis section 5.7, step 16).
+func (j *Jar) shadowsSecure(c *Cookie) bool {
+	for _, old := range j.cookies {
+		if old.Secure && old.Name == c.Name &&
+			(DomainMatch(old.Domain, c.Domain) || DomainMatch(c.Domain, old.Domain)) &&
+			PathMatch(c.Path, old.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// evict removes expired cookies and enforces the limits, removing the least
+// recently accessed cookies first. The cookie just stored is evicted last.
+func (j *Jar) evict(added *Cookie, now time.Time) {
+	for id, c := range j.cookies {
+		if c.Expired(now) {
+			delete(j.cookies, id)
+		}
+	}
+	domain := registrableDomain(added.Domain)
+	if added.PartitionKey == "" {
+		j.enforce(j.opts.MaxPerDomain, added, func(c *Cookie) bool {
+			return c.PartitionKey == "" && registrableDomain(c.Domain) == domain
+		})
+	} else {
+		j.enforce(j.opts.MaxPerPartition, added, func(c *Cookie) bool {
+			return c.PartitionKey == added.PartitionKey && registrableDomain(c.Domain) == domain
+		})
+	}
+	j.enforce(j.opts.MaxTotal, added, func(*Cookie) bool { return true })
+}
+
+func (j *Jar) enforce(limit int, added *Cookie, match func(*Cookie) bool) {
+	if limit < 0 {
+		return
+	}
+	var candidates []*Cookie
+	for _, c := range j.cookies {
+		if match(c) {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) <= limit {
+		return
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		if (candidates[a] == added) != (candidates[b] == added) {
+			return candidates[b] == added
+		}
+		return candidates[a].LastAccess.Before(candidates[b].LastAccess)
+	})
+	for _, c := range candidates[:len(candidates)-limit] {
+		delete(j.cookies, c.id())
+	}
+}
+
+// Select returns the cookies sent with req in serialization order and
+// updates their last access time.
+func (j *Jar) Select(req Request) []*Cookie {
+	now := j.opts.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	all := make([]*Cookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		all = append(all, c)
+	}
+	selected := j.opts.Evaluator.Send(all, req, now)
+	out := make([]*Cookie, len(selected))
+	for i, c := range selected {
+		c.LastAccess = now
+		cp := *c
+		out[i] = &cp
+	}
+	return out
+}
+
+// All returns copies of all unexpired cookies in the jar.
+func (j *Jar) All() []*Cookie {
+	now := j.opts.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var out []*Cookie
+	for _, c := range j.cookies {
+		if !c.Expired(now) {
+			cp := *c
+			out = append(out, &cp)
+		}
+	}
+	SortForRequest(out)
+	return out
+}
+
+// SetCookies implements http.CookieJar, treating the response as the result
+// of a top-level navigation to u.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.setCookies(Request{URL: u, Method: http.MethodGet, Navigation: TopLevelNavigation}, cookies)
+}
+
+// Cookies implements http.CookieJar, treating the request as a top-level
+// navigation to u.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	return toHTTP(j.Select(Request{URL: u, Method: http.MethodGet, Navigation: TopLevelNavigation}))
+}
+
+func (j *Jar) setCookies(req Request, cookies []*http.Cookie) {
+	for _, hc := range cookies {
+		raw := hc.Raw
+		if raw == "" {
+			raw = hc.String()
+		}
+		j.Store(raw, req)
+	}
+}
+
+// Partition returns an http.CookieJar for requests made by a document loaded
+// in a frame of the top-level document at topLevel. Requests through it are
+// subresource requests initiated by topLevel, so SameSite restrictions and
+// partitioning apply.
+func (j *Jar) Partition(topLevel *url.URL) http.CookieJar {
+	return partitionJar{jar: j, topLevel: topLevel}
+}
+
+type partitionJar struct {
+	jar      *Jar
+	topLevel *url.URL
+}
+
+func (p partitionJar) request(u *url.URL) Request {
+	return Request{URL: u, Method: http.MethodGet, Initiator: p.topLevel, TopLevel: p.topLevel}
+}
+
+func (p partitionJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	p.jar.setCookies(p.request(u), cookies)
+}
+
+func (p partitionJar) Cookies(u *url.URL) []*http.Cookie {
+	return toHTTP(p.jar.Select(p.request(u)))
+}
+
+func toHTTP(cookies []*Cookie) []*http.Cookie {
+	out := make([]*http.Cookie, len(cookies))
+	for i, c := range cookies {
+		out[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return out
+}
@@ -0,0 +1,271 @@
+package cookie
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartitionedJar(t *testing.T) {
+	jar := NewJar(JarOptions{})
+	embedded := mustURL(t, "https://widget.example/api")
+	siteA := mustURL(t, "https://a.example.com/")
+	siteB := mustURL(t, "https://b.example.org/")
+
+	embedReq := Request{URL: embedded, Method: "GET", Initiator: siteA, TopLevel: siteA}
+	if err := jar.Store("chips=1; Secure; SameSite=None; Partitioned; Path=/", embedReq); err != nil {
+		t.Fatal(err)
+	}
+	all := jar.All()
+	if len(all) != 1 || all[0].PartitionKey != "https://example.com" {
+		t.Fatalf("stored cookies: %+v", all)
+	}
+
+	if got := jar.Partition(siteA).Cookies(embedded); len(got) != 1 || got[0].Name != "chips" {
+		t.Errorf("same partition: %v", got)
+	}
+	// A top-level document on another subdomain of the same site shares
+	// the partition.
+	if got := jar.Partition(mustURL(t, "https://www.example.com/")).Cookies(embedded); len(got) != 1 {
+		t.Errorf("same-site top level: %v", got)
+	}
+	if got := jar.Partition(siteB).Cookies(embedded); len(got) != 0 {
+		t.Errorf("other partition: %v", got)
+	}
+	if got := jar.Cookies(embedded); len(got) != 0 {
+		t.Errorf("first-party context: %v", got)
+	}
+
+	// The same cookie set under another partition is stored separately.
+	if err := jar.Store("chips=2; Secure; SameSite=None; Partitioned; Path=/", Request{URL: embedded, Method: "GET", Initiator: siteB, TopLevel: siteB}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(jar.All()); n != 2 {
+		t.Errorf("%d cookies, want 2", n)
+	}
+	if got := jar.Partition(siteA).Cookies(embedded); len(got) != 1 || got[0].Value != "1" {
+		t.Errorf("partition A after storing in B: %v", got)
+	}
+
+	d := Evaluate(jar.All(), Request{URL: embedded, Method: "GET", Initiator: siteB, TopLevel: siteB}, time.Now())
+	var mismatch int
+	for _, dd := range d {
+		if dd.Reason == ExcludedPartitionMismatch {
+			mismatch++
+		}
+	}
+	if mismatch != 1 {
+		t.Errorf("decisions: %+v", d)
+	}
+}
+
+func TestPartitionLimit(t *testing.T) {
+	jar := NewJar(JarOptions{MaxPerPartition: 2})
+	embedded := mustURL(t, "https://widget.example/")
+	top := mustURL(t, "https://a.example.com/")
+	req := Request{URL: embedded, Method: "GET", Initiator: top, TopLevel: top}
+	for _, name := range []string{"a", "b", "c"} {
+		if err := jar.Store(name+"=1; Secure; SameSite=None; Partitioned", req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(jar.All()); n != 2 {
+		t.Errorf("%d cookies in partition, want 2", n)
+	}
+	// Unpartitioned cookies of the same domain are counted separately.
+	if err := jar.Store("d=1", Request{URL: embedded, Method: "GET", Navigation: TopLevelNavigation}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(jar.All()); n != 3 {
+		t.Errorf("%d cookies, want 3", n)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+	jar := NewJar(JarOptions{Now: clock})
+
+	first := Request{URL: mustURL(t, "https://www.example.com/a/page"), Method: "GET", Navigation: TopLevelNavigation}
+	top := mustURL(t, "https://a.example.com/")
+	embedded := Request{URL: mustURL(t, "https://widget.example/"), Method: "GET", Initiator: top, TopLevel: top}
+	for _, s := range []struct {
+		setCookie string
+		req       Request
+	}{
+		{"host=1; Max-Age=3600; SameSite=Lax", first},
+		{"domain=2; Domain=example.com; Path=/a; Secure; HttpOnly; SameSite=Strict; Max-Age=3600", first},
+		{"default=3; Max-Age=3600", first},
+		{"chips=4; Secure; SameSite=None; Partitioned; Max-Age=3600", embedded},
+		{"session=5; SameSite=Lax", first},
+		{"short=6; Max-Age=60", first},
+		{"gone=7; Max-Age=30", first},
+	} {
+		if err := jar.Store(s.setCookie, s.req); err != nil {
+			t.Fatalf("Store(%q) = %v", s.setCookie, err)
+		}
+	}
+
+	now = start.Add(45 * time.Second)
+	var buf bytes.Buffer
+	if err := jar.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.String()
+	for _, name := range []string{`"session"`, `"gone"`} {
+		if strings.Contains(saved, name) {
+			t.Errorf("saved %s: %s", name, saved)
+		}
+	}
+	if !strings.Contains(saved, `"version": 1`) {
+		t.Errorf("no version: %s", saved)
+	}
+
+	// Cookies that expire between saving and loading are skipped.
+	now = start.Add(2 * time.Minute)
+	loaded := NewJar(JarOptions{Now: clock})
+	if err := loaded.Load(strings.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	var want []*Cookie
+	for _, c := range jar.All() {
+		if c.Persistent && c.Name != "short" {
+			want = append(want, c)
+		}
+	}
+	got := loaded.All()
+	byNameOrder := func(cookies []*Cookie) {
+		sort.Slice(cookies, func(i, j int) bool { return cookies[i].Name < cookies[j].Name })
+	}
+	byNameOrder(got)
+	byNameOrder(want)
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d cookies, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("loaded %+v, want %+v", *got[i], *want[i])
+		}
+	}
+
+	byName := make(map[string]*Cookie)
+	for _, c := range got {
+		byName[c.Name] = c
+	}
+	if c := byName["host"]; c == nil || !c.HostOnly || c.Domain != "www.example.com" || c.SameSite != SameSiteLax {
+		t.Errorf("host-only cookie: %+v", c)
+	}
+	if c := byName["domain"]; c == nil || c.HostOnly || c.Path != "/a" || !c.Secure || !c.HTTPOnly || c.SameSite != SameSiteStrict {
+		t.Errorf("domain cookie: %+v", c)
+	}
+	if c := byName["default"]; c == nil || c.SameSite != SameSiteDefault {
+		t.Errorf("default cookie: %+v", c)
+	}
+	if c := byName["chips"]; c == nil || !c.Partitioned || c.PartitionKey != "https://example.com" || c.SameSite != SameSiteNone {
+		t.Errorf("partitioned cookie: %+v", c)
+	}
+
+	// The loaded partitioned cookie is only sent in its partition.
+	if got := loaded.Partition(top).Cookies(embedded.URL); len(got) != 1 || got[0].Name != "chips" {
+		t.Errorf("partitioned cookie after Load: %v", got)
+	}
+	if got := loaded.Cookies(embedded.URL); len(got) != 0 {
+		t.Errorf("partitioned cookie sent unpartitioned: %v", got)
+	}
+
+	// Loading replaces cookies with the same identity.
+	if err := loaded.Store("host=old; Max-Age=3600", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Load(strings.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(loaded.All()); n != len(want) {
+		t.Errorf("%d cookies after loading twice, want %d", n, len(want))
+	}
+	values := make(map[string]string)
+	for _, c := range loaded.Select(first) {
+		values[c.Name] = c.Value
+	}
+	if want := map[string]string{"host": "1", "domain": "2", "default": "3"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("cookies after reload: %v, want %v", values, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err string
+	}{
+		{`{"version": 2, "cookies": []}`, "cookie: unsupported jar format version 2"},
+		{`{"cookies": []}`, "cookie: unsupported jar format version 0"},
+		{`{"version": 1, "cookies": [`, "unexpected EOF"},
+		{``, "EOF"},
+	}
+	for _, tt := range tests {
+		jar := NewJar(JarOptions{})
+		err := jar.Load(strings.NewReader(tt.in))
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Load(%q) = %v, want %s", tt.in, err, tt.err)
+		}
+		if n := len(jar.All()); n != 0 {
+			t.Errorf("Load(%q) stored %d cookies", tt.in, n)
+		}
+	}
+}
+
+func TestLoadLimits(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cookie := func(name, domain, partition string, accessed time.Duration) persistedCookie {
+		return persistedCookie{
+			Name: name, Value: "1", Domain: domain, Path: "/", SameSite: "Lax",
+			Partitioned: partition != "", PartitionKey: partition,
+			Expires: now.Add(time.Hour), Creation: now.Add(-time.Hour), LastAccess: now.Add(-accessed),
+		}
+	}
+	doc := persistedJar{Version: persistVersion, Cookies: []persistedCookie{
+		cookie("a", "example.com", "", 3*time.Hour),
+		cookie("b", "www.example.com", "", time.Hour),
+		cookie("c", "example.com", "", 2*time.Hour),
+		cookie("p1", "widget.example", "https://a.example", time.Minute),
+		cookie("p2", "widget.example", "https://a.example", 2*time.Minute),
+		cookie("q1", "widget.example", "https://b.example", time.Minute),
+		cookie("other", "example.org", "", 4*time.Hour),
+	}}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		opts JarOptions
+		want []string
+	}{
+		{JarOptions{}, []string{"a", "b", "c", "other", "p1", "p2", "q1"}},
+		// The cookie being loaded is kept; among the others the least
+		// recently accessed goes first.
+		{JarOptions{MaxPerDomain: 2}, []string{"b", "c", "other", "p1", "p2", "q1"}},
+		{JarOptions{MaxPerPartition: 1}, []string{"a", "b", "c", "other", "p2", "q1"}},
+		{JarOptions{MaxTotal: 3}, []string{"other", "p1", "q1"}},
+	}
+	for _, tt := range tests {
+		tt.opts.Now = func() time.Time { return now }
+		jar := NewJar(tt.opts)
+		if err := jar.Load(bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, c := range jar.All() {
+			names = append(names, c.Name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tt.want) {
+			t.Errorf("Load with %+v kept %v, want %v", tt.opts, names, tt.want)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package cookie
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/palsivertsen/gohttpfields/internal/ascii"
+	"github.com/palsivertsen/gohttpfields/publicsuffix"
+)
+
+// Reasons for ignoring a Set-Cookie field, returned by Parse and Jar.Store.
+var (
+	ErrInvalidCharacters    = errors.New("cookie: control characters in field")
+	ErrEmpty                = errors.New("cookie: empty name and value")
+	ErrTooLarge             = errors.New("cookie: name and value exceed 4096 bytes")
+	ErrPublicSuffix         = errors.New("cookie: domain attribute is a public suffix")
+	ErrDomainMismatch       = errors.New("cookie: domain attribute does not match request host")
+	ErrInsecureOrigin       = errors.New("cookie: secure cookie set from insecure origin")
+	ErrSameSiteNoneInsecure = errors.New("cookie: SameSite=None requires Secure")
+	ErrPartitionedInsecure  = errors.New("cookie: Partitioned requires Secure")
+	ErrPrefix               = errors.New("cookie: name prefix requirements not met")
+	ErrCrossSite            = errors.New("cookie: cookie set in cross-site context")
+	ErrShadowsSecure        = errors.New("cookie: insecure origin would overwrite secure cookie")
+)
+
+// Limits from RFC 6265bis section 5.6.
+const (
+	maxNameValueSize = 4096
+	maxAttributeSize = 1024
+	maxAge           = 400 * 24 * time.Hour
+)
+
+// Parse processes a Set-Cookie field received in a response to requestURL at
+// time now, following the parsing and storage algorithms of RFC 6265bis
+// sections 5.6 and 5.7. It returns an error naming the reason the cookie
+// would be ignored by a user agent.
+func Parse(setCookie string, requestURL *url.URL, now time.Time) (*Cookie, error) {
+	for i := 0; i < len(setCookie); i++ {
+		if c := setCookie[i]; c < 0x20 && c != '\t' || c == 0x7f {
+			return nil, ErrInvalidCharacters
+		}
+	}
+	pair, attrs := setCookie, ""
+	if i := strings.IndexByte(setCookie, ';'); i >= 0 {
+		pair, attrs = setCookie[:i], setCookie[i+1:]
+	}
+	c := &Cookie{Creation: now, LastAccess: now}
+	if i := strings.IndexByte(pair, '='); i >= 0 {
+		c.Name, c.Value = trimWSP(pair[:i]), trimWSP(pair[i+1:])
+	} else {
+		c.Value = trimWSP(pair)
+	}
+	if c.Name == "" && c.Value == "" {
+		return nil, ErrEmpty
+	}
+	if len(c.Name)+len(c.Value) > maxNameValueSize {
+		return nil, ErrTooLarge
+	}
+
+	var (
+		domain        string
+		hasMaxAge     bool
+		maxAgeExpiry  time.Time
+		hasExpires    bool
+		expiresExpiry time.Time
+		pathSet       bool
+	)
+	for attrs != "" {
+		var av string
+		if i := strings.IndexByte(attrs, ';'); i >= 0 {
+			av, attrs = attrs[:i], attrs[i+1:]
+		} else {
+			av, attrs = attrs, ""
+		}
+		name, value := av, ""
+		if i := strings.IndexByte(av, '='); i >= 0 {
+			name, value = av[:i], av[i+1:]
+		}
+		name, value = trimWSP(name), trimWSP(value)
+		if len(value) > maxAttributeSize {
+			continue
+		}
+		switch strings.ToLower(name) {
+		case "expires":
+			if t, ok := parseCookieDate(value); ok {
+				hasExpires, expiresExpiry = true, t
+			}
+		case "max-age":
+			if d, ok := parseMaxAge(value); ok {
+				hasMaxAge = true
+				if d <= 0 {
+					maxAgeExpiry = time.Unix(0, 0)
+				} else {
+					maxAgeExpiry = now.Add(d)
+				}
+			}
+		case "domain":
+			if value != "" {
+				domain = strings.ToLower(strings.TrimPrefix(value, "."))
+			}
+		case "path":
+			if value != "" && value[0] == '/' {
+				c.Path, pathSet = value, true
+			} else {
+				c.Path, pathSet = "", false
+			}
+		case "secure":
+			c.Secure = true
+		case "httponly":
+			c.HTTPOnly = true
+		case "samesite":
+			c.SameSite = parseSameSite(value)
+		case "partitioned":
+			c.Partitioned = true
+		}
+	}
+
+	switch {
+	case hasMaxAge:
+		c.Persistent, c.Expires = true, maxAgeExpiry
+	case hasExpires:
+		c.Persistent, c.Expires = true, expiresExpiry
+	}
+	if c.Persistent && c.Expires.After(now.Add(maxAge)) {
+		c.Expires = now.Add(maxAge)
+	}
+
+	host := CanonicalHost(requestURL)
+	if domain != "" {
+		if publicsuffix.IsPublicSuffix(domain) {
+			if domain != host {
+				return nil, ErrPublicSuffix
+			}
+			domain = ""
+		}
+	}
+	if domain != "" {
+		if !DomainMatch(host, domain) {
+			return nil, ErrDomainMismatch
+		}
+		c.Domain = domain
+	} else {
+		c.Domain, c.HostOnly = host, true
+	}
+	if !pathSet {
+		c.Path = DefaultPath(requestURL.EscapedPath())
+	}
+
+	secureOrigin := IsSecureScheme(requestURL.Scheme)
+	if c.Secure && !secureOrigin {
+		return nil, ErrInsecureOrigin
+	}
+	if c.SameSite == SameSiteNone && !c.Secure {
+		return nil, ErrSameSiteNoneInsecure
+	}
+	if c.Partitioned && !c.Secure {
+		return nil, ErrPartitionedInsecure
+	}
+	if !checkPrefixes(c) {
+		return nil, ErrPrefix
+	}
+	return c, nil
+}
+
+// checkPrefixes enforces the __Secure- and __Host- name prefixes (RFC 6265bis
+// section 4.1.3). Prefixes are matched case-insensitively, and nameless
+// cookies whose value looks like a prefixed name are rejected.
+func checkPrefixes(c *Cookie) bool {
+	name := c.Name
+	if name == "" {
+		name = c.Value
+		if ascii.HasPrefixFold(name, "__Secure-") || ascii.HasPrefixFold(name, "__Host-") {
+			return false
+		}
+		return true
+	}
+	if ascii.HasPrefixFold(name, "__Secure-") && !c.Secure {
+		return false
+	}
+	if ascii.HasPrefixFold(name, "__Host-") && (!c.Secure || !c.HostOnly || c.Path != "/") {
+		return false
+	}
+	return true
+}
+
+func trimWSP(s string) string {
+	return strings.Trim(s, " \t")
+}
+
+func parseMaxAge(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	digits := s
+	if s[0] == '-' {
+		digits = s[1:]
+	}
+	if digits == "" {
+		return 0, false
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, false
+		}
+	}
+	if s[0] == '-' {
+		return 0, true
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || n > int64(maxAge/time.Second) {
+		return maxAge, true
+	}
+	return time.Duration(n) * time.Second, true
+}
+
+// CanonicalHost returns the lower-cased host of u without port and brackets.
+func CanonicalHost(u *url.URL) string {
+	return strings.ToLower(u.Hostname())
+}
+
+// IsSecureScheme reports whether scheme denotes a secure protocol.
+func IsSecureScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "https", "wss":
+		return true
+	}
+	return false
+}
+
+// DomainMatch reports whether host domain-matches domain (RFC 6265bis section
+// 5.1.3).
+func DomainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+// DefaultPath computes the default cookie path for a request path (RFC
+// 6265bis section 5.1.4).
+func DefaultPath(requestPath string) string {
+	if requestPath == "" || requestPath[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndexByte(requestPath, '/')
+	if i == 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
+
+// PathMatch reports whether requestPath path-matches cookiePath (RFC 6265bis
+// section 5.1.4).
+func PathMatch(requestPath, cookiePath string) bool {
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+var months = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+
+// parseCookieDate implements the lenient date algorithm of RFC 6265bis
+// section 5.1.1.
+func parseCookieDate(s string) (time.Time, bool) {
+	var (
+		foundTime, foundDay, foundMonth, foundYear bool
+		hour, minute, second, day, year            int
+		month                                      time.Month
+	)
+	for _, token := range strings.FieldsFunc(s, isDateDelimiter) {
+		if !foundTime {
+			if h, m, sec, ok := parseHMS(token); ok {
+				foundTime, hour, minute, second = true, h, m, sec
+				continue
+			}
+		}
+		if !foundDay {
+			if n, ok := leadingDigits(token, 1, 2); ok {
+				foundDay, day = true, n
+				continue
+			}
+		}
+		if !foundMonth && len(token) >= 3 {
+			prefix := strings.ToLower(token[:3])
+			for i, m := range months {
+				if prefix == m {
+					foundMonth, month = true, time.Month(i+1)
+					break
+				}
+			}
+			if foundMonth {
+				continue
+			}
+		}
+		if !foundYear {
+			if n, ok := leadingDigits(token, 2, 4); ok {
+				foundYear, year = true, n
+				continue
+			}
+		}
+	}
+	switch {
+	case year >= 70 && year <= 99:
+		year += 1900
+	case year >= 0 && year <= 69:
+		year += 2000
+	}
+	if !foundTime || !foundDay || !foundMonth || !foundYear ||
+		day < 1 || day > 31 || year < 1601 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, false
+	}
+	t := time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	if t.Day() != day {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func isDateDelimiter(r rune) bool {
+	switch {
+	case r == '\t', r >= 0x20 && r <= 0x2f, r >= 0x3b && r <= 0x40,
+		r >= 0x5b && r <= 0x60, r >= 0x7b && r <= 0x7e:
+		return true
+	}
+	return false
+}
+
+// leadingDigits parses between minDigits and maxDigits leading digits of
+// token, which must be followed by a non-digit or the end of the token.
+func leadingDigits(token string, minDigits, maxDigits int) (int, bool) {
+	i := 0
+	for i < len(token) && i <= maxDigits && token[i] >= '0' && token[i] <= '9' {
+		i++
+	}
+	if i < minDigits || i > maxDigits {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(token[:i])
+	return n, true
+}
+
+func parseHMS(token string) (h, m, s int, ok bool) {
+	parts := strings.SplitN(token, ":", 3)
+	if len(parts) != 3 {
+		return 0, 0, 0, false
+	}
+	var ok1, ok2, ok3 bool
+	h, ok1 = exactDigits(parts[0])
+	m, ok2 = exactDigits(parts[1])
+	s, ok3 = leadingDigits(parts[2], 1, 2)
+	return h, m, s, ok1 && ok2 && ok3
+}
+
+func exactDigits(s string) (int, bool) {
+	if len(s) < 1 || len(s) > 2 {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, _ := strconv.Atoi(s)
+	return n, true
+}
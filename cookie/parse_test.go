@@ -0,0 +1,93 @@
+package cookie
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func mustURL(t *testing.T, s string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestParseDomain(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		setCookie string
+		url       string
+		domain    string
+		hostOnly  bool
+		err       error
+	}{
+		{"a=b", "https://www.example.com/", "www.example.com", true, nil},
+		{"a=b; Domain=example.com", "https://www.example.com/", "example.com", false, nil},
+		{"a=b; Domain=.EXAMPLE.com", "https://www.example.com/", "example.com", false, nil},
+		{"a=b; Domain=other.com", "https://www.example.com/", "", false, ErrDomainMismatch},
+		{"a=b; Domain=ample.com", "https://example.com/", "", false, ErrDomainMismatch},
+		// Public suffixes are rejected, unless they equal the host, in
+		// which case the cookie becomes host-only.
+		{"a=b; Domain=com", "https://www.example.com/", "", false, ErrPublicSuffix},
+		{"a=b; Domain=co.uk", "https://www.example.co.uk/", "", false, ErrPublicSuffix},
+		{"a=b; Domain=github.io", "https://alice.github.io/", "", false, ErrPublicSuffix},
+		{"a=b; Domain=github.io", "https://github.io/", "github.io", true, nil},
+		{"a=b; Domain=alice.github.io", "https://www.alice.github.io/", "alice.github.io", false, nil},
+		{"a=b; Domain=b.ck", "https://a.b.ck/", "", false, ErrPublicSuffix},
+		{"a=b; Domain=www.ck", "https://a.www.ck/", "www.ck", false, nil},
+	}
+	for _, tt := range tests {
+		c, err := Parse(tt.setCookie, mustURL(t, tt.url), now)
+		if err != tt.err {
+			t.Errorf("Parse(%q) from %s: err = %v, want %v", tt.setCookie, tt.url, err, tt.err)
+			continue
+		}
+		if err == nil && (c.Domain != tt.domain || c.HostOnly != tt.hostOnly) {
+			t.Errorf("Parse(%q) from %s: domain %q host-only %v, want %q %v", tt.setCookie, tt.url, c.Domain, c.HostOnly, tt.domain, tt.hostOnly)
+		}
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		setCookie string
+		url       string
+		err       error
+	}{
+		{"a=b; Partitioned", "https://example.com/", ErrPartitionedInsecure},
+		{"a=b; Partitioned; Secure", "https://example.com/", nil},
+		{"a=b; Secure", "http://example.com/", ErrInsecureOrigin},
+		{"a=b; SameSite=None", "https://example.com/", ErrSameSiteNoneInsecure},
+		{"__Secure-a=b", "https://example.com/", ErrPrefix},
+		{"__secure-a=b; Secure", "https://example.com/", nil},
+		{"__Host-a=b; Secure; Path=/", "https://example.com/", nil},
+		{"__Host-a=b; Secure; Path=/; Domain=example.com", "https://example.com/", ErrPrefix},
+		{"__Host-a=b; Secure", "https://example.com/dir/page", ErrPrefix},
+		{"=__Host-a", "https://example.com/", ErrPrefix},
+		{"a=b\x01", "https://example.com/", ErrInvalidCharacters},
+		{"=", "https://example.com/", ErrEmpty},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.setCookie, mustURL(t, tt.url), now); err != tt.err {
+			t.Errorf("Parse(%q) from %s: err = %v, want %v", tt.setCookie, tt.url, err, tt.err)
+		}
+	}
+
+	c, err := Parse("a=b; Secure; HttpOnly; SameSite=Lax; Partitioned; Path=/x; Max-Age=60; Expires=Wed, 09 Jun 2021 10:18:14 GMT", mustURL(t, "https://example.com/"), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Secure || !c.HTTPOnly || c.SameSite != SameSiteLax || !c.Partitioned || c.Path != "/x" {
+		t.Errorf("attributes: %+v", c)
+	}
+	if !c.Persistent || !c.Expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("Max-Age does not take precedence over Expires: %v", c.Expires)
+	}
+	if c.PartitionKey != "" {
+		t.Errorf("Parse assigned partition key %q", c.PartitionKey)
+	}
+}
@@ -0,0 +1,114 @@
+package cookie
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// persistVersion is the version of the format written by Jar.Save.
+const persistVersion = 1
+
+type persistedJar struct {
+	Version int               `json:"version"`
+	Cookies []persistedCookie `json:"cookies"`
+}
+
+type persistedCookie struct {
+	Name         string    `json:"name"`
+	Value        string    `json:"value"`
+	Domain       string    `json:"domain"`
+	HostOnly     bool      `json:"hostOnly,omitempty"`
+	Path         string    `json:"path"`
+	Secure       bool      `json:"secure,omitempty"`
+	HTTPOnly     bool      `json:"httpOnly,omitempty"`
+	SameSite     string    `json:"sameSite"`
+	Partitioned  bool      `json:"partitioned,omitempty"`
+	PartitionKey string    `json:"partitionKey,omitempty"`
+	Expires      time.Time `json:"expires"`
+	Creation     time.Time `json:"creation"`
+	LastAccess   time.Time `json:"lastAccess"`
+}
+
+// Save writes the persistent cookies of the jar to w as JSON. Session cookies
+// are not saved, mirroring a browser restart.
+func (j *Jar) Save(w io.Writer) error {
+	doc := persistedJar{Version: persistVersion, Cookies: []persistedCookie{}}
+	for _, c := range j.All() {
+		if !c.Persistent {
+			continue
+		}
+		doc.Cookies = append(doc.Cookies, persistedCookie{
+			Name:         c.Name,
+			Value:        c.Value,
+			Domain:       c.Domain,
+			HostOnly:     c.HostOnly,
+			Path:         c.Path,
+			Secure:       c.Secure,
+			HTTPOnly:     c.HTTPOnly,
+			SameSite:     c.SameSite.String(),
+			Partitioned:  c.Partitioned,
+			PartitionKey: c.PartitionKey,
+			Expires:      c.Expires,
+			Creation:     c.Creation,
+			LastAccess:   c.LastAccess,
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Load adds the cookies saved by Save to the jar, replacing stored cookies
+// with the same identity. Expired cookies are skipped. Limits are enforced as
+// if the cookies had been stored in order.
+func (j *Jar) Load(r io.Reader) error {
+	var doc persistedJar
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return err
+	}
+	if doc.Version != persistVersion {
+		return fmt.Errorf("cookie: unsupported jar format version %d", doc.Version)
+	}
+	now := j.opts.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, p := range doc.Cookies {
+		c := &Cookie{
+			Name:         p.Name,
+			Value:        p.Value,
+			Domain:       p.Domain,
+			HostOnly:     p.HostOnly,
+			Path:         p.Path,
+			Secure:       p.Secure,
+			HTTPOnly:     p.HTTPOnly,
+			SameSite:     parseSameSite(p.SameSite),
+			Partitioned:  p.Partitioned,
+			PartitionKey: p.PartitionKey,
+			Expires:      p.Expires,
+			Persistent:   true,
+			Creation:     p.Creation,
+			LastAccess:   p.LastAccess,
+		}
+		if c.Expired(now) {
+			continue
+		}
+		j.cookies[c.id()] = c
+		j.evict(c, now)
+	}
+	return nil
+}
+
+func parseSameSite(s string) SameSite {
+	switch strings.ToLower(s) {
+	case "none":
+		return SameSiteNone
+	case "lax":
+		return SameSiteLax
+	case "strict":
+		return SameSiteStrict
+	}
+	return SameSiteDefault
+}
@@ -0,0 +1,259 @@
+package cookie
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Navigation classifies a request by how it was initiated.
+type Navigation int
+
+// Request classes.
+const (
+	// Subresource is a request for an image, script, fetch() and the like.
+	Subresource Navigation = iota
+	// TopLevelNavigation loads a document into a top-level browsing
+	// context, such as following a link or submitting a form.
+	TopLevelNavigation
+	// NestedNavigation loads a document into a frame.
+	NestedNavigation
+)
+
+// Request describes a request for which cookies are selected.
+type Request struct {
+	URL    *url.URL
+	Method string
+
+	// Initiator is the origin of the document that initiated the request.
+	// It is nil for browser-initiated requests, such as typing into the
+	// address bar or opening a bookmark.
+	Initiator *url.URL
+
+	Navigation Navigation
+
+	// TopLevel is the URL of the top-level document the request is made
+	// from. It is ignored for top-level navigations and defaults to
+	// Initiator otherwise.
+	TopLevel *url.URL
+
+	// Ancestors lists the URLs of the frames between the top-level
+	// document and the frame making the request.
+	Ancestors []*url.URL
+
+	// Redirects lists the URLs the request was redirected through before
+	// reaching URL, in order.
+	Redirects []*url.URL
+}
+
+// siteForCookies computes the "site for cookies" of the request's client
+// (RFC 6265bis section 5.2.1), returning nil if it is the null site.
+func (r *Request) siteForCookies() *url.URL {
+	if r.Navigation == TopLevelNavigation {
+		return r.URL
+	}
+	top := r.TopLevel
+	if top == nil {
+		top = r.Initiator
+	}
+	if top == nil {
+		return nil
+	}
+	for _, a := range r.Ancestors {
+		if !IsSameSite(a, top) {
+			return nil
+		}
+	}
+	return top
+}
+
+// PartitionKey returns the partition key of the request's context: the site
+// of the top-level document.
+func (r *Request) PartitionKey() string {
+	if r.Navigation == TopLevelNavigation {
+		return Site(r.URL)
+	}
+	top := r.TopLevel
+	if top == nil {
+		top = r.Initiator
+	}
+	if top == nil {
+		return Site(r.URL)
+	}
+	return Site(top)
+}
+
+// IsSameSiteRequest reports whether the request is same-site (RFC 6265bis
+// section 5.2): its client's site for cookies, every URL it was redirected
+// through and, if set, its initiator are all same-site with its URL.
+func (r *Request) IsSameSiteRequest() bool {
+	site := r.siteForCookies()
+	if site == nil || !IsSameSite(site, r.URL) {
+		return false
+	}
+	for _, u := range r.Redirects {
+		if !IsSameSite(u, site) {
+			return false
+		}
+	}
+	if r.Initiator != nil && !IsSameSite(r.Initiator, r.URL) {
+		return false
+	}
+	return true
+}
+
+// Reason explains a Decision.
+type Reason int
+
+// Reasons for including or excluding a cookie.
+const (
+	Included Reason = iota
+	ExcludedExpired
+	ExcludedDomainMismatch
+	ExcludedPathMismatch
+	ExcludedSecureOnly
+	ExcludedSameSiteStrict
+	ExcludedSameSiteLax
+	ExcludedSameSiteDefault
+	ExcludedPartitionMismatch
+	ExcludedThirdParty
+)
+
+var reasonNames = []string{
+	Included:                "included",
+	ExcludedExpired:         "expired",
+	ExcludedDomainMismatch:  "domain mismatch",
+	ExcludedPathMismatch:    "path mismatch",
+	ExcludedSecureOnly:      "secure only",
+	ExcludedSameSiteStrict:  "SameSite=Strict on cross-site request",
+	ExcludedSameSiteLax:     "SameSite=Lax on cross-site request",
+	ExcludedSameSiteDefault: "SameSite defaulted to Lax on cross-site request",
+
+	ExcludedPartitionMismatch: "partition mismatch",
+	ExcludedThirdParty:        "unpartitioned cookie in cross-site context",
+}
+
+func (r Reason) String() string {
+	if int(r) < len(reasonNames) {
+		return reasonNames[r]
+	}
+	return "Reason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// Decision records whether a cookie is sent and why.
+type Decision struct {
+	Cookie *Cookie
+	Reason Reason
+}
+
+// Send reports whether the cookie is sent.
+func (d Decision) Send() bool { return d.Reason == Included }
+
+// Evaluator selects cookies for requests. The zero value implements RFC
+// 6265bis without browser-specific relaxations.
+type Evaluator struct {
+	// LaxAllowingUnsafe, if positive, lets cookies without a SameSite
+	// attribute accompany cross-site top-level navigations with unsafe
+	// methods, such as POST, if they were created at most this long ago.
+	// Chrome uses two minutes.
+	LaxAllowingUnsafe time.Duration
+
+	// BlockThirdParty excludes unpartitioned cookies from requests made in
+	// a cross-site context, as browsers that phase out third-party cookies
+	// do. Partitioned cookies are unaffected.
+	BlockThirdParty bool
+}
+
+// Evaluate decides for each cookie whether it is attached to req at time
+// now, in the order of cookies.
+func (e Evaluator) Evaluate(cookies []*Cookie, req Request, now time.Time) []Decision {
+	sameSite := req.IsSameSiteRequest()
+	decisions := make([]Decision, len(cookies))
+	for i, c := range cookies {
+		decisions[i] = Decision{Cookie: c, Reason: e.reason(c, &req, sameSite, now)}
+	}
+	return decisions
+}
+
+func (e Evaluator) reason(c *Cookie, req *Request, sameSite bool, now time.Time) Reason {
+	host := CanonicalHost(req.URL)
+	switch {
+	case c.Expired(now):
+		return ExcludedExpired
+	case c.HostOnly && host != c.Domain, !c.HostOnly && !DomainMatch(host, c.Domain):
+		return ExcludedDomainMismatch
+	case !PathMatch(req.URL.EscapedPath(), c.Path):
+		return ExcludedPathMismatch
+	case c.Secure && !IsSecureScheme(req.URL.Scheme):
+		return ExcludedSecureOnly
+	case c.PartitionKey != "" && c.PartitionKey != req.PartitionKey():
+		return ExcludedPartitionMismatch
+	case e.BlockThirdParty && c.PartitionKey == "" && !sameSite && req.Navigation != TopLevelNavigation:
+		return ExcludedThirdParty
+	}
+	if sameSite {
+		return Included
+	}
+	laxAllowed := req.Navigation == TopLevelNavigation && isSafeMethod(req.Method)
+	switch c.SameSite {
+	case SameSiteStrict:
+		return ExcludedSameSiteStrict
+	case SameSiteLax:
+		if !laxAllowed {
+			return ExcludedSameSiteLax
+		}
+	case SameSiteDefault:
+		if laxAllowed {
+			break
+		}
+		if e.LaxAllowingUnsafe > 0 && req.Navigation == TopLevelNavigation && now.Sub(c.Creation) <= e.LaxAllowingUnsafe {
+			break
+		}
+		return ExcludedSameSiteDefault
+	}
+	return Included
+}
+
+// Send returns the cookies attached to req at time now in the order a user
+// agent serializes them: longer paths first, then earlier creation times.
+func (e Evaluator) Send(cookies []*Cookie, req Request, now time.Time) []*Cookie {
+	var out []*Cookie
+	for _, d := range e.Evaluate(cookies, req, now) {
+		if d.Send() {
+			out = append(out, d.Cookie)
+		}
+	}
+	SortForRequest(out)
+	return out
+}
+
+// Evaluate calls Evaluate on the zero Evaluator.
+func Evaluate(cookies []*Cookie, req Request, now time.Time) []Decision {
+	return Evaluator{}.Evaluate(cookies, req, now)
+}
+
+// Send calls Send on the zero Evaluator.
+func Send(cookies []*Cookie, req Request, now time.Time) []*Cookie {
+	return Evaluator{}.Send(cookies, req, now)
+}
+
+// SortForRequest sorts cookies by descending path length and then ascending
+// creation time (RFC 6265bis section 5.8.3).
+func SortForRequest(cookies []*Cookie) {
+	sort.SliceStable(cookies, func(i, j int) bool {
+		if len(cookies[i].Path) != len(cookies[j].Path) {
+			return len(cookies[i].Path) > len(cookies[j].Path)
+		}
+		return cookies[i].Creation.Before(cookies[j].Creation)
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,282 @@
+package cookie
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func sameSiteCookies(now time.Time) []*Cookie {
+	modes := []struct {
+		name string
+		mode SameSite
+	}{
+		{"strict", SameSiteStrict},
+		{"lax", SameSiteLax},
+		{"none", SameSiteNone},
+		{"default", SameSiteDefault},
+	}
+	cookies := make([]*Cookie, len(modes))
+	for i, m := range modes {
+		cookies[i] = &Cookie{
+			Name: m.name, Value: "1", Domain: "example.com", Path: "/",
+			SameSite: m.mode, Creation: now.Add(-time.Minute),
+		}
+	}
+	return cookies
+}
+
+func reasons(decisions []Decision) map[string]Reason {
+	m := make(map[string]Reason, len(decisions))
+	for _, d := range decisions {
+		m[d.Cookie.Name] = d.Reason
+	}
+	return m
+}
+
+func TestEvaluateSameSite(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	target := mustURL(t, "https://example.com/")
+	sameSite := mustURL(t, "https://www.example.com/")
+	crossSite := mustURL(t, "https://other.example/")
+
+	tests := []struct {
+		name string
+		req  Request
+		want map[string]Reason
+	}{
+		{
+			name: "browser-initiated navigation",
+			req:  Request{URL: target, Method: "GET", Navigation: TopLevelNavigation},
+			want: map[string]Reason{"strict": Included, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			name: "same-site subresource",
+			req:  Request{URL: target, Method: "POST", Initiator: sameSite},
+			want: map[string]Reason{"strict": Included, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			name: "cross-site navigation",
+			req:  Request{URL: target, Method: "GET", Initiator: crossSite, Navigation: TopLevelNavigation},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			name: "cross-site navigation with unsafe method",
+			req:  Request{URL: target, Method: "POST", Initiator: crossSite, Navigation: TopLevelNavigation},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": ExcludedSameSiteLax, "none": Included, "default": ExcludedSameSiteDefault},
+		},
+		{
+			name: "cross-site subresource",
+			req:  Request{URL: target, Method: "GET", Initiator: crossSite},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": ExcludedSameSiteLax, "none": Included, "default": ExcludedSameSiteDefault},
+		},
+		{
+			name: "cross-site frame navigation",
+			req:  Request{URL: target, Method: "GET", Initiator: crossSite, Navigation: NestedNavigation},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": ExcludedSameSiteLax, "none": Included, "default": ExcludedSameSiteDefault},
+		},
+		{
+			// Schemeful same-site: http and https of the same
+			// registrable domain are different sites.
+			name: "insecure initiator on the same domain",
+			req:  Request{URL: target, Method: "GET", Initiator: mustURL(t, "http://www.example.com/")},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": ExcludedSameSiteLax, "none": Included, "default": ExcludedSameSiteDefault},
+		},
+		{
+			name: "insecure navigation to the same domain",
+			req:  Request{URL: mustURL(t, "http://example.com/"), Method: "GET", Initiator: sameSite, Navigation: TopLevelNavigation},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			name: "secure websocket",
+			req:  Request{URL: mustURL(t, "wss://example.com/"), Method: "GET", Initiator: sameSite},
+			want: map[string]Reason{"strict": Included, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			name: "same-site frame of a cross-site page",
+			req:  Request{URL: target, Method: "GET", Initiator: sameSite, TopLevel: crossSite},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": ExcludedSameSiteLax, "none": Included, "default": ExcludedSameSiteDefault},
+		},
+		{
+			name: "cross-site ancestor frame",
+			req:  Request{URL: target, Method: "GET", Initiator: sameSite, TopLevel: sameSite, Ancestors: []*url.URL{crossSite}},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": ExcludedSameSiteLax, "none": Included, "default": ExcludedSameSiteDefault},
+		},
+		{
+			name: "same-site ancestor frames",
+			req:  Request{URL: target, Method: "GET", Initiator: sameSite, TopLevel: sameSite, Ancestors: []*url.URL{target, sameSite}},
+			want: map[string]Reason{"strict": Included, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			name: "same-site redirects",
+			req: Request{URL: target, Method: "GET", Initiator: sameSite, Navigation: TopLevelNavigation,
+				Redirects: []*url.URL{mustURL(t, "https://login.example.com/")}},
+			want: map[string]Reason{"strict": Included, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			// A cross-site hop makes the whole chain cross-site, even
+			// though it starts and ends on the same site.
+			name: "redirect through another site",
+			req: Request{URL: target, Method: "GET", Initiator: sameSite, Navigation: TopLevelNavigation,
+				Redirects: []*url.URL{mustURL(t, "https://sso.example.net/"), sameSite}},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": Included, "none": Included, "default": Included},
+		},
+		{
+			name: "subresource redirect through another site",
+			req: Request{URL: target, Method: "GET", Initiator: sameSite,
+				Redirects: []*url.URL{crossSite}},
+			want: map[string]Reason{"strict": ExcludedSameSiteStrict, "lax": ExcludedSameSiteLax, "none": Included, "default": ExcludedSameSiteDefault},
+		},
+	}
+	for _, tt := range tests {
+		got := reasons(Evaluate(sameSiteCookies(now), tt.req, now))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Evaluate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLaxAllowingUnsafe(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := Evaluator{LaxAllowingUnsafe: 2 * time.Minute}
+	target := mustURL(t, "https://example.com/")
+	crossSite := mustURL(t, "https://other.example/")
+
+	tests := []struct {
+		name       string
+		req        Request
+		mode       SameSite
+		age        time.Duration
+		want       Reason
+		wantStrict Reason // without LaxAllowingUnsafe
+	}{
+		{"unsafe navigation with new cookie", Request{URL: target, Method: "POST", Initiator: crossSite, Navigation: TopLevelNavigation},
+			SameSiteDefault, time.Minute, Included, ExcludedSameSiteDefault},
+		{"unsafe navigation at the limit", Request{URL: target, Method: "POST", Initiator: crossSite, Navigation: TopLevelNavigation},
+			SameSiteDefault, 2 * time.Minute, Included, ExcludedSameSiteDefault},
+		{"unsafe navigation with old cookie", Request{URL: target, Method: "POST", Initiator: crossSite, Navigation: TopLevelNavigation},
+			SameSiteDefault, 2*time.Minute + time.Second, ExcludedSameSiteDefault, ExcludedSameSiteDefault},
+		{"safe navigation with old cookie", Request{URL: target, Method: "GET", Initiator: crossSite, Navigation: TopLevelNavigation},
+			SameSiteDefault, time.Hour, Included, Included},
+		{"explicit Lax is not relaxed", Request{URL: target, Method: "POST", Initiator: crossSite, Navigation: TopLevelNavigation},
+			SameSiteLax, time.Minute, ExcludedSameSiteLax, ExcludedSameSiteLax},
+		{"unsafe subresource", Request{URL: target, Method: "POST", Initiator: crossSite},
+			SameSiteDefault, time.Minute, ExcludedSameSiteDefault, ExcludedSameSiteDefault},
+		{"unsafe frame navigation", Request{URL: target, Method: "PUT", Initiator: crossSite, Navigation: NestedNavigation},
+			SameSiteDefault, time.Minute, ExcludedSameSiteDefault, ExcludedSameSiteDefault},
+	}
+	for _, tt := range tests {
+		c := &Cookie{Name: "a", Domain: "example.com", Path: "/", SameSite: tt.mode, Creation: now.Add(-tt.age)}
+		if got := e.Evaluate([]*Cookie{c}, tt.req, now)[0].Reason; got != tt.want {
+			t.Errorf("%s: Reason = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := Evaluate([]*Cookie{c}, tt.req, now)[0].Reason; got != tt.wantStrict {
+			t.Errorf("%s: Reason without LaxAllowingUnsafe = %v, want %v", tt.name, got, tt.wantStrict)
+		}
+	}
+}
+
+func TestBlockThirdParty(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := Evaluator{BlockThirdParty: true}
+	widget := mustURL(t, "https://widget.example/")
+	top := mustURL(t, "https://a.example.com/")
+	cookies := []*Cookie{
+		{Name: "tracker", Domain: "widget.example", Path: "/", Secure: true, SameSite: SameSiteNone},
+		{Name: "chips", Domain: "widget.example", Path: "/", Secure: true, SameSite: SameSiteNone,
+			Partitioned: true, PartitionKey: "https://example.com"},
+		{Name: "other-chips", Domain: "widget.example", Path: "/", Secure: true, SameSite: SameSiteNone,
+			Partitioned: true, PartitionKey: "https://example.org"},
+	}
+	tests := []struct {
+		name string
+		req  Request
+		want map[string]Reason
+	}{
+		{
+			name: "embedded",
+			req:  Request{URL: widget, Method: "GET", Initiator: top, TopLevel: top},
+			want: map[string]Reason{"tracker": ExcludedThirdParty, "chips": Included, "other-chips": ExcludedPartitionMismatch},
+		},
+		{
+			name: "cross-site navigation",
+			req:  Request{URL: widget, Method: "GET", Initiator: top, Navigation: TopLevelNavigation},
+			want: map[string]Reason{"tracker": Included, "chips": ExcludedPartitionMismatch, "other-chips": ExcludedPartitionMismatch},
+		},
+		{
+			name: "first party",
+			req:  Request{URL: widget, Method: "GET", Initiator: widget},
+			want: map[string]Reason{"tracker": Included, "chips": ExcludedPartitionMismatch, "other-chips": ExcludedPartitionMismatch},
+		},
+	}
+	for _, tt := range tests {
+		if got := reasons(e.Evaluate(cookies, tt.req, now)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Evaluate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	embedded := Request{URL: widget, Method: "GET", Initiator: top, TopLevel: top}
+	if got := reasons(Evaluate(cookies, embedded, now)); got["tracker"] != Included {
+		t.Errorf("without BlockThirdParty: %v", got)
+	}
+}
+
+func TestEvaluateReasons(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	req := Request{URL: mustURL(t, "http://www.example.com/docs/page"), Method: "GET", Navigation: TopLevelNavigation}
+	tests := []struct {
+		c    Cookie
+		want Reason
+	}{
+		{Cookie{Domain: "example.com", Path: "/"}, Included},
+		{Cookie{Domain: "www.example.com", HostOnly: true, Path: "/docs"}, Included},
+		{Cookie{Domain: "example.com", Path: "/", Persistent: true, Expires: now.Add(time.Second)}, Included},
+		{Cookie{Domain: "example.com", Path: "/", Persistent: true, Expires: now}, ExcludedExpired},
+		{Cookie{Domain: "example.com", HostOnly: true, Path: "/"}, ExcludedDomainMismatch},
+		{Cookie{Domain: "other.com", Path: "/"}, ExcludedDomainMismatch},
+		{Cookie{Domain: "example.com", Path: "/doc"}, ExcludedPathMismatch},
+		{Cookie{Domain: "example.com", Path: "/docs/page/more"}, ExcludedPathMismatch},
+		{Cookie{Domain: "example.com", Path: "/", Secure: true}, ExcludedSecureOnly},
+		{Cookie{Domain: "example.com", Path: "/", Partitioned: true, PartitionKey: "https://example.org"}, ExcludedPartitionMismatch},
+		{Cookie{Domain: "example.com", Path: "/", Partitioned: true, PartitionKey: "http://example.com"}, Included},
+	}
+	for _, tt := range tests {
+		c := tt.c
+		c.Name = "a"
+		d := Evaluate([]*Cookie{&c}, req, now)[0]
+		if d.Reason != tt.want || d.Send() != (tt.want == Included) || d.Cookie != &c {
+			t.Errorf("Evaluate(%+v) = %v, want %v", tt.c, d.Reason, tt.want)
+		}
+	}
+
+	names := map[Reason]string{
+		Included:                "included",
+		ExcludedSameSiteDefault: "SameSite defaulted to Lax on cross-site request",
+		ExcludedThirdParty:      "unpartitioned cookie in cross-site context",
+		Reason(99):              "Reason(99)",
+	}
+	for r, want := range names {
+		if got := r.String(); got != want {
+			t.Errorf("Reason(%d).String() = %q, want %q", int(r), got, want)
+		}
+	}
+}
+
+func TestSendOrder(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cookies := []*Cookie{
+		{Name: "root-new", Domain: "example.com", Path: "/", Creation: now.Add(-time.Minute)},
+		{Name: "strict", Domain: "example.com", Path: "/a/b", SameSite: SameSiteStrict, Creation: now},
+		{Name: "deep", Domain: "example.com", Path: "/a/b", Creation: now.Add(-time.Minute)},
+		{Name: "root-old", Domain: "example.com", Path: "/", Creation: now.Add(-time.Hour)},
+		{Name: "mid", Domain: "example.com", Path: "/a", Creation: now},
+	}
+	req := Request{URL: mustURL(t, "https://example.com/a/b/c"), Method: "GET", Navigation: TopLevelNavigation}
+	if got := Header(Send(cookies, req, now)); got != "deep=; strict=; mid=; root-old=; root-new=" {
+		t.Errorf("same-site Send = %q", got)
+	}
+	req.Initiator = mustURL(t, "https://other.example/")
+	if got := Header(Send(cookies, req, now)); got != "deep=; mid=; root-old=; root-new=" {
+		t.Errorf("cross-site Send = %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+package cookie
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/palsivertsen/gohttpfields/publicsuffix"
+)
+
+// Site returns the schemeful site of u (RFC 6265bis section 5.2): the scheme,
+// with "ws" and "wss" normalized to "http" and "https", followed by the
+// registrable domain of the host. Hosts without a registrable domain, such as
+// IP addresses and public suffixes, are used as is. URLs without a host, such
+// as data: URLs, have an opaque origin and an empty site.
+func Site(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	host := CanonicalHost(u)
+	if host == "" {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	switch scheme {
+	case "ws":
+		scheme = "http"
+	case "wss":
+		scheme = "https"
+	}
+	return scheme + "://" + registrableDomain(host)
+}
+
+func registrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if d, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return d
+	}
+	return host
+}
+
+// IsSameSite reports whether a and b are schemefully same-site. URLs with an
+// opaque origin are never same-site.
+func IsSameSite(a, b *url.URL) bool {
+	s := Site(a)
+	return s != "" && s == Site(b)
+}
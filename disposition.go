@@ -0,0 +1,87 @@
+package httpfields
+
+import (
+	"strings"
+
+	"github.com/palsivertsen/gohttpfields/encword"
+)
+
+// Disposition types defined by RFC 6266 and RFC 7578.
+const (
+	DispositionInline     = "inline"
+	DispositionAttachment = "attachment"
+	DispositionFormData   = "form-data"
+)
+
+// ContentDisposition is a parsed Content-Disposition field value (RFC 6266,
+// RFC 7578 section 4.2).
+type ContentDisposition struct {
+	Type string // lower-cased disposition type, such as "form-data"
+
+	// Params holds the parameters keyed by lower-cased name. Extended
+	// parameters such as filename* are decoded and stored under their plain
+	// name.
+	Params map[string]string
+}
+
+// ParseContentDisposition parses a Content-Disposition field value.
+func ParseContentDisposition(s string) (ContentDisposition, error) {
+	d, err := parseContentDisposition(s)
+	if err != nil {
+		return ContentDisposition{}, &ParseError{Field: "Content-Disposition", Value: s, Err: err}
+	}
+	return d, nil
+}
+
+func parseContentDisposition(s string) (ContentDisposition, error) {
+	var d ContentDisposition
+	typ, rest := consumeToken(skipOWS(s))
+	if typ == "" {
+		return d, errExpectedToken
+	}
+	params, err := parseParams(rest)
+	if err != nil {
+		return d, err
+	}
+	d.Type = strings.ToLower(typ)
+	d.Params = params
+	return d, nil
+}
+
+// Name returns the name parameter, which identifies the form field of a
+// multipart/form-data part.
+func (d ContentDisposition) Name() string {
+	return d.Params["name"]
+}
+
+// RawFilename returns the filename parameter exactly as sent.
+func (d ContentDisposition) RawFilename() string {
+	return d.Params["filename"]
+}
+
+// Filename returns the last path element of the filename parameter. Both
+// slashes and backslashes are treated as separators, and the names "." and
+// ".." are reported as empty, so the result is safe to use as a file name.
+// RFC 2047 encoded-words, which legacy senders use instead of filename*,
+// are decoded first unless the encoded-word cannot be decoded.
+func (d ContentDisposition) Filename() string {
+	name := d.RawFilename()
+	if decoded, err := encword.Decode(name); err == nil {
+		name = decoded
+	}
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "." || name == ".." {
+		return ""
+	}
+	return name
+}
+
+// String formats d as a field value.
+func (d ContentDisposition) String() string {
+	var b strings.Builder
+	b.WriteString(d.Type)
+	writeParams(&b, d.Params)
+	return b.String()
+}
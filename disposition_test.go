@@ -0,0 +1,68 @@
+package httpfields
+
+import (
+	"testing"
+)
+
+func TestParseContentDisposition(t *testing.T) {
+	d, err := ParseContentDisposition(`Form-Data; Name="file"; filename="a b.txt"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Type != DispositionFormData || d.Name() != "file" || d.RawFilename() != "a b.txt" {
+		t.Errorf("ParseContentDisposition = %+v", d)
+	}
+	if got, want := d.String(), `form-data; filename="a b.txt"; name=file`; got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+
+	for _, s := range []string{"", "; filename=a", `attachment; filename="a`, "attachment; filename"} {
+		_, err := ParseContentDisposition(s)
+		if pe, ok := err.(*ParseError); !ok || pe.Field != "Content-Disposition" {
+			t.Errorf("ParseContentDisposition(%q) = %v, want *ParseError", s, err)
+		}
+	}
+}
+
+func TestFilename(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`attachment; filename="report.pdf"`, "report.pdf"},
+		{`attachment; filename="../../etc/passwd"`, "passwd"},
+		{`attachment; filename="C:\\Users\\me\\evil.exe"`, "evil.exe"},
+		{`attachment; filename=".."`, ""},
+		{`attachment; filename="dir/."`, ""},
+		{`attachment; filename="dir/"`, ""},
+		{"attachment", ""},
+
+		// RFC 8187 and RFC 2231 values are decoded by the parameter parser.
+		{"attachment; filename*=UTF-8''%E2%82%AC%20rates.txt", "€ rates.txt"},
+		{"attachment; filename*=UTF-8''%2E%2E%2Fsecret", "secret"},
+		{"attachment; filename*0*=UTF-8''..%2F; filename*1=x.txt", "x.txt"},
+
+		// RFC 2047 encoded-words are decoded before the path is stripped,
+		// so encoded separators cannot smuggle in a directory.
+		{`attachment; filename="=?UTF-8?Q?Gr=C3=BC=C3=9Fe.txt?="`, "Grüße.txt"},
+		{`attachment; filename="=?ISO-8859-1?B?R3L832UudHh0?="`, "Grüße.txt"},
+		{`attachment; filename="=?utf-8?q?..=2F..=2Fetc=2Fpasswd?="`, "passwd"},
+		{`attachment; filename="=?utf-8?q?C:=5Cevil.exe?="`, "evil.exe"},
+		{`attachment; filename="=?utf-8?q?=2E=2E?="`, ""},
+		{`attachment; filename="=?utf-8?b?Li4vYS50eHQ=?= =?utf-8?b?Lw==?="`, ""},
+		{`attachment; filename="=?utf-8?q?a?= =?utf-8?q?b.txt?="`, "ab.txt"},
+		// Undecodable encoded-words are kept as sent, still sanitized.
+		{`attachment; filename="../=?koi8-r?q?a?="`, "=?koi8-r?q?a?="},
+		{`attachment; filename="x/=?utf-8?q?=FF?="`, "=?utf-8?q?=FF?="},
+	}
+	for _, tt := range tests {
+		d, err := ParseContentDisposition(tt.in)
+		if err != nil {
+			t.Errorf("ParseContentDisposition(%q): %v", tt.in, err)
+			continue
+		}
+		if got := d.Filename(); got != tt.want {
+			t.Errorf("Filename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
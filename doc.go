@@ -0,0 +1,7 @@
+// Package httpfields provides typed parsing and formatting of HTTP field
+// values.
+//
+// The root package holds the field types shared between protocols, such as
+// media types and content dispositions, together with the lexical helpers
+// used to read them. Protocol specific functionality lives in subpackages.
+package httpfields
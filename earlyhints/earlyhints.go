@@ -0,0 +1,307 @@
+// Package earlyhints sends 103 (Early Hints) informational responses (RFC
+// 8297) carrying Link fields that let clients preload and preconnect while
+// the final response is being generated.
+//
+// Wrap a handler with Middleware and call Send from it:
+//
+//	earlyhints.Send(w, r, earlyhints.Preload("/app.css", "style"),
+//		earlyhints.Preconnect("https://cdn.example.com"))
+//
+// Only the Link field and the fields in Writer.Allowed are sent in the
+// informational response; everything else the handler has set so far is
+// held back for the final response.
+//
+// net/http sends informational responses from Go 1.19 on. Built with an
+// older toolchain, no 103 responses are written and the hints only appear
+// in the Link field of the final response.
+package earlyhints
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// statusEarlyHints is the 103 status code of RFC 8297.
+const statusEarlyHints = 103
+
+// Relation types commonly sent in early hints.
+const (
+	RelPreload       = "preload"
+	RelModulePreload = "modulepreload"
+	RelPreconnect    = "preconnect"
+)
+
+// CORS settings for the crossorigin attribute.
+const (
+	CrossOriginAnonymous      = "anonymous"
+	CrossOriginUseCredentials = "use-credentials"
+)
+
+var (
+	// ErrMissingAs is returned for preload hints without a destination,
+	// which clients ignore.
+	ErrMissingAs = errors.New("earlyhints: preload hint without as")
+	// ErrInvalidHint is returned for hints without a URL or with
+	// unknown crossorigin or fetchpriority values.
+	ErrInvalidHint = errors.New("earlyhints: invalid hint")
+)
+
+// Hint is a resource the client may fetch or connect to early.
+type Hint struct {
+	URL           string
+	Rel           string // RelPreload when empty
+	As            string // request destination such as "style" or "script"
+	Type          string // media type, letting clients skip unsupported formats
+	CrossOrigin   string // empty, CrossOriginAnonymous or CrossOriginUseCredentials
+	FetchPriority string // empty, "high", "low" or "auto"
+	NoPush        bool   // ask HTTP/2 servers not to push the resource
+}
+
+// Preload returns a hint to preload url as the destination as.
+func Preload(url, as string) Hint {
+	return Hint{URL: url, Rel: RelPreload, As: as}
+}
+
+// Preconnect returns a hint to open a connection to origin.
+func Preconnect(origin string) Hint {
+	return Hint{URL: origin, Rel: RelPreconnect}
+}
+
+// Validate checks h for values clients would reject.
+func (h Hint) Validate() error {
+	if h.URL == "" || strings.ContainsAny(h.URL, "<> \t\r\n") {
+		return ErrInvalidHint
+	}
+	switch h.CrossOrigin {
+	case "", CrossOriginAnonymous, CrossOriginUseCredentials:
+	default:
+		return ErrInvalidHint
+	}
+	switch h.FetchPriority {
+	case "", "high", "low", "auto":
+	default:
+		return ErrInvalidHint
+	}
+	if (h.Rel == "" || h.Rel == RelPreload) && h.As == "" {
+		return ErrMissingAs
+	}
+	return nil
+}
+
+// Link returns h as a link. An anonymous crossorigin setting is written
+// without a value, as browsers expect.
+func (h Hint) Link() httpfields.Link {
+	l := httpfields.Link{Target: h.URL, Rel: h.Rel, Params: make(map[string]string)}
+	if l.Rel == "" {
+		l.Rel = RelPreload
+	}
+	set := func(name, value string) {
+		if value != "" {
+			l.Params[name] = value
+		}
+	}
+	set("as", h.As)
+	set("type", h.Type)
+	set("fetchpriority", h.FetchPriority)
+	switch h.CrossOrigin {
+	case CrossOriginAnonymous:
+		l.Params["crossorigin"] = ""
+	case CrossOriginUseCredentials:
+		l.Params["crossorigin"] = CrossOriginUseCredentials
+	}
+	if h.NoPush {
+		l.Params["nopush"] = ""
+	}
+	return l
+}
+
+// DefaultAllowed lists the fields besides Link that are sent in early
+// hints by default: they govern how the hinted resources are fetched.
+var DefaultAllowed = []string{"Content-Security-Policy", "Referrer-Policy"}
+
+// Writer is an http.ResponseWriter that sends early hints. It remembers
+// the links it has sent, never hints a link twice and, unless
+// OmitFromFinal is set, repeats them once in the Link field of the final
+// response for clients that ignore informational responses.
+type Writer struct {
+	// Allowed lists the fields besides Link that may be sent early,
+	// defaulting to DefaultAllowed.
+	Allowed []string
+
+	// OmitFromFinal stops hinted links from being added to the final
+	// response.
+	OmitFromFinal bool
+
+	w       http.ResponseWriter
+	enabled bool
+	sent    []httpfields.Link
+	seen    map[string]bool
+	final   bool
+}
+
+// NewWriter wraps w for the response to r. Early hints are not sent to
+// HTTP/1.0 clients, as RFC 8297 section 3 requires, nor by toolchains
+// whose net/http cannot write informational responses.
+func NewWriter(w http.ResponseWriter, r *http.Request) *Writer {
+	return &Writer{
+		w:       w,
+		enabled: informationalSupported && r.ProtoAtLeast(1, 1),
+		seen:    make(map[string]bool),
+	}
+}
+
+// Middleware wraps the ResponseWriter passed to next in a Writer, so that
+// Send can be used by the handler.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(NewWriter(w, r), r)
+	})
+}
+
+// Send writes a 103 response with the hints not sent before. It does
+// nothing once the final response has started or when all hints were
+// already sent. If any hint is invalid, none are sent.
+func (ew *Writer) Send(hints ...Hint) error {
+	for _, h := range hints {
+		if err := h.Validate(); err != nil {
+			return err
+		}
+	}
+	var links []httpfields.Link
+	for _, h := range hints {
+		l := h.Link()
+		key := l.String()
+		if ew.seen[key] {
+			continue
+		}
+		ew.seen[key] = true
+		links = append(links, l)
+	}
+	if len(links) == 0 || ew.final {
+		return nil
+	}
+	ew.sent = append(ew.sent, links...)
+	if !ew.enabled {
+		return nil
+	}
+
+	// WriteHeader(103) sends the whole header map, so swap in the early
+	// fields and restore the handler's fields afterwards.
+	header := ew.w.Header()
+	saved := make(http.Header, len(header))
+	for name, values := range header {
+		saved[name] = values
+		delete(header, name)
+	}
+	allowed := ew.Allowed
+	if allowed == nil {
+		allowed = DefaultAllowed
+	}
+	for _, name := range allowed {
+		name = http.CanonicalHeaderKey(name)
+		if v, ok := saved[name]; ok && name != "Link" {
+			header[name] = v
+		}
+	}
+	header.Set("Link", httpfields.FormatLinks(links))
+	ew.w.WriteHeader(statusEarlyHints)
+	for name := range header {
+		delete(header, name)
+	}
+	for name, values := range saved {
+		header[name] = values
+	}
+	return nil
+}
+
+// Sent returns the links hinted so far.
+func (ew *Writer) Sent() []httpfields.Link {
+	return ew.sent
+}
+
+// Header returns the header map of the final response.
+func (ew *Writer) Header() http.Header {
+	return ew.w.Header()
+}
+
+// WriteHeader starts the final response. Unless OmitFromFinal is set,
+// hinted links missing from its Link field are appended, and duplicate
+// links in the field are removed. Informational status codes are passed
+// through unchanged.
+func (ew *Writer) WriteHeader(status int) {
+	if status < 200 || ew.final {
+		ew.w.WriteHeader(status)
+		return
+	}
+	ew.final = true
+	ew.mergeLinks()
+	ew.w.WriteHeader(status)
+}
+
+// Write writes the body of the final response.
+func (ew *Writer) Write(b []byte) (int, error) {
+	if !ew.final {
+		ew.WriteHeader(http.StatusOK)
+	}
+	return ew.w.Write(b)
+}
+
+// Flush sends buffered data to the client if the underlying writer
+// supports it.
+func (ew *Writer) Flush() {
+	if !ew.final {
+		ew.WriteHeader(http.StatusOK)
+	}
+	if f, ok := ew.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter. http.ResponseController,
+// available from Go 1.20, uses it to reach the connection's features.
+func (ew *Writer) Unwrap() http.ResponseWriter {
+	return ew.w
+}
+
+func (ew *Writer) mergeLinks() {
+	header := ew.w.Header()
+	existing, err := httpfields.ParseLinks(header["Link"]...)
+	if err != nil {
+		// Leave fields we cannot parse as the handler wrote them.
+		return
+	}
+	links := existing
+	if !ew.OmitFromFinal {
+		links = append(links, ew.sent...)
+	}
+	if len(links) == 0 {
+		return
+	}
+	seen := make(map[string]bool, len(links))
+	var merged []httpfields.Link
+	for _, l := range links {
+		key := l.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, l)
+	}
+	header.Set("Link", httpfields.FormatLinks(merged))
+}
+
+// Send sends hints in the response to r on w, which should be a Writer
+// installed by Middleware or NewWriter. For other writers the hints are sent
+// without deduplication and are not repeated in the final response; as with
+// NewWriter, nothing is sent to HTTP/1.0 clients.
+func Send(w http.ResponseWriter, r *http.Request, hints ...Hint) error {
+	if ew, ok := w.(*Writer); ok {
+		return ew.Send(hints...)
+	}
+	ew := NewWriter(w, r)
+	ew.OmitFromFinal = true
+	return ew.Send(hints...)
+}
@@ -0,0 +1,320 @@
+package earlyhints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"reflect"
+	"testing"
+)
+
+// recorder records the header of every informational response and of the
+// final response.
+type recorder struct {
+	header        http.Header
+	informational []http.Header
+	status        int
+	final         http.Header
+}
+
+func newRecorder() *recorder { return &recorder{header: make(http.Header)} }
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(status int) {
+	snapshot := make(http.Header, len(r.header))
+	for name, values := range r.header {
+		snapshot[name] = append([]string(nil), values...)
+	}
+	if status < 200 {
+		r.informational = append(r.informational, snapshot)
+		return
+	}
+	r.status, r.final = status, snapshot
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.final == nil {
+		r.WriteHeader(http.StatusOK)
+	}
+	return len(b), nil
+}
+
+func newTestWriter(t *testing.T) (*Writer, *recorder) {
+	t.Helper()
+	if !informationalSupported {
+		t.Skip("net/http cannot send informational responses")
+	}
+	rec := newRecorder()
+	return NewWriter(rec, httptest.NewRequest("GET", "/", nil)), rec
+}
+
+func TestHint(t *testing.T) {
+	tests := []struct {
+		hint Hint
+		link string
+		err  error
+	}{
+		{Preload("/app.css", "style"), "</app.css>; rel=preload; as=style", nil},
+		{Hint{URL: "/app.js", As: "script"}, "</app.js>; rel=preload; as=script", nil},
+		{Preconnect("https://cdn.example.com"), "<https://cdn.example.com>; rel=preconnect", nil},
+		{
+			Hint{URL: "/font.woff2", As: "font", Type: "font/woff2", CrossOrigin: CrossOriginAnonymous, FetchPriority: "high", NoPush: true},
+			"</font.woff2>; rel=preload; as=font; crossorigin; fetchpriority=high; nopush; type=\"font/woff2\"",
+			nil,
+		},
+		{
+			Hint{URL: "/m.js", Rel: RelModulePreload, CrossOrigin: CrossOriginUseCredentials},
+			"</m.js>; rel=modulepreload; crossorigin=use-credentials",
+			nil,
+		},
+		{Hint{URL: "/app.css"}, "", ErrMissingAs},
+		{Hint{URL: "/app.css", Rel: RelPreload}, "", ErrMissingAs},
+		{Hint{As: "style"}, "", ErrInvalidHint},
+		{Preload("/a b.css", "style"), "", ErrInvalidHint},
+		{Preload("/a>.css", "style"), "", ErrInvalidHint},
+		{Hint{URL: "/a.css", As: "style", CrossOrigin: "yes"}, "", ErrInvalidHint},
+		{Hint{URL: "/a.css", As: "style", FetchPriority: "urgent"}, "", ErrInvalidHint},
+	}
+	for _, tt := range tests {
+		if err := tt.hint.Validate(); err != tt.err {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.hint, err, tt.err)
+			continue
+		}
+		if tt.err == nil {
+			if got := tt.hint.Link().String(); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		}
+	}
+}
+
+func TestSendFiltersFields(t *testing.T) {
+	ew, rec := newTestWriter(t)
+	h := ew.Header()
+	h.Set("Content-Security-Policy", "default-src 'self'")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("Set-Cookie", "session=secret")
+	h.Set("Cache-Control", "private")
+	h.Set("Link", "</final>; rel=canonical")
+	if err := ew.Send(Preload("/app.css", "style")); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.informational) != 1 {
+		t.Fatalf("%d informational responses", len(rec.informational))
+	}
+	want := http.Header{
+		"Content-Security-Policy": {"default-src 'self'"},
+		"Referrer-Policy":         {"no-referrer"},
+		"Link":                    {"</app.css>; rel=preload; as=style"},
+	}
+	if got := rec.informational[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("103 fields = %v, want %v", got, want)
+	}
+	// The handler's fields are restored for the final response.
+	if h.Get("Set-Cookie") != "session=secret" || h.Get("Link") != "</final>; rel=canonical" {
+		t.Errorf("fields after Send = %v", h)
+	}
+
+	ew, rec = newTestWriter(t)
+	ew.Allowed = []string{"x-hint-policy", "Link"}
+	ew.Header().Set("X-Hint-Policy", "a")
+	ew.Header().Set("Content-Security-Policy", "default-src 'self'")
+	ew.Header().Set("Link", "</final>; rel=canonical")
+	if err := ew.Send(Preload("/app.css", "style")); err != nil {
+		t.Fatal(err)
+	}
+	want = http.Header{
+		"X-Hint-Policy": {"a"},
+		"Link":          {"</app.css>; rel=preload; as=style"},
+	}
+	if got := rec.informational[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("103 fields with Allowed = %v, want %v", got, want)
+	}
+}
+
+func TestSendDeduplicates(t *testing.T) {
+	ew, rec := newTestWriter(t)
+	css, js := Preload("/app.css", "style"), Preload("/app.js", "script")
+	if err := ew.Send(css, css); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Send(css); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Send(css, js); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, h := range rec.informational {
+		got = append(got, h.Get("Link"))
+	}
+	want := []string{"</app.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("103 links = %q, want %q", got, want)
+	}
+	if len(ew.Sent()) != 2 {
+		t.Errorf("Sent = %v", ew.Sent())
+	}
+
+	// An invalid hint stops the whole batch without marking the others
+	// as sent.
+	font := Hint{URL: "/font.woff2", As: "font"}
+	if err := ew.Send(font, Hint{URL: "/x"}); err != ErrMissingAs {
+		t.Errorf("Send with invalid hint = %v", err)
+	}
+	if err := ew.Send(font); err != nil || len(rec.informational) != 3 {
+		t.Errorf("valid hint after failed batch: %d responses, %v", len(rec.informational), err)
+	}
+}
+
+func TestFinalLinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		omit  bool
+		links []string // set by the handler before the final response
+		want  []string
+	}{
+		{
+			name: "hints appended",
+			want: []string{"</app.css>; rel=preload; as=style, </app.js>; rel=preload; as=script"},
+		},
+		{
+			name:  "handler link kept first and duplicates dropped",
+			links: []string{"</final>; rel=canonical, </app.js>; rel=preload; as=script", "</final>; rel=canonical"},
+			want:  []string{"</final>; rel=canonical, </app.js>; rel=preload; as=script, </app.css>; rel=preload; as=style"},
+		},
+		{
+			name:  "omitted from final",
+			omit:  true,
+			links: []string{"</final>; rel=canonical", "</final>; rel=canonical"},
+			want:  []string{"</final>; rel=canonical"},
+		},
+		{
+			name: "omitted without handler links",
+			omit: true,
+		},
+		{
+			name:  "unparseable field left alone",
+			links: []string{"not a link"},
+			want:  []string{"not a link"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ew, rec := newTestWriter(t)
+			ew.OmitFromFinal = tt.omit
+			if err := ew.Send(Preload("/app.css", "style"), Preload("/app.js", "script")); err != nil {
+				t.Fatal(err)
+			}
+			for _, l := range tt.links {
+				ew.Header().Add("Link", l)
+			}
+			ew.Write([]byte("body"))
+			if rec.status != http.StatusOK {
+				t.Errorf("status %d", rec.status)
+			}
+			if got := rec.final["Link"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("final Link = %q, want %q", got, tt.want)
+			}
+
+			// Hints after the final response has started are dropped.
+			n := len(rec.informational)
+			if err := ew.Send(Preload("/late.css", "style")); err != nil || len(rec.informational) != n {
+				t.Errorf("late Send wrote %d responses, %v", len(rec.informational)-n, err)
+			}
+		})
+	}
+}
+
+func TestHTTP10(t *testing.T) {
+	rec := newRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.0", 1, 0
+	ew := NewWriter(rec, r)
+	if err := ew.Send(Preload("/app.css", "style")); err != nil {
+		t.Fatal(err)
+	}
+	ew.WriteHeader(http.StatusNoContent)
+	if len(rec.informational) != 0 {
+		t.Errorf("103 sent to an HTTP/1.0 client")
+	}
+	if got := rec.final.Get("Link"); got != "</app.css>; rel=preload; as=style" {
+		t.Errorf("final Link = %q", got)
+	}
+}
+
+func TestSendWithoutWriter(t *testing.T) {
+	if !informationalSupported {
+		t.Skip("net/http cannot send informational responses")
+	}
+	rec := newRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	css := Preload("/app.css", "style")
+	if err := Send(rec, r, css, css); err != nil {
+		t.Fatal(err)
+	}
+	rec.WriteHeader(http.StatusOK)
+	if len(rec.informational) != 1 || rec.informational[0].Get("Link") != "</app.css>; rel=preload; as=style" {
+		t.Errorf("103 responses: %v", rec.informational)
+	}
+	if rec.final.Get("Link") != "" {
+		t.Errorf("final Link = %q", rec.final.Get("Link"))
+	}
+
+	// RFC 8297 section 3 applies without a Writer too.
+	rec = newRecorder()
+	r.Proto, r.ProtoMajor, r.ProtoMinor = "HTTP/1.0", 1, 0
+	if err := Send(rec, r, css); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.informational) != 0 {
+		t.Errorf("103 sent to an HTTP/1.0 client")
+	}
+	if err := Send(rec, r, Hint{URL: "/x"}); err != ErrMissingAs {
+		t.Errorf("Send of an invalid hint = %v, want %v", err, ErrMissingAs)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	if !informationalSupported {
+		t.Skip("net/http cannot send informational responses")
+	}
+	srv := httptest.NewServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'")
+		Send(w, r, Preload("/app.css", "style"))
+		w.Write([]byte("ok"))
+	})))
+	defer srv.Close()
+
+	var hints []textproto.MIMEHeader
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == 103 {
+				hints = append(hints, header)
+			}
+			return nil
+		},
+	}
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(hints) != 1 {
+		t.Fatalf("%d early hints received", len(hints))
+	}
+	if hints[0].Get("Link") != "</app.css>; rel=preload; as=style" || hints[0].Get("Set-Cookie") != "" ||
+		hints[0].Get("Content-Security-Policy") == "" {
+		t.Errorf("103 fields = %v", hints[0])
+	}
+	if resp.Header.Get("Link") != "</app.css>; rel=preload; as=style" || resp.Header.Get("Set-Cookie") == "" {
+		t.Errorf("final fields = %v", resp.Header)
+	}
+}
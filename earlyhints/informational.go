@@ -0,0 +1,9 @@
+//go:build go1.19
+// +build go1.19
+
+package earlyhints
+
+// informationalSupported reports whether net/http sends WriteHeader calls
+// with 1xx status codes as informational responses, which it does since
+// Go 1.19.
+const informationalSupported = true
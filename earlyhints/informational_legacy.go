@@ -0,0 +1,9 @@
+//go:build !go1.19
+// +build !go1.19
+
+package earlyhints
+
+// informationalSupported reports whether net/http sends WriteHeader calls
+// with 1xx status codes as informational responses. Before Go 1.19 a 103
+// would become the final status.
+const informationalSupported = false
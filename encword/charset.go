@@ -0,0 +1,106 @@
+package encword
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnknownCharset is returned for charsets that are not registered.
+	ErrUnknownCharset = errors.New("encword: unknown charset")
+	// ErrInvalidText is returned when text is not valid in its charset.
+	ErrInvalidText = errors.New("encword: text invalid in charset")
+)
+
+// A CharsetDecoder converts text in some charset to UTF-8.
+type CharsetDecoder func(b []byte) (string, error)
+
+var (
+	charsetsMu sync.RWMutex
+	charsets   = map[string]CharsetDecoder{
+		"utf-8":        decodeUTF8,
+		"utf8":         decodeUTF8,
+		"us-ascii":     decodeASCII,
+		"ascii":        decodeASCII,
+		"iso-8859-1":   decodeLatin1,
+		"iso8859-1":    decodeLatin1,
+		"iso_8859-1":   decodeLatin1,
+		"latin1":       decodeLatin1,
+		"windows-1252": decodeWindows1252,
+		"cp1252":       decodeWindows1252,
+	}
+)
+
+// RegisterCharset makes a charset available under the given names, which
+// are matched case-insensitively. It replaces earlier registrations of the
+// same names. UTF-8, US-ASCII, ISO-8859-1 and windows-1252 are registered
+// by default; other charsets can be added from golang.org/x/text.
+func RegisterCharset(dec CharsetDecoder, names ...string) {
+	charsetsMu.Lock()
+	defer charsetsMu.Unlock()
+	for _, name := range names {
+		charsets[strings.ToLower(name)] = dec
+	}
+}
+
+// DecodeCharset converts b from the named charset to UTF-8. A language
+// suffix as allowed by RFC 2231 section 5 ("utf-8*en") is ignored.
+func DecodeCharset(charset string, b []byte) (string, error) {
+	if i := strings.IndexByte(charset, '*'); i >= 0 {
+		charset = charset[:i]
+	}
+	charsetsMu.RLock()
+	dec, ok := charsets[strings.ToLower(charset)]
+	charsetsMu.RUnlock()
+	if !ok {
+		return "", ErrUnknownCharset
+	}
+	return dec(b)
+}
+
+func decodeUTF8(b []byte) (string, error) {
+	if !utf8.Valid(b) {
+		return "", ErrInvalidText
+	}
+	return string(b), nil
+}
+
+func decodeASCII(b []byte) (string, error) {
+	for _, c := range b {
+		if c >= utf8.RuneSelf {
+			return "", ErrInvalidText
+		}
+	}
+	return string(b), nil
+}
+
+func decodeLatin1(b []byte) (string, error) {
+	var s strings.Builder
+	for _, c := range b {
+		s.WriteRune(rune(c))
+	}
+	return s.String(), nil
+}
+
+// windows1252 maps the bytes 0x80 to 0x9f. Unassigned bytes map to the C1
+// control with the same value, as in the WHATWG Encoding Standard.
+var windows1252 = [32]rune{
+	0x20ac, 0x0081, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+	0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008d, 0x017d, 0x008f,
+	0x0090, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+	0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0x009d, 0x017e, 0x0178,
+}
+
+func decodeWindows1252(b []byte) (string, error) {
+	var s strings.Builder
+	for _, c := range b {
+		if c >= 0x80 && c <= 0x9f {
+			s.WriteRune(windows1252[c-0x80])
+		} else {
+			s.WriteRune(rune(c))
+		}
+	}
+	return s.String(), nil
+}
@@ -0,0 +1,206 @@
+// Package encword decodes and encodes RFC 2047 encoded-words, the
+// "=?charset?encoding?text?=" syntax that carries non-ASCII text in MIME
+// header fields.
+//
+// Decoding is lenient in the ways legacy senders require: encoded-words
+// need not be delimited by whitespace, whitespace between adjacent
+// encoded-words is dropped, and adjacent encoded-words in the same charset
+// are joined before charset decoding, so characters split across words
+// survive. Malformed encoded-words are kept as literal text.
+package encword
+
+import (
+	"encoding/base64"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/palsivertsen/gohttpfields/internal/ascii"
+)
+
+// Encoding selects the encoding of encoded-words.
+type Encoding byte
+
+// Encodings defined by RFC 2047 section 4.
+const (
+	BEncoding Encoding = 'b' // base64
+	QEncoding Encoding = 'q' // quoted-printable variant
+)
+
+// maxWordLength is the longest encoded-word allowed by RFC 2047 section 2.
+const maxWordLength = 75
+
+// segment is literal text or the decoded bytes of encoded-words.
+type segment struct {
+	text    string
+	charset string // empty for literal text
+	raw     []byte
+}
+
+// Decode decodes all encoded-words in s. It fails if an encoded-word names
+// an unknown charset or its text is invalid in that charset.
+func Decode(s string) (string, error) {
+	if !strings.Contains(s, "=?") {
+		return s, nil
+	}
+	var segs []segment
+	literal := func(text string) {
+		if n := len(segs); n > 0 && segs[n-1].charset == "" {
+			segs[n-1].text += text
+			return
+		}
+		segs = append(segs, segment{text: text})
+	}
+	for len(s) > 0 {
+		i := strings.Index(s, "=?")
+		if i < 0 {
+			literal(s)
+			break
+		}
+		charset, raw, n, ok := parseWord(s[i:])
+		if !ok {
+			literal(s[:i+2])
+			s = s[i+2:]
+			continue
+		}
+		if i > 0 {
+			literal(s[:i])
+		}
+		s = s[i+n:]
+		// Drop whitespace between adjacent encoded-words.
+		if last := len(segs) - 1; last >= 1 && segs[last].charset == "" &&
+			segs[last-1].charset != "" && strings.Trim(segs[last].text, " \t\r\n") == "" {
+			segs = segs[:last]
+		}
+		if last := len(segs) - 1; last >= 0 && strings.EqualFold(segs[last].charset, charset) {
+			segs[last].raw = append(segs[last].raw, raw...)
+			continue
+		}
+		segs = append(segs, segment{charset: charset, raw: raw})
+	}
+	var b strings.Builder
+	for _, seg := range segs {
+		if seg.charset == "" {
+			b.WriteString(seg.text)
+			continue
+		}
+		text, err := DecodeCharset(seg.charset, seg.raw)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(text)
+	}
+	return b.String(), nil
+}
+
+// parseWord parses the encoded-word at the start of s, returning its
+// charset, decoded bytes and length.
+func parseWord(s string) (charset string, raw []byte, n int, ok bool) {
+	rest := s[2:]
+	i := strings.IndexByte(rest, '?')
+	if i <= 0 || len(rest) < i+3 || rest[i+2] != '?' {
+		return "", nil, 0, false
+	}
+	charset, enc, rest := rest[:i], rest[i+1], rest[i+3:]
+	end := strings.Index(rest, "?=")
+	if end < 0 || strings.ContainsAny(rest[:end], " \t\r\n?") || strings.ContainsAny(charset, " \t\r\n()<>@,;:\"/[]?.=") {
+		return "", nil, 0, false
+	}
+	text := rest[:end]
+	switch enc {
+	case 'b', 'B':
+		b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(text, "="))
+		if err != nil {
+			return "", nil, 0, false
+		}
+		raw = b
+	case 'q', 'Q':
+		for j := 0; j < len(text); j++ {
+			switch c := text[j]; c {
+			case '_':
+				raw = append(raw, ' ')
+			case '=':
+				if j+2 >= len(text) {
+					return "", nil, 0, false
+				}
+				hi, ok1 := ascii.Unhex(text[j+1])
+				lo, ok2 := ascii.Unhex(text[j+2])
+				if !ok1 || !ok2 {
+					return "", nil, 0, false
+				}
+				raw = append(raw, hi<<4|lo)
+				j += 2
+			default:
+				raw = append(raw, c)
+			}
+		}
+	default:
+		return "", nil, 0, false
+	}
+	return charset, raw, 2 + i + 3 + end + 2, true
+}
+
+// NeedsEncoding reports whether s must be encoded to appear in a header
+// field: it contains bytes outside printable ASCII or text that would be
+// mistaken for an encoded-word.
+func NeedsEncoding(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; (c < ' ' && c != '\t') || c > '~' {
+			return true
+		}
+	}
+	return strings.Contains(s, "=?")
+}
+
+// Encode encodes the UTF-8 text s as space separated encoded-words of at
+// most 75 characters, never splitting a character across words. Text that
+// does not need encoding is returned unchanged.
+func (e Encoding) Encode(s string) string {
+	if !NeedsEncoding(s) {
+		return s
+	}
+	prefix := "=?utf-8?" + string(e) + "?"
+	room := maxWordLength - len(prefix) - 2
+	var words []string
+	for len(s) > 0 {
+		n := 0
+		for n < len(s) {
+			_, w := utf8.DecodeRuneInString(s[n:])
+			if n > 0 && e.encodedLen(s[:n+w]) > room {
+				break
+			}
+			n += w
+		}
+		words = append(words, prefix+e.encode(s[:n])+"?=")
+		s = s[n:]
+	}
+	return strings.Join(words, " ")
+}
+
+func (e Encoding) encodedLen(s string) int {
+	if e == BEncoding {
+		return base64.StdEncoding.EncodedLen(len(s))
+	}
+	return len(e.encode(s))
+}
+
+func (e Encoding) encode(s string) string {
+	if e == BEncoding {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ':
+			b.WriteByte('_')
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '!' || c == '*' || c == '+' || c == '-' || c == '/':
+			b.WriteByte(c)
+		default:
+			b.WriteByte('=')
+			b.WriteByte(ascii.UpperHex[c>>4])
+			b.WriteByte(ascii.UpperHex[c&15])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package encword
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		// RFC 2047 section 8.
+		{"=?US-ASCII?Q?Keith_Moore?= <moore@cs.utk.edu>", "Keith Moore <moore@cs.utk.edu>"},
+		{"=?ISO-8859-1?Q?Keld_J=F8rn_Simonsen?=", "Keld Jørn Simonsen"},
+		{"=?ISO-8859-1?Q?Andr=E9?= Pirard", "André Pirard"},
+		{"=?ISO-8859-1?B?SWYgeW91IGNhbiByZWFkIHRoaXMgeW8=?=", "If you can read this yo"},
+		{"(=?ISO-8859-1?Q?a?=)", "(a)"},
+		{"(=?ISO-8859-1?Q?a?= b)", "(a b)"},
+		{"(=?ISO-8859-1?Q?a?= =?ISO-8859-1?Q?b?=)", "(ab)"},
+		{"(=?ISO-8859-1?Q?a?=  =?ISO-8859-1?Q?b?=)", "(ab)"},
+		{"(=?ISO-8859-1?Q?a?=\r\n    =?ISO-8859-1?Q?b?=)", "(ab)"},
+		{"(=?ISO-8859-1?Q?a_b?=)", "(a b)"},
+		{"(=?ISO-8859-1?Q?a?= =?UTF-8?Q?_b?=)", "(a b)"},
+
+		// Whitespace next to literal text is kept.
+		{"x =?utf-8?q?a?=", "x a"},
+		{"=?utf-8?q?a?= \t", "a \t"},
+		{"=?utf-8?q?a?= x =?utf-8?q?b?=", "a x b"},
+		// Encoded-words need not be delimited by whitespace.
+		{"a=?utf-8?q?b?=c", "abc"},
+
+		// Characters split across adjacent words, even in different case.
+		{"=?utf-8?q?=C3?= =?UTF-8?Q?=A9?=", "é"},
+		{"=?utf-8?b?4oI=?= =?utf-8?b?rA==?=", "€"},
+		{"=?utf-8?b?4oI?==?utf-8?b?rA?=", "€"},
+
+		// Padded and unpadded base64, lower-case hex in Q.
+		{"=?utf-8?b?w6k=?=", "é"},
+		{"=?utf-8?b?w6k?=", "é"},
+		{"=?utf-8?q?=c3=a9?=", "é"},
+
+		// Charset names and aliases.
+		{"=?UTF8?Q?=C3=A9?=", "é"},
+		{"=?latin1?q?=E9?=", "é"},
+		{"=?iso_8859-1?q?=E9?=", "é"},
+		{"=?iso8859-1?q?=E9?=", "é"},
+		{"=?ascii?q?abc?=", "abc"},
+		{"=?US-ASCII*EN?Q?Keith_Moore?=", "Keith Moore"},
+		{"=?windows-1252?q?=80=93=9F?=", "€“Ÿ"},
+		{"=?cp1252?q?=81?=", "\u0081"},
+
+		// Malformed encoded-words are kept literally.
+		{"=?utf-8?x?a?=", "=?utf-8?x?a?="},
+		{"=?utf-8?q?a b?=", "=?utf-8?q?a b?="},
+		{"=?utf-8?q?=4?=", "=?utf-8?q?=4?="},
+		{"=?utf-8?q?=ZZ?=", "=?utf-8?q?=ZZ?="},
+		{"=??q?a?=", "=??q?a?="},
+		{"=?utf 8?q?a?=", "=?utf 8?q?a?="},
+		{"=?utf-8?b?!!?=", "=?utf-8?b?!!?="},
+		{"=?utf-8?q?abc", "=?utf-8?q?abc"},
+		{"=?utf-8?q?", "=?utf-8?q?"},
+		{"=?", "=?"},
+		{"=?bad =?utf-8?q?a?=", "=?bad a"},
+		{"no words", "no words"},
+	}
+	for _, tt := range tests {
+		got, err := Decode(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Decode(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{"=?koi8-r?q?a?=", ErrUnknownCharset},
+		{"=?utf-8?q?=FF?=", ErrInvalidText},
+		{"=?utf-8?q?=C3?=", ErrInvalidText},
+		{"=?us-ascii?q?=E9?=", ErrInvalidText},
+		// Split characters only join within one charset.
+		{"=?utf-8?q?=C3?= =?latin1?q?a?=", ErrInvalidText},
+	}
+	for _, tt := range tests {
+		if got, err := Decode(tt.in); err != tt.err {
+			t.Errorf("Decode(%q) = %q, %v; want %v", tt.in, got, err, tt.err)
+		}
+	}
+}
+
+func TestRegisterCharset(t *testing.T) {
+	upper := func(b []byte) (string, error) { return strings.ToUpper(string(b)), nil }
+	RegisterCharset(upper, "X-Test-Upper")
+	if got, err := Decode("=?x-test-upper?q?abc?="); err != nil || got != "ABC" {
+		t.Errorf("Decode with registered charset = %q, %v", got, err)
+	}
+	if got, err := DecodeCharset("X-TEST-UPPER*en", []byte("a")); err != nil || got != "A" {
+		t.Errorf("DecodeCharset = %q, %v", got, err)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		enc  Encoding
+		in   string
+		want string
+	}{
+		{QEncoding, "plain text", "plain text"},
+		{QEncoding, "café au lait", "=?utf-8?q?caf=C3=A9_au_lait?="},
+		{BEncoding, "café", "=?utf-8?b?Y2Fmw6k=?="},
+		{QEncoding, "a=?b", "=?utf-8?q?a=3D=3Fb?="},
+		{QEncoding, "tab\tline\n", "=?utf-8?q?tab=09line=0A?="},
+	}
+	for _, tt := range tests {
+		if got := tt.enc.Encode(tt.in); got != tt.want {
+			t.Errorf("%c.Encode(%q) = %q, want %q", tt.enc, tt.in, got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("Grüße aus Köln, ", 10) + "€"
+	for _, enc := range []Encoding{QEncoding, BEncoding} {
+		encoded := enc.Encode(long)
+		words := strings.Split(encoded, " ")
+		if len(words) < 2 {
+			t.Errorf("%c: %d words for %d bytes", enc, len(words), len(long))
+		}
+		for _, w := range words {
+			if len(w) > maxWordLength {
+				t.Errorf("%c: word of %d characters: %q", enc, len(w), w)
+			}
+			// Every word decodes on its own: no character is split.
+			if d, err := Decode(w); err != nil || !utf8.ValidString(d) {
+				t.Errorf("%c: word %q decodes to %q, %v", enc, w, d, err)
+			}
+		}
+		if got, err := Decode(encoded); err != nil || got != long {
+			t.Errorf("%c: round trip = %q, %v", enc, got, err)
+		}
+	}
+}
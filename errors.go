@@ -0,0 +1,17 @@
+package httpfields
+
+import "strconv"
+
+// A ParseError reports a field value that could not be parsed.
+type ParseError struct {
+	Field string // field name or grammar element, such as "Content-Type"
+	Value string // the offending field value
+	Err   error  // the underlying reason
+}
+
+func (e *ParseError) Error() string {
+	return "httpfields: invalid " + e.Field + " " + strconv.Quote(e.Value) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying reason.
+func (e *ParseError) Unwrap() error { return e.Err }
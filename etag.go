@@ -0,0 +1,144 @@
+package httpfields
+
+import (
+	"errors"
+	"strings"
+)
+
+var errMalformedETag = errors.New("malformed entity-tag")
+
+// EntityTag is an entity-tag as defined by RFC 9110 section 8.8.3.
+type EntityTag struct {
+	Weak   bool
+	Opaque string // the opaque-tag without quotes
+}
+
+// ParseEntityTag parses an ETag field value such as `W/"xyzzy"`.
+func ParseEntityTag(s string) (EntityTag, error) {
+	t, rest, err := consumeEntityTag(TrimOWS(s))
+	if err == nil && rest != "" {
+		err = errUnexpectedCharacters
+	}
+	if err != nil {
+		return EntityTag{}, &ParseError{Field: "ETag", Value: s, Err: err}
+	}
+	return t, nil
+}
+
+func consumeEntityTag(s string) (EntityTag, string, error) {
+	var t EntityTag
+	if strings.HasPrefix(s, "W/") {
+		t.Weak = true
+		s = s[2:]
+	}
+	if s == "" || s[0] != '"' {
+		return t, s, errMalformedETag
+	}
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			t.Opaque = s[1:i]
+			return t, s[i+1:], nil
+		case c == 0x21 || c >= 0x23 && c != 0x7f:
+		default:
+			return t, s, errMalformedETag
+		}
+	}
+	return t, s, errMalformedETag
+}
+
+// String formats t as a field value.
+func (t EntityTag) String() string {
+	if t.Weak {
+		return `W/"` + t.Opaque + `"`
+	}
+	return `"` + t.Opaque + `"`
+}
+
+// StrongMatch reports whether t and u are strongly equal: neither is weak and
+// their opaque-tags match.
+func (t EntityTag) StrongMatch(u EntityTag) bool {
+	return !t.Weak && !u.Weak && t.Opaque == u.Opaque
+}
+
+// WeakMatch reports whether the opaque-tags of t and u match, regardless of
+// either being weak.
+func (t EntityTag) WeakMatch(u EntityTag) bool {
+	return t.Opaque == u.Opaque
+}
+
+// EntityTagList is a parsed If-Match or If-None-Match field value.
+type EntityTagList struct {
+	Any  bool // the field value was "*"
+	Tags []EntityTag
+}
+
+// ParseEntityTagList parses an If-Match or If-None-Match field value.
+func ParseEntityTagList(s string) (EntityTagList, error) {
+	var l EntityTagList
+	if TrimOWS(s) == "*" {
+		l.Any = true
+		return l, nil
+	}
+	rest := s
+	for {
+		rest = skipOWS(rest)
+		if rest == "" {
+			break
+		}
+		if rest[0] == ',' {
+			rest = rest[1:]
+			continue
+		}
+		t, r, err := consumeEntityTag(rest)
+		if err != nil {
+			return EntityTagList{}, &ParseError{Field: "entity-tag list", Value: s, Err: err}
+		}
+		l.Tags = append(l.Tags, t)
+		rest = skipOWS(r)
+		if rest != "" && rest[0] != ',' {
+			return EntityTagList{}, &ParseError{Field: "entity-tag list", Value: s, Err: errUnexpectedCharacters}
+		}
+	}
+	return l, nil
+}
+
+// StrongMatch reports whether the list matches t using the strong comparison
+// required for If-Match.
+func (l EntityTagList) StrongMatch(t EntityTag) bool {
+	if l.Any {
+		return true
+	}
+	for _, u := range l.Tags {
+		if u.StrongMatch(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// WeakMatch reports whether the list matches t using the weak comparison
+// required for If-None-Match.
+func (l EntityTagList) WeakMatch(t EntityTag) bool {
+	if l.Any {
+		return true
+	}
+	for _, u := range l.Tags {
+		if u.WeakMatch(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats l as a field value.
+func (l EntityTagList) String() string {
+	if l.Any {
+		return "*"
+	}
+	tags := make([]string, len(l.Tags))
+	for i, t := range l.Tags {
+		tags[i] = t.String()
+	}
+	return strings.Join(tags, ", ")
+}
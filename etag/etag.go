@@ -0,0 +1,264 @@
+// Package etag adds validators to responses and answers conditional GET and
+// HEAD requests.
+//
+// A Tagger derives a strong entity-tag from the response body by hashing
+// it, or a weak entity-tag from a version the caller supplies, and then
+// answers requests whose preconditions say the client is up to date with
+// 304 (Not Modified) as described in RFC 9110 section 15.4.5.
+package etag
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// DefaultMaxBufferSize is the default limit on the bytes of a response
+// body buffered to compute its entity-tag.
+const DefaultMaxBufferSize = 1 << 20
+
+// Strong returns a strong entity-tag for the content read from r, hashing
+// it with newHash, or SHA-256 if newHash is nil.
+func Strong(r io.Reader, newHash func() hash.Hash) (httpfields.EntityTag, error) {
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	h := newHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return httpfields.EntityTag{}, err
+	}
+	return tagFromSum(h.Sum(nil)), nil
+}
+
+func tagFromSum(sum []byte) httpfields.EntityTag {
+	return httpfields.EntityTag{Opaque: base64.RawURLEncoding.EncodeToString(sum)}
+}
+
+// Weak returns a weak entity-tag for a version string, such as a row
+// version or update timestamp. Versions with characters not allowed in an
+// entity-tag are hashed.
+func Weak(version string) httpfields.EntityTag {
+	for i := 0; i < len(version); i++ {
+		if c := version[i]; c <= 0x20 || c == '"' || c == 0x7f {
+			sum := sha256.Sum256([]byte(version))
+			return httpfields.EntityTag{Weak: true, Opaque: base64.RawURLEncoding.EncodeToString(sum[:])}
+		}
+	}
+	return httpfields.EntityTag{Weak: true, Opaque: version}
+}
+
+// Tagger is middleware configuration for entity-tags. The zero value
+// hashes buffered bodies with SHA-256.
+type Tagger struct {
+	// Hash creates the hash for strong entity-tags, defaulting to SHA-256.
+	Hash func() hash.Hash
+
+	// MaxBufferSize bounds the bytes of a body buffered for hashing.
+	// Larger bodies are streamed to the client without an entity-tag. A
+	// zero value selects DefaultMaxBufferSize and a negative value
+	// disables the limit.
+	MaxBufferSize int64
+
+	// Version, if set, returns the version of the resource a request
+	// targets. A non-empty version yields a weak entity-tag that is
+	// checked before the handler runs, so up to date clients are answered
+	// without generating the response. Requests for which Version returns
+	// the empty string fall back to hashing the body.
+	Version func(r *http.Request) string
+}
+
+// Middleware is Tagger{}.Middleware.
+func Middleware(next http.Handler) http.Handler {
+	return (&Tagger{}).Middleware(next)
+}
+
+// Middleware adds entity-tags to the 200 responses of next to GET and HEAD
+// requests and evaluates the conditional request fields against them.
+// Responses that already carry an ETag field keep it. HEAD responses are
+// only tagged if next writes the body for them; handlers such as
+// http.ServeContent that write no body for HEAD get no generated tag, since
+// the hash of an empty body would not match the GET tag.
+func (t *Tagger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if t.Version != nil {
+			if v := t.Version(r); v != "" {
+				tag := Weak(v)
+				w.Header().Set("ETag", tag.String())
+				if status := httpfields.EvaluatePreconditions(r.Method, r.Header, httpfields.Validators{ETag: &tag}); status != 0 {
+					writeNotModified(w, status)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		newHash := t.Hash
+		if newHash == nil {
+			newHash = sha256.New
+		}
+		limit := t.MaxBufferSize
+		if limit == 0 {
+			limit = DefaultMaxBufferSize
+		}
+		bw := &bufferedWriter{ResponseWriter: w, req: r, hash: newHash(), limit: limit}
+		next.ServeHTTP(bw, r)
+		bw.finish()
+	})
+}
+
+// removedOnNotModified lists the representation metadata a 304 response
+// omits (RFC 9110 section 15.4.5).
+var removedOnNotModified = []string{
+	"Content-Type", "Content-Length", "Content-Encoding", "Content-Language",
+	"Content-Range", "Content-Disposition", "Content-Digest", "Repr-Digest",
+	"Digest", "Transfer-Encoding",
+}
+
+// writeNotModified answers with 304 or 412 and no body. For 304, the
+// fields that describe the omitted content are removed while ETag,
+// Cache-Control, Content-Location, Date, Expires, Vary and Last-Modified
+// remain.
+func writeNotModified(w http.ResponseWriter, status int) {
+	h := w.Header()
+	for _, name := range removedOnNotModified {
+		h.Del(name)
+	}
+	w.WriteHeader(status)
+}
+
+// bufferedWriter buffers a 200 response and hashes its body. It switches to
+// streaming when the body outgrows the limit, the handler flushes, or the
+// status is not 200.
+type bufferedWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	hash        hash.Hash
+	limit       int64
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	streaming   bool
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	if status >= 100 && status <= 199 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	if status != http.StatusOK || w.Header().Get("ETag") != "" {
+		w.stream()
+	}
+}
+
+func (w *bufferedWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.streaming {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.limit > 0 && int64(w.buf.Len()+len(b)) > w.limit {
+		w.stream()
+		return w.ResponseWriter.Write(b)
+	}
+	w.hash.Write(b)
+	return w.buf.Write(b)
+}
+
+func (w *bufferedWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.stream()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream sends the header and any buffered body, without an entity-tag
+// unless the handler set one. Preconditions are still evaluated against a
+// handler supplied ETag.
+func (w *bufferedWriter) stream() {
+	if w.streaming {
+		return
+	}
+	w.streaming = true
+	if w.status == http.StatusOK && w.buf.Len() == 0 && w.Header().Get("ETag") != "" && w.checkPreconditions() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+}
+
+// checkPreconditions evaluates the request against the ETag and
+// Last-Modified fields of the response and, if the client is up to date or
+// a precondition failed, writes the response and discards the body.
+func (w *bufferedWriter) checkPreconditions() bool {
+	var v httpfields.Validators
+	if tag, err := httpfields.ParseEntityTag(w.Header().Get("ETag")); err == nil {
+		v.ETag = &tag
+	}
+	if lm, err := http.ParseTime(w.Header().Get("Last-Modified")); err == nil {
+		v.LastModified = lm
+	}
+	status := httpfields.EvaluatePreconditions(w.req.Method, w.req.Header, v)
+	if status == 0 {
+		return false
+	}
+	writeNotModified(w.ResponseWriter, status)
+	w.ResponseWriter = discard{w.ResponseWriter}
+	return true
+}
+
+// finish completes a buffered response once the handler has returned.
+func (w *bufferedWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.streaming {
+		return
+	}
+	w.streaming = true
+	// A HEAD handler that wrote no body tells nothing about the GET body,
+	// neither its tag nor its length, so its response is passed through
+	// and left to evaluate its own preconditions.
+	if w.req.Method == http.MethodHead && w.buf.Len() == 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+		return
+	}
+	tag := tagFromSum(w.hash.Sum(nil))
+	w.Header().Set("ETag", tag.String())
+	if w.checkPreconditions() {
+		return
+	}
+	if w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.Itoa(w.buf.Len()))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(w.buf.Bytes())
+}
+
+// discard drops the body of a response answered with 304 or 412.
+type discard struct {
+	http.ResponseWriter
+}
+
+func (discard) WriteHeader(int) {}
+
+func (discard) Write(b []byte) (int, error) { return len(b), nil }
@@ -0,0 +1,263 @@
+package etag
+
+import (
+	"crypto/md5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	body    = "hello world"
+	bodyTag = `"uU0nuZNNPgilLlLX2n2r-sSE7-N6U4DukIj3rOLvzek"`
+)
+
+// content answers like http.ServeContent: representation metadata for GET
+// and HEAD, but a body for GET only unless headBody is set.
+func content(headBody bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Content-Type", "text/plain")
+		h.Set("Content-Language", "en")
+		h.Set("Cache-Control", "max-age=60")
+		h.Set("Vary", "Accept-Encoding")
+		h.Set("Last-Modified", "Fri, 01 Mar 2024 12:00:00 GMT")
+		if r.Method == http.MethodHead && !headBody {
+			return
+		}
+		w.Write([]byte("hello "))
+		w.Write([]byte("world"))
+	})
+}
+
+func do(h http.Handler, method string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestStrongAndWeak(t *testing.T) {
+	tag, err := Strong(strings.NewReader(body), nil)
+	if err != nil || tag.String() != bodyTag {
+		t.Errorf("Strong = %s, %v; want %s", tag, err, bodyTag)
+	}
+	tag, err = Strong(strings.NewReader(body), md5.New)
+	if err != nil || tag.String() != `"XrY7u-Ae7tCTyyK7j1rNww"` {
+		t.Errorf("Strong with MD5 = %s, %v", tag, err)
+	}
+	if got := Weak("v42").String(); got != `W/"v42"` {
+		t.Errorf("Weak = %s", got)
+	}
+	if got := Weak("a b").String(); got != `W/"yGh6CKpdbtIEQyj6aml6uOltw0KR6MIDSujDjm_MbWU"` {
+		t.Errorf("Weak with space = %s", got)
+	}
+	if got := Weak(`say "hi"`); strings.Contains(got.Opaque, `"`) || !got.Weak {
+		t.Errorf("Weak with quotes = %s", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	h := Middleware(content(false))
+	rec := do(h, "GET", nil)
+	if rec.Code != 200 || rec.Body.String() != body {
+		t.Fatalf("%d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("ETag") != bodyTag || rec.Header().Get("Content-Length") != "11" {
+		t.Errorf("fields = %v", rec.Header())
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		code   int
+	}{
+		{"matching If-None-Match", "GET", http.Header{"If-None-Match": {bodyTag}}, 304},
+		{"weak If-None-Match", "GET", http.Header{"If-None-Match": {"W/" + bodyTag}}, 304},
+		{"If-None-Match list", "GET", http.Header{"If-None-Match": {`"old", ` + bodyTag}}, 304},
+		{"stale If-None-Match", "GET", http.Header{"If-None-Match": {`"old"`}}, 200},
+		{"If-Modified-Since", "GET", http.Header{"If-Modified-Since": {"Fri, 01 Mar 2024 12:00:00 GMT"}}, 304},
+		{"matching If-Match", "GET", http.Header{"If-Match": {bodyTag}}, 200},
+		{"failed If-Match", "GET", http.Header{"If-Match": {`"old"`}}, 412},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(h, tt.method, tt.header)
+			if rec.Code != tt.code {
+				t.Fatalf("status %d, want %d", rec.Code, tt.code)
+			}
+			if tt.code == 200 {
+				if rec.Body.String() != body {
+					t.Errorf("body %q", rec.Body.String())
+				}
+				return
+			}
+			if rec.Body.Len() != 0 {
+				t.Errorf("body %q on %d", rec.Body.String(), rec.Code)
+			}
+			// Fields describing the omitted content are stripped; the
+			// validators and caching fields remain.
+			for _, name := range []string{"Content-Type", "Content-Length", "Content-Language"} {
+				if v := rec.Header().Get(name); v != "" {
+					t.Errorf("%s = %q on %d", name, v, rec.Code)
+				}
+			}
+			for _, name := range []string{"ETag", "Cache-Control", "Vary", "Last-Modified"} {
+				if rec.Header().Get(name) == "" {
+					t.Errorf("%s missing on %d", name, rec.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestUntagged(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		handler http.HandlerFunc
+		header  http.Header
+		code    int
+		etag    string
+	}{
+		{"POST", "POST", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(body)) }, nil, 200, ""},
+		{"not found", "GET", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "gone", http.StatusNotFound)
+		}, http.Header{"If-None-Match": {"*"}}, 404, ""},
+		{"created", "GET", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(body))
+		}, nil, 201, ""},
+		{"flushed", "GET", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello "))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("world"))
+		}, http.Header{"If-None-Match": {"*"}}, 200, ""},
+		{"handler tag kept", "GET", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"mine"`)
+			w.Write([]byte(body))
+		}, nil, 200, `"mine"`},
+		{"handler tag evaluated", "GET", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("ETag", `"mine"`)
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(body))
+		}, http.Header{"If-None-Match": {`"mine"`}}, 304, `"mine"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(Middleware(tt.handler), tt.method, tt.header)
+			if rec.Code != tt.code || rec.Header().Get("ETag") != tt.etag {
+				t.Errorf("%d ETag %q, want %d ETag %q", rec.Code, rec.Header().Get("ETag"), tt.code, tt.etag)
+			}
+			if tt.code == 304 {
+				if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+					t.Errorf("304 with %q, %v", rec.Body.String(), rec.Header())
+				}
+			} else if tt.code == 200 && rec.Body.String() != body {
+				t.Errorf("body %q", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBufferLimit(t *testing.T) {
+	tests := []struct {
+		limit int64
+		etag  string
+	}{
+		{5, ""},
+		{11, bodyTag},
+		{-1, bodyTag},
+	}
+	for _, tt := range tests {
+		h := (&Tagger{MaxBufferSize: tt.limit}).Middleware(content(false))
+		// Once streaming, a body without a tag cannot satisfy
+		// If-None-Match and is sent in full.
+		rec := do(h, "GET", http.Header{"If-None-Match": {`"x"`}})
+		if rec.Code != 200 || rec.Body.String() != body || rec.Header().Get("ETag") != tt.etag {
+			t.Errorf("limit %d: %d %q ETag %q, want ETag %q", tt.limit, rec.Code, rec.Body.String(), rec.Header().Get("ETag"), tt.etag)
+		}
+	}
+
+	big := strings.Repeat("x", DefaultMaxBufferSize+1)
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(big)) }))
+	if rec := do(h, "GET", nil); rec.Header().Get("ETag") != "" || rec.Body.Len() != len(big) {
+		t.Errorf("default limit: ETag %q, %d bytes", rec.Header().Get("ETag"), rec.Body.Len())
+	}
+}
+
+func TestVersion(t *testing.T) {
+	calls := 0
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		content(false).ServeHTTP(w, r)
+	})
+	tagger := &Tagger{Version: func(r *http.Request) string { return r.URL.Query().Get("v") }}
+	h := tagger.Middleware(inner)
+
+	tests := []struct {
+		target string
+		header http.Header
+		code   int
+		etag   string
+		calls  int
+	}{
+		{"/?v=7", nil, 200, `W/"7"`, 1},
+		{"/?v=7", http.Header{"If-None-Match": {`W/"7"`}}, 304, `W/"7"`, 0},
+		{"/?v=7", http.Header{"If-None-Match": {`"7"`}}, 304, `W/"7"`, 0},
+		{"/?v=8", http.Header{"If-None-Match": {`W/"7"`}}, 200, `W/"8"`, 1},
+		// A weak tag never satisfies If-Match.
+		{"/?v=7", http.Header{"If-Match": {`W/"7"`}}, 412, `W/"7"`, 0},
+		// Without a version the body is hashed.
+		{"/", nil, 200, bodyTag, 1},
+		{"/", http.Header{"If-None-Match": {bodyTag}}, 304, bodyTag, 1},
+	}
+	for _, tt := range tests {
+		calls = 0
+		r := httptest.NewRequest("GET", tt.target, nil)
+		for name, values := range tt.header {
+			r.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != tt.code || rec.Header().Get("ETag") != tt.etag || calls != tt.calls {
+			t.Errorf("%s %v: %d ETag %q, %d handler calls; want %d %q %d",
+				tt.target, tt.header, rec.Code, rec.Header().Get("ETag"), calls, tt.code, tt.etag, tt.calls)
+		}
+	}
+}
+
+func TestHead(t *testing.T) {
+	// A HEAD handler without a body gets no generated tag: the hash of
+	// nothing would not match the GET tag.
+	rec := do(Middleware(content(false)), "HEAD", nil)
+	if rec.Code != 200 || rec.Header().Get("ETag") != "" || rec.Body.Len() != 0 {
+		t.Errorf("bodiless HEAD: %d ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	rec = do(Middleware(content(false)), "HEAD", http.Header{"If-None-Match": {bodyTag}})
+	if rec.Code != 200 {
+		t.Errorf("bodiless HEAD with If-None-Match: %d", rec.Code)
+	}
+
+	// A HEAD handler that writes the body is tagged like GET.
+	rec = do(Middleware(content(true)), "HEAD", nil)
+	if rec.Code != 200 || rec.Header().Get("ETag") != bodyTag || rec.Header().Get("Content-Length") != "11" {
+		t.Errorf("HEAD with body: %d %v", rec.Code, rec.Header())
+	}
+	rec = do(Middleware(content(true)), "HEAD", http.Header{"If-None-Match": {bodyTag}})
+	if rec.Code != 304 {
+		t.Errorf("HEAD with body and If-None-Match: %d", rec.Code)
+	}
+
+	// A version tags HEAD without the body.
+	tagger := &Tagger{Version: func(*http.Request) string { return "7" }}
+	rec = do(tagger.Middleware(content(false)), "HEAD", http.Header{"If-None-Match": {`W/"7"`}})
+	if rec.Code != 304 || rec.Header().Get("ETag") != `W/"7"` {
+		t.Errorf("versioned HEAD: %d %v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,124 @@
+package httpfields
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEntityTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want EntityTag
+		err  bool
+	}{
+		{`"xyzzy"`, EntityTag{Opaque: "xyzzy"}, false},
+		{`W/"xyzzy"`, EntityTag{Weak: true, Opaque: "xyzzy"}, false},
+		{` "a" `, EntityTag{Opaque: "a"}, false},
+		{`""`, EntityTag{}, false},
+		{`"aé"`, EntityTag{Opaque: `aé`}, false},
+		{"\"\xe9\"", EntityTag{Opaque: "\xe9"}, false},
+		{`xyzzy`, EntityTag{}, true},
+		{`w/"xyzzy"`, EntityTag{}, true},
+		{`"xyzzy`, EntityTag{}, true},
+		{`"a b"`, EntityTag{}, true},
+		{`"a"b`, EntityTag{}, true},
+		{`W/`, EntityTag{}, true},
+		{``, EntityTag{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEntityTag(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseEntityTag(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+		if err == nil && got.String() != TrimOWS(tt.in) {
+			t.Errorf("ParseEntityTag(%q).String() = %q", tt.in, got.String())
+		}
+	}
+}
+
+func TestEntityTagMatch(t *testing.T) {
+	// The comparison table of RFC 9110 section 8.8.3.2.
+	tests := []struct {
+		a, b         string
+		strong, weak bool
+	}{
+		{`W/"1"`, `W/"1"`, false, true},
+		{`W/"1"`, `W/"2"`, false, false},
+		{`W/"1"`, `"1"`, false, true},
+		{`"1"`, `"1"`, true, true},
+	}
+	for _, tt := range tests {
+		a, _ := ParseEntityTag(tt.a)
+		b, _ := ParseEntityTag(tt.b)
+		if got := a.StrongMatch(b); got != tt.strong {
+			t.Errorf("%s StrongMatch %s = %v", tt.a, tt.b, got)
+		}
+		if got := a.WeakMatch(b); got != tt.weak {
+			t.Errorf("%s WeakMatch %s = %v", tt.a, tt.b, got)
+		}
+	}
+}
+
+func TestParseEntityTagList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want EntityTagList
+		err  bool
+	}{
+		{`*`, EntityTagList{Any: true}, false},
+		{` * `, EntityTagList{Any: true}, false},
+		{`"a"`, EntityTagList{Tags: []EntityTag{{Opaque: "a"}}}, false},
+		{`"a", W/"b" ,"c,d"`, EntityTagList{Tags: []EntityTag{{Opaque: "a"}, {Weak: true, Opaque: "b"}, {Opaque: "c,d"}}}, false},
+		{`,"a",,`, EntityTagList{Tags: []EntityTag{{Opaque: "a"}}}, false},
+		{``, EntityTagList{}, false},
+		{`"a" "b"`, EntityTagList{}, true},
+		{`"a", *`, EntityTagList{}, true},
+		{`a`, EntityTagList{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEntityTagList(tt.in)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseEntityTagList(%q) = %+v, %v; want %+v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+		if err != nil {
+			if _, ok := err.(*ParseError); !ok {
+				t.Errorf("ParseEntityTagList(%q) error %T, want *ParseError", tt.in, err)
+			}
+		}
+	}
+}
+
+func TestEntityTagListMatch(t *testing.T) {
+	l, err := ParseEntityTagList(`W/"a", "b"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tag          EntityTag
+		strong, weak bool
+	}{
+		{EntityTag{Opaque: "a"}, false, true},
+		{EntityTag{Weak: true, Opaque: "a"}, false, true},
+		{EntityTag{Opaque: "b"}, true, true},
+		{EntityTag{Weak: true, Opaque: "b"}, false, true},
+		{EntityTag{Opaque: "c"}, false, false},
+	}
+	for _, tt := range tests {
+		if got := l.StrongMatch(tt.tag); got != tt.strong {
+			t.Errorf("StrongMatch(%s) = %v", tt.tag, got)
+		}
+		if got := l.WeakMatch(tt.tag); got != tt.weak {
+			t.Errorf("WeakMatch(%s) = %v", tt.tag, got)
+		}
+	}
+	star := EntityTagList{Any: true}
+	if !star.StrongMatch(EntityTag{Weak: true, Opaque: "x"}) || !star.WeakMatch(EntityTag{}) {
+		t.Error("* does not match every tag")
+	}
+	if got := l.String(); got != `W/"a", "b"` {
+		t.Errorf("String() = %q", got)
+	}
+	if got := star.String(); got != "*" {
+		t.Errorf("String() = %q", got)
+	}
+}
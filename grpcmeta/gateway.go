@@ -0,0 +1,117 @@
+package grpcmeta
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Field name prefixes used by a JSON/HTTP gateway in front of a gRPC
+// service, compatible with grpc-gateway's default matchers.
+const (
+	MetadataHeaderPrefix = "Grpc-Metadata-"
+	TrailerHeaderPrefix  = "Grpc-Trailer-"
+	PermanentKeyPrefix   = "grpcgateway-"
+)
+
+// permanentFields are the standard request fields a gateway forwards with
+// PermanentKeyPrefix.
+var permanentFields = map[string]bool{
+	"Accept":                true,
+	"Accept-Charset":        true,
+	"Accept-Language":       true,
+	"Accept-Ranges":         true,
+	"Authorization":         true,
+	"Cache-Control":         true,
+	"Content-Type":          true,
+	"Cookie":                true,
+	"Date":                  true,
+	"Expect":                true,
+	"From":                  true,
+	"Host":                  true,
+	"If-Match":              true,
+	"If-Modified-Since":     true,
+	"If-None-Match":         true,
+	"If-Schedule-Tag-Match": true,
+	"If-Unmodified-Since":   true,
+	"Max-Forwards":          true,
+	"Origin":                true,
+	"Pragma":                true,
+	"Referer":               true,
+	"User-Agent":            true,
+	"Via":                   true,
+	"Warning":               true,
+}
+
+// IncomingKey maps a request field name to the metadata key a gateway
+// forwards it as: permanent HTTP fields gain PermanentKeyPrefix, fields
+// with MetadataHeaderPrefix lose it, and all other fields are dropped.
+func IncomingKey(name string) (string, bool) {
+	name = http.CanonicalHeaderKey(name)
+	if permanentFields[name] {
+		return PermanentKeyPrefix + strings.ToLower(name), true
+	}
+	if strings.HasPrefix(name, MetadataHeaderPrefix) && len(name) > len(MetadataHeaderPrefix) {
+		return strings.ToLower(name[len(MetadataHeaderPrefix):]), true
+	}
+	return "", false
+}
+
+// IncomingMetadata builds the metadata a gateway sends upstream for req:
+// the fields selected by IncomingKey, plus x-forwarded-host and
+// x-forwarded-for derived from the request. Binary values are decoded.
+func IncomingMetadata(req *http.Request) (Metadata, error) {
+	md := make(Metadata)
+	for name, values := range req.Header {
+		key, ok := IncomingKey(name)
+		if !ok || !ValidKey(key) || IsReserved(key) {
+			continue
+		}
+		for _, v := range values {
+			if IsBinary(key) {
+				b, err := DecodeBinary(v)
+				if err != nil {
+					return nil, err
+				}
+				v = string(b)
+			}
+			md[key] = append(md[key], v)
+		}
+	}
+	if req.Host != "" {
+		md.Append("x-forwarded-host", req.Host)
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		forwarded := host
+		if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+			forwarded = prior + ", " + host
+		}
+		md["x-forwarded-for"] = []string{forwarded}
+	}
+	return md, nil
+}
+
+// SetResponseHeader adds the response header metadata md to h with
+// MetadataHeaderPrefix. Binary values are base64 encoded.
+func SetResponseHeader(h http.Header, md Metadata) {
+	addPrefixed(h, MetadataHeaderPrefix, md)
+}
+
+// SetResponseTrailer adds the trailer metadata md to h with
+// TrailerHeaderPrefix. Binary values are base64 encoded.
+func SetResponseTrailer(h http.Header, md Metadata) {
+	addPrefixed(h, TrailerHeaderPrefix, md)
+}
+
+func addPrefixed(h http.Header, prefix string, md Metadata) {
+	for key, values := range md {
+		for _, v := range values {
+			if IsBinary(key) {
+				v = EncodeBinary([]byte(v))
+			} else if !ValidValue(v) {
+				continue
+			}
+			h.Add(prefix+key, v)
+		}
+	}
+}
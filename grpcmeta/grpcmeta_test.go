@@ -0,0 +1,267 @@
+package grpcmeta
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBinary(t *testing.T) {
+	raw := []byte{0x00, 0x01, 0x02, 0xff}
+	if got := EncodeBinary(raw); got != "AAEC/w" {
+		t.Errorf("EncodeBinary = %q, want unpadded %q", got, "AAEC/w")
+	}
+	for _, s := range []string{"AAEC/w", "AAEC/w=="} {
+		if b, err := DecodeBinary(s); err != nil || string(b) != string(raw) {
+			t.Errorf("DecodeBinary(%q) = %x, %v", s, b, err)
+		}
+	}
+	for _, s := range []string{"AAEC_w", "A", "AA EC", "*"} {
+		if _, err := DecodeBinary(s); err != ErrInvalidValue {
+			t.Errorf("DecodeBinary(%q) = %v, want %v", s, err, ErrInvalidValue)
+		}
+	}
+	if !IsBinary("Trace-BIN") || IsBinary("bin") || IsBinary("trace") {
+		t.Error("IsBinary misclassifies keys")
+	}
+}
+
+func TestToHeader(t *testing.T) {
+	md := Metadata{
+		"trace-bin": {"\x00\x01", "\xff"},
+		"user":      {"alice", "bob"},
+	}
+	h := make(http.Header)
+	if err := ToHeader(md, h); err != nil {
+		t.Fatal(err)
+	}
+	want := http.Header{
+		"Trace-Bin": {"AAE", "/w"},
+		"User":      {"alice", "bob"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("ToHeader = %v, want %v", h, want)
+	}
+
+	tests := []struct {
+		name string
+		md   Metadata
+		err  error
+	}{
+		{"upper-case key", Metadata{"User": {"a"}}, ErrInvalidKey},
+		{"space in key", Metadata{"a b": {"a"}}, ErrInvalidKey},
+		{"empty key", Metadata{"": {"a"}}, ErrInvalidKey},
+		{"reserved key", Metadata{"grpc-timeout": {"1S"}}, ErrReservedKey},
+		{"transport field", Metadata{"content-type": {"application/grpc"}}, ErrReservedKey},
+		{"control character", Metadata{"user": {"a\nb"}}, ErrInvalidValue},
+		{"non-ASCII", Metadata{"user": {"bjørn"}}, ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{"Keep": {"x"}}
+			tt.md["ok"] = []string{"fine"}
+			if err := ToHeader(tt.md, h); err != tt.err {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if len(h) != 1 {
+				t.Errorf("header changed on error: %v", h)
+			}
+		})
+	}
+
+	// Binary values may hold any bytes.
+	if err := ToHeader(Metadata{"raw-bin": {"bjørn\n"}}, make(http.Header)); err != nil {
+		t.Errorf("binary value rejected: %v", err)
+	}
+}
+
+func TestFromHeader(t *testing.T) {
+	h := http.Header{
+		"Trace-Bin":    {"AAE, /w==", "AAEC"},
+		"User":         {"alice"},
+		"Grpc-Timeout": {"1S"},
+		"Content-Type": {"application/grpc"},
+		"Te":           {"trailers"},
+		"Bad Key":      {"x"},
+	}
+	md, err := FromHeader(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{
+		"trace-bin": {"\x00\x01", "\xff", "\x00\x01\x02"},
+		"user":      {"alice"},
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("FromHeader = %q, want %q", md, want)
+	}
+	if got := md.Get("USER"); len(got) != 1 || got[0] != "alice" {
+		t.Errorf("Get = %q", got)
+	}
+
+	if _, err := FromHeader(http.Header{"Trace-Bin": {"AAE, !!"}}); err != ErrInvalidValue {
+		t.Errorf("malformed binary value: %v", err)
+	}
+
+	// Metadata survives a trip through HTTP fields.
+	md = Metadata{"a-bin": {"\x00\xfe\xff", ""}, "b": {"1", "2"}}
+	h = make(http.Header)
+	if err := ToHeader(md, h); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := FromHeader(h); err != nil || !reflect.DeepEqual(got, md) {
+		t.Errorf("round trip = %q, %v; want %q", got, err, md)
+	}
+}
+
+func TestIncomingMetadata(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://api.example.com/v1/items", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("Authorization", "Bearer t")
+	r.Header.Set("Grpc-Metadata-Tenant", "acme")
+	r.Header.Set("Grpc-Metadata-Trace-Bin", "AAE=")
+	r.Header.Set("Grpc-Metadata-Grpc-Status", "0")
+	r.Header.Set("Grpc-Metadata-", "empty")
+	r.Header.Set("X-Request-Id", "dropped")
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	md, err := IncomingMetadata(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Metadata{
+		"grpcgateway-authorization": {"Bearer t"},
+		"tenant":                    {"acme"},
+		"trace-bin":                 {"\x00\x01"},
+		"x-forwarded-host":          {"api.example.com"},
+		"x-forwarded-for":           {"203.0.113.7, 192.0.2.1"},
+	}
+	if !reflect.DeepEqual(md, want) {
+		t.Errorf("IncomingMetadata = %q, want %q", md, want)
+	}
+
+	r.Header.Set("Grpc-Metadata-Trace-Bin", "!")
+	if _, err := IncomingMetadata(r); err != ErrInvalidValue {
+		t.Errorf("malformed binary value: %v", err)
+	}
+}
+
+func TestSetResponseHeader(t *testing.T) {
+	md := Metadata{"trace-bin": {"\x00\x01"}, "note": {"ok", "bad\r\n"}}
+	h := make(http.Header)
+	SetResponseHeader(h, md)
+	SetResponseTrailer(h, Metadata{"count": {"3"}})
+	want := http.Header{
+		"Grpc-Metadata-Trace-Bin": {"AAE"},
+		"Grpc-Metadata-Note":      {"ok"},
+		"Grpc-Trailer-Count":      {"3"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("header = %v, want %v", h, want)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  error
+	}{
+		{"0n", 0, nil},
+		{"1n", time.Nanosecond, nil},
+		{"250u", 250 * time.Microsecond, nil},
+		{"100m", 100 * time.Millisecond, nil},
+		{"5S", 5 * time.Second, nil},
+		{"2M", 2 * time.Minute, nil},
+		{"1H", time.Hour, nil},
+		{"00000007S", 7 * time.Second, nil},
+		{"99999999S", 99999999 * time.Second, nil},
+		{"99999999H", time.Duration(math.MaxInt64), nil},
+		{"100000000S", 0, ErrInvalidTimeout},
+		{"1s", 0, ErrInvalidTimeout},
+		{"1h", 0, ErrInvalidTimeout},
+		{"1", 0, ErrInvalidTimeout},
+		{"S", 0, ErrInvalidTimeout},
+		{"", 0, ErrInvalidTimeout},
+		{"-1S", 0, ErrInvalidTimeout},
+		{"+1S", 0, ErrInvalidTimeout},
+		{"1 S", 0, ErrInvalidTimeout},
+		{"1.5S", 0, ErrInvalidTimeout},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeout(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseTimeout(%q) = %v, %v; want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestFormatTimeout(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want string
+	}{
+		{0, "0n"},
+		{-time.Second, "0n"},
+		{time.Nanosecond, "1n"},
+		{99999999 * time.Nanosecond, "99999999n"},
+		{100 * time.Millisecond, "100000u"},
+		{100*time.Millisecond + 1, "100001u"}, // rounded up
+		{30 * time.Second, "30000000u"},
+		{time.Hour, "3600000m"},
+		{200 * time.Hour, "720000S"},
+		{time.Duration(math.MaxInt64), "2562048H"},
+	}
+	for _, tt := range tests {
+		got := FormatTimeout(tt.in)
+		if got != tt.want {
+			t.Errorf("FormatTimeout(%v) = %q, want %q", tt.in, got, tt.want)
+		}
+		if d, err := ParseTimeout(got); err != nil || tt.in > 0 && d < tt.in {
+			t.Errorf("ParseTimeout(%q) = %v, %v; shorter than %v", got, d, err, tt.in)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	s := Status{Code: NotFound, Message: "100% gone ✓\n", Details: []byte{0x08, 0x05}}
+	h := http.Header{HeaderMessage: {"stale"}}
+	s.SetHeader(h)
+	if got := h.Get(HeaderMessage); got != "100%25 gone %E2%9C%93%0A" {
+		t.Errorf("grpc-message = %q", got)
+	}
+	if got := h.Get(HeaderStatusDetails); got != "CAU" {
+		t.Errorf("grpc-status-details-bin = %q", got)
+	}
+	got, ok, err := ParseStatus(h)
+	if !ok || err != nil || !reflect.DeepEqual(got, s) {
+		t.Errorf("ParseStatus = %+v, %v, %v; want %+v", got, ok, err, s)
+	}
+
+	Status{Code: OK}.SetHeader(h)
+	if len(h[HeaderMessage]) != 0 || len(h[HeaderStatusDetails]) != 0 {
+		t.Errorf("stale fields kept: %v", h)
+	}
+	if _, ok, err := ParseStatus(http.Header{}); ok || err != nil {
+		t.Errorf("missing grpc-status = %v, %v", ok, err)
+	}
+	for _, v := range []string{"-1", "x", "4294967296"} {
+		if _, ok, err := ParseStatus(http.Header{HeaderStatus: {v}}); !ok || err != ErrInvalidStatus {
+			t.Errorf("ParseStatus(%q) = %v, %v", v, ok, err)
+		}
+	}
+
+	// Malformed percent-encodings are kept verbatim.
+	for in, want := range map[string]string{
+		"a%2": "a%2", "%zz": "%zz", "50%": "50%", "%41%e2%9c%93": "A✓",
+	} {
+		if got := DecodeMessage(in); got != want {
+			t.Errorf("DecodeMessage(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if Unavailable.HTTPStatus() != 503 || CodeFromHTTPStatus(404) != Unimplemented || Code(99).String() != "CODE(99)" {
+		t.Error("status code mapping")
+	}
+}
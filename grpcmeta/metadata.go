@@ -0,0 +1,179 @@
+// Package grpcmeta maps gRPC metadata to HTTP fields and back following the
+// gRPC over HTTP/2 protocol, and parses the grpc-timeout, grpc-status and
+// grpc-message fields, without depending on grpc-go.
+//
+// Metadata keys are lower-case. Keys ending in "-bin" carry binary values,
+// which are base64 encoded on the wire. Keys starting with "grpc-" are
+// reserved for the protocol itself.
+package grpcmeta
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// BinarySuffix marks metadata keys with binary values.
+const BinarySuffix = "-bin"
+
+// ReservedPrefix starts keys reserved for gRPC.
+const ReservedPrefix = "grpc-"
+
+var (
+	// ErrInvalidKey is returned for keys with characters other than
+	// lower-case letters, digits, "-", "_" and ".".
+	ErrInvalidKey = errors.New("grpcmeta: invalid metadata key")
+	// ErrReservedKey is returned when user metadata uses a reserved key.
+	ErrReservedKey = errors.New("grpcmeta: reserved metadata key")
+	// ErrInvalidValue is returned for ASCII values with characters outside
+	// printable ASCII, and for malformed base64 in binary values.
+	ErrInvalidValue = errors.New("grpcmeta: invalid metadata value")
+)
+
+// Metadata maps lower-case keys to values. Values of binary keys hold the
+// decoded bytes.
+type Metadata map[string][]string
+
+// Get returns the values for key, which is matched case-insensitively.
+func (md Metadata) Get(key string) []string {
+	return md[strings.ToLower(key)]
+}
+
+// Append adds values for key, lower-casing it.
+func (md Metadata) Append(key string, values ...string) {
+	key = strings.ToLower(key)
+	md[key] = append(md[key], values...)
+}
+
+// ValidKey reports whether key is a valid metadata key.
+func ValidKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// IsBinary reports whether key carries binary values.
+func IsBinary(key string) bool {
+	return strings.HasSuffix(strings.ToLower(key), BinarySuffix)
+}
+
+// IsReserved reports whether key is reserved for gRPC.
+func IsReserved(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), ReservedPrefix)
+}
+
+// ValidValue reports whether v may be sent as the value of a non-binary key:
+// printable ASCII including space.
+func ValidValue(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if v[i] < 0x20 || v[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// EncodeBinary encodes a binary value as unpadded base64, as gRPC
+// implementations send it.
+func EncodeBinary(b []byte) string {
+	return base64.RawStdEncoding.EncodeToString(b)
+}
+
+// DecodeBinary decodes a binary value, accepting padded and unpadded
+// base64.
+func DecodeBinary(s string) ([]byte, error) {
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, ErrInvalidValue
+	}
+	return b, nil
+}
+
+// transportFields are HTTP/2 fields used by the gRPC transport itself,
+// which are neither metadata nor forwarded as such.
+var transportFields = map[string]bool{
+	"content-type":      true,
+	"te":                true,
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"host":              true,
+	"content-length":    true,
+}
+
+// IsTransportField reports whether the HTTP field name is used by the gRPC
+// transport and thus never mapped to metadata.
+func IsTransportField(name string) bool {
+	return transportFields[strings.ToLower(name)]
+}
+
+// ToHeader adds md to h. Binary values are base64 encoded. Reserved keys,
+// invalid keys and values that cannot be sent are errors, and h is left
+// unchanged in that case.
+func ToHeader(md Metadata, h http.Header) error {
+	keys := make([]string, 0, len(md))
+	for key := range md {
+		if !ValidKey(key) {
+			return ErrInvalidKey
+		}
+		if IsReserved(key) || IsTransportField(key) {
+			return ErrReservedKey
+		}
+		if !IsBinary(key) {
+			for _, v := range md[key] {
+				if !ValidValue(v) {
+					return ErrInvalidValue
+				}
+			}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, v := range md[key] {
+			if IsBinary(key) {
+				v = EncodeBinary([]byte(v))
+			}
+			h.Add(key, v)
+		}
+	}
+	return nil
+}
+
+// FromHeader extracts metadata from h, skipping reserved and transport
+// fields. Binary values are decoded; since HTTP/1.1 intermediaries may
+// combine repeated fields, a binary field value is split on commas first.
+func FromHeader(h http.Header) (Metadata, error) {
+	md := make(Metadata)
+	for name, values := range h {
+		key := strings.ToLower(name)
+		if IsReserved(key) || IsTransportField(key) || !ValidKey(key) {
+			continue
+		}
+		for _, v := range values {
+			if !IsBinary(key) {
+				md[key] = append(md[key], v)
+				continue
+			}
+			for _, part := range strings.Split(v, ",") {
+				b, err := DecodeBinary(strings.TrimSpace(part))
+				if err != nil {
+					return nil, err
+				}
+				md[key] = append(md[key], string(b))
+			}
+		}
+	}
+	return md, nil
+}
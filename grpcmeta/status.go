@@ -0,0 +1,190 @@
+package grpcmeta
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/palsivertsen/gohttpfields/internal/ascii"
+)
+
+// Fields carrying the outcome of a call, usually sent as trailers.
+const (
+	HeaderStatus        = "Grpc-Status"
+	HeaderMessage       = "Grpc-Message"
+	HeaderStatusDetails = "Grpc-Status-Details-Bin"
+)
+
+// ErrInvalidStatus is returned for grpc-status values that are not
+// non-negative decimal integers.
+var ErrInvalidStatus = errors.New("grpcmeta: invalid grpc-status")
+
+// Code is a gRPC status code.
+type Code uint32
+
+// Status codes defined by gRPC.
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+var codeNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// String returns the canonical name of c, such as "NOT_FOUND".
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "CODE(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+// HTTPStatus returns the HTTP status code a gateway responds with for c,
+// following the mapping of grpc-gateway.
+func (c Code) HTTPStatus() int {
+	switch c {
+	case OK:
+		return http.StatusOK
+	case Canceled:
+		return 499
+	case InvalidArgument, FailedPrecondition, OutOfRange:
+		return http.StatusBadRequest
+	case DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case NotFound:
+		return http.StatusNotFound
+	case AlreadyExists, Aborted:
+		return http.StatusConflict
+	case PermissionDenied:
+		return http.StatusForbidden
+	case Unauthenticated:
+		return http.StatusUnauthorized
+	case ResourceExhausted:
+		return http.StatusTooManyRequests
+	case Unimplemented:
+		return http.StatusNotImplemented
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// CodeFromHTTPStatus returns the code a client reports for an HTTP
+// response without grpc-status, as specified in gRPC's "HTTP to gRPC
+// Status Code Mapping".
+func CodeFromHTTPStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return Internal
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return Unavailable
+	}
+	return Unknown
+}
+
+// Status is the outcome of a call.
+type Status struct {
+	Code    Code
+	Message string // decoded grpc-message
+	Details []byte // serialized google.rpc.Status, if any
+}
+
+// ParseStatus reads the status fields from h, which is usually the trailer
+// of a response. ok is false when h has no grpc-status.
+func ParseStatus(h http.Header) (s Status, ok bool, err error) {
+	v := h.Get(HeaderStatus)
+	if v == "" {
+		return s, false, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return s, true, ErrInvalidStatus
+	}
+	s.Code = Code(n)
+	s.Message = DecodeMessage(h.Get(HeaderMessage))
+	if d := h.Get(HeaderStatusDetails); d != "" {
+		if s.Details, err = DecodeBinary(d); err != nil {
+			return s, true, err
+		}
+	}
+	return s, true, nil
+}
+
+// SetHeader sets the status fields in h, omitting empty ones.
+func (s Status) SetHeader(h http.Header) {
+	h.Set(HeaderStatus, strconv.FormatUint(uint64(s.Code), 10))
+	h.Del(HeaderMessage)
+	h.Del(HeaderStatusDetails)
+	if s.Message != "" {
+		h.Set(HeaderMessage, EncodeMessage(s.Message))
+	}
+	if len(s.Details) > 0 {
+		h.Set(HeaderStatusDetails, EncodeBinary(s.Details))
+	}
+}
+
+// EncodeMessage percent-encodes a grpc-message value: bytes outside
+// printable ASCII and "%" are encoded, operating on the UTF-8 bytes.
+func EncodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			b.WriteByte('%')
+			b.WriteByte(ascii.UpperHex[c>>4])
+			b.WriteByte(ascii.UpperHex[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// DecodeMessage decodes a grpc-message value. Malformed percent-encodings
+// are kept verbatim rather than rejected, as the specification requires.
+func DecodeMessage(s string) string {
+	if strings.IndexByte(s, '%') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if hi, ok := ascii.Unhex(s[i+1]); ok {
+				if lo, ok := ascii.Unhex(s[i+2]); ok {
+					b.WriteByte(hi<<4 | lo)
+					i += 2
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package grpcmeta
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// HeaderTimeout is the field carrying the deadline of a call.
+const HeaderTimeout = "Grpc-Timeout"
+
+// ErrInvalidTimeout is returned for grpc-timeout values that are not one to
+// eight digits followed by a unit.
+var ErrInvalidTimeout = errors.New("grpcmeta: invalid grpc-timeout")
+
+// maxTimeoutValue is the largest value of eight digits.
+const maxTimeoutValue = 99999999
+
+var timeoutUnits = []struct {
+	unit byte
+	d    time.Duration
+}{
+	{'n', time.Nanosecond},
+	{'u', time.Microsecond},
+	{'m', time.Millisecond},
+	{'S', time.Second},
+	{'M', time.Minute},
+	{'H', time.Hour},
+}
+
+// ParseTimeout parses a grpc-timeout value. Values too large for a
+// time.Duration saturate.
+func ParseTimeout(s string) (time.Duration, error) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, ErrInvalidTimeout
+	}
+	digits, unit := s[:len(s)-1], s[len(s)-1]
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, ErrInvalidTimeout
+		}
+	}
+	n, _ := strconv.ParseInt(digits, 10, 64)
+	for _, u := range timeoutUnits {
+		if u.unit == unit {
+			if n > math.MaxInt64/int64(u.d) {
+				return time.Duration(math.MaxInt64), nil
+			}
+			return time.Duration(n) * u.d, nil
+		}
+	}
+	return 0, ErrInvalidTimeout
+}
+
+// FormatTimeout formats d as a grpc-timeout value using the finest unit
+// that fits in eight digits. Timeouts are rounded up so that the receiver
+// never sees a shorter deadline; non-positive durations format as "0n".
+func FormatTimeout(d time.Duration) string {
+	if d <= 0 {
+		return "0n"
+	}
+	for _, u := range timeoutUnits {
+		n := d / u.d
+		if d%u.d != 0 {
+			n++
+		}
+		if n <= maxTimeoutValue {
+			return strconv.FormatInt(int64(n), 10) + string(u.unit)
+		}
+	}
+	return strconv.Itoa(maxTimeoutValue) + "H"
+}
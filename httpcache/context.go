@@ -0,0 +1,19 @@
+package httpcache
+
+import (
+	"context"
+	"time"
+)
+
+// contextWithoutCancel keeps the values of a context but never expires.
+type contextWithoutCancel struct {
+	parent context.Context
+}
+
+func (contextWithoutCancel) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (contextWithoutCancel) Done() <-chan struct{}       { return nil }
+func (contextWithoutCancel) Err() error                  { return nil }
+
+func (c contextWithoutCancel) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
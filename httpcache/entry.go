@@ -0,0 +1,188 @@
+package httpcache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// entry is a stored response together with the metadata needed to compute
+// its age and to select it for later requests.
+type entry struct {
+	StatusCode   int
+	Header       http.Header
+	Body         []byte
+	RequestTime  time.Time
+	ResponseTime time.Time
+
+	// Vary holds the request header values selected by the Vary field of
+	// the response, keyed by canonical field name.
+	Vary map[string]string
+}
+
+func decodeEntry(b []byte) (*entry, error) {
+	var e entry
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (e *entry) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(e); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *entry) cacheControl() httpfields.CacheControl {
+	return httpfields.ParseCacheControl(e.Header["Cache-Control"]...)
+}
+
+// date returns the Date field, falling back to the response time when it is
+// missing or invalid.
+func (e *entry) date() time.Time {
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return t
+	}
+	return e.ResponseTime
+}
+
+// heuristicStatus lists the status codes that are heuristically cacheable
+// (RFC 9110 section 15.1), except 206 which is never stored.
+var heuristicStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+// freshnessLifetime implements RFC 9111 section 4.2.1 for a private cache.
+func (e *entry) freshnessLifetime() time.Duration {
+	cc := e.cacheControl()
+	if d, ok := cc.Seconds(httpfields.DirectiveMaxAge); ok {
+		return d
+	}
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			// Invalid dates, notably "0", represent a time in the past.
+			return 0
+		}
+		return expires.Sub(e.date())
+	}
+	if !heuristicStatus[e.StatusCode] && !cc.Has(httpfields.DirectivePublic) {
+		return 0
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		if d := e.date().Sub(lm); d > 0 {
+			return d / 10
+		}
+	}
+	return 0
+}
+
+// age implements RFC 9111 section 4.2.3.
+func (e *entry) age(now time.Time) time.Duration {
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+	apparentAge := e.ResponseTime.Sub(e.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+	correctedAgeValue := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	correctedInitialAge := apparentAge
+	if correctedAgeValue > correctedInitialAge {
+		correctedInitialAge = correctedAgeValue
+	}
+	return correctedInitialAge + now.Sub(e.ResponseTime)
+}
+
+// matches reports whether the request selects this entry according to the
+// stored Vary values (RFC 9111 section 4.1).
+func (e *entry) matches(req *http.Request) bool {
+	for name, value := range e.Vary {
+		if normalizeFieldValue(req.Header[name]) != value {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeFieldValue(values []string) string {
+	var members []string
+	for _, v := range values {
+		members = append(members, httpfields.SplitList(v)...)
+	}
+	return strings.Join(members, ", ")
+}
+
+// update merges the header fields of a 304 response into the entry (RFC 9111
+// section 4.3.4).
+func (e *entry) update(resp *http.Response, requestTime, responseTime time.Time) {
+	for name, values := range storableHeader(resp.Header) {
+		if name == "Content-Length" {
+			continue
+		}
+		e.Header[name] = values
+	}
+	e.RequestTime = requestTime
+	e.ResponseTime = responseTime
+}
+
+func (e *entry) response(req *http.Request, now time.Time, status string) *http.Response {
+	h := make(http.Header, len(e.Header)+2)
+	for name, values := range e.Header {
+		h[name] = append([]string(nil), values...)
+	}
+	h.Set("Age", strconv.FormatInt(int64(e.age(now)/time.Second), 10))
+	h.Set(StatusHeader, status)
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h,
+		Body:          ioutil.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+// hopByHop lists the fields that are never stored (RFC 9111 section 3.1).
+var hopByHop = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+	StatusHeader,
+}
+
+// storableHeader returns a copy of h without hop-by-hop fields, fields
+// nominated by Connection, and fields named by a qualified no-cache directive.
+func storableHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		out[name] = append([]string(nil), values...)
+	}
+	for _, v := range h["Connection"] {
+		for _, name := range httpfields.SplitList(v) {
+			out.Del(name)
+		}
+	}
+	if fields := httpfields.ParseCacheControl(h["Cache-Control"]...)[httpfields.DirectiveNoCache]; fields != "" {
+		for _, name := range httpfields.SplitList(fields) {
+			out.Del(name)
+		}
+	}
+	for _, name := range hopByHop {
+		out.Del(name)
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package httpcache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryStore is an in-memory Store that evicts the least recently used
+// entries once MaxEntries or MaxBytes is exceeded. A zero limit disables it.
+type MemoryStore struct {
+	MaxEntries int
+	MaxBytes   int64
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	bytes int64
+}
+
+type memoryItem struct {
+	key   string
+	value []byte
+}
+
+// NewMemoryStore returns a MemoryStore with the given limits.
+func NewMemoryStore(maxEntries int, maxBytes int64) *MemoryStore {
+	return &MemoryStore{MaxEntries: maxEntries, MaxBytes: maxBytes}
+}
+
+// Get implements Store.
+func (s *MemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.ll.MoveToFront(e)
+	return e.Value.(*memoryItem).value, true, nil
+}
+
+// Set implements Store.
+func (s *MemoryStore) Set(key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items == nil {
+		s.items = make(map[string]*list.Element)
+		s.ll = list.New()
+	}
+	if e, ok := s.items[key]; ok {
+		item := e.Value.(*memoryItem)
+		s.bytes += int64(len(value)) - int64(len(item.value))
+		item.value = value
+		s.ll.MoveToFront(e)
+	} else {
+		s.items[key] = s.ll.PushFront(&memoryItem{key: key, value: value})
+		s.bytes += int64(len(value))
+	}
+	for s.ll.Len() > 0 && (s.MaxEntries > 0 && s.ll.Len() > s.MaxEntries || s.MaxBytes > 0 && s.bytes > s.MaxBytes) {
+		s.removeElement(s.ll.Back())
+	}
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.items[key]; ok {
+		s.removeElement(e)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ll == nil {
+		return 0
+	}
+	return s.ll.Len()
+}
+
+func (s *MemoryStore) removeElement(e *list.Element) {
+	item := s.ll.Remove(e).(*memoryItem)
+	delete(s.items, item.key)
+	s.bytes -= int64(len(item.value))
+}
@@ -0,0 +1,13 @@
+package httpcache
+
+// Store persists cache entries. Keys are opaque strings derived from the
+// request; values are encoded entries. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Get returns the value stored under key and whether it was found.
+	Get(key string) ([]byte, bool, error)
+	// Set stores value under key, replacing any previous value.
+	Set(key string, value []byte) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+}
@@ -0,0 +1,444 @@
+// Package httpcache implements a private HTTP cache (RFC 9111) as an
+// http.RoundTripper.
+//
+// Responses to GET requests are stored in a pluggable Store, reused while
+// fresh, and revalidated with conditional requests once stale. The
+// stale-while-revalidate and stale-if-error extensions (RFC 5861) are
+// supported, the former by revalidating in the background.
+package httpcache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// StatusHeader is the response field the Transport sets to describe how a
+// response was produced.
+const StatusHeader = "X-Cache"
+
+// Values of StatusHeader.
+const (
+	StatusHit         = "HIT"         // served from the cache without contacting the origin
+	StatusMiss        = "MISS"        // fetched from the origin
+	StatusRevalidated = "REVALIDATED" // served from the cache after a 304 response
+	StatusStale       = "STALE"       // served stale, see stale-while-revalidate and stale-if-error
+)
+
+// DefaultMaxEntrySize is the largest body stored when Transport.MaxEntrySize
+// is zero.
+const DefaultMaxEntrySize = 1 << 20
+
+// Transport is a caching http.RoundTripper. Only GET requests without Range
+// or conditional header fields are answered from the cache; other requests
+// are forwarded, and unsafe methods invalidate the entries of the URLs they
+// affect.
+type Transport struct {
+	// Transport is the underlying RoundTripper. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Store holds the cache entries.
+	Store Store
+
+	// MaxEntrySize is the largest response body stored. Larger responses
+	// are passed through. Zero selects DefaultMaxEntrySize.
+	MaxEntrySize int64
+
+	// OnStoreError, if set, is called with errors returned by Store. The
+	// cache otherwise treats them as misses.
+	OnStoreError func(key string, err error)
+
+	mu         sync.Mutex
+	revalidate map[string]bool // keys being revalidated in the background
+}
+
+// NewTransport returns a Transport that caches responses in store.
+func NewTransport(store Store) *Transport {
+	return &Transport{Store: store}
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+func (t *Transport) maxEntrySize() int64 {
+	if t.MaxEntrySize > 0 {
+		return t.MaxEntrySize
+	}
+	return DefaultMaxEntrySize
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp, err := t.transport().RoundTrip(req)
+		if err == nil && !isSafe(req.Method) && resp.StatusCode < 400 {
+			t.invalidate(req, resp)
+		}
+		return resp, err
+	}
+	reqCC := httpfields.ParseCacheControl(req.Header["Cache-Control"]...)
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" || isConditional(req) || reqCC.Has(httpfields.DirectiveNoStore) {
+		return t.transport().RoundTrip(req)
+	}
+
+	key := cacheKey(req)
+	e := t.load(key)
+	if e != nil && !e.matches(req) {
+		e = nil
+	}
+	now := time.Now()
+	if e == nil {
+		if reqCC.Has(httpfields.DirectiveOnlyIfCached) {
+			return gatewayTimeout(req), nil
+		}
+		return t.fetch(key, req, now)
+	}
+
+	switch t.evaluate(e, reqCC, now) {
+	case useFresh:
+		return e.response(req, now, StatusHit), nil
+	case useStaleAndRevalidate:
+		resp := e.response(req, now, StatusStale)
+		t.revalidateInBackground(key, req, e)
+		return resp, nil
+	}
+	if reqCC.Has(httpfields.DirectiveOnlyIfCached) {
+		return gatewayTimeout(req), nil
+	}
+	return t.revalidateEntry(key, req, e, reqCC)
+}
+
+// Decisions returned by evaluate.
+const (
+	mustValidate = iota
+	useFresh
+	useStaleAndRevalidate
+)
+
+// evaluate decides whether the entry can be used without contacting the
+// origin, honouring both request and response directives.
+func (t *Transport) evaluate(e *entry, reqCC httpfields.CacheControl, now time.Time) int {
+	respCC := e.cacheControl()
+	if reqCC.Has(httpfields.DirectiveNoCache) || respCC.Has(httpfields.DirectiveNoCache) {
+		return mustValidate
+	}
+	if e.Header.Get("Pragma") == "no-cache" && !respCC.Has(httpfields.DirectiveMaxAge) {
+		return mustValidate
+	}
+	lifetime := e.freshnessLifetime()
+	age := e.age(now)
+	if maxAge, ok := reqCC.Seconds(httpfields.DirectiveMaxAge); ok && age > maxAge {
+		return mustValidate
+	}
+	if minFresh, ok := reqCC.Seconds(httpfields.DirectiveMinFresh); ok {
+		lifetime -= minFresh
+	}
+	if lifetime > age {
+		return useFresh
+	}
+	if respCC.Has(httpfields.DirectiveMustRevalidate) {
+		return mustValidate
+	}
+	staleness := age - lifetime
+	if v, ok := reqCC[httpfields.DirectiveMaxStale]; ok {
+		if v == "" {
+			return useFresh
+		}
+		if maxStale, ok := reqCC.Seconds(httpfields.DirectiveMaxStale); ok && staleness <= maxStale {
+			return useFresh
+		}
+	}
+	if swr, ok := respCC.Seconds(httpfields.DirectiveStaleWhileRevalidate); ok && staleness <= swr {
+		return useStaleAndRevalidate
+	}
+	return mustValidate
+}
+
+// canServeStaleOnError implements the stale-if-error extension.
+func canServeStaleOnError(e *entry, reqCC httpfields.CacheControl, now time.Time) bool {
+	respCC := e.cacheControl()
+	if respCC.Has(httpfields.DirectiveMustRevalidate) || respCC.Has(httpfields.DirectiveNoCache) {
+		return false
+	}
+	staleness := e.age(now) - e.freshnessLifetime()
+	if d, ok := reqCC.Seconds(httpfields.DirectiveStaleIfError); ok {
+		return staleness <= d
+	}
+	d, ok := respCC.Seconds(httpfields.DirectiveStaleIfError)
+	return ok && staleness <= d
+}
+
+// revalidateEntry sends a conditional request for a stale entry.
+func (t *Transport) revalidateEntry(key string, req *http.Request, e *entry, reqCC httpfields.CacheControl) (*http.Response, error) {
+	creq := conditionalRequest(req, e)
+	requestTime := time.Now()
+	resp, err := t.transport().RoundTrip(creq)
+	if err != nil {
+		if canServeStaleOnError(e, reqCC, time.Now()) {
+			return e.response(req, time.Now(), StatusStale), nil
+		}
+		return nil, err
+	}
+	responseTime := time.Now()
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		drain(resp.Body)
+		e.update(resp, requestTime, responseTime)
+		t.save(key, e)
+		return e.response(req, responseTime, StatusRevalidated), nil
+	case resp.StatusCode >= 500 && canServeStaleOnError(e, reqCC, responseTime):
+		drain(resp.Body)
+		return e.response(req, responseTime, StatusStale), nil
+	}
+	resp.Request = req
+	return t.storeResponse(key, req, resp, requestTime, responseTime)
+}
+
+func (t *Transport) revalidateInBackground(key string, req *http.Request, e *entry) {
+	t.mu.Lock()
+	if t.revalidate[key] {
+		t.mu.Unlock()
+		return
+	}
+	if t.revalidate == nil {
+		t.revalidate = make(map[string]bool)
+	}
+	t.revalidate[key] = true
+	t.mu.Unlock()
+
+	// Detach from the caller's context, which ends with the stale response
+	// being delivered.
+	breq := cloneRequest(req)
+	breq = breq.WithContext(contextWithoutCancel{req.Context()})
+	go func() {
+		defer func() {
+			t.mu.Lock()
+			delete(t.revalidate, key)
+			t.mu.Unlock()
+		}()
+		resp, err := t.revalidateEntry(key, breq, e, nil)
+		if err == nil {
+			drain(resp.Body)
+		}
+	}()
+}
+
+// fetch forwards a request that the cache cannot answer.
+func (t *Transport) fetch(key string, req *http.Request, requestTime time.Time) (*http.Response, error) {
+	resp, err := t.transport().RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	return t.storeResponse(key, req, resp, requestTime, time.Now())
+}
+
+// storeResponse stores resp if permitted and returns a response for the
+// caller.
+func (t *Transport) storeResponse(key string, req *http.Request, resp *http.Response, requestTime, responseTime time.Time) (*http.Response, error) {
+	resp.Header.Set(StatusHeader, StatusMiss)
+	if !isStorable(resp) {
+		return resp, nil
+	}
+	limit := t.maxEntrySize()
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if n > limit {
+		resp.Body = readCloser{io.MultiReader(&buf, resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(buf.Bytes()))
+
+	e := &entry{
+		StatusCode:   resp.StatusCode,
+		Header:       storableHeader(resp.Header),
+		Body:         buf.Bytes(),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+		Vary:         make(map[string]string),
+	}
+	for _, v := range resp.Header["Vary"] {
+		for _, name := range httpfields.SplitList(v) {
+			name = http.CanonicalHeaderKey(name)
+			e.Vary[name] = normalizeFieldValue(req.Header[name])
+		}
+	}
+	t.save(key, e)
+	return resp, nil
+}
+
+// understoodStatus lists the status codes whose caching semantics are
+// implemented.
+var understoodStatus = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 302: true,
+	303: true, 307: true, 308: true, 404: true, 405: true, 410: true,
+	414: true, 501: true,
+}
+
+// isStorable implements RFC 9111 section 3 for a private cache.
+func isStorable(resp *http.Response) bool {
+	if !understoodStatus[resp.StatusCode] {
+		return false
+	}
+	cc := httpfields.ParseCacheControl(resp.Header["Cache-Control"]...)
+	if cc.Has(httpfields.DirectiveNoStore) {
+		return false
+	}
+	for _, v := range resp.Header["Vary"] {
+		for _, name := range httpfields.SplitList(v) {
+			if name == "*" {
+				return false
+			}
+		}
+	}
+	if resp.Header.Get("Expires") != "" || cc.Has(httpfields.DirectiveMaxAge) ||
+		cc.Has(httpfields.DirectivePublic) || cc.Has(httpfields.DirectivePrivate) ||
+		cc.Has(httpfields.DirectiveNoCache) {
+		return true
+	}
+	return heuristicStatus[resp.StatusCode]
+}
+
+// invalidate removes the entries affected by a successful unsafe request
+// (RFC 9111 section 4.4).
+func (t *Transport) invalidate(req *http.Request, resp *http.Response) {
+	t.delete(cacheKeyURL(req.URL))
+	for _, name := range []string{"Location", "Content-Location"} {
+		v := resp.Header.Get(name)
+		if v == "" {
+			continue
+		}
+		u, err := req.URL.Parse(v)
+		if err != nil || u.Scheme != req.URL.Scheme || u.Host != req.URL.Host {
+			continue
+		}
+		t.delete(cacheKeyURL(u))
+	}
+}
+
+func (t *Transport) load(key string) *entry {
+	b, ok, err := t.Store.Get(key)
+	if err != nil {
+		t.storeError(key, err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	e, err := decodeEntry(b)
+	if err != nil {
+		t.storeError(key, err)
+		return nil
+	}
+	return e
+}
+
+func (t *Transport) save(key string, e *entry) {
+	b, err := e.encode()
+	if err == nil {
+		err = t.Store.Set(key, b)
+	}
+	if err != nil {
+		t.storeError(key, err)
+	}
+}
+
+func (t *Transport) delete(key string) {
+	if err := t.Store.Delete(key); err != nil {
+		t.storeError(key, err)
+	}
+}
+
+func (t *Transport) storeError(key string, err error) {
+	if t.OnStoreError != nil {
+		t.OnStoreError(key, err)
+	}
+}
+
+func cacheKey(req *http.Request) string {
+	return cacheKeyURL(req.URL)
+}
+
+func cacheKeyURL(u *url.URL) string {
+	v := *u
+	v.Fragment = ""
+	return v.String()
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isConditional(req *http.Request) bool {
+	for _, name := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range"} {
+		if req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func conditionalRequest(req *http.Request, e *entry) *http.Request {
+	creq := cloneRequest(req)
+	if etag := e.Header.Get("ETag"); etag != "" {
+		creq.Header.Set("If-None-Match", etag)
+	}
+	if lm := e.Header.Get("Last-Modified"); lm != "" {
+		creq.Header.Set("If-Modified-Since", lm)
+	}
+	return creq
+}
+
+// cloneRequest returns a shallow copy of req with its own header map, as
+// RoundTrippers must not modify the caller's request.
+func cloneRequest(req *http.Request) *http.Request {
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header))
+	for name, values := range req.Header {
+		r.Header[name] = append([]string(nil), values...)
+	}
+	return r
+}
+
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 " + http.StatusText(http.StatusGatewayTimeout),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{StatusHeader: {StatusMiss}},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+}
+
+func drain(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, 4<<10))
+	body.Close()
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,413 @@
+package httpcache
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// origin is a RoundTripper that answers with a scripted handler and records
+// the requests it receives.
+type origin struct {
+	mu      sync.Mutex
+	reqs    []*http.Request
+	handler func(req *http.Request) (*http.Response, error)
+}
+
+func (o *origin) RoundTrip(req *http.Request) (*http.Response, error) {
+	o.mu.Lock()
+	o.reqs = append(o.reqs, req)
+	o.mu.Unlock()
+	return o.handler(req)
+}
+
+func (o *origin) count() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.reqs)
+}
+
+func (o *origin) last() *http.Request {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.reqs[len(o.reqs)-1]
+}
+
+func response(req *http.Request, status int, header http.Header, body string) *http.Response {
+	if header.Get("Date") == "" {
+		header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	return &http.Response{
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func newTestTransport(handler func(req *http.Request) (*http.Response, error)) (*Transport, *origin) {
+	o := &origin{handler: handler}
+	tr := NewTransport(NewMemoryStore(0, 0))
+	tr.Transport = o
+	tr.OnStoreError = func(key string, err error) { panic(err) }
+	return tr, o
+}
+
+// get performs a GET through tr and returns the StatusHeader value and body.
+func get(t *testing.T, tr *Transport, url string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(b)
+}
+
+func TestFreshHit(t *testing.T) {
+	tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		return response(req, 200, http.Header{"Cache-Control": {"max-age=60"}}, "hello"), nil
+	})
+	resp, body := get(t, tr, "http://example.com/a", nil)
+	if s := resp.Header.Get(StatusHeader); s != StatusMiss || body != "hello" {
+		t.Fatalf("first request: %s %q", s, body)
+	}
+	resp, body = get(t, tr, "http://example.com/a#fragment", nil)
+	if s := resp.Header.Get(StatusHeader); s != StatusHit || body != "hello" {
+		t.Fatalf("second request: %s %q", s, body)
+	}
+	if resp.Header.Get("Age") == "" {
+		t.Error("cached response without Age")
+	}
+	if n := o.count(); n != 1 {
+		t.Errorf("origin saw %d requests, want 1", n)
+	}
+
+	// Request directives can still force a trip to the origin.
+	resp, _ = get(t, tr, "http://example.com/a", http.Header{"Cache-Control": {"no-cache"}})
+	if s := resp.Header.Get(StatusHeader); s != StatusMiss {
+		t.Errorf("request no-cache: %s", s)
+	}
+	resp, _ = get(t, tr, "http://example.com/a", http.Header{"Cache-Control": {"min-fresh=120"}})
+	if s := resp.Header.Get(StatusHeader); s != StatusMiss {
+		t.Errorf("request min-fresh beyond lifetime: %s", s)
+	}
+}
+
+func TestRevalidation(t *testing.T) {
+	tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		h := http.Header{
+			"Cache-Control": {"max-age=0"},
+			"Etag":          {`"v1"`},
+			"Last-Modified": {"Mon, 02 Jan 2006 15:04:05 GMT"},
+		}
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			h.Set("X-Fresh", "yes")
+			h.Set("Content-Length", "0")
+			return response(req, http.StatusNotModified, h, ""), nil
+		}
+		h.Set("Content-Type", "text/plain")
+		return response(req, 200, h, "body"), nil
+	})
+	get(t, tr, "http://example.com/r", nil)
+	resp, body := get(t, tr, "http://example.com/r", nil)
+	if s := resp.Header.Get(StatusHeader); s != StatusRevalidated {
+		t.Fatalf("status %s, want %s", s, StatusRevalidated)
+	}
+	if resp.StatusCode != 200 || body != "body" {
+		t.Errorf("revalidated response: %d %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Fresh") != "yes" || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("304 fields not merged into stored fields: %v", resp.Header)
+	}
+	creq := o.last()
+	if creq.Header.Get("If-None-Match") != `"v1"` || creq.Header.Get("If-Modified-Since") != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("conditional request header: %v", creq.Header)
+	}
+}
+
+func TestConditionalRequestBypassesCache(t *testing.T) {
+	tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		return response(req, 200, http.Header{"Cache-Control": {"max-age=60"}}, "x"), nil
+	})
+	get(t, tr, "http://example.com/", nil)
+	get(t, tr, "http://example.com/", http.Header{"If-None-Match": {`"a"`}})
+	get(t, tr, "http://example.com/", http.Header{"Range": {"bytes=0-0"}})
+	if n := o.count(); n != 3 {
+		t.Errorf("origin saw %d requests, want 3", n)
+	}
+}
+
+func TestNotStored(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+	}{
+		{"no-store", 200, http.Header{"Cache-Control": {"max-age=60, no-store"}}},
+		{"vary star", 200, http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"*"}}},
+		{"partial content", 206, http.Header{"Cache-Control": {"max-age=60"}}},
+		{"not heuristically cacheable", 302, http.Header{}},
+		{"expires in the past", 200, http.Header{"Expires": {"0"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+				h := make(http.Header)
+				for name, values := range tt.header {
+					h[name] = values
+				}
+				return response(req, tt.status, h, "x"), nil
+			})
+			get(t, tr, "http://example.com/", nil)
+			resp, _ := get(t, tr, "http://example.com/", nil)
+			if s := resp.Header.Get(StatusHeader); s != StatusMiss {
+				t.Errorf("second request: %s", s)
+			}
+			if n := o.count(); n != 2 {
+				t.Errorf("origin saw %d requests, want 2", n)
+			}
+		})
+	}
+}
+
+func TestRequestNoStore(t *testing.T) {
+	tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		return response(req, 200, http.Header{"Cache-Control": {"max-age=60"}}, "x"), nil
+	})
+	get(t, tr, "http://example.com/", http.Header{"Cache-Control": {"no-store"}})
+	get(t, tr, "http://example.com/", nil)
+	if n := o.count(); n != 2 {
+		t.Errorf("origin saw %d requests, want 2", n)
+	}
+}
+
+func TestVary(t *testing.T) {
+	tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		h := http.Header{"Cache-Control": {"max-age=60"}, "Vary": {"Accept-Language"}}
+		return response(req, 200, h, req.Header.Get("Accept-Language")), nil
+	})
+	get(t, tr, "http://example.com/", http.Header{"Accept-Language": {"en, de"}})
+	resp, body := get(t, tr, "http://example.com/", http.Header{"Accept-Language": {"en,de"}})
+	if s := resp.Header.Get(StatusHeader); s != StatusHit || body != "en, de" {
+		t.Errorf("normalized value: %s %q", s, body)
+	}
+	resp, body = get(t, tr, "http://example.com/", http.Header{"Accept-Language": {"fr"}})
+	if s := resp.Header.Get(StatusHeader); s != StatusMiss || body != "fr" {
+		t.Errorf("other value: %s %q", s, body)
+	}
+	if n := o.count(); n != 2 {
+		t.Errorf("origin saw %d requests, want 2", n)
+	}
+}
+
+func TestHeuristicFreshness(t *testing.T) {
+	now := time.Now()
+	date := now.UTC().Format(http.TimeFormat)
+	tests := []struct {
+		name         string
+		lastModified time.Time
+		status       string
+	}{
+		// A tenth of ten days is a day.
+		{"old", now.Add(-10 * 24 * time.Hour), StatusHit},
+		// A tenth of five seconds has passed before the second request.
+		{"recent", now.Add(-5 * time.Second), StatusMiss},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _ := newTestTransport(func(req *http.Request) (*http.Response, error) {
+				h := http.Header{
+					"Date":          {date},
+					"Last-Modified": {tt.lastModified.UTC().Format(http.TimeFormat)},
+				}
+				return response(req, 200, h, "x"), nil
+			})
+			get(t, tr, "http://example.com/", nil)
+			time.Sleep(600 * time.Millisecond)
+			resp, _ := get(t, tr, "http://example.com/", nil)
+			if s := resp.Header.Get(StatusHeader); s != tt.status {
+				t.Errorf("status %s, want %s", s, tt.status)
+			}
+		})
+	}
+}
+
+func TestStaleIfError(t *testing.T) {
+	fail := false
+	tr, _ := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		h := http.Header{"Cache-Control": {"max-age=0, stale-if-error=60"}}
+		return response(req, 200, h, "old"), nil
+	})
+	get(t, tr, "http://example.com/", nil)
+	fail = true
+	resp, body := get(t, tr, "http://example.com/", nil)
+	if s := resp.Header.Get(StatusHeader); s != StatusStale || body != "old" {
+		t.Errorf("status %s %q, want stale body", s, body)
+	}
+}
+
+func TestOnlyIfCached(t *testing.T) {
+	tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		return response(req, 200, http.Header{"Cache-Control": {"max-age=60"}}, "x"), nil
+	})
+	resp, _ := get(t, tr, "http://example.com/", http.Header{"Cache-Control": {"only-if-cached"}})
+	if resp.StatusCode != http.StatusGatewayTimeout || o.count() != 0 {
+		t.Errorf("uncached only-if-cached: %d, %d origin requests", resp.StatusCode, o.count())
+	}
+}
+
+func TestInvalidation(t *testing.T) {
+	tr, o := newTestTransport(func(req *http.Request) (*http.Response, error) {
+		h := http.Header{"Cache-Control": {"max-age=60"}}
+		if req.Method == http.MethodPost {
+			h.Set("Location", "/b")
+			h.Set("Content-Location", "http://other.example/a")
+			return response(req, http.StatusCreated, h, ""), nil
+		}
+		return response(req, 200, h, "x"), nil
+	})
+	get(t, tr, "http://example.com/a", nil)
+	get(t, tr, "http://example.com/b", nil)
+	req, _ := http.NewRequest(http.MethodPost, "http://example.com/a", strings.NewReader("y"))
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for _, path := range []string{"/a", "/b"} {
+		resp, _ := get(t, tr, "http://example.com"+path, nil)
+		if s := resp.Header.Get(StatusHeader); s != StatusMiss {
+			t.Errorf("%s after POST: %s", path, s)
+		}
+	}
+	if n := o.count(); n != 5 {
+		t.Errorf("origin saw %d requests, want 5", n)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Now()
+	date := now.Add(-100 * time.Second)
+	tests := []struct {
+		name   string
+		cc     string
+		reqCC  string
+		expect int
+	}{
+		{"fresh", "max-age=200", "", useFresh},
+		{"stale", "max-age=50", "", mustValidate},
+		{"response no-cache", "max-age=200, no-cache", "", mustValidate},
+		{"request max-age", "max-age=200", "max-age=10", mustValidate},
+		{"request min-fresh", "max-age=200", "min-fresh=150", mustValidate},
+		{"unbounded max-stale", "max-age=50", "max-stale", useFresh},
+		{"max-stale covers staleness", "max-age=50", "max-stale=60", useFresh},
+		{"max-stale too short", "max-age=50", "max-stale=10", mustValidate},
+		{"must-revalidate overrides max-stale", "max-age=50, must-revalidate", "max-stale", mustValidate},
+		{"stale-while-revalidate", "max-age=50, stale-while-revalidate=60", "", useStaleAndRevalidate},
+		{"stale-while-revalidate too short", "max-age=50, stale-while-revalidate=10", "", mustValidate},
+	}
+	tr := &Transport{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &entry{
+				StatusCode:   200,
+				Header:       http.Header{"Date": {date.UTC().Format(http.TimeFormat)}, "Cache-Control": {tt.cc}},
+				RequestTime:  date,
+				ResponseTime: date,
+			}
+			reqCC := httpfields.ParseCacheControl(tt.reqCC)
+			if got := tr.evaluate(e, reqCC, now); got != tt.expect {
+				t.Errorf("evaluate = %d, want %d", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestFreshnessLifetime(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   time.Duration
+	}{
+		{"max-age wins over Expires", 200, http.Header{"Cache-Control": {"max-age=5"}, "Expires": {date.Add(time.Hour).Format(http.TimeFormat)}}, 5 * time.Second},
+		{"Expires", 200, http.Header{"Expires": {date.Add(time.Hour).Format(http.TimeFormat)}}, time.Hour},
+		{"invalid Expires", 200, http.Header{"Expires": {"0"}}, 0},
+		{"heuristic", 200, http.Header{"Last-Modified": {date.Add(-100 * time.Hour).Format(http.TimeFormat)}}, 10 * time.Hour},
+		{"heuristic not allowed", 302, http.Header{"Last-Modified": {date.Add(-100 * time.Hour).Format(http.TimeFormat)}}, 0},
+		{"heuristic with public", 302, http.Header{"Cache-Control": {"public"}, "Last-Modified": {date.Add(-100 * time.Hour).Format(http.TimeFormat)}}, 10 * time.Hour},
+		{"nothing", 200, http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.header.Set("Date", date.Format(http.TimeFormat))
+			e := &entry{StatusCode: tt.status, Header: tt.header, ResponseTime: date}
+			if got := e.freshnessLifetime(); got != tt.want {
+				t.Errorf("freshnessLifetime = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAge(t *testing.T) {
+	date := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := &entry{
+		Header:       http.Header{"Date": {date.Format(http.TimeFormat)}, "Age": {"30"}},
+		RequestTime:  date.Add(8 * time.Second),
+		ResponseTime: date.Add(10 * time.Second),
+	}
+	// corrected_age_value = 30 + 2 exceeds apparent_age = 10; then 5
+	// seconds of resident time.
+	if got, want := e.age(date.Add(15*time.Second)), 37*time.Second; got != want {
+		t.Errorf("age = %v, want %v", got, want)
+	}
+}
+
+func TestStorableHeader(t *testing.T) {
+	h := http.Header{
+		"Connection":    {"X-Hop"},
+		"X-Hop":         {"1"},
+		"Keep-Alive":    {"timeout=5"},
+		"Cache-Control": {`no-cache="Set-Cookie"`},
+		"Set-Cookie":    {"a=b"},
+		StatusHeader:    {StatusMiss},
+		"Content-Type":  {"text/plain"},
+	}
+	got := storableHeader(h)
+	for _, name := range []string{"Connection", "X-Hop", "Keep-Alive", "Set-Cookie", StatusHeader} {
+		if _, ok := got[name]; ok {
+			t.Errorf("%s stored", name)
+		}
+	}
+	if got.Get("Content-Type") != "text/plain" || got.Get("Cache-Control") == "" {
+		t.Errorf("end-to-end fields dropped: %v", got)
+	}
+	if h.Get("Set-Cookie") == "" {
+		t.Error("input header modified")
+	}
+}
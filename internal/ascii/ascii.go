@@ -0,0 +1,28 @@
+// Package ascii holds the byte-level helpers shared by the field codecs:
+// hexadecimal digits for percent- and Q-encoding and case-insensitive
+// prefix matching of ASCII names.
+package ascii
+
+import "strings"
+
+// UpperHex are the digits used to write percent-encoded bytes, which RFC
+// 3986 section 2.1 recommends to be upper case.
+const UpperHex = "0123456789ABCDEF"
+
+// Unhex returns the value of the hexadecimal digit c in either case.
+func Unhex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// HasPrefixFold reports whether s begins with prefix, ignoring case.
+func HasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,39 @@
+package ascii
+
+import "testing"
+
+func TestUnhex(t *testing.T) {
+	for i := 0; i < 256; i++ {
+		c := byte(i)
+		got, ok := Unhex(c)
+		want := -1
+		for v := 0; v < 16; v++ {
+			if c == UpperHex[v] || c == "0123456789abcdef"[v] {
+				want = v
+			}
+		}
+		if ok != (want >= 0) || ok && int(got) != want {
+			t.Errorf("Unhex(%q) = %d, %v; want %d", c, got, ok, want)
+		}
+	}
+}
+
+func TestHasPrefixFold(t *testing.T) {
+	tests := []struct {
+		s, prefix string
+		want      bool
+	}{
+		{"ce-id", "ce-", true},
+		{"Ce-Id", "ce-", true},
+		{"CE-", "ce-", true},
+		{"ce", "ce-", false},
+		{"x-ce-id", "ce-", false},
+		{"__Host-a", "__host-", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		if got := HasPrefixFold(tt.s, tt.prefix); got != tt.want {
+			t.Errorf("HasPrefixFold(%q, %q) = %v, want %v", tt.s, tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Package punycode converts internationalized domain names to their ASCII
+// form (RFC 3492, RFC 5891 section 4.4). It does not perform the IDNA
+// mapping or validity checks; labels are only lower-cased.
+package punycode
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	base        = 36
+	tmin        = 1
+	tmax        = 26
+	skew        = 38
+	damp        = 700
+	initialBias = 72
+	initialN    = 128
+)
+
+var errOverflow = errors.New("punycode: overflow")
+
+// ToASCII converts each non-ASCII label of domain to its "xn--" A-label.
+func ToASCII(domain string) (string, error) {
+	if isASCII(domain) {
+		return strings.ToLower(domain), nil
+	}
+	labels := strings.Split(domain, ".")
+	for i, label := range labels {
+		label = strings.ToLower(label)
+		if !isASCII(label) {
+			encoded, err := encode(label)
+			if err != nil {
+				return "", err
+			}
+			label = "xn--" + encoded
+		}
+		labels[i] = label
+	}
+	return strings.Join(labels, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// encode implements the encoding procedure of RFC 3492 section 6.3.
+func encode(s string) (string, error) {
+	var (
+		out   []byte
+		runes = []rune(s)
+		n     = int32(initialN)
+		delta int32
+		bias  = int32(initialBias)
+	)
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	b := int32(len(out))
+	h := b
+	if b > 0 {
+		out = append(out, '-')
+	}
+	for int(h) < len(runes) {
+		m := int32(0x7fffffff)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		if (m - n) > (0x7fffffff-delta)/(h+1) {
+			return "", errOverflow
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+				if delta < 0 {
+					return "", errOverflow
+				}
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := int32(base); ; k += base {
+				t := k - bias
+				if t < tmin {
+					t = tmin
+				} else if t > tmax {
+					t = tmax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, digit(t+(q-t)%(base-t)))
+				q = (q - t) / (base - t)
+			}
+			out = append(out, digit(q))
+			bias = adapt(delta, h+1, h == b)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return string(out), nil
+}
+
+func digit(d int32) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func adapt(delta, numPoints int32, first bool) int32 {
+	if first {
+		delta /= damp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := int32(0)
+	for delta > ((base-tmin)*tmax)/2 {
+		delta /= base - tmin
+		k += base
+	}
+	return k + (base-tmin+1)*delta/(delta+skew)
+}
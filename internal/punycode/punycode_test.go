@@ -0,0 +1,63 @@
+package punycode
+
+import "testing"
+
+// The sample strings of RFC 3492 section 7.1, without the mixed-case
+// annotations.
+var rfc3492 = []struct {
+	name    string
+	decoded string
+	encoded string
+}{
+	{"arabic", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+	{"chinese simplified", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+	{"chinese traditional", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+	{"czech", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+	{"hebrew", "למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+	{"hindi", "यहलोगहिन्दीक्योंनहींबोलसकतेहैं", "i1baa7eci9glrd9b2ae1bj0hfcgg6iyaf8o0a1dig0cd"},
+	{"japanese", "なぜみんな日本語を話してくれないのか", "n8jok5ay5dzabd5bym9f0cm5685rrjetr6pdxa"},
+	{"korean", "세계의모든사람들이한국어를이해한다면얼마나좋을까", "989aomsvi5e83db1d2a355cv1e0vak1dwrv93d5xbh15a0dt30a5jpsd879ccm6fea98c"},
+	{"russian", "почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+	{"spanish", "PorquénopuedensimplementehablarenEspañol", "PorqunopuedensimplementehablarenEspaol-fmd56a"},
+	{"vietnamese", "TạisaohọkhôngthểchỉnóitiếngViệt", "TisaohkhngthchnitingVit-kjcr8268qyxafd2f1b9g"},
+	{"L", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+	{"M", "安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+	{"N", "Hello-Another-Way-それぞれの場所", "Hello-Another-Way--fc4qua05auwb3674vfr0b"},
+	{"O", "ひとつ屋根の下2", "2-u9tlzr9756bt3uc0v"},
+	{"P", "MajiでKoiする5秒前", "MajiKoi5-783gue6qz075azm5e"},
+	{"Q", "パフィーdeルンバ", "de-jg4avhby1noc0d"},
+	{"R", "そのスピードで", "d9juau41awczczp"},
+	{"S", "-> $1.00 <-", "-> $1.00 <--"},
+}
+
+func TestEncode(t *testing.T) {
+	for _, tt := range rfc3492 {
+		got, err := encode(tt.decoded)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got != tt.encoded {
+			t.Errorf("%s: encode = %q, want %q", tt.name, got, tt.encoded)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"example.com", "example.com"},
+		{"EXAMPLE.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"MÜNCHEN.de", "xn--mnchen-3ya.de"},
+		{"公司.cn", "xn--55qx5d.cn"},
+		{"www.مصر", "www.xn--wgbh1c"},
+	}
+	for _, tt := range tests {
+		got, err := ToASCII(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ToASCII(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
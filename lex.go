@@ -0,0 +1,149 @@
+package httpfields
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	errExpectedToken        = errors.New("expected token")
+	errUnterminatedQuote    = errors.New("unterminated quoted-string")
+	errUnexpectedCharacters = errors.New("unexpected characters")
+)
+
+// IsTokenChar reports whether c is a tchar as defined by RFC 9110 section
+// 5.6.2.
+func IsTokenChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// IsToken reports whether s is a non-empty token.
+func IsToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !IsTokenChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Quote returns s as is if it is a token and as a quoted-string otherwise.
+func Quote(s string) string {
+	if IsToken(s) {
+		return s
+	}
+	return QuoteString(s)
+}
+
+// QuoteString returns s as a quoted-string, escaping quotes and backslashes.
+func QuoteString(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// TrimOWS removes optional whitespace (spaces and horizontal tabs) from both
+// ends of s.
+func TrimOWS(s string) string {
+	return strings.Trim(s, " \t")
+}
+
+func skipOWS(s string) string {
+	return strings.TrimLeft(s, " \t")
+}
+
+// consumeToken splits s into a leading token and the remainder.
+func consumeToken(s string) (token, rest string) {
+	i := 0
+	for i < len(s) && IsTokenChar(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// consumeQuoted reads a quoted-string at the start of s and returns the
+// unescaped value and the remainder.
+func consumeQuoted(s string) (value, rest string, err error) {
+	if s == "" || s[0] != '"' {
+		return "", s, errUnterminatedQuote
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			return b.String(), s[i+1:], nil
+		case c == '\\':
+			if i+1 == len(s) {
+				return "", s, errUnterminatedQuote
+			}
+			i++
+			b.WriteByte(s[i])
+		case c == '\t' || c >= 0x20 && c != 0x7f:
+			b.WriteByte(c)
+		default:
+			return "", s, errUnexpectedCharacters
+		}
+	}
+	return "", s, errUnterminatedQuote
+}
+
+// consumeValue reads a token or a quoted-string at the start of s.
+func consumeValue(s string) (value, rest string, err error) {
+	if s != "" && s[0] == '"' {
+		return consumeQuoted(s)
+	}
+	value, rest = consumeToken(s)
+	if value == "" {
+		return "", s, errExpectedToken
+	}
+	return value, rest, nil
+}
+
+// SplitList splits a comma separated field value into its trimmed members,
+// honouring quoted-strings. Empty members are dropped as required by RFC 9110
+// section 5.6.1.
+func SplitList(s string) []string {
+	var (
+		out    []string
+		start  int
+		quoted bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case '\\':
+			if quoted {
+				i++
+			}
+		case ',':
+			if !quoted {
+				if m := TrimOWS(s[start:i]); m != "" {
+					out = append(out, m)
+				}
+				start = i + 1
+			}
+		}
+	}
+	if start <= len(s) {
+		if m := TrimOWS(s[start:]); m != "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
@@ -0,0 +1,159 @@
+package httpfields
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var errExpectedTarget = errors.New("expected link target in angle brackets")
+
+// Link is a single link of a Link field (RFC 8288).
+type Link struct {
+	Target string // URI-reference, without the angle brackets
+	Rel    string // space separated relation types, such as "next"
+
+	// Params holds the other target attributes keyed by lower-cased name.
+	// Attributes without a value, such as nopush, map to the empty string.
+	// Extended attributes such as title* are decoded and stored under their
+	// plain name.
+	Params map[string]string
+}
+
+// ParseLinks parses Link field values. As required by RFC 8288 section 3,
+// only the first occurrence of each attribute of a link counts.
+func ParseLinks(values ...string) ([]Link, error) {
+	var links []Link
+	for _, v := range values {
+		s := v
+		for {
+			s = skipOWS(s)
+			for s != "" && s[0] == ',' {
+				s = skipOWS(s[1:])
+			}
+			if s == "" {
+				break
+			}
+			l, rest, err := parseLink(s)
+			if err != nil {
+				return nil, &ParseError{Field: "Link", Value: v, Err: err}
+			}
+			links = append(links, l)
+			s = skipOWS(rest)
+			if s != "" && s[0] != ',' {
+				return nil, &ParseError{Field: "Link", Value: v, Err: errUnexpectedCharacters}
+			}
+		}
+	}
+	return links, nil
+}
+
+func parseLink(s string) (Link, string, error) {
+	var l Link
+	if s[0] != '<' {
+		return l, s, errExpectedTarget
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return l, s, errExpectedTarget
+	}
+	l.Target, s = s[1:end], s[end+1:]
+	l.Params = make(map[string]string)
+	rel, hasRel := "", false
+	extended := make(map[string]bool)
+	for {
+		s = skipOWS(s)
+		if s == "" || s[0] != ';' {
+			break
+		}
+		s = skipOWS(s[1:])
+		var name string
+		name, s = consumeToken(s)
+		if name == "" {
+			return l, s, errExpectedToken
+		}
+		name = strings.ToLower(name)
+		value := ""
+		if t := skipOWS(s); t != "" && t[0] == '=' {
+			var err error
+			if value, s, err = consumeValue(skipOWS(t[1:])); err != nil {
+				return l, s, err
+			}
+		}
+		switch {
+		case name == "rel":
+			if !hasRel {
+				rel, hasRel = value, true
+			}
+		case strings.HasSuffix(name, "*"):
+			base := name[:len(name)-1]
+			if extended[base] {
+				continue
+			}
+			decoded, err := decodeExtValue(value)
+			if err != nil {
+				return l, s, err
+			}
+			l.Params[base], extended[base] = decoded, true
+		default:
+			if _, dup := l.Params[name]; !dup {
+				l.Params[name] = value
+			}
+		}
+	}
+	l.Rel = strings.Join(strings.Fields(rel), " ")
+	return l, s, nil
+}
+
+// HasRel reports whether l has the relation type rel, compared
+// case-insensitively.
+func (l Link) HasRel(rel string) bool {
+	for _, r := range strings.Fields(l.Rel) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats l as a link-value. The rel attribute comes first and the
+// others follow in name order.
+func (l Link) String() string {
+	var b strings.Builder
+	b.WriteByte('<')
+	b.WriteString(l.Target)
+	b.WriteByte('>')
+	if l.Rel != "" {
+		b.WriteString("; rel=")
+		b.WriteString(Quote(l.Rel))
+	}
+	names := make([]string, 0, len(l.Params))
+	for name := range l.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := l.Params[name]
+		b.WriteString("; ")
+		b.WriteString(name)
+		switch {
+		case value == "":
+		case needsExtValue(value):
+			b.WriteString("*=utf-8''")
+			b.WriteString(encodeExtValue(value))
+		default:
+			b.WriteByte('=')
+			b.WriteString(Quote(value))
+		}
+	}
+	return b.String()
+}
+
+// FormatLinks formats links as a single Link field value.
+func FormatLinks(links []Link) string {
+	parts := make([]string, len(links))
+	for i, l := range links {
+		parts[i] = l.String()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,125 @@
+package httpfields
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []Link
+	}{
+		// RFC 8288 section 3.5.
+		{
+			[]string{`<http://example.com/TheBook/chapter2>; rel="previous"; title="previous chapter"`},
+			[]Link{{Target: "http://example.com/TheBook/chapter2", Rel: "previous", Params: map[string]string{"title": "previous chapter"}}},
+		},
+		{
+			[]string{`</>; rel="http://example.net/foo"`},
+			[]Link{{Target: "/", Rel: "http://example.net/foo", Params: map[string]string{}}},
+		},
+		{
+			[]string{`</terms>; rel="copyright"; anchor="#foo"`},
+			[]Link{{Target: "/terms", Rel: "copyright", Params: map[string]string{"anchor": "#foo"}}},
+		},
+		{
+			[]string{"</TheBook/chapter2>; rel=\"previous\"; title*=UTF-8'de'letztes%20Kapitel, </TheBook/chapter4>; rel=\"next\"; title*=UTF-8'de'n%c3%a4chstes%20Kapitel"},
+			[]Link{
+				{Target: "/TheBook/chapter2", Rel: "previous", Params: map[string]string{"title": "letztes Kapitel"}},
+				{Target: "/TheBook/chapter4", Rel: "next", Params: map[string]string{"title": "nächstes Kapitel"}},
+			},
+		},
+		{
+			[]string{`<http://example.org/>; rel="start   http://example.net/relation/other"`},
+			[]Link{{Target: "http://example.org/", Rel: "start http://example.net/relation/other", Params: map[string]string{}}},
+		},
+
+		// Only the first occurrence of an attribute counts, and title*
+		// wins over title in either order.
+		{
+			[]string{`<a>; REL=next; rel=prev; x=1; X=2; title="t"; title*=UTF-8''%C3%A9; title*=UTF-8''x`},
+			[]Link{{Target: "a", Rel: "next", Params: map[string]string{"x": "1", "title": "é"}}},
+		},
+		{
+			[]string{`<a>; title*=UTF-8''%C3%A9; title="t"`},
+			[]Link{{Target: "a", Params: map[string]string{"title": "é"}}},
+		},
+		// Attributes without a value, several field lines and empty
+		// list elements.
+		{
+			[]string{" , </a.css>;rel=preload;as=style;nopush ,", "</b.js>; rel=preload; as=script"},
+			[]Link{
+				{Target: "/a.css", Rel: "preload", Params: map[string]string{"as": "style", "nopush": ""}},
+				{Target: "/b.js", Rel: "preload", Params: map[string]string{"as": "script"}},
+			},
+		},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseLinks(tt.in...)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLinks(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{
+		"a; rel=next",
+		"<a",
+		"<a>; =x",
+		"<a>; rel=next <b>",
+		`<a>; title="unterminated`,
+		"<a>; title*=UTF-8'bad",
+		"<a>; title*=koi8-r''x",
+	} {
+		_, err := ParseLinks(s)
+		if pe, ok := err.(*ParseError); !ok || pe.Field != "Link" || pe.Value != s {
+			t.Errorf("ParseLinks(%q) = %v, want *ParseError", s, err)
+		}
+	}
+}
+
+func TestLinkString(t *testing.T) {
+	tests := []struct {
+		link Link
+		want string
+	}{
+		{Link{Target: "/a"}, "</a>"},
+		{Link{Target: "/a", Rel: "next"}, "</a>; rel=next"},
+		{Link{Target: "/a", Rel: "preload prefetch"}, `</a>; rel="preload prefetch"`},
+		{
+			Link{Target: "/a.css", Rel: "preload", Params: map[string]string{"nopush": "", "as": "style", "crossorigin": ""}},
+			"</a.css>; rel=preload; as=style; crossorigin; nopush",
+		},
+		{
+			Link{Target: "/c4", Rel: "next", Params: map[string]string{"title": "nächstes Kapitel", "anchor": "#x"}},
+			`</c4>; rel=next; anchor=#x; title*=utf-8''n%C3%A4chstes%20Kapitel`,
+		},
+	}
+	for _, tt := range tests {
+		got := tt.link.String()
+		if got != tt.want {
+			t.Errorf("String = %q, want %q", got, tt.want)
+		}
+		back, err := ParseLinks(got)
+		want := tt.link
+		if want.Params == nil {
+			want.Params = map[string]string{}
+		}
+		if err != nil || len(back) != 1 || !reflect.DeepEqual(back[0], want) {
+			t.Errorf("ParseLinks(%q) = %+v, %v; want %+v", got, back, err, want)
+		}
+	}
+
+	links := []Link{{Target: "/a", Rel: "next"}, {Target: "/b", Rel: "prev"}}
+	if got, want := FormatLinks(links), "</a>; rel=next, </b>; rel=prev"; got != want {
+		t.Errorf("FormatLinks = %q, want %q", got, want)
+	}
+}
+
+func TestHasRel(t *testing.T) {
+	l := Link{Rel: "preload Next"}
+	if !l.HasRel("next") || !l.HasRel("PRELOAD") || l.HasRel("prev") || l.HasRel("pre") {
+		t.Errorf("HasRel on %q", l.Rel)
+	}
+}
@@ -0,0 +1,83 @@
+package httpfields
+
+import (
+	"errors"
+	"strings"
+)
+
+var errMissingSubtype = errors.New("expected type/subtype")
+
+// MediaType is a parsed Content-Type field value (RFC 9110 section 8.3).
+type MediaType struct {
+	Type    string // lower-cased top-level type, such as "text"
+	Subtype string // lower-cased subtype, such as "html"
+
+	// Params holds the parameters keyed by lower-cased name.
+	Params map[string]string
+}
+
+// ParseMediaType parses a media type such as
+// `multipart/form-data; boundary="abc"`.
+func ParseMediaType(s string) (MediaType, error) {
+	m, err := parseMediaType(s)
+	if err != nil {
+		return MediaType{}, &ParseError{Field: "Content-Type", Value: s, Err: err}
+	}
+	return m, nil
+}
+
+func parseMediaType(s string) (MediaType, error) {
+	var m MediaType
+	rest := skipOWS(s)
+	m.Type, rest = consumeToken(rest)
+	if m.Type == "" || rest == "" || rest[0] != '/' {
+		return m, errMissingSubtype
+	}
+	m.Subtype, rest = consumeToken(rest[1:])
+	if m.Subtype == "" {
+		return m, errMissingSubtype
+	}
+	m.Type = strings.ToLower(m.Type)
+	m.Subtype = strings.ToLower(m.Subtype)
+	params, err := parseParams(rest)
+	if err != nil {
+		return MediaType{}, err
+	}
+	m.Params = params
+	return m, nil
+}
+
+// Essence returns the media type without parameters, such as "text/html".
+func (m MediaType) Essence() string {
+	return m.Type + "/" + m.Subtype
+}
+
+// Matches reports whether m matches the media range r, such as "text/*" or
+// "*/*". Parameters are not considered.
+func (m MediaType) Matches(r string) bool {
+	i := strings.IndexByte(r, '/')
+	if i < 0 {
+		return false
+	}
+	typ, sub := r[:i], r[i+1:]
+	if typ == "*" {
+		return sub == "*"
+	}
+	if !strings.EqualFold(typ, m.Type) {
+		return false
+	}
+	return sub == "*" || strings.EqualFold(sub, m.Subtype)
+}
+
+// Param returns the named parameter. The name is matched case-insensitively.
+func (m MediaType) Param(name string) string {
+	return m.Params[strings.ToLower(name)]
+}
+
+// String formats m as a field value.
+func (m MediaType) String() string {
+	var b strings.Builder
+	b.WriteString(m.Essence())
+	writeParams(&b, m.Params)
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package multipart
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"os"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// ErrMemoryLimit is returned by ReadForm when the form does not fit in memory
+// and spooling to disk has not been enabled.
+var ErrMemoryLimit = errors.New("multipart: form exceeds memory limit")
+
+// DefaultMaxMemory is the memory budget used by ReadForm when
+// FormOptions.MaxMemory is zero.
+const DefaultMaxMemory = 10 << 20
+
+// FormOptions controls ReadForm.
+type FormOptions struct {
+	// MaxMemory is the number of bytes of field values and file contents
+	// kept in memory. Zero selects DefaultMaxMemory.
+	MaxMemory int64
+
+	// SpoolDir enables spooling of file contents that do not fit in memory
+	// to temporary files in the named directory. If empty, ReadForm fails
+	// with ErrMemoryLimit instead.
+	SpoolDir string
+}
+
+// Form is a parsed multipart/form-data body.
+type Form struct {
+	Value map[string][]string
+	File  map[string][]*FileHeader
+}
+
+// RemoveAll removes the temporary files created for the form.
+func (f *Form) RemoveAll() error {
+	var err error
+	for _, files := range f.File {
+		for _, fh := range files {
+			if fh.tmpfile == "" {
+				continue
+			}
+			if e := os.Remove(fh.tmpfile); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+// FileHeader describes a file part of a form.
+type FileHeader struct {
+	Filename    string // sanitized file name, see httpfields.ContentDisposition.Filename
+	Header      textproto.MIMEHeader
+	ContentType httpfields.MediaType
+	Size        int64
+
+	content []byte
+	tmpfile string
+}
+
+// File is an opened form file.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// Open opens the file content.
+func (fh *FileHeader) Open() (File, error) {
+	if fh.tmpfile != "" {
+		return os.Open(fh.tmpfile)
+	}
+	return nopCloser{bytes.NewReader(fh.content)}, nil
+}
+
+type nopCloser struct{ *bytes.Reader }
+
+func (nopCloser) Close() error { return nil }
+
+// ReadForm reads a complete multipart/form-data body. Parts without a form
+// name are skipped. Field values and file contents are decoded according to
+// their content transfer encoding.
+func (r *Reader) ReadForm(opts FormOptions) (*Form, error) {
+	form := &Form{
+		Value: make(map[string][]string),
+		File:  make(map[string][]*FileHeader),
+	}
+	budget := opts.MaxMemory
+	if budget == 0 {
+		budget = DefaultMaxMemory
+	}
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+		name := p.FormName()
+		if name == "" {
+			continue
+		}
+		if !p.IsFile() {
+			var buf bytes.Buffer
+			n, err := io.Copy(&buf, io.LimitReader(p.Decoded(), budget+1))
+			if err == nil && n > budget {
+				err = ErrMemoryLimit
+			}
+			if err != nil {
+				form.RemoveAll()
+				return nil, err
+			}
+			budget -= n
+			form.Value[name] = append(form.Value[name], buf.String())
+			continue
+		}
+		fh, err := readFile(p, &budget, opts.SpoolDir)
+		if err != nil {
+			form.RemoveAll()
+			return nil, err
+		}
+		form.File[name] = append(form.File[name], fh)
+	}
+}
+
+func readFile(p *Part, budget *int64, spoolDir string) (*FileHeader, error) {
+	fh := &FileHeader{
+		Filename:    p.FileName(),
+		Header:      p.Header,
+		ContentType: p.ContentType,
+	}
+	body := p.Decoded()
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(body, *budget+1))
+	if err != nil {
+		return nil, err
+	}
+	if n <= *budget {
+		*budget -= n
+		fh.content = buf.Bytes()
+		fh.Size = n
+		return fh, nil
+	}
+	if spoolDir == "" {
+		return nil, ErrMemoryLimit
+	}
+	f, err := ioutil.TempFile(spoolDir, "multipart-")
+	if err != nil {
+		return nil, err
+	}
+	fh.tmpfile = f.Name()
+	size, err := io.Copy(f, io.MultiReader(&buf, body))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(fh.tmpfile)
+		return nil, err
+	}
+	fh.Size = size
+	return fh, nil
+}
@@ -0,0 +1,141 @@
+package multipart
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const formBody = "--b\r\n" +
+	"Content-Disposition: form-data; name=\"title\"\r\n\r\n" +
+	"hello\r\n" +
+	"--b\r\n" +
+	"Content-Disposition: form-data; name=\"upload\"; filename=\"../../etc/passwd\"\r\n" +
+	"Content-Type: text/plain\r\n\r\n" +
+	"0123456789\r\n" +
+	"--b\r\n" +
+	"Content-Disposition: attachment; filename=\"ignored.txt\"\r\n\r\n" +
+	"skipped\r\n" +
+	"--b--\r\n"
+
+func readForm(t *testing.T, opts FormOptions) (*Form, error) {
+	t.Helper()
+	r, err := NewReader(strings.NewReader(formBody), "b", Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.ReadForm(opts)
+}
+
+func TestReadFormInMemory(t *testing.T) {
+	form, err := readForm(t, FormOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer form.RemoveAll()
+	if got := form.Value["title"]; len(got) != 1 || got[0] != "hello" {
+		t.Errorf("title = %q", got)
+	}
+	if len(form.Value)+len(form.File) != 2 {
+		t.Errorf("part without form name was not skipped: %v %v", form.Value, form.File)
+	}
+	files := form.File["upload"]
+	if len(files) != 1 {
+		t.Fatalf("upload files = %d, want 1", len(files))
+	}
+	fh := files[0]
+	if fh.Filename != "passwd" || fh.Size != 10 || fh.ContentType.Subtype != "plain" {
+		t.Errorf("file header = %q, %d, %v", fh.Filename, fh.Size, fh.ContentType)
+	}
+	if fh.tmpfile != "" {
+		t.Errorf("file spooled to %s, want in memory", fh.tmpfile)
+	}
+	assertContent(t, fh, "0123456789")
+}
+
+func TestReadFormSpillsToDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multipart-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The field value uses 5 of 8 bytes, so the 10 byte file does not fit.
+	form, err := readForm(t, FormOptions{MaxMemory: 8, SpoolDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh := form.File["upload"][0]
+	if filepath.Dir(fh.tmpfile) != dir {
+		t.Fatalf("tmpfile = %q, want a file in %s", fh.tmpfile, dir)
+	}
+	if fh.Size != 10 {
+		t.Errorf("Size = %d, want 10", fh.Size)
+	}
+	assertContent(t, fh, "0123456789")
+
+	if err := form.RemoveAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fh.tmpfile); !os.IsNotExist(err) {
+		t.Errorf("tmpfile still exists after RemoveAll: %v", err)
+	}
+}
+
+func TestReadFormMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxMemory int64
+	}{
+		{"file does not fit", 8},
+		{"value does not fit", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readForm(t, FormOptions{MaxMemory: tt.maxMemory}); err != ErrMemoryLimit {
+				t.Errorf("err = %v, want %v", err, ErrMemoryLimit)
+			}
+		})
+	}
+}
+
+func TestReadFormRemovesFilesOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "multipart-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Truncate the body after the file part so ReadForm fails after
+	// spooling it.
+	body := formBody[:strings.Index(formBody, "--b\r\nContent-Disposition: attachment")]
+	r, err := NewReader(strings.NewReader(body), "b", Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ReadForm(FormOptions{MaxMemory: 8, SpoolDir: dir}); err == nil {
+		t.Fatal("ReadForm succeeded on a truncated body")
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d spooled files left behind", len(entries))
+	}
+}
+
+func assertContent(t *testing.T, fh *FileHeader, want string) {
+	t.Helper()
+	f, err := fh.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got, err := ioutil.ReadAll(f)
+	if err != nil || string(got) != want {
+		t.Errorf("content = %q, %v; want %q", got, err, want)
+	}
+}
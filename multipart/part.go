@@ -0,0 +1,163 @@
+package multipart
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime/quotedprintable"
+	"net/textproto"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+	"github.com/palsivertsen/gohttpfields/encword"
+)
+
+var errUnsupportedEncoding = errors.New("multipart: unsupported content transfer encoding")
+
+// Part is a single part of a multipart body. Reading from it yields the raw
+// body of the part; use Decoded to undo the content transfer encoding.
+type Part struct {
+	// Header holds the header fields of the part with canonical keys.
+	Header textproto.MIMEHeader
+
+	// Disposition is the parsed Content-Disposition field, or the zero
+	// value if the part has none.
+	Disposition httpfields.ContentDisposition
+
+	// ContentType is the parsed Content-Type field. It defaults to
+	// text/plain as specified by RFC 2046 section 5.1.
+	ContentType httpfields.MediaType
+
+	// TransferEncoding is the parsed Content-Transfer-Encoding field, or
+	// the empty string if the part has none.
+	TransferEncoding httpfields.ContentTransferEncoding
+
+	r   *Reader
+	n   int64 // body bytes read
+	err error // sticky
+}
+
+func newPart(r *Reader, header textproto.MIMEHeader) (*Part, error) {
+	p := &Part{
+		Header:      header,
+		ContentType: httpfields.MediaType{Type: "text", Subtype: "plain"},
+		r:           r,
+	}
+	var err error
+	if v := header.Get("Content-Disposition"); v != "" {
+		if p.Disposition, err = httpfields.ParseContentDisposition(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := header.Get("Content-Type"); v != "" {
+		if p.ContentType, err = httpfields.ParseMediaType(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := header.Get("Content-Transfer-Encoding"); v != "" {
+		if p.TransferEncoding, err = httpfields.ParseContentTransferEncoding(v); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// FormName returns the name parameter of a form-data Content-Disposition, or
+// the empty string for other dispositions.
+func (p *Part) FormName() string {
+	if p.Disposition.Type != httpfields.DispositionFormData {
+		return ""
+	}
+	return p.Disposition.Name()
+}
+
+// FileName returns the sanitized filename parameter of the Content-Disposition
+// field. See httpfields.ContentDisposition.Filename.
+func (p *Part) FileName() string {
+	return p.Disposition.Filename()
+}
+
+// DecodedHeader returns the first value of the header field key with RFC
+// 2047 encoded-words decoded. Values whose encoded-words cannot be decoded
+// are returned unchanged.
+func (p *Part) DecodedHeader(key string) string {
+	v := p.Header.Get(key)
+	if decoded, err := encword.Decode(v); err == nil {
+		return decoded
+	}
+	return v
+}
+
+// IsFile reports whether the part carries a filename parameter.
+func (p *Part) IsFile() bool {
+	_, ok := p.Disposition.Params["filename"]
+	return ok
+}
+
+// Read reads the raw body of the part.
+func (p *Part) Read(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	br := p.r.br
+	atEOF := false
+	for {
+		buf, _ := br.Peek(br.Buffered())
+		n, delim := p.r.scanBody(buf, p.n == 0, atEOF)
+		if n > 0 {
+			if n > len(b) {
+				n = len(b)
+			}
+			if limit := p.r.limits.MaxPartSize; limit > 0 && p.n+int64(n) > limit {
+				p.err = ErrPartTooLarge
+				return 0, p.err
+			}
+			copy(b, buf[:n])
+			br.Discard(n)
+			p.n += int64(n)
+			return n, nil
+		}
+		if delim {
+			p.err = io.EOF
+			return 0, p.err
+		}
+		if atEOF {
+			p.err = io.ErrUnexpectedEOF
+			return 0, p.err
+		}
+		if _, err := br.Peek(len(buf) + 1); err != nil {
+			switch err {
+			case io.EOF:
+				atEOF = true
+			case bufio.ErrBufferFull:
+				p.err = ErrMalformedDelimiter
+				return 0, p.err
+			default:
+				p.err = err
+				return 0, p.err
+			}
+		}
+	}
+}
+
+// Decoded returns a reader for the body of the part with the content transfer
+// encoding removed. Reads fail for unknown "x-" encodings.
+func (p *Part) Decoded() io.Reader {
+	switch p.TransferEncoding {
+	case httpfields.EncodingBase64:
+		return base64.NewDecoder(base64.StdEncoding, p)
+	case httpfields.EncodingQuotedPrintable:
+		return quotedprintable.NewReader(p)
+	}
+	if !p.TransferEncoding.IsIdentity() {
+		return errReader{errUnsupportedEncoding}
+	}
+	return p
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
@@ -0,0 +1,414 @@
+// Package multipart implements a streaming reader for multipart/form-data
+// (RFC 7578) and multipart/mixed (RFC 2046) bodies.
+//
+// Unlike mime/multipart every resource the reader consumes is bounded by
+// Limits, part fields are exposed through the typed values of the httpfields
+// package, and nothing is written to disk unless the caller asks for it.
+package multipart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// Errors returned when a body exceeds the configured limits or is malformed.
+var (
+	ErrTooManyParts        = errors.New("multipart: too many parts")
+	ErrPartTooLarge        = errors.New("multipart: part too large")
+	ErrMessageTooLarge     = errors.New("multipart: message too large")
+	ErrHeaderTooLarge      = errors.New("multipart: part header too large")
+	ErrTooManyHeaderFields = errors.New("multipart: too many part header fields")
+	ErrMalformedHeader     = errors.New("multipart: malformed part header")
+	ErrMalformedDelimiter  = errors.New("multipart: malformed delimiter")
+	ErrInvalidBoundary     = errors.New("multipart: invalid boundary")
+	ErrNotMultipart        = errors.New("multipart: request is not multipart")
+)
+
+// Default limits applied when the corresponding Limits field is zero.
+const (
+	DefaultMaxParts        = 1000
+	DefaultMaxPartSize     = 10 << 20
+	DefaultMaxTotalSize    = 32 << 20
+	DefaultMaxHeaderBytes  = 8 << 10
+	DefaultMaxHeaderFields = 16
+)
+
+// Limits bounds the resources consumed by a Reader. A zero field selects the
+// corresponding default and a negative field disables the limit.
+type Limits struct {
+	MaxParts        int   // number of parts
+	MaxPartSize     int64 // body bytes of a single part, before decoding
+	MaxTotalSize    int64 // bytes read from the underlying reader
+	MaxHeaderBytes  int   // bytes in the header section of a single part
+	MaxHeaderFields int   // header fields of a single part
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxParts == 0 {
+		l.MaxParts = DefaultMaxParts
+	}
+	if l.MaxPartSize == 0 {
+		l.MaxPartSize = DefaultMaxPartSize
+	}
+	if l.MaxTotalSize == 0 {
+		l.MaxTotalSize = DefaultMaxTotalSize
+	}
+	if l.MaxHeaderBytes == 0 {
+		l.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if l.MaxHeaderFields == 0 {
+		l.MaxHeaderFields = DefaultMaxHeaderFields
+	}
+	return l
+}
+
+// Reader iterates over the parts of a multipart body.
+type Reader struct {
+	br     *bufio.Reader
+	limits Limits
+
+	dashBoundary   []byte // "--boundary"
+	nlDashBoundary []byte // "\n--boundary"
+
+	parts   int
+	current *Part
+	started bool
+	err     error // sticky
+}
+
+// NewReader returns a Reader reading parts delimited by boundary from r.
+func NewReader(r io.Reader, boundary string, limits Limits) (*Reader, error) {
+	if !validBoundary(boundary) {
+		return nil, ErrInvalidBoundary
+	}
+	limits = limits.withDefaults()
+	if limits.MaxTotalSize > 0 {
+		r = &limitReader{r: r, n: limits.MaxTotalSize}
+	}
+	return &Reader{
+		br:             bufio.NewReaderSize(r, 4096),
+		limits:         limits,
+		dashBoundary:   []byte("--" + boundary),
+		nlDashBoundary: []byte("\n--" + boundary),
+	}, nil
+}
+
+// NewRequestReader returns a Reader for the body of a request with a
+// multipart Content-Type, such as multipart/form-data or multipart/mixed.
+func NewRequestReader(req *http.Request, limits Limits) (*Reader, error) {
+	ct := req.Header.Get("Content-Type")
+	if ct == "" {
+		return nil, ErrNotMultipart
+	}
+	mt, err := httpfields.ParseMediaType(ct)
+	if err != nil {
+		return nil, err
+	}
+	if mt.Type != "multipart" {
+		return nil, ErrNotMultipart
+	}
+	return NewReader(req.Body, mt.Param("boundary"), limits)
+}
+
+// validBoundary reports whether s is a boundary as defined by RFC 2046
+// section 5.1.1.
+func validBoundary(s string) bool {
+	if len(s) == 0 || len(s) > 70 || s[len(s)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("'()+_,-./:=? ", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// NextPart returns the next part of the body, or io.EOF after the closing
+// delimiter. Any unread content of the previous part is discarded, subject to
+// the same limits as if it had been read.
+func (r *Reader) NextPart() (*Part, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	p, err := r.nextPart()
+	if err != nil {
+		r.err = err
+		return nil, err
+	}
+	return p, nil
+}
+
+func (r *Reader) nextPart() (*Part, error) {
+	if r.current != nil {
+		if _, err := io.Copy(ioutil.Discard, r.current); err != nil {
+			return nil, err
+		}
+		r.current = nil
+	}
+	var (
+		final bool
+		err   error
+	)
+	if !r.started {
+		final, err = r.skipPreamble()
+		r.started = true
+	} else {
+		final, err = r.readDelimiter()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if final {
+		return nil, io.EOF
+	}
+	if r.limits.MaxParts > 0 && r.parts >= r.limits.MaxParts {
+		return nil, ErrTooManyParts
+	}
+	r.parts++
+	header, err := r.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPart(r, header)
+	if err != nil {
+		return nil, err
+	}
+	r.current = p
+	return p, nil
+}
+
+// skipPreamble discards everything up to and including the first delimiter
+// line.
+func (r *Reader) skipPreamble() (final bool, err error) {
+	lineStart := true
+	for {
+		line, err := r.br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			lineStart = false
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return false, unexpectedEOF(err)
+		}
+		if lineStart {
+			if final, ok := r.matchDelimiterLine(line); ok {
+				return final, nil
+			}
+		}
+		if err == io.EOF {
+			return false, io.ErrUnexpectedEOF
+		}
+		lineStart = true
+	}
+}
+
+// readDelimiter consumes the delimiter line that follows the body of the
+// previous part.
+func (r *Reader) readDelimiter() (final bool, err error) {
+	for i := 0; i < 2; i++ {
+		line, err := r.br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			return false, ErrMalformedDelimiter
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return false, unexpectedEOF(err)
+		}
+		if final, ok := r.matchDelimiterLine(line); ok {
+			return final, nil
+		}
+		// The first line may be the line break that belongs to the
+		// delimiter.
+		if i > 0 || len(bytes.TrimRight(line, "\r\n")) > 0 {
+			break
+		}
+	}
+	return false, ErrMalformedDelimiter
+}
+
+// matchDelimiterLine reports whether line is a delimiter line and whether it
+// is the closing delimiter.
+func (r *Reader) matchDelimiterLine(line []byte) (final, ok bool) {
+	if !bytes.HasPrefix(line, r.dashBoundary) {
+		return false, false
+	}
+	rest := line[len(r.dashBoundary):]
+	if bytes.HasPrefix(rest, []byte("--")) {
+		rest, final = rest[2:], true
+	}
+	rest = bytes.TrimLeft(rest, " \t")
+	switch string(rest) {
+	case "", "\n", "\r\n":
+		return final, true
+	}
+	return false, false
+}
+
+func (r *Reader) readHeader() (textproto.MIMEHeader, error) {
+	var (
+		header = make(textproto.MIMEHeader)
+		budget = r.limits.MaxHeaderBytes
+		fields int
+		last   string
+	)
+	for {
+		line, err := r.readLine(&budget)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return header, nil
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// Obsolete line folding; append to the previous field.
+			if last == "" {
+				return nil, ErrMalformedHeader
+			}
+			values := header[last]
+			values[len(values)-1] += " " + httpfields.TrimOWS(line)
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 || !httpfields.IsToken(line[:i]) {
+			return nil, ErrMalformedHeader
+		}
+		fields++
+		if r.limits.MaxHeaderFields > 0 && fields > r.limits.MaxHeaderFields {
+			return nil, ErrTooManyHeaderFields
+		}
+		last = textproto.CanonicalMIMEHeaderKey(line[:i])
+		header[last] = append(header[last], httpfields.TrimOWS(line[i+1:]))
+	}
+}
+
+// readLine reads a header line without its line break, charging its length
+// to budget unless budget is negative.
+func (r *Reader) readLine(budget *int) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.br.ReadSlice('\n')
+		if *budget >= 0 {
+			if len(chunk) > *budget {
+				return "", ErrHeaderTooLarge
+			}
+			*budget -= len(chunk)
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		line = bytes.TrimSuffix(line[:len(line)-1], []byte("\r"))
+		return string(line), nil
+	}
+}
+
+// Classifications returned by delimiterSuffix.
+const (
+	delimNone = iota
+	delimIncomplete
+	delimFound
+)
+
+// scanBody inspects buffered body bytes. It returns how many leading bytes
+// are content that can be handed out, and whether a delimiter immediately
+// follows them.
+func (r *Reader) scanBody(buf []byte, atStart, atEOF bool) (n int, delim bool) {
+	if atStart && bytes.HasPrefix(buf, r.dashBoundary) {
+		// A delimiter directly after the header section, without the
+		// line break that normally precedes it.
+		if r.delimiterSuffix(buf[len(r.dashBoundary):], atEOF) == delimFound {
+			return 0, true
+		}
+	}
+	for off := 0; ; {
+		i := bytes.Index(buf[off:], r.nlDashBoundary)
+		if i < 0 {
+			break
+		}
+		i += off
+		start := i
+		if start > 0 && buf[start-1] == '\r' {
+			start--
+		}
+		switch r.delimiterSuffix(buf[i+len(r.nlDashBoundary):], atEOF) {
+		case delimFound:
+			return start, start == 0
+		case delimIncomplete:
+			return start, false
+		}
+		off = i + 1
+	}
+	// Hold back enough bytes to hold a partial "\r\n--boundary".
+	n = len(buf) - len(r.nlDashBoundary)
+	if n < 0 {
+		n = 0
+	}
+	return n, false
+}
+
+// delimiterSuffix classifies the bytes following "\n--boundary".
+func (r *Reader) delimiterSuffix(rest []byte, atEOF bool) int {
+	if bytes.HasPrefix(rest, []byte("--")) {
+		return delimFound
+	}
+	if len(rest) == 1 && rest[0] == '-' {
+		if atEOF {
+			return delimNone
+		}
+		return delimIncomplete
+	}
+	rest = bytes.TrimLeft(rest, " \t")
+	switch {
+	case len(rest) == 0, len(rest) == 1 && rest[0] == '\r':
+		if atEOF {
+			return delimNone
+		}
+		return delimIncomplete
+	case rest[0] == '\n', rest[0] == '\r' && rest[1] == '\n':
+		return delimFound
+	}
+	return delimNone
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// limitReader fails with ErrMessageTooLarge once more than n bytes have been
+// read.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrMessageTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n - int(-l.n), ErrMessageTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,279 @@
+package multipart
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readParts returns the raw bodies of all parts and the first error other
+// than io.EOF.
+func readParts(body, boundary string, limits Limits) ([]string, error) {
+	r, err := NewReader(strings.NewReader(body), boundary, limits)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return parts, err
+		}
+		b, err := ioutil.ReadAll(p)
+		if err != nil {
+			return parts, err
+		}
+		parts = append(parts, string(b))
+	}
+}
+
+func TestBoundaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		boundary string
+		body     string
+		want     []string
+		err      error
+	}{
+		{
+			name:     "crlf",
+			boundary: "xyz",
+			body:     "--xyz\r\n\r\none\r\n--xyz\r\n\r\ntwo\r\n--xyz--\r\n",
+			want:     []string{"one", "two"},
+		},
+		{
+			name:     "lf only",
+			boundary: "xyz",
+			body:     "--xyz\n\none\n--xyz\n\ntwo\n--xyz--\n",
+			want:     []string{"one", "two"},
+		},
+		{
+			name:     "preamble and epilogue",
+			boundary: "xyz",
+			body:     "preamble --xyz\r\n--xyz\r\n\r\none\r\n--xyz--\r\nepilogue --xyz\r\n",
+			want:     []string{"one"},
+		},
+		{
+			name:     "transport padding",
+			boundary: "xyz",
+			body:     "--xyz \t\r\n\r\none\r\n--xyz-- \r\n",
+			want:     []string{"one"},
+		},
+		{
+			name:     "closing delimiter without line break",
+			boundary: "xyz",
+			body:     "--xyz\r\n\r\none\r\n--xyz--",
+			want:     []string{"one"},
+		},
+		{
+			name:     "boundary prefix inside body",
+			boundary: "xyz",
+			body:     "--xyz\r\n\r\na\r\n--xy\r\n--xyzz\r\n--xyz--\r\n",
+			want:     []string{"a\r\n--xy\r\n--xyzz"},
+		},
+		{
+			name:     "empty part",
+			boundary: "xyz",
+			body:     "--xyz\r\n\r\n\r\n--xyz--\r\n",
+			want:     []string{""},
+		},
+		{
+			name:     "boundary with spaces and punctuation",
+			boundary: "a b'()+_,-./:=?",
+			body:     "--a b'()+_,-./:=?\r\n\r\none\r\n--a b'()+_,-./:=?--\r\n",
+			want:     []string{"one"},
+		},
+		{
+			name:     "missing closing delimiter",
+			boundary: "xyz",
+			body:     "--xyz\r\n\r\none",
+			err:      io.ErrUnexpectedEOF,
+		},
+		{
+			name:     "no delimiter",
+			boundary: "xyz",
+			body:     "just text\r\n",
+			err:      io.ErrUnexpectedEOF,
+		},
+		{
+			name:     "garbage after delimiter",
+			boundary: "xyz",
+			body:     "--xyz\r\n\r\none\r\n--xyzgarbage\r\n--xyz--\r\n",
+			want:     []string{"one\r\n--xyzgarbage"},
+		},
+		{
+			name:     "empty boundary",
+			boundary: "",
+			err:      ErrInvalidBoundary,
+		},
+		{
+			name:     "boundary ending in space",
+			boundary: "xyz ",
+			err:      ErrInvalidBoundary,
+		},
+		{
+			name:     "boundary too long",
+			boundary: strings.Repeat("b", 71),
+			err:      ErrInvalidBoundary,
+		},
+		{
+			name:     "boundary with invalid character",
+			boundary: "x;y",
+			err:      ErrInvalidBoundary,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readParts(tt.body, tt.boundary, Limits{})
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parts = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	threeParts := "--b\r\n\r\none\r\n--b\r\n\r\ntwo\r\n--b\r\n\r\nthree\r\n--b--\r\n"
+	tests := []struct {
+		name   string
+		body   string
+		limits Limits
+		err    error
+	}{
+		{"parts within limit", threeParts, Limits{MaxParts: 3}, nil},
+		{"too many parts", threeParts, Limits{MaxParts: 2}, ErrTooManyParts},
+		{"parts limit disabled", threeParts, Limits{MaxParts: -1}, nil},
+		{"part within size", threeParts, Limits{MaxPartSize: 5}, nil},
+		{"part too large", threeParts, Limits{MaxPartSize: 4}, ErrPartTooLarge},
+		{"total too large", threeParts, Limits{MaxTotalSize: 20}, ErrMessageTooLarge},
+		{"total within limit", threeParts, Limits{MaxTotalSize: int64(len(threeParts))}, nil},
+		{
+			"header within limit",
+			"--b\r\nA: 1\r\n\r\nx\r\n--b--\r\n",
+			Limits{MaxHeaderBytes: len("A: 1\r\n\r\n")},
+			nil,
+		},
+		{
+			"header too large",
+			"--b\r\nA: 1\r\n\r\nx\r\n--b--\r\n",
+			Limits{MaxHeaderBytes: len("A: 1\r\n\r\n") - 1},
+			ErrHeaderTooLarge,
+		},
+		{
+			"too many header fields",
+			"--b\r\nA: 1\r\nB: 2\r\nC: 3\r\n\r\nx\r\n--b--\r\n",
+			Limits{MaxHeaderFields: 2},
+			ErrTooManyHeaderFields,
+		},
+		{
+			"malformed header",
+			"--b\r\nno colon\r\n\r\nx\r\n--b--\r\n",
+			Limits{},
+			ErrMalformedHeader,
+		},
+		{
+			"continuation without field",
+			"--b\r\n folded\r\n\r\nx\r\n--b--\r\n",
+			Limits{},
+			ErrMalformedHeader,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readParts(tt.body, "b", tt.limits)
+			if err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestUnreadPartCountsTowardsLimit(t *testing.T) {
+	body := "--b\r\n\r\n" + strings.Repeat("x", 100) + "\r\n--b\r\n\r\ny\r\n--b--\r\n"
+	r, err := NewReader(strings.NewReader(body), "b", Limits{MaxPartSize: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.NextPart(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.NextPart(); err != ErrPartTooLarge {
+		t.Fatalf("skipping oversized part: err = %v, want %v", err, ErrPartTooLarge)
+	}
+	if _, err := r.NextPart(); err != ErrPartTooLarge {
+		t.Errorf("error is not sticky: %v", err)
+	}
+}
+
+func TestHeaderFolding(t *testing.T) {
+	body := "--b\r\nX-Long: one\r\n two\r\n\r\nx\r\n--b--\r\n"
+	r, err := NewReader(strings.NewReader(body), "b", Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := r.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Header.Get("X-Long"); got != "one two" {
+		t.Errorf("X-Long = %q, want %q", got, "one two")
+	}
+}
+
+func TestNewRequestReader(t *testing.T) {
+	tests := []struct {
+		contentType string
+		err         error
+	}{
+		{"multipart/form-data; boundary=b", nil},
+		{"multipart/mixed; boundary=\"b\"", nil},
+		{"text/plain", ErrNotMultipart},
+		{"", ErrNotMultipart},
+		{"multipart/form-data", ErrInvalidBoundary},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader("--b--\r\n"))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		if _, err := NewRequestReader(req, Limits{}); err != tt.err {
+			t.Errorf("%q: err = %v, want %v", tt.contentType, err, tt.err)
+		}
+	}
+}
+
+func TestDecoded(t *testing.T) {
+	body := "--b\r\nContent-Transfer-Encoding: base64\r\n\r\naGVsbG8=\r\n" +
+		"--b\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\ncaf=C3=A9\r\n" +
+		"--b\r\nContent-Transfer-Encoding: x-custom\r\n\r\nraw\r\n--b--\r\n"
+	r, err := NewReader(strings.NewReader(body), "b", Limits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"hello", "café"} {
+		p, err := r.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadAll(p.Decoded())
+		if err != nil || string(got) != want {
+			t.Errorf("Decoded = %q, %v; want %q", got, err, want)
+		}
+	}
+	p, err := r.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(p.Decoded()); err != errUnsupportedEncoding {
+		t.Errorf("x-custom: err = %v, want %v", err, errUnsupportedEncoding)
+	}
+}
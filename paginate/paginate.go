@@ -0,0 +1,256 @@
+// Package paginate emits cursor pagination links as Link fields (RFC 8288)
+// and an optional total count field.
+//
+// Wrap list endpoints with Middleware and declare the neighbouring pages
+// before writing the response:
+//
+//	page := paginate.FromRequest(r)
+//	page.Next(lastID)
+//	page.First()
+//	page.Total(count)
+//
+// Each link is the request URL with the cursor parameter replaced, keeping
+// every other query parameter exactly as the client sent it. Cursors are
+// base64url encoded, so any cursor value survives the round trip.
+package paginate
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+	"github.com/palsivertsen/gohttpfields/query"
+)
+
+// Relation types of pagination links.
+const (
+	RelNext  = "next"
+	RelPrev  = "prev"
+	RelFirst = "first"
+	RelLast  = "last"
+)
+
+// Defaults applied when the corresponding Paginator field is empty.
+const (
+	DefaultCursorParam = "cursor"
+	DefaultTotalHeader = "X-Total-Count"
+)
+
+// ErrInvalidCursor is returned for cursor parameters that are not
+// base64url encoded.
+var ErrInvalidCursor = errors.New("paginate: invalid cursor")
+
+// EncodeCursor encodes a cursor for use in a URL.
+func EncodeCursor(cursor string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor))
+}
+
+// DecodeCursor decodes a cursor produced by EncodeCursor.
+func DecodeCursor(s string) (string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	return string(b), nil
+}
+
+// Paginator is middleware configuration. The zero value uses the "cursor"
+// query parameter, X-Total-Count and relative link targets.
+type Paginator struct {
+	CursorParam string // query parameter carrying the cursor
+	TotalHeader string // field carrying the total count
+
+	// Absolute makes link targets absolute URLs built from the request's
+	// Host and TLS state instead of relative references.
+	Absolute bool
+}
+
+func (p *Paginator) cursorParam() string {
+	if p.CursorParam != "" {
+		return p.CursorParam
+	}
+	return DefaultCursorParam
+}
+
+func (p *Paginator) totalHeader() string {
+	if p.TotalHeader != "" {
+		return p.TotalHeader
+	}
+	return DefaultTotalHeader
+}
+
+// Middleware is Paginator{}.Middleware.
+func Middleware(next http.Handler) http.Handler {
+	return (&Paginator{}).Middleware(next)
+}
+
+// Middleware makes a Page available to next through FromRequest and writes
+// its links and total count when next writes the response header.
+func (p *Paginator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := &Page{paginator: p, req: r}
+		r = r.WithContext(context.WithValue(r.Context(), contextKey{}, page))
+		next.ServeHTTP(&responseWriter{ResponseWriter: w, page: page}, r)
+	})
+}
+
+type contextKey struct{}
+
+// FromRequest returns the Page of a request passed through Middleware, or
+// nil. The methods of a nil Page do nothing.
+func FromRequest(r *http.Request) *Page {
+	page, _ := r.Context().Value(contextKey{}).(*Page)
+	return page
+}
+
+// Cursor returns the decoded cursor of a request, or the empty string for
+// the first page. The parameter name is taken from the Paginator of the
+// request if it passed through Middleware.
+func Cursor(r *http.Request) (string, error) {
+	param := DefaultCursorParam
+	if page := FromRequest(r); page != nil {
+		param = page.paginator.cursorParam()
+	}
+	v, ok := query.Parse(r.URL.RawQuery).Get(param)
+	if !ok || v == "" {
+		return "", nil
+	}
+	return DecodeCursor(v)
+}
+
+type link struct {
+	rel    string
+	cursor string
+}
+
+// Page collects the pagination links of a response.
+type Page struct {
+	paginator *Paginator
+	req       *http.Request
+	links     []link
+	total     int64
+	hasTotal  bool
+}
+
+// Link declares a link with relation type rel to the page at cursor. An
+// empty cursor links to the URL without a cursor parameter. Declaring a
+// relation again replaces the earlier link.
+func (pg *Page) Link(rel, cursor string) {
+	if pg == nil {
+		return
+	}
+	for i := range pg.links {
+		if pg.links[i].rel == rel {
+			pg.links[i].cursor = cursor
+			return
+		}
+	}
+	pg.links = append(pg.links, link{rel: rel, cursor: cursor})
+}
+
+// Next declares the next page.
+func (pg *Page) Next(cursor string) { pg.Link(RelNext, cursor) }
+
+// Prev declares the previous page.
+func (pg *Page) Prev(cursor string) { pg.Link(RelPrev, cursor) }
+
+// First declares the first page, which has no cursor.
+func (pg *Page) First() { pg.Link(RelFirst, "") }
+
+// Last declares the last page.
+func (pg *Page) Last(cursor string) { pg.Link(RelLast, cursor) }
+
+// Total declares the total number of items across all pages.
+func (pg *Page) Total(n int64) {
+	if pg == nil {
+		return
+	}
+	pg.total, pg.hasTotal = n, true
+}
+
+// URL returns the target of a link to the page at cursor, or the empty
+// string for a nil Page.
+func (pg *Page) URL(cursor string) string {
+	if pg == nil {
+		return ""
+	}
+	u := pg.req.URL
+	param := pg.paginator.cursorParam()
+	q := query.Parse(u.RawQuery)
+	params := q.Params[:0:0]
+	for _, p := range q.Params {
+		if p.Key != param {
+			params = append(params, p)
+		}
+	}
+	if cursor != "" {
+		params = append(params, query.Param{
+			RawKey:    query.FormEscaping.Escape(param),
+			RawValue:  EncodeCursor(cursor),
+			HasValue:  true,
+			Separator: '&',
+		})
+	}
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if len(params) > 0 {
+		target += "?" + query.Query{Params: params}.String()
+	}
+	if pg.paginator.Absolute {
+		scheme := "http"
+		if pg.req.TLS != nil {
+			scheme = "https"
+		}
+		target = scheme + "://" + pg.req.Host + target
+	}
+	return target
+}
+
+// writeHeader adds the declared links and total count to h.
+func (pg *Page) writeHeader(h http.Header) {
+	if len(pg.links) > 0 {
+		links := make([]httpfields.Link, len(pg.links))
+		for i, l := range pg.links {
+			links[i] = httpfields.Link{Target: pg.URL(l.cursor), Rel: l.rel}
+		}
+		h.Add("Link", httpfields.FormatLinks(links))
+	}
+	if pg.hasTotal {
+		h.Set(pg.paginator.totalHeader(), strconv.FormatInt(pg.total, 10))
+	}
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	page        *Page
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if status >= 200 && !w.wroteHeader {
+		w.wroteHeader = true
+		w.page.writeHeader(w.Header())
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
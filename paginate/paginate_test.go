@@ -0,0 +1,183 @@
+package paginate
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+func serve(p *Paginator, target string, h http.HandlerFunc) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	p.Middleware(h).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+func TestCursor(t *testing.T) {
+	for _, c := range []string{"", "42", "id:5", "a/b+c=d&e", "café \x00\xff"} {
+		got, err := DecodeCursor(EncodeCursor(c))
+		if err != nil || got != c {
+			t.Errorf("DecodeCursor(EncodeCursor(%q)) = %q, %v", c, got, err)
+		}
+	}
+	if got := EncodeCursor("id:5"); got != "aWQ6NQ" {
+		t.Errorf("EncodeCursor(%q) = %q", "id:5", got)
+	}
+	for _, s := range []string{"a+b", "aWQ6NQ==", "!"} {
+		if _, err := DecodeCursor(s); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) = %v, want %v", s, err, ErrInvalidCursor)
+		}
+	}
+
+	// A cursor from a Link round-trips through Cursor on the next request.
+	var next string
+	serve(&Paginator{}, "/items", func(w http.ResponseWriter, r *http.Request) {
+		next = FromRequest(r).URL("a/b+c=d&e")
+	})
+	var got string
+	var err error
+	serve(&Paginator{}, next, func(w http.ResponseWriter, r *http.Request) {
+		got, err = Cursor(r)
+	})
+	if err != nil || got != "a/b+c=d&e" {
+		t.Errorf("Cursor(%q) = %q, %v", next, got, err)
+	}
+
+	tests := []struct {
+		target string
+		param  string
+		want   string
+		err    error
+	}{
+		{"/items", "", "", nil},
+		{"/items?cursor=", "", "", nil},
+		{"/items?cursor=aWQ6NQ", "", "id:5", nil},
+		{"/items?after=aWQ6NQ&cursor=x", "after", "id:5", nil},
+		{"/items?cursor=%%%", "", "", ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		serve(&Paginator{CursorParam: tt.param}, tt.target, func(w http.ResponseWriter, r *http.Request) {
+			got, err = Cursor(r)
+		})
+		if got != tt.want || err != tt.err {
+			t.Errorf("Cursor(%q) = %q, %v; want %q, %v", tt.target, got, err, tt.want, tt.err)
+		}
+	}
+
+	// Without Middleware the default parameter is used.
+	if got, err := Cursor(httptest.NewRequest("GET", "/items?cursor=aWQ6NQ", nil)); got != "id:5" || err != nil {
+		t.Errorf("Cursor without Middleware = %q, %v", got, err)
+	}
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		p      Paginator
+		target string
+		cursor string
+		want   string
+	}{
+		{Paginator{}, "/items", "id:5", "/items?cursor=aWQ6NQ"},
+		{Paginator{}, "/items?cursor=old", "", "/items"},
+		// Other parameters keep their order and raw encoding.
+		{Paginator{}, "/items?z=1&a=%41&q=caf%C3%A9+x&cursor=old&tag=a&tag=b", "id:5",
+			"/items?z=1&a=%41&q=caf%C3%A9+x&tag=a&tag=b&cursor=aWQ6NQ"},
+		{Paginator{}, "/items?sort=-name;id&cursor=old&flag", "", "/items?sort=-name;id&flag"},
+		{Paginator{}, "/a%2Fb?x=%2f", "1", "/a%2Fb?x=%2f&cursor=MQ"},
+		{Paginator{}, "/items?curs%6Fr=old&x", "", "/items?x"},
+		{Paginator{CursorParam: "page token"}, "/items?page+token=old", "1", "/items?page+token=MQ"},
+		{Paginator{Absolute: true}, "/items?x=1", "1", "http://example.com/items?x=1&cursor=MQ"},
+	}
+	for _, tt := range tests {
+		p := tt.p
+		var got string
+		serve(&p, tt.target, func(w http.ResponseWriter, r *http.Request) {
+			got = FromRequest(r).URL(tt.cursor)
+		})
+		if got != tt.want {
+			t.Errorf("URL(%q) for %s = %q, want %q", tt.cursor, tt.target, got, tt.want)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "https://api.example.com/items", nil)
+	r.TLS = &tls.ConnectionState{}
+	var got string
+	(&Paginator{Absolute: true}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = FromRequest(r).URL("1")
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	if got != "https://api.example.com/items?cursor=MQ" {
+		t.Errorf("URL over TLS = %q", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	rec := serve(&Paginator{}, "/items?sort=name&cursor=aWQ6NQ", func(w http.ResponseWriter, r *http.Request) {
+		page := FromRequest(r)
+		page.First()
+		page.Prev("id:1")
+		page.Next("id:9")
+		page.Next("id:10")
+		page.Total(1234)
+		w.Header().Add("Link", "</docs>; rel=help")
+		w.Write([]byte("[]"))
+	})
+	want := []string{
+		"</docs>; rel=help",
+		`</items?sort=name>; rel=first, </items?sort=name&cursor=aWQ6MQ>; rel=prev, </items?sort=name&cursor=aWQ6MTA>; rel=next`,
+	}
+	got := rec.Header()["Link"]
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Link = %q, want %q", got, want)
+	}
+	links, err := httpfields.ParseLinks(got[1])
+	if err != nil || len(links) != 3 || links[2].Rel != RelNext {
+		t.Errorf("ParseLinks = %+v, %v", links, err)
+	}
+	if got := rec.Header().Get("X-Total-Count"); got != "1234" {
+		t.Errorf("X-Total-Count = %q", got)
+	}
+
+	// Custom total field, explicit status, and fields only on the final
+	// response.
+	rec = serve(&Paginator{TotalHeader: "Total-Items"}, "/items", func(w http.ResponseWriter, r *http.Request) {
+		FromRequest(r).Total(0)
+		w.WriteHeader(http.StatusPartialContent)
+		FromRequest(r).Next("late")
+	})
+	if rec.Code != 206 || rec.Header().Get("Total-Items") != "0" || rec.Header().Get("X-Total-Count") != "" || rec.Header().Get("Link") != "" {
+		t.Errorf("%d %v", rec.Code, rec.Header())
+	}
+
+	// Nothing declared, nothing written.
+	rec = serve(&Paginator{}, "/items", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	if rec.Header().Get("Link") != "" || rec.Header().Get("X-Total-Count") != "" {
+		t.Errorf("fields without a page: %v", rec.Header())
+	}
+
+	// Flushing writes the header.
+	rec = serve(&Paginator{}, "/items", func(w http.ResponseWriter, r *http.Request) {
+		FromRequest(r).Total(3)
+		w.(http.Flusher).Flush()
+	})
+	if !rec.Flushed || rec.Header().Get("X-Total-Count") != "3" {
+		t.Errorf("flushed: %v", rec.Header())
+	}
+}
+
+func TestNilPage(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items", nil)
+	page := FromRequest(r)
+	if page != nil {
+		t.Fatalf("FromRequest without Middleware = %v", page)
+	}
+	page.Next("x")
+	page.First()
+	page.Total(1)
+	if got := page.URL("x"); got != "" {
+		t.Errorf("URL = %q", got)
+	}
+}
@@ -0,0 +1,258 @@
+package httpfields
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/palsivertsen/gohttpfields/encword"
+	"github.com/palsivertsen/gohttpfields/internal/ascii"
+)
+
+var (
+	errDuplicateParam = errors.New("duplicate parameter")
+	errMissingEquals  = errors.New("expected '=' after parameter name")
+	errBadExtValue    = errors.New("malformed extended parameter value")
+	errBadCharset     = errors.New("unsupported extended parameter charset")
+)
+
+// parseParams parses a list of ";"-prefixed parameters as used by media
+// types and content dispositions. Names are lower-cased. Extended values
+// (RFC 8187) and RFC 2231 continuations are decoded and stored under the
+// plain parameter name, taking precedence over a non-extended value of the
+// same name.
+func parseParams(s string) (map[string]string, error) {
+	var (
+		params   = make(map[string]string)
+		extended = make(map[string]string)
+		// continuations holds RFC 2231 sections keyed by base name and then
+		// section name ("0", "1*", ...).
+		continuations map[string]map[string]string
+	)
+	for {
+		s = skipOWS(s)
+		if s == "" {
+			break
+		}
+		if s[0] != ';' {
+			return nil, errUnexpectedCharacters
+		}
+		s = skipOWS(s[1:])
+		if s == "" {
+			// Tolerate a trailing semicolon.
+			break
+		}
+		var name string
+		name, s = consumeToken(s)
+		if name == "" {
+			return nil, errExpectedToken
+		}
+		name = strings.ToLower(name)
+		s = skipOWS(s)
+		if s == "" || s[0] != '=' {
+			return nil, errMissingEquals
+		}
+		s = skipOWS(s[1:])
+		value, rest, err := consumeValue(s)
+		if err != nil {
+			return nil, err
+		}
+		s = rest
+
+		if base, section, ok := splitSection(name); ok {
+			if continuations == nil {
+				continuations = make(map[string]map[string]string)
+			}
+			sections := continuations[base]
+			if sections == nil {
+				sections = make(map[string]string)
+				continuations[base] = sections
+			}
+			if _, dup := sections[section]; dup {
+				return nil, errDuplicateParam
+			}
+			sections[section] = value
+			continue
+		}
+		if strings.HasSuffix(name, "*") {
+			base := name[:len(name)-1]
+			if _, dup := extended[base]; dup {
+				return nil, errDuplicateParam
+			}
+			decoded, err := decodeExtValue(value)
+			if err != nil {
+				return nil, err
+			}
+			extended[base] = decoded
+			continue
+		}
+		if _, dup := params[name]; dup {
+			return nil, errDuplicateParam
+		}
+		params[name] = value
+	}
+	for base, sections := range continuations {
+		value, err := joinSections(sections)
+		if err != nil {
+			return nil, err
+		}
+		extended[base] = value
+	}
+	for name, value := range extended {
+		params[name] = value
+	}
+	return params, nil
+}
+
+// splitSection splits an RFC 2231 section parameter name such as "title*1"
+// or "title*0*" into its base name and section.
+func splitSection(name string) (base, section string, ok bool) {
+	i := strings.IndexByte(name, '*')
+	if i <= 0 || i == len(name)-1 {
+		return "", "", false
+	}
+	section = name[i+1:]
+	digits := strings.TrimSuffix(section, "*")
+	if digits == "" {
+		return "", "", false
+	}
+	for j := 0; j < len(digits); j++ {
+		if digits[j] < '0' || digits[j] > '9' {
+			return "", "", false
+		}
+	}
+	return name[:i], section, true
+}
+
+func joinSections(sections map[string]string) (string, error) {
+	var (
+		raw     strings.Builder
+		charset string
+	)
+	for n := 0; ; n++ {
+		key := strconv.Itoa(n)
+		if v, ok := sections[key]; ok {
+			raw.WriteString(v)
+			continue
+		}
+		v, ok := sections[key+"*"]
+		if !ok {
+			break
+		}
+		if n == 0 {
+			parts := strings.SplitN(v, "'", 3)
+			if len(parts) != 3 {
+				return "", errBadExtValue
+			}
+			charset, v = parts[0], parts[2]
+		}
+		unescaped, err := percentDecode(v)
+		if err != nil {
+			return "", err
+		}
+		raw.WriteString(unescaped)
+	}
+	if charset == "" {
+		return raw.String(), nil
+	}
+	return decodeCharset(charset, raw.String())
+}
+
+// decodeExtValue decodes an RFC 8187 ext-value: charset'[language]'value.
+func decodeExtValue(s string) (string, error) {
+	parts := strings.SplitN(s, "'", 3)
+	if len(parts) != 3 {
+		return "", errBadExtValue
+	}
+	unescaped, err := percentDecode(parts[2])
+	if err != nil {
+		return "", err
+	}
+	return decodeCharset(parts[0], unescaped)
+}
+
+func percentDecode(s string) (string, error) {
+	if strings.IndexByte(s, '%') < 0 {
+		return s, nil
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			b = append(b, s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", errBadExtValue
+		}
+		hi, ok1 := ascii.Unhex(s[i+1])
+		lo, ok2 := ascii.Unhex(s[i+2])
+		if !ok1 || !ok2 {
+			return "", errBadExtValue
+		}
+		b = append(b, hi<<4|lo)
+		i += 2
+	}
+	return string(b), nil
+}
+
+// decodeCharset converts s from a charset in the encword registry.
+func decodeCharset(charset, s string) (string, error) {
+	text, err := encword.DecodeCharset(charset, []byte(s))
+	switch err {
+	case nil:
+		return text, nil
+	case encword.ErrUnknownCharset:
+		return "", errBadCharset
+	}
+	return "", errBadExtValue
+}
+
+// writeParams appends params to b in name order. Values that cannot be
+// represented as a token or quoted-string are written as UTF-8 extended
+// values.
+func writeParams(b *strings.Builder, params map[string]string) {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := params[name]
+		b.WriteString("; ")
+		b.WriteString(name)
+		if needsExtValue(value) {
+			b.WriteString("*=utf-8''")
+			b.WriteString(encodeExtValue(value))
+			continue
+		}
+		b.WriteByte('=')
+		b.WriteString(Quote(value))
+	}
+}
+
+func needsExtValue(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 && c != '\t' || c >= 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		// attr-char from RFC 8187 section 3.2.1.
+		if c < 0x80 && IsTokenChar(c) && c != '*' && c != '\'' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0xf])
+	}
+	return b.String()
+}
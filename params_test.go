@@ -0,0 +1,92 @@
+package httpfields
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{"", map[string]string{}},
+		{`; A=1; b="x y"`, map[string]string{"a": "1", "b": "x y"}},
+		{" ; a = 1 ;", map[string]string{"a": "1"}},
+		{`; q="a\"b"`, map[string]string{"q": `a"b`}},
+
+		// RFC 8187 extended values, with charset aliases and a language.
+		{"; title*=UTF-8''%E2%82%AC%20rates", map[string]string{"title": "€ rates"}},
+		{"; title*=utf8'en'%C3%A9", map[string]string{"title": "é"}},
+		{"; title*=ISO-8859-1'de'Gr%FC%DFe", map[string]string{"title": "Grüße"}},
+		{"; title*=latin1''%E9", map[string]string{"title": "é"}},
+		{"; title*=windows-1252''%80", map[string]string{"title": "€"}},
+		{"; title*=us-ascii''plain", map[string]string{"title": "plain"}},
+		// The extended value wins regardless of order.
+		{`; title*=UTF-8''%C3%A9; title="e"`, map[string]string{"title": "é"}},
+		{`; title="e"; title*=UTF-8''%C3%A9`, map[string]string{"title": "é"}},
+
+		// RFC 2231 section 4.1 continuations.
+		{
+			"; title*0*=us-ascii'en'This%20is%20even%20more%20; title*1*=%2A%2A%2Afun%2A%2A%2A%20; title*2=\"isn't it!\"",
+			map[string]string{"title": "This is even more ***fun*** isn't it!"},
+		},
+		{
+			`; url*0="ftp://"; url*1="cs.utk.edu/pub/moore/bulk-mailer/bulk-mailer.tar"`,
+			map[string]string{"url": "ftp://cs.utk.edu/pub/moore/bulk-mailer/bulk-mailer.tar"},
+		},
+		// Bytes of one character may be split across sections.
+		{"; name*0*=utf-8''%C3; name*1*=%A9", map[string]string{"name": "é"}},
+		// Sections after a gap are ignored.
+		{"; name*0=a; name*2=c", map[string]string{"name": "a"}},
+	}
+	for _, tt := range tests {
+		got, err := parseParams(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseParams(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseParamsErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		err error
+	}{
+		{"; a=1; A=2", errDuplicateParam},
+		{"; a*=utf-8''x; a*=utf-8''y", errDuplicateParam},
+		{"; a*0=x; a*0=y", errDuplicateParam},
+		{"; a", errMissingEquals},
+		{"; =1", errExpectedToken},
+		{"a=1", errUnexpectedCharacters},
+		{"; a*=utf-8'x", errBadExtValue},
+		{"; a*=utf-8''%E", errBadExtValue},
+		{"; a*=utf-8''%ZZ", errBadExtValue},
+		{"; a*=utf-8''%FF", errBadExtValue},
+		{"; a*=us-ascii''%E9", errBadExtValue},
+		{"; a*=koi8-r''x", errBadCharset},
+		{"; a*0*=x", errBadExtValue},
+		{"; a*0*=koi8-r''x", errBadCharset},
+	}
+	for _, tt := range tests {
+		if got, err := parseParams(tt.in); err != tt.err {
+			t.Errorf("parseParams(%q) = %q, %v; want %v", tt.in, got, err, tt.err)
+		}
+	}
+}
+
+func TestWriteParams(t *testing.T) {
+	m := MediaType{Type: "text", Subtype: "plain", Params: map[string]string{
+		"charset": "utf-8",
+		"title":   "€ rates",
+		"note":    "a b",
+	}}
+	want := `text/plain; charset=utf-8; note="a b"; title*=utf-8''%E2%82%AC%20rates`
+	if got := m.String(); got != want {
+		t.Errorf("String = %q, want %q", got, want)
+	}
+	back, err := ParseMediaType(want)
+	if err != nil || !reflect.DeepEqual(back, m) {
+		t.Errorf("ParseMediaType(%q) = %+v, %v", want, back, err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package policy applies declarative header rules to requests and responses.
+//
+// A Policy is a list of rules loaded from JSON or YAML. Each rule matches on
+// method, path and header fields and carries actions that allow or deny the
+// message or edit its fields. An Engine evaluates a compiled policy and can
+// be installed as server middleware or as an http.RoundTripper. In dry-run
+// mode the engine reports what it would change without changing anything.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the document loaded from JSON or YAML.
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule is a single policy rule. All conditions in Match must hold for the
+// actions to run.
+type Rule struct {
+	Name    string   `json:"name" yaml:"name"`
+	Phase   Phase    `json:"phase,omitempty" yaml:"phase,omitempty"`
+	Match   Match    `json:"match" yaml:"match"`
+	Actions []Action `json:"actions" yaml:"actions"`
+}
+
+// Phase selects whether a rule applies to requests, responses or both.
+type Phase string
+
+// Phases. An empty phase applies to both.
+const (
+	PhaseRequest  Phase = "request"
+	PhaseResponse Phase = "response"
+)
+
+// Match holds the conditions of a rule. Empty conditions always hold.
+type Match struct {
+	// Methods lists the request methods, matched case-sensitively.
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+
+	// Path is a pattern in the syntax of path.Match matched against the
+	// request path.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// PathPrefix matches request paths starting with the prefix.
+	PathPrefix string `json:"pathPrefix,omitempty" yaml:"pathPrefix,omitempty"`
+
+	// Fields lists conditions on header fields of the message the rule is
+	// applied to.
+	Fields []FieldMatch `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// Value types understood by FieldMatch.
+const (
+	TypeString    = "string"
+	TypeInteger   = "integer"
+	TypeMediaType = "media-type"
+	TypeTokenList = "token-list"
+)
+
+// FieldMatch is a condition on a header field. Value conditions are tested
+// against the combined field value interpreted according to Type; a field
+// that is absent or cannot be interpreted does not match them.
+type FieldMatch struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Present, if set, requires the field to be present (true) or absent
+	// (false).
+	Present *bool `json:"present,omitempty" yaml:"present,omitempty"`
+
+	// Type is one of TypeString (the default), TypeInteger,
+	// TypeMediaType or TypeTokenList.
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// Equals compares strings exactly, integers numerically, media types
+	// against a media range such as "text/*", and token lists by
+	// case-insensitive membership.
+	Equals string `json:"equals,omitempty" yaml:"equals,omitempty"`
+
+	// Prefix and Regexp apply to strings.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+	Regexp string `json:"regexp,omitempty" yaml:"regexp,omitempty"`
+
+	// Min and Max bound integers, inclusively.
+	Min *int64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max *int64 `json:"max,omitempty" yaml:"max,omitempty"`
+}
+
+// Action types.
+const (
+	ActionAllow  = "allow"  // stop evaluating rules and accept the message
+	ActionDeny   = "deny"   // stop evaluating rules and reject the message
+	ActionSet    = "set"    // replace Field with Value
+	ActionAppend = "append" // add Value to Field
+	ActionRemove = "remove" // delete Field
+	ActionRename = "rename" // move the values of Field to To
+	ActionRedact = "redact" // replace each value of Field with Value, or "REDACTED"
+)
+
+// Action is a single action of a rule.
+type Action struct {
+	Type  string `json:"type" yaml:"type"`
+	Field string `json:"field,omitempty" yaml:"field,omitempty"`
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	To    string `json:"to,omitempty" yaml:"to,omitempty"`
+
+	// Status is the response status used by deny. It defaults to 403 for
+	// requests and 502 for responses.
+	Status int `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+// ParseJSON parses a JSON policy document. Unknown keys are rejected.
+func ParseJSON(data []byte) (*Policy, error) {
+	var p Policy
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ParseYAML parses a YAML policy document. Unknown keys are rejected.
+func ParseYAML(data []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// LoadFile reads a policy document, choosing the format by file extension:
+// ".yaml" and ".yml" are parsed as YAML and anything else as JSON.
+func LoadFile(name string) (*Policy, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+	return ParseJSON(data)
+}
@@ -0,0 +1,319 @@
+package policy
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// Change describes a single edit made, or in dry-run mode proposed, by an
+// action.
+type Change struct {
+	Rule   string
+	Action string
+	Field  string
+	Old    []string // values before the change, nil if absent
+	New    []string // values after the change, nil if removed
+}
+
+// Decision is the outcome of evaluating a policy against a message.
+type Decision struct {
+	// Denied is set if a deny action ran. Status holds the status to
+	// respond with and Rule the name of the denying rule.
+	Denied bool
+	Status int
+	Rule   string
+
+	// Changes lists the edits in the order they were made.
+	Changes []Change
+}
+
+// Engine evaluates a compiled policy. It is safe for concurrent use.
+type Engine struct {
+	rules []rule
+
+	// DryRun makes the engine report decisions without editing or
+	// rejecting messages. OnDecision still sees denials, but the Decision
+	// returned by Evaluate never has Denied set.
+	DryRun bool
+
+	// OnDecision, if set, is called for every evaluated message with at
+	// least one change or a denial.
+	OnDecision func(req *http.Request, phase Phase, d Decision)
+}
+
+type rule struct {
+	Rule
+	fields []fieldMatch
+}
+
+type fieldMatch struct {
+	FieldMatch
+	name   string
+	re     *regexp.Regexp
+	equals int64
+}
+
+// New compiles p into an Engine.
+func New(p *Policy) (*Engine, error) {
+	e := &Engine{}
+	for i, r := range p.Rules {
+		cr, err := compileRule(r)
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i)
+			}
+			return nil, fmt.Errorf("policy: rule %s: %v", name, err)
+		}
+		e.rules = append(e.rules, cr)
+	}
+	return e, nil
+}
+
+func compileRule(r Rule) (rule, error) {
+	cr := rule{Rule: r}
+	switch r.Phase {
+	case "", PhaseRequest, PhaseResponse:
+	default:
+		return cr, fmt.Errorf("unknown phase %q", r.Phase)
+	}
+	if r.Match.Path != "" {
+		if _, err := path.Match(r.Match.Path, ""); err != nil {
+			return cr, fmt.Errorf("path %q: %v", r.Match.Path, err)
+		}
+	}
+	for _, f := range r.Match.Fields {
+		if f.Name == "" {
+			return cr, fmt.Errorf("field condition without name")
+		}
+		cf := fieldMatch{FieldMatch: f, name: http.CanonicalHeaderKey(f.Name)}
+		switch f.Type {
+		case "", TypeString:
+			if f.Regexp != "" {
+				re, err := regexp.Compile(f.Regexp)
+				if err != nil {
+					return cr, fmt.Errorf("field %s: %v", f.Name, err)
+				}
+				cf.re = re
+			}
+		case TypeInteger:
+			if f.Equals != "" {
+				n, err := strconv.ParseInt(f.Equals, 10, 64)
+				if err != nil {
+					return cr, fmt.Errorf("field %s: %v", f.Name, err)
+				}
+				cf.equals = n
+			}
+		case TypeMediaType, TypeTokenList:
+		default:
+			return cr, fmt.Errorf("field %s: unknown type %q", f.Name, f.Type)
+		}
+		if f.Type != "" && f.Type != TypeString && (f.Prefix != "" || f.Regexp != "") {
+			return cr, fmt.Errorf("field %s: prefix and regexp require type string", f.Name)
+		}
+		if f.Type != TypeInteger && (f.Min != nil || f.Max != nil) {
+			return cr, fmt.Errorf("field %s: min and max require type integer", f.Name)
+		}
+		cr.fields = append(cr.fields, cf)
+	}
+	if len(r.Actions) == 0 {
+		return cr, fmt.Errorf("no actions")
+	}
+	for _, a := range r.Actions {
+		switch a.Type {
+		case ActionAllow, ActionDeny:
+		case ActionSet, ActionAppend, ActionRemove, ActionRedact:
+			if a.Field == "" {
+				return cr, fmt.Errorf("%s action without field", a.Type)
+			}
+		case ActionRename:
+			if a.Field == "" || a.To == "" {
+				return cr, fmt.Errorf("rename action requires field and to")
+			}
+		default:
+			return cr, fmt.Errorf("unknown action %q", a.Type)
+		}
+	}
+	return cr, nil
+}
+
+// Evaluate runs the rules of the given phase against a message. For the
+// request phase header is the request header; for the response phase it is
+// the response header, while method and path are still taken from req. Unless
+// the engine is in dry-run mode, header is edited in place.
+func (e *Engine) Evaluate(phase Phase, req *http.Request, header http.Header) Decision {
+	var d Decision
+	target := header
+	if e.DryRun {
+		target = cloneHeader(header)
+	}
+rules:
+	for _, r := range e.rules {
+		if r.Phase != "" && r.Phase != phase {
+			continue
+		}
+		if !r.matches(req, target) {
+			continue
+		}
+		for _, a := range r.Actions {
+			switch a.Type {
+			case ActionAllow:
+				break rules
+			case ActionDeny:
+				d.Denied, d.Rule, d.Status = true, r.Name, a.Status
+				if d.Status == 0 {
+					d.Status = http.StatusForbidden
+					if phase == PhaseResponse {
+						d.Status = http.StatusBadGateway
+					}
+				}
+				break rules
+			default:
+				d.Changes = append(d.Changes, apply(r.Name, a, target)...)
+			}
+		}
+	}
+	if e.OnDecision != nil && (d.Denied || len(d.Changes) > 0) {
+		e.OnDecision(req, phase, d)
+	}
+	if e.DryRun {
+		d.Denied = false
+	}
+	return d
+}
+
+func (r *rule) matches(req *http.Request, header http.Header) bool {
+	m := r.Match
+	if len(m.Methods) > 0 {
+		found := false
+		for _, method := range m.Methods {
+			if method == req.Method {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if m.Path != "" {
+		if ok, _ := path.Match(m.Path, req.URL.Path); !ok {
+			return false
+		}
+	}
+	if m.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, m.PathPrefix) {
+		return false
+	}
+	for _, f := range r.fields {
+		if !f.matches(header) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *fieldMatch) matches(header http.Header) bool {
+	values, present := header[f.name]
+	if f.Present != nil && *f.Present != present {
+		return false
+	}
+	hasValueCondition := f.Equals != "" || f.Prefix != "" || f.Regexp != "" || f.Min != nil || f.Max != nil
+	if !hasValueCondition {
+		return true
+	}
+	if !present {
+		return false
+	}
+	value := strings.Join(values, ", ")
+	switch f.Type {
+	case TypeInteger:
+		n, err := strconv.ParseInt(httpfields.TrimOWS(value), 10, 64)
+		if err != nil {
+			return false
+		}
+		return (f.Equals == "" || n == f.equals) &&
+			(f.Min == nil || n >= *f.Min) &&
+			(f.Max == nil || n <= *f.Max)
+	case TypeMediaType:
+		mt, err := httpfields.ParseMediaType(value)
+		return err == nil && mt.Matches(f.Equals)
+	case TypeTokenList:
+		for _, member := range httpfields.SplitList(value) {
+			if strings.EqualFold(member, f.Equals) {
+				return true
+			}
+		}
+		return false
+	}
+	return (f.Equals == "" || value == f.Equals) &&
+		(f.Prefix == "" || strings.HasPrefix(value, f.Prefix)) &&
+		(f.re == nil || f.re.MatchString(value))
+}
+
+// apply performs an editing action on header and returns the changes made.
+func apply(ruleName string, a Action, header http.Header) []Change {
+	name := http.CanonicalHeaderKey(a.Field)
+	old := header[name]
+	change := Change{Rule: ruleName, Action: a.Type, Field: name, Old: copyValues(old)}
+	switch a.Type {
+	case ActionSet:
+		header[name] = []string{a.Value}
+	case ActionAppend:
+		header[name] = append(copyValues(old), a.Value)
+	case ActionRemove:
+		if old == nil {
+			return nil
+		}
+		delete(header, name)
+	case ActionRedact:
+		if old == nil {
+			return nil
+		}
+		redacted := make([]string, len(old))
+		for i := range redacted {
+			redacted[i] = a.Value
+			if redacted[i] == "" {
+				redacted[i] = "REDACTED"
+			}
+		}
+		header[name] = redacted
+	case ActionRename:
+		if old == nil {
+			return nil
+		}
+		to := http.CanonicalHeaderKey(a.To)
+		if to == name {
+			// Renaming a field to itself, possibly in other case, changes
+			// nothing.
+			return nil
+		}
+		prev := header[to]
+		delete(header, name)
+		header[to] = append(copyValues(prev), old...)
+		change.New = nil
+		return []Change{change, {Rule: ruleName, Action: a.Type, Field: to, Old: copyValues(prev), New: copyValues(header[to])}}
+	}
+	change.New = copyValues(header[name])
+	return []Change{change}
+}
+
+func copyValues(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string(nil), values...)
+}
+
+func cloneHeader(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		out[name] = copyValues(values)
+	}
+	return out
+}
@@ -0,0 +1,347 @@
+package policy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustEngine(t *testing.T, doc string) *Engine {
+	t.Helper()
+	p, err := ParseYAML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := New(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		err  string
+	}{
+		{"phase", Rule{Name: "r", Phase: "both", Actions: []Action{{Type: ActionAllow}}}, `policy: rule r: unknown phase "both"`},
+		{"path", Rule{Name: "r", Match: Match{Path: "["}, Actions: []Action{{Type: ActionAllow}}}, `policy: rule r: path "["`},
+		{"field without name", Rule{Match: Match{Fields: []FieldMatch{{Equals: "x"}}}, Actions: []Action{{Type: ActionAllow}}}, "policy: rule #0: field condition without name"},
+		{"regexp", Rule{Name: "r", Match: Match{Fields: []FieldMatch{{Name: "a", Regexp: "("}}}, Actions: []Action{{Type: ActionAllow}}}, "policy: rule r: field a: error parsing regexp"},
+		{"integer", Rule{Name: "r", Match: Match{Fields: []FieldMatch{{Name: "a", Type: TypeInteger, Equals: "x"}}}, Actions: []Action{{Type: ActionAllow}}}, "policy: rule r: field a: strconv.ParseInt"},
+		{"type", Rule{Name: "r", Match: Match{Fields: []FieldMatch{{Name: "a", Type: "float"}}}, Actions: []Action{{Type: ActionAllow}}}, `policy: rule r: field a: unknown type "float"`},
+		{"prefix on integer", Rule{Name: "r", Match: Match{Fields: []FieldMatch{{Name: "a", Type: TypeInteger, Prefix: "1"}}}, Actions: []Action{{Type: ActionAllow}}}, "policy: rule r: field a: prefix and regexp require type string"},
+		{"min on string", Rule{Name: "r", Match: Match{Fields: []FieldMatch{{Name: "a", Min: new(int64)}}}, Actions: []Action{{Type: ActionAllow}}}, "policy: rule r: field a: min and max require type integer"},
+		{"no actions", Rule{Name: "r"}, "policy: rule r: no actions"},
+		{"set without field", Rule{Name: "r", Actions: []Action{{Type: ActionSet}}}, "policy: rule r: set action without field"},
+		{"rename without to", Rule{Name: "r", Actions: []Action{{Type: ActionRename, Field: "a"}}}, "policy: rule r: rename action requires field and to"},
+		{"unknown action", Rule{Name: "r", Actions: []Action{{Type: "log"}}}, `policy: rule r: unknown action "log"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(&Policy{Rules: []Rule{tt.rule}})
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("err = %v, want %s...", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseUnknownKeys(t *testing.T) {
+	if _, err := ParseJSON([]byte(`{"rules":[{"name":"r","actions":[{"type":"allow"}],"extra":1}]}`)); err == nil {
+		t.Error("JSON: unknown key accepted")
+	}
+	if _, err := ParseYAML([]byte("rules:\n- name: r\n  extra: 1\n")); err == nil {
+		t.Error("YAML: unknown key accepted")
+	}
+}
+
+func TestActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  Action
+		header  http.Header
+		want    http.Header
+		changes []Change
+	}{
+		{
+			"set", Action{Type: ActionSet, Field: "x-a", Value: "v"},
+			http.Header{"X-A": {"1", "2"}}, http.Header{"X-A": {"v"}},
+			[]Change{{Rule: "r", Action: ActionSet, Field: "X-A", Old: []string{"1", "2"}, New: []string{"v"}}},
+		},
+		{
+			"append", Action{Type: ActionAppend, Field: "X-A", Value: "v"},
+			http.Header{"X-A": {"1"}}, http.Header{"X-A": {"1", "v"}},
+			[]Change{{Rule: "r", Action: ActionAppend, Field: "X-A", Old: []string{"1"}, New: []string{"1", "v"}}},
+		},
+		{
+			"remove", Action{Type: ActionRemove, Field: "X-A"},
+			http.Header{"X-A": {"1"}, "X-B": {"2"}}, http.Header{"X-B": {"2"}},
+			[]Change{{Rule: "r", Action: ActionRemove, Field: "X-A", Old: []string{"1"}}},
+		},
+		{
+			"remove absent", Action{Type: ActionRemove, Field: "X-A"},
+			http.Header{"X-B": {"2"}}, http.Header{"X-B": {"2"}}, nil,
+		},
+		{
+			"redact", Action{Type: ActionRedact, Field: "Authorization"},
+			http.Header{"Authorization": {"Bearer a", "Bearer b"}}, http.Header{"Authorization": {"REDACTED", "REDACTED"}},
+			[]Change{{Rule: "r", Action: ActionRedact, Field: "Authorization", Old: []string{"Bearer a", "Bearer b"}, New: []string{"REDACTED", "REDACTED"}}},
+		},
+		{
+			"redact with value", Action{Type: ActionRedact, Field: "Cookie", Value: "-"},
+			http.Header{"Cookie": {"a=b"}}, http.Header{"Cookie": {"-"}},
+			[]Change{{Rule: "r", Action: ActionRedact, Field: "Cookie", Old: []string{"a=b"}, New: []string{"-"}}},
+		},
+		{
+			"rename", Action{Type: ActionRename, Field: "X-Old", To: "x-new"},
+			http.Header{"X-Old": {"1"}, "X-New": {"0"}}, http.Header{"X-New": {"0", "1"}},
+			[]Change{
+				{Rule: "r", Action: ActionRename, Field: "X-Old", Old: []string{"1"}},
+				{Rule: "r", Action: ActionRename, Field: "X-New", Old: []string{"0"}, New: []string{"0", "1"}},
+			},
+		},
+		{
+			"rename to same name", Action{Type: ActionRename, Field: "X-Same", To: "x-same"},
+			http.Header{"X-Same": {"1", "2"}}, http.Header{"X-Same": {"1", "2"}}, nil,
+		},
+		{
+			"rename absent", Action{Type: ActionRename, Field: "X-Old", To: "X-New"},
+			http.Header{}, http.Header{}, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := New(&Policy{Rules: []Rule{{Name: "r", Actions: []Action{tt.action}}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			d := e.Evaluate(PhaseRequest, req, tt.header)
+			if !reflect.DeepEqual(tt.header, tt.want) {
+				t.Errorf("header = %v, want %v", tt.header, tt.want)
+			}
+			if !reflect.DeepEqual(d.Changes, tt.changes) {
+				t.Errorf("changes = %+v, want %+v", d.Changes, tt.changes)
+			}
+		})
+	}
+}
+
+func TestMatching(t *testing.T) {
+	e := mustEngine(t, `
+rules:
+- name: admin
+  match:
+    methods: [POST]
+    path: /admin/*
+  actions: [{type: set, field: X-Matched, value: admin}]
+- name: api
+  match:
+    pathPrefix: /api/
+    fields:
+    - {name: content-type, type: media-type, equals: "application/*"}
+    - {name: content-length, type: integer, max: 100}
+    - {name: x-debug, present: false}
+  actions: [{type: set, field: X-Matched, value: api}]
+- name: features
+  match:
+    fields:
+    - {name: x-features, type: token-list, equals: beta}
+    - {name: user-agent, prefix: "curl/", regexp: "^curl/8\\."}
+  actions: [{type: set, field: X-Matched, value: features}]
+`)
+	tests := []struct {
+		method, path string
+		header       http.Header
+		want         string
+	}{
+		{"POST", "/admin/users", nil, "admin"},
+		{"GET", "/admin/users", nil, ""},
+		{"POST", "/admin/users/1", nil, ""},
+		{"PUT", "/api/x", http.Header{"Content-Type": {"application/json; charset=utf-8"}, "Content-Length": {"42"}}, "api"},
+		{"PUT", "/api/x", http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"42"}}, ""},
+		{"PUT", "/api/x", http.Header{"Content-Type": {"application/json"}, "Content-Length": {"420"}}, ""},
+		{"PUT", "/api/x", http.Header{"Content-Type": {"application/json"}, "Content-Length": {"x"}}, ""},
+		{"PUT", "/api/x", http.Header{"Content-Type": {"application/json"}}, ""},
+		{"PUT", "/api/x", http.Header{"Content-Type": {"application/json"}, "Content-Length": {"1"}, "X-Debug": {""}}, ""},
+		{"GET", "/", http.Header{"X-Features": {"alpha", "BETA"}, "User-Agent": {"curl/8.4.0"}}, "features"},
+		{"GET", "/", http.Header{"X-Features": {"alphabeta"}, "User-Agent": {"curl/8.4.0"}}, ""},
+		{"GET", "/", http.Header{"X-Features": {"beta"}, "User-Agent": {"curl/7.88.1"}}, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		h := tt.header
+		if h == nil {
+			h = make(http.Header)
+		}
+		e.Evaluate(PhaseRequest, req, h)
+		if got := h.Get("X-Matched"); got != tt.want {
+			t.Errorf("%s %s %v: matched %q, want %q", tt.method, tt.path, tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestAllowAndDeny(t *testing.T) {
+	e := mustEngine(t, `
+rules:
+- name: health
+  match: {path: /healthz}
+  actions: [{type: allow}]
+- name: strip
+  actions: [{type: remove, field: X-Internal}]
+- name: no-tokens
+  phase: request
+  match:
+    fields: [{name: x-token, present: true}]
+  actions: [{type: deny, status: 401}, {type: set, field: X-Unreached, value: x}]
+- name: no-leaks
+  phase: response
+  match:
+    fields: [{name: x-secret, present: true}]
+  actions: [{type: deny}]
+`)
+	h := http.Header{"X-Internal": {"1"}, "X-Token": {"t"}}
+	d := e.Evaluate(PhaseRequest, httptest.NewRequest("GET", "/healthz", nil), h)
+	if d.Denied || h.Get("X-Internal") == "" {
+		t.Errorf("allow did not stop evaluation: %+v %v", d, h)
+	}
+
+	h = http.Header{"X-Internal": {"1"}, "X-Token": {"t"}}
+	d = e.Evaluate(PhaseRequest, httptest.NewRequest("GET", "/", nil), h)
+	if !d.Denied || d.Status != 401 || d.Rule != "no-tokens" || h.Get("X-Unreached") != "" {
+		t.Errorf("request deny: %+v %v", d, h)
+	}
+	if len(d.Changes) != 1 || d.Changes[0].Field != "X-Internal" {
+		t.Errorf("changes before deny: %+v", d.Changes)
+	}
+
+	d = e.Evaluate(PhaseResponse, httptest.NewRequest("GET", "/", nil), http.Header{"X-Secret": {"s"}, "X-Token": {"t"}})
+	if !d.Denied || d.Status != http.StatusBadGateway || d.Rule != "no-leaks" {
+		t.Errorf("response deny: %+v", d)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	e := mustEngine(t, `
+rules:
+- name: r
+  actions: [{type: set, field: X-A, value: new}, {type: deny}]
+`)
+	e.DryRun = true
+	var seen []Decision
+	e.OnDecision = func(req *http.Request, phase Phase, d Decision) { seen = append(seen, d) }
+	h := http.Header{"X-A": {"old"}}
+	d := e.Evaluate(PhaseRequest, httptest.NewRequest("GET", "/", nil), h)
+	if d.Denied || h.Get("X-A") != "old" {
+		t.Errorf("dry run edited or rejected: %+v %v", d, h)
+	}
+	if len(d.Changes) != 1 || d.Changes[0].New[0] != "new" {
+		t.Errorf("dry run changes: %+v", d.Changes)
+	}
+	if len(seen) != 1 || !seen[0].Denied || seen[0].Status != http.StatusForbidden {
+		t.Errorf("OnDecision saw %+v", seen)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	e := mustEngine(t, `
+rules:
+- name: rename
+  phase: request
+  actions: [{type: rename, field: X-Client, to: X-Forwarded-Client}]
+- name: same
+  phase: request
+  actions: [{type: rename, field: X-Same, to: X-SAME}]
+- name: block
+  phase: request
+  match: {methods: [DELETE]}
+  actions: [{type: deny, status: 405}]
+- name: server
+  phase: response
+  actions: [{type: remove, field: Server}, {type: set, field: X-Frame-Options, value: DENY}]
+- name: leak
+  phase: response
+  match:
+    fields: [{name: x-debug, present: true}]
+  actions: [{type: deny}]
+`)
+	var got http.Header
+	handler := e.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Header().Set("Server", "app/1.0")
+		if r.URL.Path == "/debug" {
+			w.Header().Set("X-Debug", "stack")
+		}
+		w.Write([]byte("body"))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Client", "c")
+	req.Header["X-Same"] = []string{"1", "2"}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got.Get("X-Forwarded-Client") != "c" || got.Get("X-Client") != "" {
+		t.Errorf("request rename: %v", got)
+	}
+	if !reflect.DeepEqual(got["X-Same"], []string{"1", "2"}) {
+		t.Errorf("rename to the same name: %q", got["X-Same"])
+	}
+	if rec.Code != 200 || rec.Body.String() != "body" || rec.Header().Get("Server") != "" || rec.Header().Get("X-Frame-Options") != "DENY" {
+		t.Errorf("response: %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	got = nil
+	handler.ServeHTTP(rec, httptest.NewRequest("DELETE", "/", nil))
+	if rec.Code != 405 || got != nil {
+		t.Errorf("denied request: %d, handler ran %v", rec.Code, got != nil)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug", nil))
+	if rec.Code != http.StatusBadGateway || rec.Body.Len() != 0 || rec.Header().Get("X-Debug") != "" {
+		t.Errorf("denied response: %d %q %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Seen", r.Header.Get("Authorization"))
+		if r.URL.Path == "/leak" {
+			w.Header().Set("X-Debug", "1")
+		}
+	}))
+	defer srv.Close()
+	e := mustEngine(t, `
+rules:
+- name: auth
+  phase: request
+  actions: [{type: set, field: Authorization, value: Bearer t}]
+- name: leak
+  phase: response
+  match:
+    fields: [{name: x-debug, present: true}]
+  actions: [{type: deny}]
+`)
+	client := &http.Client{Transport: e.RoundTripper(nil)}
+	req, _ := http.NewRequest("GET", srv.URL+"/", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.Header.Get("X-Seen") != "Bearer t" || req.Header.Get("Authorization") != "" {
+		t.Errorf("request rules: sent %q, caller's header %v", resp.Header.Get("X-Seen"), req.Header)
+	}
+
+	_, err = client.Get(srv.URL + "/leak")
+	if err == nil || !strings.Contains(err.Error(), `policy: response denied by rule "leak"`) {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package policy
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// DeniedError is returned by the RoundTripper when a rule denies a message.
+type DeniedError struct {
+	Phase  Phase
+	Rule   string
+	Status int
+}
+
+func (e *DeniedError) Error() string {
+	return "policy: " + string(e.Phase) + " denied by rule " + strconv.Quote(e.Rule)
+}
+
+// Middleware applies the request rules before calling next and the response
+// rules to the header written by next. Denied requests are answered with the
+// status of the deny action; denied responses are replaced by an empty
+// response with that status.
+func (e *Engine) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d := e.Evaluate(PhaseRequest, r, r.Header); d.Denied {
+			w.WriteHeader(d.Status)
+			return
+		}
+		next.ServeHTTP(&responseWriter{ResponseWriter: w, engine: e, req: r}, r)
+	})
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	engine      *Engine
+	req         *http.Request
+	wroteHeader bool
+	denied      bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if d := w.engine.Evaluate(PhaseResponse, w.req, w.Header()); d.Denied {
+		w.denied = true
+		h := w.Header()
+		for name := range h {
+			delete(h, name)
+		}
+		status = d.Status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.denied {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.denied {
+		f.Flush()
+	}
+}
+
+// RoundTripper returns an http.RoundTripper that applies the request rules
+// to a copy of each request and the response rules to each response. Denials
+// are reported as *DeniedError. If next is nil, http.DefaultTransport is
+// used.
+func (e *Engine) RoundTripper(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper{engine: e, next: next}
+}
+
+type roundTripper struct {
+	engine *Engine
+	next   http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.WithContext(req.Context())
+	r.Header = cloneHeader(req.Header)
+	if d := rt.engine.Evaluate(PhaseRequest, r, r.Header); d.Denied {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &DeniedError{Phase: PhaseRequest, Rule: d.Rule, Status: d.Status}
+	}
+	resp, err := rt.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	if d := rt.engine.Evaluate(PhaseResponse, req, resp.Header); d.Denied {
+		resp.Body.Close()
+		return nil, &DeniedError{Phase: PhaseResponse, Rule: d.Rule, Status: d.Status}
+	}
+	return resp, nil
+}
@@ -0,0 +1,238 @@
+// Package privacy handles the privacy preference signals sent by user
+// agents: Global Privacy Control (the Sec-GPC field) and the legacy Do Not
+// Track field (DNT).
+//
+// Wrap handlers with Middleware and consult OptedOut before selling or
+// sharing personal data:
+//
+//	if privacy.OptedOut(r) {
+//		// do not share
+//	}
+//
+// Middleware can also serve the /.well-known/gpc.json resource declaring
+// that the site honours the signal.
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+	"github.com/palsivertsen/gohttpfields/sfv"
+)
+
+// Field names of the privacy signals.
+const (
+	HeaderSecGPC = "Sec-GPC"
+	HeaderDNT    = "DNT"
+)
+
+// WellKnownPath is the path of the GPC support resource.
+const WellKnownPath = "/.well-known/gpc.json"
+
+var (
+	// ErrInvalidSecGPC is returned for Sec-GPC values other than "1" and
+	// structured booleans.
+	ErrInvalidSecGPC = errors.New("privacy: invalid Sec-GPC value")
+	// ErrInvalidDNT is returned for DNT values that do not start with 0 or
+	// 1 or carry invalid extension characters.
+	ErrInvalidDNT = errors.New("privacy: invalid DNT value")
+)
+
+// ParseSecGPC parses a Sec-GPC field value. Browsers send the literal "1",
+// the only value the GPC specification defines; the structured boolean ?1
+// (with any parameters) is accepted as well. Only true expresses a
+// preference.
+func ParseSecGPC(s string) (bool, error) {
+	if httpfields.TrimOWS(s) == "1" {
+		return true, nil
+	}
+	it, err := sfv.ParseItem(s)
+	if err != nil {
+		return false, ErrInvalidSecGPC
+	}
+	b, ok := it.Value.(bool)
+	if !ok {
+		return false, ErrInvalidSecGPC
+	}
+	return b, nil
+}
+
+// DNT is a Do Not Track preference.
+type DNT int
+
+// DNT preferences. DNTUnset is the zero value, used when the field is
+// missing or invalid.
+const (
+	DNTUnset   DNT = iota
+	DNTOptOut      // "1": do not track
+	DNTConsent     // "0": tracking permitted
+)
+
+// ParseDNT parses a DNT field value: "0" or "1" optionally followed by
+// extension characters, which are ignored.
+func ParseDNT(s string) (DNT, error) {
+	s = httpfields.TrimOWS(s)
+	if s == "" {
+		return DNTUnset, ErrInvalidDNT
+	}
+	for i := 1; i < len(s); i++ {
+		if c := s[i]; c < 0x21 || c > 0x7e || c == '"' || c == ',' || c == '\\' {
+			return DNTUnset, ErrInvalidDNT
+		}
+	}
+	switch s[0] {
+	case '1':
+		return DNTOptOut, nil
+	case '0':
+		return DNTConsent, nil
+	}
+	return DNTUnset, ErrInvalidDNT
+}
+
+// Signal holds the privacy preferences of a request. Invalid or repeated
+// fields are treated as absent.
+type Signal struct {
+	GPC bool
+	DNT DNT
+}
+
+// Parse reads the privacy preferences from a request header.
+func Parse(h http.Header) Signal {
+	var sig Signal
+	if v := h[http.CanonicalHeaderKey(HeaderSecGPC)]; len(v) == 1 {
+		sig.GPC, _ = ParseSecGPC(v[0])
+	}
+	if v := h[http.CanonicalHeaderKey(HeaderDNT)]; len(v) == 1 {
+		sig.DNT, _ = ParseDNT(v[0])
+	}
+	return sig
+}
+
+// OptOut reports whether the signal asks not to sell or share personal
+// data. DNT counts only if honorDNT is set, since it was never given the
+// legal weight of GPC.
+func (s Signal) OptOut(honorDNT bool) bool {
+	return s.GPC || honorDNT && s.DNT == DNTOptOut
+}
+
+// Options configures Middleware. The zero value honours GPC only and does
+// not serve the well-known resource.
+type Options struct {
+	// HonorDNT treats DNT: 1 as an opt-out.
+	HonorDNT bool
+	// Resource is served at WellKnownPath when not nil.
+	Resource *Resource
+}
+
+// Middleware is Options{}.Middleware.
+func Middleware(next http.Handler) http.Handler {
+	return (&Options{}).Middleware(next)
+}
+
+// Middleware makes the privacy signal of each request available to next
+// through FromContext and OptedOut, and answers requests for WellKnownPath
+// if o.Resource is set.
+func (o *Options) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.Resource != nil && r.URL.Path == WellKnownPath {
+			o.Resource.ServeHTTP(w, r)
+			return
+		}
+		sig := Parse(r.Header)
+		ctx := context.WithValue(r.Context(), contextKey{}, state{
+			signal: sig,
+			optOut: sig.OptOut(o.HonorDNT),
+		})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type contextKey struct{}
+
+type state struct {
+	signal Signal
+	optOut bool
+}
+
+// FromContext returns the signal stored by Middleware. Without
+// Middleware, the zero Signal is returned.
+func FromContext(ctx context.Context) Signal {
+	st, _ := ctx.Value(contextKey{}).(state)
+	return st.signal
+}
+
+// OptedOut reports whether the user of a request opted out of the sale or
+// sharing of personal data. Requests that did not pass through Middleware
+// are checked for GPC only.
+func OptedOut(r *http.Request) bool {
+	if st, ok := r.Context().Value(contextKey{}).(state); ok {
+		return st.optOut
+	}
+	return Parse(r.Header).OptOut(false)
+}
+
+// Resource is the GPC support resource served at WellKnownPath.
+type Resource struct {
+	// GPC declares that the site honours GPC signals.
+	GPC bool
+	// LastUpdate is the date the resource last changed; the time of day
+	// is ignored. A zero LastUpdate is omitted.
+	LastUpdate time.Time
+}
+
+type resourceJSON struct {
+	GPC        bool   `json:"gpc"`
+	LastUpdate string `json:"lastUpdate,omitempty"`
+}
+
+// MarshalJSON encodes the resource, writing LastUpdate as a full date.
+func (res Resource) MarshalJSON() ([]byte, error) {
+	v := resourceJSON{GPC: res.GPC}
+	if !res.LastUpdate.IsZero() {
+		v.LastUpdate = res.LastUpdate.Format("2006-01-02")
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes the resource.
+func (res *Resource) UnmarshalJSON(b []byte) error {
+	var v resourceJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	res.GPC = v.GPC
+	res.LastUpdate = time.Time{}
+	if v.LastUpdate != "" {
+		t, err := time.Parse("2006-01-02", v.LastUpdate)
+		if err != nil {
+			return err
+		}
+		res.LastUpdate = t
+	}
+	return nil
+}
+
+// ServeHTTP serves the resource as JSON to GET and HEAD requests.
+func (res *Resource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(b)
+	}
+}
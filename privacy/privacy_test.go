@@ -0,0 +1,205 @@
+package privacy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBrowserSecGPCOptsOut(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Sec-GPC", "1")
+
+	if got, err := ParseSecGPC("1"); err != nil || !got {
+		t.Fatalf(`ParseSecGPC("1") = %v, %v; want true, nil`, got, err)
+	}
+	if !Parse(r.Header).GPC {
+		t.Error("Parse: GPC = false, want true")
+	}
+	if !OptedOut(r) {
+		t.Error("OptedOut without middleware = false, want true")
+	}
+	var optedOut bool
+	Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		optedOut = OptedOut(r)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	if !optedOut {
+		t.Error("OptedOut behind middleware = false, want true")
+	}
+}
+
+func TestParseSecGPC(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+		err  error
+	}{
+		{"1", true, nil},
+		{" 1 ", true, nil},
+		{"?1", true, nil},
+		{"?1;v=2", true, nil},
+		{"?0", false, nil},
+		{"0", false, ErrInvalidSecGPC},
+		{"true", false, ErrInvalidSecGPC},
+		{"", false, ErrInvalidSecGPC},
+		{"1, 1", false, ErrInvalidSecGPC},
+	}
+	for _, tt := range tests {
+		got, err := ParseSecGPC(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseSecGPC(%q) = %v, %v; want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestParseDNT(t *testing.T) {
+	tests := []struct {
+		in   string
+		want DNT
+		err  error
+	}{
+		{"1", DNTOptOut, nil},
+		{"0", DNTConsent, nil},
+		{" 1 ", DNTOptOut, nil},
+		{"1xyz", DNTOptOut, nil},
+		{"0!#$", DNTConsent, nil},
+		{"", DNTUnset, ErrInvalidDNT},
+		{"2", DNTUnset, ErrInvalidDNT},
+		{"yes", DNTUnset, ErrInvalidDNT},
+		{"1 x", DNTUnset, ErrInvalidDNT},
+		{"1,0", DNTUnset, ErrInvalidDNT},
+		{`1"`, DNTUnset, ErrInvalidDNT},
+	}
+	for _, tt := range tests {
+		got, err := ParseDNT(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseDNT(%q) = %v, %v; want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestSignalMatrix(t *testing.T) {
+	tests := []struct {
+		name      string
+		gpc       []string
+		dnt       []string
+		signal    Signal
+		optOut    bool // GPC only
+		optOutDNT bool // with HonorDNT
+	}{
+		{"none", nil, nil, Signal{}, false, false},
+		{"GPC", []string{"1"}, nil, Signal{GPC: true}, true, true},
+		{"DNT opt-out", nil, []string{"1"}, Signal{DNT: DNTOptOut}, false, true},
+		{"DNT consent", nil, []string{"0"}, Signal{DNT: DNTConsent}, false, false},
+		{"GPC and DNT consent", []string{"1"}, []string{"0"}, Signal{GPC: true, DNT: DNTConsent}, true, true},
+		{"GPC false and DNT opt-out", []string{"?0"}, []string{"1"}, Signal{DNT: DNTOptOut}, false, true},
+		{"invalid GPC", []string{"yes"}, []string{"1"}, Signal{DNT: DNTOptOut}, false, true},
+		{"invalid DNT", []string{"1"}, []string{"x"}, Signal{GPC: true}, true, true},
+		{"repeated GPC", []string{"1", "1"}, nil, Signal{}, false, false},
+		{"repeated DNT", nil, []string{"1", "1"}, Signal{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, v := range tt.gpc {
+				r.Header.Add("Sec-GPC", v)
+			}
+			for _, v := range tt.dnt {
+				r.Header.Add("DNT", v)
+			}
+			if got := Parse(r.Header); got != tt.signal {
+				t.Errorf("Parse = %+v, want %+v", got, tt.signal)
+			}
+			if got := tt.signal.OptOut(false); got != tt.optOut {
+				t.Errorf("OptOut(false) = %v, want %v", got, tt.optOut)
+			}
+			if got := tt.signal.OptOut(true); got != tt.optOutDNT {
+				t.Errorf("OptOut(true) = %v, want %v", got, tt.optOutDNT)
+			}
+			if got := OptedOut(r); got != tt.optOut {
+				t.Errorf("OptedOut without middleware = %v, want %v", got, tt.optOut)
+			}
+			for _, honorDNT := range []bool{false, true} {
+				var sig Signal
+				var optedOut bool
+				(&Options{HonorDNT: honorDNT}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					sig, optedOut = FromContext(r.Context()), OptedOut(r)
+				})).ServeHTTP(httptest.NewRecorder(), r)
+				want := tt.optOut
+				if honorDNT {
+					want = tt.optOutDNT
+				}
+				if sig != tt.signal || optedOut != want {
+					t.Errorf("HonorDNT %v: %+v, OptedOut %v; want %+v, %v", honorDNT, sig, optedOut, tt.signal, want)
+				}
+			}
+		})
+	}
+	if got := FromContext(httptest.NewRequest("GET", "/", nil).Context()); got != (Signal{}) {
+		t.Errorf("FromContext without middleware = %+v", got)
+	}
+}
+
+func TestResource(t *testing.T) {
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	opts := &Options{Resource: &Resource{GPC: true, LastUpdate: time.Date(2024, 3, 1, 15, 4, 5, 0, time.UTC)}}
+	h := opts.Middleware(next)
+
+	tests := []struct {
+		method string
+		code   int
+		body   string
+	}{
+		{"GET", 200, `{"gpc":true,"lastUpdate":"2024-03-01"}`},
+		{"HEAD", 200, ""},
+		{"POST", 405, ""},
+		{"DELETE", 405, ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tt.method, WellKnownPath, nil))
+		if rec.Code != tt.code || rec.Body.String() != tt.body {
+			t.Errorf("%s: %d %q, want %d %q", tt.method, rec.Code, rec.Body.String(), tt.code, tt.body)
+		}
+		switch tt.code {
+		case 200:
+			if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Content-Length") != "38" {
+				t.Errorf("%s: fields = %v", tt.method, rec.Header())
+			}
+		case 405:
+			if rec.Header().Get("Allow") != "GET, HEAD" {
+				t.Errorf("%s: Allow = %q", tt.method, rec.Header().Get("Allow"))
+			}
+		}
+	}
+	if called {
+		t.Error("next handler called for the well-known resource")
+	}
+
+	// Other paths, and the well-known path without a Resource, reach next.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/.well-known/other", nil))
+	if !called {
+		t.Error("next handler not called")
+	}
+	called = false
+	Middleware(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", WellKnownPath, nil))
+	if !called {
+		t.Error("next handler not called without a Resource")
+	}
+
+	b, err := json.Marshal(Resource{GPC: false})
+	if err != nil || string(b) != `{"gpc":false}` {
+		t.Errorf("Marshal without date = %s, %v", b, err)
+	}
+	var res Resource
+	if err := json.Unmarshal([]byte(`{"gpc":true,"lastUpdate":"2022-06-30"}`), &res); err != nil ||
+		!res.GPC || !res.LastUpdate.Equal(time.Date(2022, 6, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unmarshal = %+v, %v", res, err)
+	}
+	if err := json.Unmarshal([]byte(`{"gpc":true,"lastUpdate":"30 June"}`), &res); err == nil {
+		t.Error("Unmarshal accepted an invalid date")
+	}
+}
@@ -0,0 +1,239 @@
+package propagation
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ParseTraceparent parses a W3C traceparent field value. Values of future
+// versions are accepted if their prefix follows version 00.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	if len(s) < 55 || !isLowerHex(s[:2]) {
+		return sc, ErrMalformed
+	}
+	switch version := s[:2]; {
+	case version == "ff":
+		return sc, ErrUnsupportedVersion
+	case version == "00" && len(s) != 55,
+		len(s) > 55 && s[55] != '-':
+		return sc, ErrMalformed
+	}
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' ||
+		!isLowerHex(s[3:35]) || !isLowerHex(s[36:52]) || !isLowerHex(s[53:55]) {
+		return sc, ErrMalformed
+	}
+	parseHex(sc.TraceID[:], s[3:35])
+	parseHex(sc.SpanID[:], s[36:52])
+	if !sc.IsValid() {
+		return sc, ErrMalformed
+	}
+	flags, _ := strconv.ParseUint(s[53:55], 16, 8)
+	sc.Sampling = SamplingDeny
+	if flags&1 != 0 {
+		sc.Sampling = SamplingAccept
+	}
+	return sc, nil
+}
+
+// Traceparent formats sc as a version 00 traceparent field value. The
+// sampled flag is set for sampled and debug decisions.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampling.Sampled() {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// parseB3Sampling parses a B3 sampling state.
+func parseB3Sampling(s string) (Sampling, bool) {
+	switch s {
+	case "0":
+		return SamplingDeny, true
+	case "1":
+		return SamplingAccept, true
+	case "d":
+		return SamplingDebug, true
+	}
+	return 0, false
+}
+
+// parseB3TraceID parses a 64- or 128-bit B3 trace id.
+func parseB3TraceID(dst *TraceID, s string) bool {
+	return (len(s) == 16 || len(s) == 32) && parseHex(dst[:], s) && dst.IsValid()
+}
+
+func parseB3SpanID(dst *SpanID, s string) bool {
+	return len(s) == 16 && parseHex(dst[:], s) && dst.IsValid()
+}
+
+// ParseB3 parses a single b3 field value: either a lone sampling state or
+// "{TraceId}-{SpanId}[-{SamplingState}[-{ParentSpanId}]]".
+func ParseB3(s string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(s, "-")
+	if len(parts) == 1 {
+		var ok bool
+		if sc.Sampling, ok = parseB3Sampling(s); !ok {
+			return sc, ErrMalformed
+		}
+		return sc, nil
+	}
+	if len(parts) > 4 || !parseB3TraceID(&sc.TraceID, parts[0]) || !parseB3SpanID(&sc.SpanID, parts[1]) {
+		return sc, ErrMalformed
+	}
+	if len(parts) > 2 {
+		var ok bool
+		if sc.Sampling, ok = parseB3Sampling(parts[2]); !ok {
+			return sc, ErrMalformed
+		}
+	}
+	if len(parts) > 3 && !parseB3SpanID(&sc.ParentID, parts[3]) {
+		return sc, ErrMalformed
+	}
+	return sc, nil
+}
+
+// B3 formats sc as a single b3 field value. 64-bit trace ids are written
+// with 16 digits. A context without ids formats as its sampling state,
+// which is empty when the decision is deferred.
+func (sc SpanContext) B3() string {
+	sampling := ""
+	switch sc.Sampling {
+	case SamplingDeny:
+		sampling = "0"
+	case SamplingAccept:
+		sampling = "1"
+	case SamplingDebug:
+		sampling = "d"
+	}
+	if !sc.IsValid() {
+		return sampling
+	}
+	s := compactTraceID(sc.TraceID) + "-" + sc.SpanID.String()
+	if sampling != "" {
+		s += "-" + sampling
+		if sc.ParentID.IsValid() {
+			s += "-" + sc.ParentID.String()
+		}
+	}
+	return s
+}
+
+// compactTraceID writes 64-bit trace ids with 16 digits.
+func compactTraceID(t TraceID) string {
+	if t.is64() {
+		return t.String()[16:]
+	}
+	return t.String()
+}
+
+// ParseB3Header parses the X-B3-* fields of h. X-B3-Sampled also accepts
+// the legacy values "true" and "false", and X-B3-Flags "1" marks a debug
+// decision.
+func ParseB3Header(h http.Header) (SpanContext, error) {
+	var sc SpanContext
+	if v := h.Get(HeaderB3TraceID); v != "" {
+		if !parseB3TraceID(&sc.TraceID, v) || !parseB3SpanID(&sc.SpanID, h.Get(HeaderB3SpanID)) {
+			return sc, ErrMalformed
+		}
+		if v := h.Get(HeaderB3ParentID); v != "" && !parseB3SpanID(&sc.ParentID, v) {
+			return sc, ErrMalformed
+		}
+	}
+	switch strings.ToLower(h.Get(HeaderB3Sampled)) {
+	case "":
+	case "1", "true":
+		sc.Sampling = SamplingAccept
+	case "0", "false":
+		sc.Sampling = SamplingDeny
+	default:
+		return sc, ErrMalformed
+	}
+	switch h.Get(HeaderB3Flags) {
+	case "", "0":
+	case "1":
+		sc.Sampling = SamplingDebug
+	default:
+		return sc, ErrMalformed
+	}
+	return sc, nil
+}
+
+// SetB3Header replaces the X-B3-* fields of h with sc. A debug decision is
+// sent as X-B3-Flags alone, which implies sampling.
+func SetB3Header(h http.Header, sc SpanContext) {
+	for _, name := range []string{HeaderB3TraceID, HeaderB3SpanID, HeaderB3ParentID, HeaderB3Sampled, HeaderB3Flags} {
+		h.Del(name)
+	}
+	if sc.IsValid() {
+		h.Set(HeaderB3TraceID, compactTraceID(sc.TraceID))
+		h.Set(HeaderB3SpanID, sc.SpanID.String())
+		if sc.ParentID.IsValid() {
+			h.Set(HeaderB3ParentID, sc.ParentID.String())
+		}
+	}
+	switch sc.Sampling {
+	case SamplingDeny:
+		h.Set(HeaderB3Sampled, "0")
+	case SamplingAccept:
+		h.Set(HeaderB3Sampled, "1")
+	case SamplingDebug:
+		h.Set(HeaderB3Flags, "1")
+	}
+}
+
+// Jaeger flag bits.
+const (
+	jaegerSampled = 0x01
+	jaegerDebug   = 0x02
+)
+
+// ParseUberTraceID parses a Jaeger uber-trace-id field value,
+// "{trace-id}:{span-id}:{parent-span-id}:{flags}". Ids may omit leading
+// zeroes, a parent id of "0" means none, and URL-encoded colons are
+// accepted as some clients send them.
+func ParseUberTraceID(s string) (SpanContext, error) {
+	var sc SpanContext
+	if strings.Contains(s, "%") {
+		s = strings.Replace(strings.Replace(s, "%3A", ":", -1), "%3a", ":", -1)
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 ||
+		!parseHex(sc.TraceID[:], parts[0]) || !parseHex(sc.SpanID[:], parts[1]) ||
+		!parseHex(sc.ParentID[:], parts[2]) || len(parts[3]) > 2 || !sc.IsValid() {
+		return SpanContext{}, ErrMalformed
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, ErrMalformed
+	}
+	switch {
+	case flags&jaegerDebug != 0:
+		sc.Sampling = SamplingDebug
+	case flags&jaegerSampled != 0:
+		sc.Sampling = SamplingAccept
+	default:
+		sc.Sampling = SamplingDeny
+	}
+	return sc, nil
+}
+
+// UberTraceID formats sc as a Jaeger uber-trace-id field value. A deferred
+// decision is sent as not sampled, since Jaeger has no deferred state.
+func (sc SpanContext) UberTraceID() string {
+	flags := 0
+	switch sc.Sampling {
+	case SamplingAccept:
+		flags = jaegerSampled
+	case SamplingDebug:
+		flags = jaegerSampled | jaegerDebug
+	}
+	parent := "0"
+	if sc.ParentID.IsValid() {
+		parent = sc.ParentID.String()
+	}
+	return compactTraceID(sc.TraceID) + ":" + sc.SpanID.String() + ":" + parent + ":" + strconv.Itoa(flags)
+}
@@ -0,0 +1,184 @@
+// Package propagation parses and formats the trace context propagation
+// fields of W3C Trace Context (traceparent), Zipkin B3 (the single b3 field
+// and the X-B3-* fields) and Jaeger (uber-trace-id), converting between them
+// through a common SpanContext with the sampling decision preserved.
+package propagation
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrMalformed is returned for field values that do not follow their
+	// format.
+	ErrMalformed = errors.New("propagation: malformed field value")
+	// ErrUnsupportedVersion is returned for traceparent version ff.
+	ErrUnsupportedVersion = errors.New("propagation: unsupported traceparent version")
+	// ErrNoContext is returned by Extract when a header carries none of the
+	// supported fields.
+	ErrNoContext = errors.New("propagation: no trace context")
+)
+
+// TraceID identifies a trace. 64-bit trace ids occupy the low eight bytes.
+type TraceID [16]byte
+
+// IsValid reports whether t is not all zeroes.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String returns t as 32 lower-case hex digits.
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// is64 reports whether t fits in 64 bits.
+func (t TraceID) is64() bool {
+	for _, b := range t[:8] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SpanID identifies a span.
+type SpanID [8]byte
+
+// IsValid reports whether s is not all zeroes.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// String returns s as 16 lower-case hex digits.
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// Sampling is a sampling decision.
+type Sampling int
+
+// Sampling decisions. Formats without a deferred or debug state map them to
+// not sampled and sampled respectively.
+const (
+	SamplingDeferred Sampling = iota // decision left to the receiver
+	SamplingDeny                     // not sampled
+	SamplingAccept                   // sampled
+	SamplingDebug                    // sampled and forced through
+)
+
+// Sampled reports whether s records the trace.
+func (s Sampling) Sampled() bool {
+	return s == SamplingAccept || s == SamplingDebug
+}
+
+// SpanContext is the propagated identity of a span.
+type SpanContext struct {
+	TraceID  TraceID
+	SpanID   SpanID // the sender's span, the parent of the receiver's span
+	ParentID SpanID // parent of SpanID, carried by B3 and Jaeger only
+	Sampling Sampling
+}
+
+// IsValid reports whether sc has a trace id and span id. A B3 field may
+// carry only a sampling decision, which yields an invalid context.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Format is a propagation format.
+type Format int
+
+// Propagation formats in the order Extract tries them.
+const (
+	Traceparent Format = iota
+	B3Single
+	B3Multi
+	Jaeger
+)
+
+// Field names of the propagation formats.
+const (
+	HeaderTraceparent = "Traceparent"
+	HeaderB3          = "B3"
+	HeaderB3TraceID   = "X-B3-Traceid"
+	HeaderB3SpanID    = "X-B3-Spanid"
+	HeaderB3ParentID  = "X-B3-Parentspanid"
+	HeaderB3Sampled   = "X-B3-Sampled"
+	HeaderB3Flags     = "X-B3-Flags"
+	HeaderUberTraceID = "Uber-Trace-Id"
+)
+
+// Extract returns the span context carried by h and its format. Formats
+// are tried in the order Traceparent, B3Single, B3Multi, Jaeger; the first
+// present field decides, and a malformed value is an error rather than a
+// reason to fall back.
+func Extract(h http.Header) (SpanContext, Format, error) {
+	if v := h.Get(HeaderTraceparent); v != "" {
+		sc, err := ParseTraceparent(v)
+		return sc, Traceparent, err
+	}
+	if v := h.Get(HeaderB3); v != "" {
+		sc, err := ParseB3(v)
+		return sc, B3Single, err
+	}
+	if h.Get(HeaderB3TraceID) != "" || h.Get(HeaderB3Sampled) != "" || h.Get(HeaderB3Flags) != "" {
+		sc, err := ParseB3Header(h)
+		return sc, B3Multi, err
+	}
+	if v := h.Get(HeaderUberTraceID); v != "" {
+		sc, err := ParseUberTraceID(v)
+		return sc, Jaeger, err
+	}
+	return SpanContext{}, 0, ErrNoContext
+}
+
+// Inject sets the fields of the given formats in h, replacing existing
+// values. Fields of a format that cannot express sc, such as traceparent
+// for a sampling-only context, are removed instead.
+func Inject(h http.Header, sc SpanContext, formats ...Format) {
+	for _, f := range formats {
+		switch f {
+		case Traceparent:
+			h.Del(HeaderTraceparent)
+			if sc.IsValid() {
+				h.Set(HeaderTraceparent, sc.Traceparent())
+			}
+		case B3Single:
+			h.Del(HeaderB3)
+			if v := sc.B3(); v != "" {
+				h.Set(HeaderB3, v)
+			}
+		case B3Multi:
+			SetB3Header(h, sc)
+		case Jaeger:
+			h.Del(HeaderUberTraceID)
+			if sc.IsValid() {
+				h.Set(HeaderUberTraceID, sc.UberTraceID())
+			}
+		}
+	}
+}
+
+// parseHex decodes lower- or upper-case hex digits into the end of dst,
+// which is left-padded with zeroes. s must not be longer than 2*len(dst).
+func parseHex(dst []byte, s string) bool {
+	if s == "" || len(s) > 2*len(dst) {
+		return false
+	}
+	if len(s)%2 == 1 {
+		s = "0" + s
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return false
+	}
+	for i := range dst {
+		dst[i] = 0
+	}
+	copy(dst[len(dst)-len(b):], b)
+	return true
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
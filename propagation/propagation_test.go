@@ -0,0 +1,348 @@
+package propagation
+
+import (
+	"net/http"
+	"testing"
+)
+
+func mustB3(t *testing.T, s string) SpanContext {
+	t.Helper()
+	sc, err := ParseB3(s)
+	if err != nil {
+		t.Fatalf("ParseB3(%q): %v", s, err)
+	}
+	return sc
+}
+
+func TestTraceparent(t *testing.T) {
+	const v = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || sc.Sampling != SamplingAccept {
+		t.Errorf("ParseTraceparent = %+v", sc)
+	}
+	if got := sc.Traceparent(); got != v {
+		t.Errorf("Traceparent = %q, want %q", got, v)
+	}
+
+	tests := []struct {
+		in   string
+		want error
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", nil},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03", nil},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", nil},
+		{"cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", nil},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ErrUnsupportedVersion},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ErrMalformed},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01x", ErrMalformed},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", ErrMalformed},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ErrMalformed},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", ErrMalformed},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0g", ErrMalformed},
+		{"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01", ErrMalformed},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", ErrMalformed},
+		{"", ErrMalformed},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTraceparent(tt.in); err != tt.want {
+			t.Errorf("ParseTraceparent(%q) = %v, want %v", tt.in, err, tt.want)
+		}
+	}
+}
+
+func TestB3(t *testing.T) {
+	tests := []struct {
+		in       string
+		sampling Sampling
+		out      string // "" means in
+	}{
+		{"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90", SamplingAccept, ""},
+		{"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-d", SamplingDebug, ""},
+		{"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0", SamplingDeny, ""},
+		{"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1", SamplingDeferred, ""},
+		{"a3ce929d0e0e4736-00f067aa0ba902b7-1", SamplingAccept, ""},
+		{"0000000000000000a3ce929d0e0e4736-00f067aa0ba902b7-1", SamplingAccept, "a3ce929d0e0e4736-00f067aa0ba902b7-1"},
+		{"A3CE929D0E0E4736-00F067AA0BA902B7-1", SamplingAccept, "a3ce929d0e0e4736-00f067aa0ba902b7-1"},
+		{"0", SamplingDeny, ""},
+		{"1", SamplingAccept, ""},
+		{"d", SamplingDebug, ""},
+	}
+	for _, tt := range tests {
+		sc := mustB3(t, tt.in)
+		if sc.Sampling != tt.sampling {
+			t.Errorf("ParseB3(%q) sampling = %v, want %v", tt.in, sc.Sampling, tt.sampling)
+		}
+		want := tt.out
+		if want == "" {
+			want = tt.in
+		}
+		if got := sc.B3(); got != want {
+			t.Errorf("B3(ParseB3(%q)) = %q, want %q", tt.in, got, want)
+		}
+	}
+
+	for _, s := range []string{
+		"", "true", "D",
+		"a3ce929d0e0e473-00f067aa0ba902b7",
+		"a3ce929d0e0e4736a-00f067aa0ba902b7",
+		"0000000000000000-00f067aa0ba902b7",
+		"a3ce929d0e0e4736-0000000000000000",
+		"a3ce929d0e0e4736-00f067aa0ba902b7-2",
+		"a3ce929d0e0e4736-00f067aa0ba902b7-1-00",
+		"a3ce929d0e0e4736-00f067aa0ba902b7-1-05e3ac9a4f6e3b90-x",
+		"a3ce929d0e0e4736-00f067aa0ba902bz",
+	} {
+		if sc, err := ParseB3(s); err != ErrMalformed {
+			t.Errorf("ParseB3(%q) = %+v, %v; want %v", s, sc, err, ErrMalformed)
+		}
+	}
+}
+
+func TestB3Header(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   string // single b3 form of the result
+	}{
+		{"sampled", http.Header{
+			HeaderB3TraceID:  {"80f198ee56343ba864fe8b2a57d3eff7"},
+			HeaderB3SpanID:   {"e457b5a2e4d86bd1"},
+			HeaderB3ParentID: {"05e3ac9a4f6e3b90"},
+			HeaderB3Sampled:  {"1"},
+		}, "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90"},
+		{"legacy true", http.Header{
+			HeaderB3TraceID: {"a3ce929d0e0e4736"},
+			HeaderB3SpanID:  {"00f067aa0ba902b7"},
+			HeaderB3Sampled: {"true"},
+		}, "a3ce929d0e0e4736-00f067aa0ba902b7-1"},
+		{"legacy false", http.Header{
+			HeaderB3TraceID: {"a3ce929d0e0e4736"},
+			HeaderB3SpanID:  {"00f067aa0ba902b7"},
+			HeaderB3Sampled: {"False"},
+		}, "a3ce929d0e0e4736-00f067aa0ba902b7-0"},
+		{"debug flag wins", http.Header{
+			HeaderB3TraceID: {"a3ce929d0e0e4736"},
+			HeaderB3SpanID:  {"00f067aa0ba902b7"},
+			HeaderB3Sampled: {"0"},
+			HeaderB3Flags:   {"1"},
+		}, "a3ce929d0e0e4736-00f067aa0ba902b7-d"},
+		{"deferred", http.Header{
+			HeaderB3TraceID: {"a3ce929d0e0e4736"},
+			HeaderB3SpanID:  {"00f067aa0ba902b7"},
+		}, "a3ce929d0e0e4736-00f067aa0ba902b7"},
+		{"sampling only", http.Header{HeaderB3Sampled: {"0"}}, "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := ParseB3Header(tt.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sc.B3(); got != tt.want {
+				t.Errorf("B3 = %q, want %q", got, tt.want)
+			}
+			h := http.Header{HeaderB3Flags: {"stale"}, HeaderB3ParentID: {"stale"}}
+			SetB3Header(h, sc)
+			if back, err := ParseB3Header(h); err != nil || back != sc {
+				t.Errorf("round trip = %+v, %v; want %+v", back, err, sc)
+			}
+		})
+	}
+
+	h := make(http.Header)
+	SetB3Header(h, mustB3(t, "a3ce929d0e0e4736-00f067aa0ba902b7-d"))
+	if h.Get(HeaderB3Flags) != "1" || h.Get(HeaderB3Sampled) != "" || h.Get(HeaderB3TraceID) != "a3ce929d0e0e4736" {
+		t.Errorf("debug context sent as %v", h)
+	}
+
+	for _, h := range []http.Header{
+		{HeaderB3TraceID: {"a3ce929d0e0e4736"}},
+		{HeaderB3TraceID: {"a3ce929d0e0e4736"}, HeaderB3SpanID: {"00f067aa0ba902b7"}, HeaderB3ParentID: {"x"}},
+		{HeaderB3Sampled: {"yes"}},
+		{HeaderB3Flags: {"2"}},
+	} {
+		if _, err := ParseB3Header(h); err != ErrMalformed {
+			t.Errorf("ParseB3Header(%v) = %v", h, err)
+		}
+	}
+}
+
+func TestUberTraceID(t *testing.T) {
+	tests := []struct {
+		in       string
+		sampling Sampling
+		out      string
+	}{
+		{"a3ce929d0e0e4736:00f067aa0ba902b7:0:1", SamplingAccept, "a3ce929d0e0e4736:00f067aa0ba902b7:0:1"},
+		{"a3ce929d0e0e4736:00f067aa0ba902b7:0:0", SamplingDeny, "a3ce929d0e0e4736:00f067aa0ba902b7:0:0"},
+		{"a3ce929d0e0e4736:00f067aa0ba902b7:0:3", SamplingDebug, "a3ce929d0e0e4736:00f067aa0ba902b7:0:3"},
+		{"a3ce929d0e0e4736:00f067aa0ba902b7:0:2", SamplingDebug, "a3ce929d0e0e4736:00f067aa0ba902b7:0:3"},
+		{"80f198ee56343ba864fe8b2a57d3eff7:e457b5a2e4d86bd1:05e3ac9a4f6e3b90:1", SamplingAccept, "80f198ee56343ba864fe8b2a57d3eff7:e457b5a2e4d86bd1:05e3ac9a4f6e3b90:1"},
+		// Leading zeroes may be omitted, and colons may be URL-encoded.
+		{"abc:f067aa0ba902b7:0:1", SamplingAccept, "0000000000000abc:00f067aa0ba902b7:0:1"},
+		{"a3ce929d0e0e4736%3A00f067aa0ba902b7%3a0%3A1", SamplingAccept, "a3ce929d0e0e4736:00f067aa0ba902b7:0:1"},
+	}
+	for _, tt := range tests {
+		sc, err := ParseUberTraceID(tt.in)
+		if err != nil {
+			t.Errorf("ParseUberTraceID(%q): %v", tt.in, err)
+			continue
+		}
+		if sc.Sampling != tt.sampling {
+			t.Errorf("ParseUberTraceID(%q) sampling = %v, want %v", tt.in, sc.Sampling, tt.sampling)
+		}
+		if got := sc.UberTraceID(); got != tt.out {
+			t.Errorf("UberTraceID(ParseUberTraceID(%q)) = %q, want %q", tt.in, got, tt.out)
+		}
+	}
+
+	for _, s := range []string{
+		"", "a3ce929d0e0e4736:00f067aa0ba902b7:0",
+		"a3ce929d0e0e4736:00f067aa0ba902b7:0:1:0",
+		"0:00f067aa0ba902b7:0:1",
+		"a3ce929d0e0e4736:0:0:1",
+		"a3ce929d0e0e4736:00f067aa0ba902b7:0:100",
+		"a3ce929d0e0e4736:00f067aa0ba902b7:0:",
+		"a3ce929d0e0e4736:00f067aa0ba902b7:0:x",
+		"a3ce929d0e0e4736:00f067aa0ba902b700:0:1",
+	} {
+		if _, err := ParseUberTraceID(s); err != ErrMalformed {
+			t.Errorf("ParseUberTraceID(%q) = %v, want %v", s, err, ErrMalformed)
+		}
+	}
+}
+
+func TestConversions(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        http.Header
+		traceparent string
+		b3          string
+		jaeger      string
+	}{
+		{
+			name:        "traceparent sampled",
+			from:        http.Header{HeaderTraceparent: {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			b3:          "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+			jaeger:      "4bf92f3577b34da6a3ce929d0e0e4736:00f067aa0ba902b7:0:1",
+		},
+		{
+			name:        "traceparent not sampled",
+			from:        http.Header{HeaderTraceparent: {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}},
+			traceparent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			b3:          "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0",
+			jaeger:      "4bf92f3577b34da6a3ce929d0e0e4736:00f067aa0ba902b7:0:0",
+		},
+		{
+			// The debug decision survives in B3 and Jaeger; traceparent
+			// can only say sampled.
+			name:        "b3 debug with parent",
+			from:        http.Header{HeaderB3: {"80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-d-05e3ac9a4f6e3b90"}},
+			traceparent: "00-80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-01",
+			b3:          "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-d-05e3ac9a4f6e3b90",
+			jaeger:      "80f198ee56343ba864fe8b2a57d3eff7:e457b5a2e4d86bd1:05e3ac9a4f6e3b90:3",
+		},
+		{
+			// 64-bit trace ids are zero-extended for traceparent and kept
+			// at 16 digits elsewhere.
+			name:        "b3 64-bit trace id",
+			from:        http.Header{HeaderB3: {"a3ce929d0e0e4736-00f067aa0ba902b7-1"}},
+			traceparent: "00-0000000000000000a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			b3:          "a3ce929d0e0e4736-00f067aa0ba902b7-1",
+			jaeger:      "a3ce929d0e0e4736:00f067aa0ba902b7:0:1",
+		},
+		{
+			name:        "b3 multi debug",
+			from:        http.Header{HeaderB3TraceID: {"a3ce929d0e0e4736"}, HeaderB3SpanID: {"00f067aa0ba902b7"}, HeaderB3Flags: {"1"}},
+			traceparent: "00-0000000000000000a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			b3:          "a3ce929d0e0e4736-00f067aa0ba902b7-d",
+			jaeger:      "a3ce929d0e0e4736:00f067aa0ba902b7:0:3",
+		},
+		{
+			// Jaeger has no deferred state and traceparent none either.
+			name:        "b3 deferred",
+			from:        http.Header{HeaderB3: {"a3ce929d0e0e4736-00f067aa0ba902b7"}},
+			traceparent: "00-0000000000000000a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			b3:          "a3ce929d0e0e4736-00f067aa0ba902b7",
+			jaeger:      "a3ce929d0e0e4736:00f067aa0ba902b7:0:0",
+		},
+		{
+			name:        "jaeger debug",
+			from:        http.Header{HeaderUberTraceID: {"a3ce929d0e0e4736:00f067aa0ba902b7:5e3ac9a4f6e3b90:3"}},
+			traceparent: "00-0000000000000000a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			b3:          "a3ce929d0e0e4736-00f067aa0ba902b7-d-05e3ac9a4f6e3b90",
+			jaeger:      "a3ce929d0e0e4736:00f067aa0ba902b7:05e3ac9a4f6e3b90:3",
+		},
+		{
+			// A sampling-only B3 context cannot be sent as traceparent or
+			// uber-trace-id.
+			name: "b3 sampling only",
+			from: http.Header{HeaderB3: {"0"}},
+			b3:   "0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, _, err := Extract(tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := http.Header{
+				HeaderTraceparent: {"stale"},
+				HeaderB3:          {"stale"},
+				HeaderUberTraceID: {"stale"},
+			}
+			Inject(h, sc, Traceparent, B3Single, B3Multi, Jaeger)
+			if got := h.Get(HeaderTraceparent); got != tt.traceparent {
+				t.Errorf("traceparent = %q, want %q", got, tt.traceparent)
+			}
+			if got := h.Get(HeaderB3); got != tt.b3 {
+				t.Errorf("b3 = %q, want %q", got, tt.b3)
+			}
+			if got := h.Get(HeaderUberTraceID); got != tt.jaeger {
+				t.Errorf("uber-trace-id = %q, want %q", got, tt.jaeger)
+			}
+			multi, err := ParseB3Header(h)
+			if err != nil || multi.B3() != tt.b3 {
+				t.Errorf("X-B3-* = %q, %v; want %q", multi.B3(), err, tt.b3)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		format Format
+		err    error
+	}{
+		{"traceparent first", http.Header{
+			HeaderTraceparent: {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+			HeaderB3:          {"a3ce929d0e0e4736-00f067aa0ba902b7-1"},
+		}, Traceparent, nil},
+		{"b3 before jaeger", http.Header{
+			HeaderB3:          {"1"},
+			HeaderUberTraceID: {"a3ce929d0e0e4736:00f067aa0ba902b7:0:1"},
+		}, B3Single, nil},
+		{"b3 multi sampled only", http.Header{HeaderB3Sampled: {"1"}}, B3Multi, nil},
+		{"jaeger", http.Header{HeaderUberTraceID: {"a3ce929d0e0e4736:00f067aa0ba902b7:0:1"}}, Jaeger, nil},
+		{"malformed does not fall back", http.Header{
+			HeaderTraceparent: {"garbage"},
+			HeaderB3:          {"a3ce929d0e0e4736-00f067aa0ba902b7-1"},
+		}, Traceparent, ErrMalformed},
+		{"none", http.Header{"Other": {"x"}}, 0, ErrNoContext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, f, err := Extract(tt.header)
+			if f != tt.format || err != tt.err {
+				t.Errorf("Extract = %v, %v; want %v, %v", f, err, tt.format, tt.err)
+			}
+		})
+	}
+}
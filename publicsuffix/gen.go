@@ -0,0 +1,117 @@
+//go:build ignore
+// +build ignore
+
+// gen.go regenerates table.go from the Public Suffix List.
+//
+//	go run gen.go [-url URL | -file public_suffix_list.dat]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/palsivertsen/gohttpfields/internal/punycode"
+)
+
+var (
+	listURL  = flag.String("url", "https://publicsuffix.org/list/public_suffix_list.dat", "URL of the list")
+	listFile = flag.String("file", "", "read the list from a local file instead of -url")
+	output   = flag.String("o", "table.go", "output file")
+)
+
+func main() {
+	flag.Parse()
+	var r io.Reader
+	if *listFile != "" {
+		f, err := os.Open(*listFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	} else {
+		resp, err := http.Get(*listURL)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Fatalf("GET %s: %s", *listURL, resp.Status)
+		}
+		r = resp.Body
+	}
+
+	var (
+		version       string
+		icann, privat []string
+		section       *[]string
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "// VERSION: "):
+			version = strings.TrimPrefix(line, "// VERSION: ")
+		case strings.Contains(line, "===BEGIN ICANN DOMAINS==="):
+			section = &icann
+		case strings.Contains(line, "===BEGIN PRIVATE DOMAINS==="):
+			section = &privat
+		case strings.Contains(line, "===END"):
+			section = nil
+		case line == "", strings.HasPrefix(line, "//"), section == nil:
+		default:
+			rule, err := toASCII(strings.Fields(line)[0])
+			if err != nil {
+				log.Fatalf("rule %q: %v", line, err)
+			}
+			*section = append(*section, rule)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if len(icann) == 0 || len(privat) == 0 {
+		log.Fatal("list has no ICANN or private section")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gen.go; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package publicsuffix\n\n")
+	fmt.Fprintf(&buf, "// Version identifies the vendored copy of the list.\n")
+	fmt.Fprintf(&buf, "const Version = %q\n\n", version)
+	fmt.Fprintf(&buf, "const icannRules = `\n%s\n`\n\n", strings.Join(icann, "\n"))
+	fmt.Fprintf(&buf, "const privateRules = `\n%s\n`\n", strings.Join(privat, "\n"))
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// toASCII converts a rule to its A-label form, keeping the "*." and "!"
+// markers.
+func toASCII(rule string) (string, error) {
+	prefix := ""
+	switch {
+	case strings.HasPrefix(rule, "*."):
+		prefix, rule = "*.", rule[2:]
+	case strings.HasPrefix(rule, "!"):
+		prefix, rule = "!", rule[1:]
+	}
+	ascii, err := punycode.ToASCII(rule)
+	if err != nil {
+		return "", err
+	}
+	return prefix + ascii, nil
+}
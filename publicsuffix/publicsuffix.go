@@ -0,0 +1,128 @@
+// Package publicsuffix answers public suffix queries from a vendored copy of
+// the Public Suffix List (https://publicsuffix.org/).
+//
+// The list is distributed under the Mozilla Public License 2.0. Run
+// "go generate" in this directory to refresh it.
+package publicsuffix
+
+//go:generate go run gen.go
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/palsivertsen/gohttpfields/internal/punycode"
+)
+
+// rule kinds
+const (
+	ruleNormal = 1 << iota
+	ruleWildcard
+	ruleException
+	ruleICANN
+)
+
+var (
+	loadOnce sync.Once
+	rules    map[string]uint8
+)
+
+func load() {
+	rules = make(map[string]uint8, 10000)
+	add := func(list string, flags uint8) {
+		for _, line := range strings.Split(list, "\n") {
+			switch {
+			case line == "":
+			case strings.HasPrefix(line, "*."):
+				rules[line[2:]] |= ruleWildcard | flags
+			case strings.HasPrefix(line, "!"):
+				rules[line[1:]] |= ruleException | flags
+			default:
+				rules[line] |= ruleNormal | flags
+			}
+		}
+	}
+	add(icannRules, ruleICANN)
+	add(privateRules, 0)
+}
+
+// PublicSuffix returns the public suffix of domain and whether it is managed
+// by ICANN as opposed to being a privately registered suffix such as
+// "github.io". Domains are matched case-insensitively; internationalized
+// names are converted to A-labels first. Domains with no matching rule fall
+// back to the implicit "*" rule and report their last label.
+func PublicSuffix(domain string) (suffix string, icann bool) {
+	loadOnce.Do(load)
+	domain = normalize(domain)
+	labels := strings.Split(domain, ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		kind := rules[candidate]
+		if kind&ruleException != 0 {
+			return strings.Join(labels[i+1:], "."), kind&ruleICANN != 0
+		}
+		if kind&ruleNormal != 0 {
+			return candidate, kind&ruleICANN != 0
+		}
+		if i+1 < len(labels) {
+			parent := rules[strings.Join(labels[i+1:], ".")]
+			if parent&ruleWildcard != 0 {
+				return candidate, parent&ruleICANN != 0
+			}
+		}
+	}
+	return labels[len(labels)-1], false
+}
+
+// ErrIsPublicSuffix is returned by EffectiveTLDPlusOne for domains that are
+// public suffixes themselves.
+var ErrIsPublicSuffix = errors.New("publicsuffix: domain is a public suffix")
+
+// EffectiveTLDPlusOne returns the public suffix of domain plus one more label,
+// also known as the registrable domain.
+func EffectiveTLDPlusOne(domain string) (string, error) {
+	domain = normalize(domain)
+	if domain == "" || strings.HasPrefix(domain, ".") || strings.Contains(domain, "..") {
+		return "", errors.New("publicsuffix: empty label in domain " + domain)
+	}
+	suffix, _ := PublicSuffix(domain)
+	if len(domain) <= len(suffix) {
+		return "", ErrIsPublicSuffix
+	}
+	i := len(domain) - len(suffix) - 1
+	if domain[i] != '.' {
+		return "", errors.New("publicsuffix: invalid domain " + domain)
+	}
+	return domain[1+strings.LastIndex(domain[:i], "."):], nil
+}
+
+// IsPublicSuffix reports whether domain is itself a public suffix.
+func IsPublicSuffix(domain string) bool {
+	domain = normalize(domain)
+	suffix, _ := PublicSuffix(domain)
+	return suffix == domain
+}
+
+func normalize(domain string) string {
+	domain = strings.TrimSuffix(domain, ".")
+	ascii, err := punycode.ToASCII(domain)
+	if err != nil {
+		return strings.ToLower(domain)
+	}
+	return ascii
+}
+
+// List implements the PublicSuffixList interface of net/http/cookiejar.
+var List list
+
+type list struct{}
+
+func (list) PublicSuffix(domain string) string {
+	suffix, _ := PublicSuffix(domain)
+	return suffix
+}
+
+func (list) String() string {
+	return Version
+}
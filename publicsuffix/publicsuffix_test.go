@@ -0,0 +1,92 @@
+package publicsuffix
+
+import "testing"
+
+func TestPublicSuffix(t *testing.T) {
+	tests := []struct {
+		domain string
+		suffix string
+		icann  bool
+	}{
+		{"com", "com", true},
+		{"www.example.com", "com", true},
+		{"WWW.Example.COM.", "com", true},
+		{"a.b.example.co.uk", "co.uk", true},
+		{"foo.github.io", "github.io", false},
+		{"github.io", "github.io", false},
+		// Wildcard and exception rules: *.ck and !www.ck.
+		{"a.b.ck", "b.ck", true},
+		{"www.ck", "ck", true},
+		{"a.www.ck", "ck", true},
+		// *.kawasaki.jp and !city.kawasaki.jp.
+		{"x.foo.kawasaki.jp", "foo.kawasaki.jp", true},
+		{"x.city.kawasaki.jp", "kawasaki.jp", true},
+		{"www.公司.cn", "xn--55qx5d.cn", true},
+		{"www.xn--55qx5d.cn", "xn--55qx5d.cn", true},
+		// The implicit "*" rule.
+		{"example.unknowntld", "unknowntld", false},
+	}
+	for _, tt := range tests {
+		suffix, icann := PublicSuffix(tt.domain)
+		if suffix != tt.suffix || icann != tt.icann {
+			t.Errorf("PublicSuffix(%q) = %q, %v; want %q, %v", tt.domain, suffix, icann, tt.suffix, tt.icann)
+		}
+	}
+}
+
+func TestEffectiveTLDPlusOne(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+		err    error
+	}{
+		{"example.com", "example.com", nil},
+		{"a.b.example.co.uk", "example.co.uk", nil},
+		{"Example.COM.", "example.com", nil},
+		{"alice.github.io", "alice.github.io", nil},
+		{"www.ck", "www.ck", nil},
+		{"a.b.c.ck", "b.c.ck", nil},
+		{"bücher.de", "xn--bcher-kva.de", nil},
+		{"com", "", ErrIsPublicSuffix},
+		{"co.uk", "", ErrIsPublicSuffix},
+		{"github.io", "", ErrIsPublicSuffix},
+		{"b.ck", "", ErrIsPublicSuffix},
+	}
+	for _, tt := range tests {
+		got, err := EffectiveTLDPlusOne(tt.domain)
+		if got != tt.want || err != tt.err {
+			t.Errorf("EffectiveTLDPlusOne(%q) = %q, %v; want %q, %v", tt.domain, got, err, tt.want, tt.err)
+		}
+	}
+	for _, domain := range []string{"", ".example.com", "a..example.com"} {
+		if got, err := EffectiveTLDPlusOne(domain); err == nil {
+			t.Errorf("EffectiveTLDPlusOne(%q) = %q, want error", domain, got)
+		}
+	}
+}
+
+func TestIsPublicSuffix(t *testing.T) {
+	for domain, want := range map[string]bool{
+		"com":           true,
+		"co.uk":         true,
+		"github.io":     true,
+		"appspot.com":   true,
+		"example.com":   false,
+		"www.ck":        false,
+		"xn--55qx5d.cn": true,
+		"公司.cn":         true,
+	} {
+		if got := IsPublicSuffix(domain); got != want {
+			t.Errorf("IsPublicSuffix(%q) = %v", domain, got)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	if got := List.PublicSuffix("www.example.co.uk"); got != "co.uk" {
+		t.Errorf("List.PublicSuffix = %q", got)
+	}
+	if List.String() != Version {
+		t.Errorf("List.String() = %q", List.String())
+	}
+}
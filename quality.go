@@ -0,0 +1,80 @@
+package httpfields
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QualityValue is a member of a list with quality values, such as
+// Accept-Encoding or Accept-Language (RFC 9110 section 12.4.2).
+type QualityValue struct {
+	Value  string  // member without parameters, lower-cased
+	Q      float64 // weight between 0 and 1, defaulting to 1
+	Params map[string]string
+}
+
+// ParseQualityList parses a comma separated list with optional weights.
+// Members with malformed parameters or weights are skipped. The result is
+// ordered by descending weight, keeping the field order for equal weights.
+func ParseQualityList(s string) []QualityValue {
+	var out []QualityValue
+	for _, member := range SplitList(s) {
+		value, rest := member, ""
+		if i := strings.IndexByte(member, ';'); i >= 0 {
+			value, rest = member[:i], member[i:]
+		}
+		params, err := parseParams(rest)
+		if err != nil {
+			continue
+		}
+		qv := QualityValue{Value: strings.ToLower(TrimOWS(value)), Q: 1, Params: params}
+		if q, ok := params["q"]; ok {
+			f, ok := parseQValue(q)
+			if !ok {
+				continue
+			}
+			qv.Q = f
+			delete(params, "q")
+		}
+		out = append(out, qv)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Q > out[j].Q })
+	return out
+}
+
+// parseQValue parses a weight following the qvalue grammar of RFC 9110
+// section 12.4.2: "0" or "1" with at most three decimals, none above 1.
+func parseQValue(s string) (float64, bool) {
+	if s == "" || len(s) > 5 || s[0] != '0' && s[0] != '1' {
+		return 0, false
+	}
+	if len(s) > 1 {
+		if s[1] != '.' {
+			return 0, false
+		}
+		for i := 2; i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' || s[0] == '1' && s[i] != '0' {
+				return 0, false
+			}
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+// Acceptable returns the weight a parsed list assigns to value, matching
+// case-insensitively and falling back to a "*" member. Values not mentioned
+// have weight 0.
+func Acceptable(list []QualityValue, value string) float64 {
+	wildcard := 0.0
+	for _, qv := range list {
+		if strings.EqualFold(qv.Value, value) {
+			return qv.Q
+		}
+		if qv.Value == "*" {
+			wildcard = qv.Q
+		}
+	}
+	return wildcard
+}
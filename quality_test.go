@@ -0,0 +1,110 @@
+package httpfields
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"1", 1, true},
+		{"0.", 0, true},
+		{"1.", 1, true},
+		{"0.5", 0.5, true},
+		{"0.001", 0.001, true},
+		{"0.999", 0.999, true},
+		{"1.000", 1, true},
+		{"0.1234", 0, false},
+		{"1.001", 0, false},
+		{"1.5", 0, false},
+		{"2", 0, false},
+		{".5", 0, false},
+		{"+0.5", 0, false},
+		{"-0", 0, false},
+		{"1e-1", 0, false},
+		{"0x1", 0, false},
+		{"NaN", 0, false},
+		{"Inf", 0, false},
+		{"0.5a", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseQValue(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseQValue(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseQualityList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []QualityValue
+	}{
+		{"gzip", []QualityValue{{Value: "gzip", Q: 1, Params: map[string]string{}}}},
+		{
+			"GZIP;Q=0.5, br",
+			[]QualityValue{{Value: "br", Q: 1, Params: map[string]string{}}, {Value: "gzip", Q: 0.5, Params: map[string]string{}}},
+		},
+		{
+			"text/html;level=1;q=0.7, text/*;q=0.3",
+			[]QualityValue{{Value: "text/html", Q: 0.7, Params: map[string]string{"level": "1"}}, {Value: "text/*", Q: 0.3, Params: map[string]string{}}},
+		},
+		// Members with malformed weights or parameters are skipped.
+		{
+			"a;q=2, b;q=NaN, c;q=0.1234, d;q, e;q=1e-1, f;q=0.25",
+			[]QualityValue{{Value: "f", Q: 0.25, Params: map[string]string{}}},
+		},
+		{"a ; q = 0.5", []QualityValue{{Value: "a", Q: 0.5, Params: map[string]string{}}}},
+		{", ,", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := ParseQualityList(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseQualityList(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQualityListTieBreaking(t *testing.T) {
+	// Equal weights keep the field order; the sort is stable.
+	list := ParseQualityList("c;q=0.5, a, b;q=0.5, d, e;q=0")
+	var got []string
+	for _, qv := range list {
+		got = append(got, qv.Value)
+	}
+	if want := []string{"a", "d", "c", "b", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %q, want %q", got, want)
+	}
+}
+
+func TestAcceptable(t *testing.T) {
+	list := ParseQualityList("gzip;q=0, br;q=0.8, *;q=0.1, DEFLATE")
+	tests := []struct {
+		value string
+		want  float64
+	}{
+		{"br", 0.8},
+		{"BR", 0.8},
+		{"deflate", 1},
+		{"gzip", 0},
+		{"zstd", 0.1},
+	}
+	for _, tt := range tests {
+		if got := Acceptable(list, tt.value); got != tt.want {
+			t.Errorf("Acceptable(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+	if got := Acceptable(ParseQualityList("gzip"), "br"); got != 0 {
+		t.Errorf("unlisted value without wildcard = %v", got)
+	}
+	// A repeated member counts with its highest weight.
+	if got := Acceptable(ParseQualityList("br;q=0.2, br;q=0.9"), "br"); got != 0.9 {
+		t.Errorf("repeated member = %v", got)
+	}
+}
@@ -0,0 +1,346 @@
+package query
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Escaping selects how keys and values are percent-encoded.
+type Escaping int
+
+// Escaping profiles.
+const (
+	// FormEscaping follows the WHATWG application/x-www-form-urlencoded
+	// serializer: ALPHA, DIGIT and "*-._" are kept, a space becomes '+'
+	// and everything else is percent-encoded.
+	FormEscaping Escaping = iota
+
+	// StrictEscaping keeps only the RFC 3986 unreserved characters
+	// (ALPHA, DIGIT and "-._~") and percent-encodes everything else,
+	// including spaces as "%20".
+	StrictEscaping
+
+	// SigV4Escaping produces the canonical query string of AWS Signature
+	// Version 4: RFC 3986 unreserved characters, parameters sorted by key
+	// and then value, and a '=' after every key.
+	SigV4Escaping
+)
+
+// ArrayNotation selects how slice fields of structs are encoded.
+type ArrayNotation int
+
+// Array notations.
+const (
+	ArrayRepeat   ArrayNotation = iota // a=1&a=2
+	ArrayBrackets                      // a[]=1&a[]=2
+	ArrayIndexed                       // a[0]=1&a[1]=2
+	ArrayComma                         // a=1,2
+)
+
+// DefaultTag is the struct tag consulted when Encoder.Tag is empty.
+const DefaultTag = "query"
+
+// Encoder serializes parameters into query strings or form bodies. The zero
+// value uses FormEscaping, keeps parameter order and repeats keys for slices.
+type Encoder struct {
+	Escaping Escaping
+
+	// Sort orders parameters by escaped key and then escaped value. It is
+	// implied by SigV4Escaping.
+	Sort bool
+
+	// Arrays selects the notation for slice and array struct fields.
+	Arrays ArrayNotation
+
+	// Tag is the struct tag holding the parameter name and options. An
+	// empty Tag selects DefaultTag.
+	Tag string
+}
+
+// Encode encodes params with the zero Encoder.
+func Encode(params []Param) string {
+	return Encoder{}.Encode(params)
+}
+
+// Encode encodes the decoded keys and values of params. A parameter without a
+// value is written without '=', except with SigV4Escaping.
+func (e Encoder) Encode(params []Param) string {
+	type pair struct {
+		key, value string
+		hasValue   bool
+	}
+	pairs := make([]pair, len(params))
+	for i, p := range params {
+		pairs[i] = pair{e.escape(p.Key), e.escape(p.Value), p.HasValue || e.Escaping == SigV4Escaping}
+	}
+	if e.Sort || e.Escaping == SigV4Escaping {
+		sort.SliceStable(pairs, func(i, j int) bool {
+			if pairs[i].key != pairs[j].key {
+				return pairs[i].key < pairs[j].key
+			}
+			return pairs[i].value < pairs[j].value
+		})
+	}
+	var b strings.Builder
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(p.key)
+		if p.hasValue {
+			b.WriteByte('=')
+			b.WriteString(p.value)
+		}
+	}
+	return b.String()
+}
+
+// Escape percent-encodes s according to the escaping profile.
+func (e Escaping) Escape(s string) string {
+	return Encoder{Escaping: e}.escape(s)
+}
+
+func (e Encoder) escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_':
+			b.WriteByte(c)
+		case c == '*' && e.Escaping == FormEscaping:
+			b.WriteByte(c)
+		case c == '~' && e.Escaping != FormEscaping:
+			b.WriteByte(c)
+		case c == ' ' && e.Escaping == FormEscaping:
+			b.WriteByte('+')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+var (
+	errNotStruct = errors.New("query: value is not a struct or pointer to struct")
+
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+// EncodeStruct encodes the exported fields of the struct v.
+func (e Encoder) EncodeStruct(v interface{}) (string, error) {
+	params, err := e.StructParams(v)
+	if err != nil {
+		return "", err
+	}
+	return e.Encode(params), nil
+}
+
+// StructParams converts the exported fields of the struct v into parameters,
+// in field order.
+//
+// The parameter name is taken from the struct tag (see Encoder.Tag), falling
+// back to the field name. A name of "-" skips the field, and the "omitempty"
+// option skips zero values. Embedded structs are flattened. Supported field
+// types are strings, booleans, numbers, time.Time (RFC 3339), types
+// implementing encoding.TextMarshaler, pointers to these, and slices or arrays
+// of them.
+func (e Encoder) StructParams(v interface{}) ([]Param, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, errNotStruct
+	}
+	return e.appendStruct(nil, rv)
+}
+
+func (e Encoder) appendStruct(params []Param, rv reflect.Value) ([]Param, error) {
+	tagName := e.Tag
+	if tagName == "" {
+		tagName = DefaultTag
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		fv := rv.Field(i)
+		tag := f.Tag.Get(tagName)
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if j := strings.IndexByte(tag, ','); j >= 0 {
+			name, opts = tag[:j], tag[j+1:]
+		}
+		if f.Anonymous && name == "" && indirectType(f.Type).Kind() == reflect.Struct {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() != reflect.Struct {
+				continue
+			}
+			var err error
+			if params, err = e.appendStruct(params, fv); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if hasOption(opts, "omitempty") && isEmpty(fv) {
+			continue
+		}
+		var err error
+		if params, err = e.appendField(params, name, fv); err != nil {
+			return nil, fmt.Errorf("query: field %s: %v", f.Name, err)
+		}
+	}
+	return params, nil
+}
+
+func (e Encoder) appendField(params []Param, name string, fv reflect.Value) ([]Param, error) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return params, nil
+		}
+		fv = fv.Elem()
+	}
+	if (fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8) || fv.Kind() == reflect.Array {
+		values := make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			s, err := formatValue(fv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, s)
+		}
+		switch e.Arrays {
+		case ArrayComma:
+			if len(values) > 0 {
+				params = append(params, Param{Key: name, Value: strings.Join(values, ","), HasValue: true})
+			}
+		case ArrayBrackets:
+			for _, s := range values {
+				params = append(params, Param{Key: name + "[]", Value: s, HasValue: true})
+			}
+		case ArrayIndexed:
+			for i, s := range values {
+				params = append(params, Param{Key: name + "[" + strconv.Itoa(i) + "]", Value: s, HasValue: true})
+			}
+		default:
+			for _, s := range values {
+				params = append(params, Param{Key: name, Value: s, HasValue: true})
+			}
+		}
+		return params, nil
+	}
+	s, err := formatValue(fv)
+	if err != nil {
+		return nil, err
+	}
+	return append(params, Param{Key: name, Value: s, HasValue: true}), nil
+}
+
+func formatValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+	if v.Type().Implements(textMarshalerType) {
+		b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(textMarshalerType) {
+		b, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+		return string(b), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func hasOption(opts, name string) bool {
+	for opts != "" {
+		var opt string
+		if i := strings.IndexByte(opts, ','); i >= 0 {
+			opt, opts = opts[:i], opts[i+1:]
+		} else {
+			opt, opts = opts, ""
+		}
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
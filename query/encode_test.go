@@ -0,0 +1,156 @@
+package query
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEscape(t *testing.T) {
+	const in = "aZ09-._~* +/=&%é"
+	tests := []struct {
+		escaping Escaping
+		want     string
+	}{
+		{FormEscaping, "aZ09-._%7E*+%2B%2F%3D%26%25%C3%A9"},
+		{StrictEscaping, "aZ09-._~%2A%20%2B%2F%3D%26%25%C3%A9"},
+		{SigV4Escaping, "aZ09-._~%2A%20%2B%2F%3D%26%25%C3%A9"},
+	}
+	for _, tt := range tests {
+		if got := tt.escaping.Escape(in); got != tt.want {
+			t.Errorf("%d: Escape = %q, want %q", tt.escaping, got, tt.want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	params := []Param{
+		{Key: "b", Value: "2", HasValue: true},
+		{Key: "a", Value: "z", HasValue: true},
+		{Key: "flag"},
+		{Key: "a", Value: "y x", HasValue: true},
+		{Key: "A", Value: "1", HasValue: true},
+	}
+	tests := []struct {
+		name    string
+		encoder Encoder
+		want    string
+	}{
+		{"form keeps order", Encoder{}, "b=2&a=z&flag&a=y+x&A=1"},
+		{"strict", Encoder{Escaping: StrictEscaping}, "b=2&a=z&flag&a=y%20x&A=1"},
+		{"sorted", Encoder{Sort: true}, "A=1&a=y+x&a=z&b=2&flag"},
+		// SigV4 sorts by escaped key, then escaped value, and always
+		// writes '=' after the key.
+		{"sigv4", Encoder{Escaping: SigV4Escaping}, "A=1&a=y%20x&a=z&b=2&flag="},
+	}
+	for _, tt := range tests {
+		if got := tt.encoder.Encode(params); got != tt.want {
+			t.Errorf("%s: Encode = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeSigV4SortsEscapedBytes(t *testing.T) {
+	// Sorting uses the escaped form: "%2A" (from '*') sorts before "-",
+	// and "a%20b" before "a-b".
+	params := []Param{
+		{Key: "k", Value: "-", HasValue: true},
+		{Key: "k", Value: "*", HasValue: true},
+		{Key: "a-b"},
+		{Key: "a b"},
+	}
+	want := "a%20b=&a-b=&k=%2A&k=-"
+	if got := (Encoder{Escaping: SigV4Escaping}).Encode(params); got != want {
+		t.Errorf("Encode = %q, want %q", got, want)
+	}
+}
+
+type inner struct {
+	Page int `query:"page"`
+}
+
+type level int
+
+func (l level) MarshalText() ([]byte, error) {
+	return []byte([]string{"low", "high"}[l]), nil
+}
+
+type search struct {
+	inner
+	Query   string    `query:"q"`
+	Tags    []string  `query:"tag"`
+	Empty   string    `query:"empty,omitempty"`
+	Skipped string    `query:"-"`
+	Since   time.Time `query:"since"`
+	Level   level     `query:"level"`
+	IP      net.IP    `query:"ip"`
+	Limit   *int      `query:"limit"`
+	Raw     []byte    `query:"raw"`
+	Plain   bool
+	hidden  string
+}
+
+func TestEncodeStruct(t *testing.T) {
+	limit := 10
+	v := search{
+		inner: inner{Page: 2},
+		Query: "a b",
+		Tags:  []string{"x", "y"},
+		Since: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level: 1,
+		IP:    net.IPv4(10, 0, 0, 1),
+		Limit: &limit,
+		Raw:   []byte("r"),
+		Plain: true,
+	}
+	const rest = "&since=2024-01-02T03%3A04%3A05Z&level=high&ip=10.0.0.1&limit=10&raw=r&Plain=true"
+	tests := []struct {
+		arrays ArrayNotation
+		want   string
+	}{
+		{ArrayRepeat, "page=2&q=a+b&tag=x&tag=y" + rest},
+		{ArrayBrackets, "page=2&q=a+b&tag%5B%5D=x&tag%5B%5D=y" + rest},
+		{ArrayIndexed, "page=2&q=a+b&tag%5B0%5D=x&tag%5B1%5D=y" + rest},
+		{ArrayComma, "page=2&q=a+b&tag=x%2Cy" + rest},
+	}
+	for _, tt := range tests {
+		got, err := Encoder{Arrays: tt.arrays}.EncodeStruct(&v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("arrays %d:\n got %s\nwant %s", tt.arrays, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeStructEmptySliceAndNil(t *testing.T) {
+	got, err := Encoder{Arrays: ArrayComma}.EncodeStruct(struct {
+		Tags  []string `query:"tag"`
+		Limit *int     `query:"limit"`
+	}{})
+	if err != nil || got != "" {
+		t.Errorf("EncodeStruct = %q, %v; want empty", got, err)
+	}
+}
+
+func TestEncodeStructCustomTag(t *testing.T) {
+	got, err := Encoder{Tag: "url"}.EncodeStruct(struct {
+		A string `url:"alpha" query:"ignored"`
+	}{"1"})
+	if err != nil || got != "alpha=1" {
+		t.Errorf("EncodeStruct = %q, %v", got, err)
+	}
+}
+
+func TestEncodeStructErrors(t *testing.T) {
+	if _, err := (Encoder{}).EncodeStruct(42); err != errNotStruct {
+		t.Errorf("non-struct: err = %v, want %v", err, errNotStruct)
+	}
+	if _, err := (Encoder{}).EncodeStruct(struct{ M map[string]int }{}); err == nil {
+		t.Error("unsupported map field: err = nil")
+	}
+	if got, err := (Encoder{}).EncodeStruct((*search)(nil)); err != nil || got != "" {
+		t.Errorf("nil pointer: %q, %v", got, err)
+	}
+}
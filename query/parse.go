@@ -0,0 +1,218 @@
+// Package query parses and encodes URL query strings and form bodies without
+// losing parameter order or the original encoding.
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// IssueKind classifies an Issue.
+type IssueKind int
+
+// Kinds of issues reported by Parse.
+const (
+	// Semicolon reports a ';' in the query. Whether it separated
+	// parameters depends on ParseOptions.Semicolons.
+	Semicolon IssueKind = iota + 1
+	// InvalidEscape reports a '%' not followed by two hexadecimal digits.
+	// The '%' is kept literally in the decoded value.
+	InvalidEscape
+)
+
+func (k IssueKind) String() string {
+	switch k {
+	case Semicolon:
+		return "semicolon"
+	case InvalidEscape:
+		return "invalid escape"
+	}
+	return "IssueKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Issue is a questionable construct found while parsing. Issues do not stop
+// parsing; they are collected in Query.Issues.
+type Issue struct {
+	Kind   IssueKind
+	Offset int // byte offset in the raw query
+	Param  int // index of the affected parameter in Query.Params
+}
+
+func (i *Issue) Error() string {
+	return "query: " + i.Kind.String() + " at offset " + strconv.Itoa(i.Offset)
+}
+
+// Param is a single query parameter.
+type Param struct {
+	RawKey   string // key as it appears in the query
+	RawValue string // value as it appears in the query
+	Key      string // decoded key
+	Value    string // decoded value
+
+	// HasValue distinguishes "a=" (true) from "a" (false).
+	HasValue bool
+
+	// Separator is the byte that precedes the parameter in the query,
+	// '&' or ';', or zero for the first parameter.
+	Separator byte
+}
+
+// Query is a parsed query string.
+type Query struct {
+	Params []Param
+	Issues []Issue
+}
+
+// ParseOptions controls parsing. The zero value splits on '&' only and decodes
+// '+' as a space, matching application/x-www-form-urlencoded.
+type ParseOptions struct {
+	// Semicolons makes ';' separate parameters in addition to '&'.
+	Semicolons bool
+
+	// LiteralPlus keeps '+' as is instead of decoding it as a space.
+	LiteralPlus bool
+}
+
+// Parse parses rawQuery, the part of a URL after '?', using the zero
+// ParseOptions.
+func Parse(rawQuery string) Query {
+	return ParseOptions{}.Parse(rawQuery)
+}
+
+// Parse parses rawQuery. Every parameter is kept, including empty ones, so
+// that Query.String reproduces rawQuery exactly.
+func (o ParseOptions) Parse(rawQuery string) Query {
+	var (
+		q   Query
+		sep byte
+	)
+	if rawQuery == "" {
+		return q
+	}
+	for start := 0; ; {
+		end := start
+		for end < len(rawQuery) {
+			c := rawQuery[end]
+			if c == '&' {
+				break
+			}
+			if c == ';' {
+				q.Issues = append(q.Issues, Issue{Kind: Semicolon, Offset: end, Param: len(q.Params)})
+				if o.Semicolons {
+					break
+				}
+			}
+			end++
+		}
+		q.Params = append(q.Params, o.parseParam(&q, rawQuery[start:end], start, sep))
+		if end == len(rawQuery) {
+			return q
+		}
+		sep = rawQuery[end]
+		start = end + 1
+	}
+}
+
+func (o ParseOptions) parseParam(q *Query, raw string, offset int, sep byte) Param {
+	p := Param{RawKey: raw, Separator: sep}
+	if i := strings.IndexByte(raw, '='); i >= 0 {
+		p.RawKey, p.RawValue, p.HasValue = raw[:i], raw[i+1:], true
+	}
+	index := len(q.Params)
+	p.Key = o.unescape(q, p.RawKey, offset, index)
+	if p.HasValue {
+		p.Value = o.unescape(q, p.RawValue, offset+len(p.RawKey)+1, index)
+	}
+	return p
+}
+
+// unescape decodes s, reporting invalid escapes relative to offset.
+func (o ParseOptions) unescape(q *Query, s string, offset, param int) string {
+	if strings.IndexByte(s, '%') < 0 && (o.LiteralPlus || strings.IndexByte(s, '+') < 0) {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '%':
+			if i+2 < len(s) && ishex(s[i+1]) && ishex(s[i+2]) {
+				b = append(b, unhex(s[i+1])<<4|unhex(s[i+2]))
+				i += 2
+				continue
+			}
+			q.Issues = append(q.Issues, Issue{Kind: InvalidEscape, Offset: offset + i, Param: param})
+			b = append(b, c)
+		case c == '+' && !o.LiteralPlus:
+			b = append(b, ' ')
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+func ishex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	}
+	return c - '0'
+}
+
+// Err returns the first issue, or nil if parsing found none.
+func (q Query) Err() error {
+	if len(q.Issues) == 0 {
+		return nil
+	}
+	return &q.Issues[0]
+}
+
+// Get returns the decoded value of the first parameter with the given decoded
+// key, and whether such a parameter exists.
+func (q Query) Get(key string) (string, bool) {
+	for _, p := range q.Params {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// All returns the decoded values of all parameters with the given decoded key,
+// in order.
+func (q Query) All(key string) []string {
+	var values []string
+	for _, p := range q.Params {
+		if p.Key == key {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// String reassembles the raw query from the raw parameters and separators.
+// For a parsed Query the result is identical to the parsed input.
+func (q Query) String() string {
+	var b strings.Builder
+	for i, p := range q.Params {
+		if i > 0 {
+			sep := p.Separator
+			if sep == 0 {
+				sep = '&'
+			}
+			b.WriteByte(sep)
+		}
+		b.WriteString(p.RawKey)
+		if p.HasValue {
+			b.WriteByte('=')
+			b.WriteString(p.RawValue)
+		}
+	}
+	return b.String()
+}
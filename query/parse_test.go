@@ -0,0 +1,146 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOrder(t *testing.T) {
+	q := Parse("z=1&a=2&m=3&a=4")
+	var keys, values []string
+	for _, p := range q.Params {
+		keys = append(keys, p.Key)
+		values = append(values, p.Value)
+	}
+	if want := []string{"z", "a", "m", "a"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("keys = %q, want %q", keys, want)
+	}
+	if want := []string{"1", "2", "3", "4"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %q, want %q", values, want)
+	}
+	if got := q.All("a"); !reflect.DeepEqual(got, []string{"2", "4"}) {
+		t.Errorf(`All("a") = %q`, got)
+	}
+	if got, ok := q.Get("a"); !ok || got != "2" {
+		t.Errorf(`Get("a") = %q, %v; want first value`, got, ok)
+	}
+}
+
+func TestParseHasValue(t *testing.T) {
+	tests := []struct {
+		raw      string
+		key      string
+		value    string
+		hasValue bool
+	}{
+		{"a", "a", "", false},
+		{"a=", "a", "", true},
+		{"a=b", "a", "b", true},
+		{"a==b", "a", "=b", true},
+		{"=b", "", "b", true},
+	}
+	for _, tt := range tests {
+		q := Parse(tt.raw)
+		if len(q.Params) != 1 {
+			t.Fatalf("%q: %d params, want 1", tt.raw, len(q.Params))
+		}
+		p := q.Params[0]
+		if p.Key != tt.key || p.Value != tt.value || p.HasValue != tt.hasValue {
+			t.Errorf("%q: got %q=%q (HasValue %v), want %q=%q (HasValue %v)",
+				tt.raw, p.Key, p.Value, p.HasValue, tt.key, tt.value, tt.hasValue)
+		}
+	}
+}
+
+func TestParseEmptyParams(t *testing.T) {
+	q := Parse("a=1&&b=2&")
+	if len(q.Params) != 4 {
+		t.Fatalf("%d params, want 4 including empty ones", len(q.Params))
+	}
+	if p := q.Params[1]; p.RawKey != "" || p.HasValue {
+		t.Errorf("empty param = %+v", p)
+	}
+	if q := Parse(""); len(q.Params) != 0 {
+		t.Errorf("empty query has %d params", len(q.Params))
+	}
+}
+
+func TestParseSemicolons(t *testing.T) {
+	raw := "a=1;b=2&c=3"
+
+	q := Parse(raw)
+	if len(q.Params) != 2 || q.Params[0].Value != "1;b=2" {
+		t.Errorf("default options split on ';': %+v", q.Params)
+	}
+	want := []Issue{{Kind: Semicolon, Offset: 3, Param: 0}}
+	if !reflect.DeepEqual(q.Issues, want) {
+		t.Errorf("issues = %+v, want %+v", q.Issues, want)
+	}
+
+	q = ParseOptions{Semicolons: true}.Parse(raw)
+	if len(q.Params) != 3 || q.Params[1].Key != "b" || q.Params[1].Separator != ';' || q.Params[2].Separator != '&' {
+		t.Errorf("Semicolons did not split: %+v", q.Params)
+	}
+	if !reflect.DeepEqual(q.Issues, want) {
+		t.Errorf("issues = %+v, want %+v", q.Issues, want)
+	}
+	if q.Err() == nil {
+		t.Error("Err() = nil with issues")
+	}
+}
+
+func TestParseEscapes(t *testing.T) {
+	tests := []struct {
+		raw     string
+		options ParseOptions
+		value   string
+		issues  []Issue
+	}{
+		{"k=%41%62", ParseOptions{}, "Ab", nil},
+		{"k=a+b", ParseOptions{}, "a b", nil},
+		{"k=a+b", ParseOptions{LiteralPlus: true}, "a+b", nil},
+		{"k=%2B", ParseOptions{}, "+", nil},
+		{"k=%zz", ParseOptions{}, "%zz", []Issue{{Kind: InvalidEscape, Offset: 2}}},
+		{"k=%4", ParseOptions{}, "%4", []Issue{{Kind: InvalidEscape, Offset: 2}}},
+		{"k=a%", ParseOptions{}, "a%", []Issue{{Kind: InvalidEscape, Offset: 3}}},
+		{"x=1&k=%g1%41", ParseOptions{}, "%g1A", []Issue{{Kind: InvalidEscape, Offset: 6, Param: 1}}},
+	}
+	for _, tt := range tests {
+		q := tt.options.Parse(tt.raw)
+		p := q.Params[len(q.Params)-1]
+		if p.Value != tt.value {
+			t.Errorf("%q: value = %q, want %q", tt.raw, p.Value, tt.value)
+		}
+		if !reflect.DeepEqual(q.Issues, tt.issues) {
+			t.Errorf("%q: issues = %+v, want %+v", tt.raw, q.Issues, tt.issues)
+		}
+	}
+}
+
+func TestParseKeyEscapes(t *testing.T) {
+	q := Parse("a%5B%5D=1")
+	if p := q.Params[0]; p.Key != "a[]" || p.RawKey != "a%5B%5D" {
+		t.Errorf("key = %q (raw %q)", p.Key, p.RawKey)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"a",
+		"a=",
+		"a=1&b=2",
+		"b=2&a=1&b=1",
+		"a=1;b=2",
+		"q=a+b%20c&x=%zz",
+		"&&a&=&",
+		"%7e=%7E&%41=%61",
+		"redirect=https%3A%2F%2Fexample.com%2F%3Fa%3Db",
+	} {
+		for _, o := range []ParseOptions{{}, {Semicolons: true}, {LiteralPlus: true}} {
+			if got := o.Parse(raw).String(); got != raw {
+				t.Errorf("%+v: String() = %q, want %q", o, got, raw)
+			}
+		}
+	}
+}
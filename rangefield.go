@@ -0,0 +1,105 @@
+package httpfields
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsatisfiableRange is returned by ParseRange when a syntactically
+// valid Range field selects no byte of the representation, which calls for a
+// 416 (Range Not Satisfiable) response.
+var ErrUnsatisfiableRange = errors.New("httpfields: range not satisfiable")
+
+var errBadRange = errors.New("expected bytes range-set")
+
+// ByteRange is a resolved byte range: the offsets of its first and last
+// byte, inclusive.
+type ByteRange struct {
+	Start, End int64
+}
+
+// Length returns the number of bytes in r.
+func (r ByteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// ContentRange formats r as a Content-Range field value for a
+// representation of the given size.
+func (r ByteRange) ContentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(r.Start, 10) + "-" + strconv.FormatInt(r.End, 10) +
+		"/" + strconv.FormatInt(size, 10)
+}
+
+// ParseRange parses a Range field value (RFC 9110 section 14.2) against a
+// representation of the given size. Ranges are resolved and clipped to the
+// representation, unsatisfiable ones are dropped, and the remaining ones are
+// returned in field order. Other range units are malformed.
+func ParseRange(s string, size int64) ([]ByteRange, error) {
+	v := TrimOWS(s)
+	if len(v) < 6 || !strings.EqualFold(v[:6], "bytes=") {
+		return nil, &ParseError{Field: "Range", Value: s, Err: errBadRange}
+	}
+	members := SplitList(v[6:])
+	if len(members) == 0 {
+		return nil, &ParseError{Field: "Range", Value: s, Err: errBadRange}
+	}
+	var ranges []ByteRange
+	for _, m := range members {
+		i := strings.IndexByte(m, '-')
+		if i < 0 {
+			return nil, &ParseError{Field: "Range", Value: s, Err: errBadRange}
+		}
+		first, last := TrimOWS(m[:i]), TrimOWS(m[i+1:])
+		if first == "" {
+			// suffix-range: the last n bytes.
+			n, ok := parseDigits(last)
+			if !ok {
+				return nil, &ParseError{Field: "Range", Value: s, Err: errBadRange}
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			ranges = append(ranges, ByteRange{Start: size - n, End: size - 1})
+			continue
+		}
+		start, ok := parseDigits(first)
+		if !ok {
+			return nil, &ParseError{Field: "Range", Value: s, Err: errBadRange}
+		}
+		end := size - 1
+		if last != "" {
+			if end, ok = parseDigits(last); !ok || end < start {
+				return nil, &ParseError{Field: "Range", Value: s, Err: errBadRange}
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, ByteRange{Start: start, End: end})
+	}
+	if len(ranges) == 0 {
+		return nil, ErrUnsatisfiableRange
+	}
+	return ranges, nil
+}
+
+// parseDigits parses a non-empty string of decimal digits.
+func parseDigits(s string) (int64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
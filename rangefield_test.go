@@ -0,0 +1,70 @@
+package httpfields
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	const size = 10000
+	tests := []struct {
+		in   string
+		want []ByteRange
+	}{
+		// RFC 9110 section 14.1.2.
+		{"bytes=0-499", []ByteRange{{0, 499}}},
+		{"bytes=500-999", []ByteRange{{500, 999}}},
+		{"bytes=-500", []ByteRange{{9500, 9999}}},
+		{"bytes=9500-", []ByteRange{{9500, 9999}}},
+		{"bytes=0-0,-1", []ByteRange{{0, 0}, {9999, 9999}}},
+		{"bytes= 0-999, 4500-5499, -1000", []ByteRange{{0, 999}, {4500, 5499}, {9000, 9999}}},
+		{"bytes=500-600,601-999", []ByteRange{{500, 600}, {601, 999}}},
+		{"bytes=500-700,601-999", []ByteRange{{500, 700}, {601, 999}}},
+
+		// Clipping, case and dropping of unsatisfiable ranges.
+		{"BYTES=9000-20000", []ByteRange{{9000, 9999}}},
+		{"bytes=-20000", []ByteRange{{0, 9999}}},
+		{"bytes=20000-, 0-1", []ByteRange{{0, 1}}},
+		{"bytes=-0, 5-5", []ByteRange{{5, 5}}},
+		{"bytes=0-1,,2-3", []ByteRange{{0, 1}, {2, 3}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRange(tt.in, size)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRange(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{"bytes=10000-", "bytes=-0", "bytes=20000-30000, 10000-"} {
+		if got, err := ParseRange(s, size); err != ErrUnsatisfiableRange {
+			t.Errorf("ParseRange(%q) = %v, %v; want %v", s, got, err, ErrUnsatisfiableRange)
+		}
+	}
+	if _, err := ParseRange("bytes=0-", 0); err != ErrUnsatisfiableRange {
+		t.Errorf("range of empty representation: %v", err)
+	}
+	if _, err := ParseRange("bytes=-5", 0); err != ErrUnsatisfiableRange {
+		t.Errorf("suffix of empty representation: %v", err)
+	}
+
+	for _, s := range []string{
+		"", "bytes", "bytes=", "bytes=,", "items=0-1", "bytes 0-1",
+		"bytes=1", "bytes=5-4", "bytes=a-b", "bytes=-", "bytes=--1",
+		"bytes=+1-2", "bytes=0-1;x", "bytes=0-99999999999999999999",
+	} {
+		_, err := ParseRange(s, size)
+		if pe, ok := err.(*ParseError); !ok || pe.Field != "Range" {
+			t.Errorf("ParseRange(%q) = %v, want *ParseError", s, err)
+		}
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	r := ByteRange{Start: 500, End: 999}
+	if r.Length() != 500 {
+		t.Errorf("Length = %d", r.Length())
+	}
+	if got := r.ContentRange(8000); got != "bytes 500-999/8000" {
+		t.Errorf("ContentRange = %q", got)
+	}
+}
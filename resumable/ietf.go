@@ -0,0 +1,222 @@
+package resumable
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/palsivertsen/gohttpfields/sfv"
+)
+
+// Fields specific to the IETF draft.
+const (
+	HeaderInteropVersion   = "Upload-Draft-Interop-Version"
+	HeaderUploadComplete   = "Upload-Complete"
+	HeaderUploadIncomplete = "Upload-Incomplete" // interop versions 3 and earlier
+	HeaderUploadLimit      = "Upload-Limit"
+)
+
+// lastIncompleteVersion is the last interop version that used
+// Upload-Incomplete instead of Upload-Complete.
+const lastIncompleteVersion = 3
+
+var (
+	// ErrInvalidComplete is returned for Upload-Complete or
+	// Upload-Incomplete values that are not a structured boolean.
+	ErrInvalidComplete = errors.New("resumable: invalid Upload-Complete")
+	// ErrInvalidInteropVersion is returned for malformed
+	// Upload-Draft-Interop-Version values.
+	ErrInvalidInteropVersion = errors.New("resumable: invalid Upload-Draft-Interop-Version")
+	// ErrInvalidLimit is returned for malformed Upload-Limit values.
+	ErrInvalidLimit = errors.New("resumable: invalid Upload-Limit")
+)
+
+// IETF holds the fields of the IETF draft. Offset and Length are -1 when
+// absent; Complete is nil when neither Upload-Complete nor
+// Upload-Incomplete is present.
+type IETF struct {
+	InteropVersion int // zero when absent
+	Complete       *bool
+	Offset         int64
+	Length         int64
+}
+
+// ParseIETF parses the IETF draft fields of a request header. The
+// Upload-Incomplete field of older drafts is mapped to Complete.
+func ParseIETF(h http.Header) (IETF, error) {
+	u := IETF{Offset: -1, Length: -1}
+	if v := h.Get(HeaderInteropVersion); v != "" {
+		n, err := parseInteger(v)
+		if err != nil || n <= 0 || n > 1<<31-1 {
+			return u, ErrInvalidInteropVersion
+		}
+		u.InteropVersion = int(n)
+	}
+	if v := h.Get(HeaderUploadComplete); v != "" {
+		b, err := parseBoolean(v)
+		if err != nil {
+			return u, err
+		}
+		u.Complete = &b
+	} else if v := h.Get(HeaderUploadIncomplete); v != "" {
+		b, err := parseBoolean(v)
+		if err != nil {
+			return u, err
+		}
+		b = !b
+		u.Complete = &b
+	}
+	if v := h.Get(HeaderUploadOffset); v != "" {
+		n, err := parseInteger(v)
+		if err != nil || n < 0 {
+			return u, ErrInvalidOffset
+		}
+		u.Offset = n
+	}
+	if v := h.Get(HeaderUploadLength); v != "" {
+		n, err := parseInteger(v)
+		if err != nil || n < 0 {
+			return u, ErrInvalidLength
+		}
+		u.Length = n
+	}
+	return u, nil
+}
+
+// SetHeader writes the fields of u to h. Completeness is written as
+// Upload-Incomplete for interop versions 3 and earlier and as
+// Upload-Complete otherwise.
+func (u IETF) SetHeader(h http.Header) {
+	if u.InteropVersion > 0 {
+		h.Set(HeaderInteropVersion, strconv.Itoa(u.InteropVersion))
+	}
+	if u.Complete != nil {
+		if u.InteropVersion > 0 && u.InteropVersion <= lastIncompleteVersion {
+			h.Set(HeaderUploadIncomplete, formatBoolean(!*u.Complete))
+		} else {
+			h.Set(HeaderUploadComplete, formatBoolean(*u.Complete))
+		}
+	}
+	if u.Offset >= 0 {
+		h.Set(HeaderUploadOffset, strconv.FormatInt(u.Offset, 10))
+	}
+	if u.Length >= 0 {
+		h.Set(HeaderUploadLength, strconv.FormatInt(u.Length, 10))
+	}
+}
+
+// Limit is the content of an Upload-Limit field. Sizes are -1 and Expires
+// is zero when not given.
+type Limit struct {
+	MaxSize       int64
+	MinSize       int64
+	MaxAppendSize int64
+	MinAppendSize int64
+	Expires       time.Duration
+}
+
+// ParseLimit parses an Upload-Limit field value, a structured dictionary
+// with integer members. Unknown members are ignored.
+func ParseLimit(s string) (Limit, error) {
+	l := Limit{MaxSize: -1, MinSize: -1, MaxAppendSize: -1, MinAppendSize: -1}
+	d, err := sfv.ParseDictionary(s)
+	if err != nil {
+		return l, ErrInvalidLimit
+	}
+	for _, f := range []struct {
+		key string
+		dst *int64
+	}{
+		{"max-size", &l.MaxSize},
+		{"min-size", &l.MinSize},
+		{"max-append-size", &l.MaxAppendSize},
+		{"min-append-size", &l.MinAppendSize},
+	} {
+		if n, ok, err := dictInteger(d, f.key); err != nil {
+			return l, err
+		} else if ok {
+			*f.dst = n
+		}
+	}
+	if n, ok, err := dictInteger(d, "expires"); err != nil {
+		return l, err
+	} else if ok {
+		l.Expires = time.Duration(n) * time.Second
+	}
+	return l, nil
+}
+
+func dictInteger(d sfv.Dictionary, key string) (int64, bool, error) {
+	m, ok := d.Get(key)
+	if !ok {
+		return 0, false, nil
+	}
+	it, isItem := m.(sfv.Item)
+	if !isItem {
+		return 0, false, ErrInvalidLimit
+	}
+	n, isInt := it.Value.(int64)
+	if !isInt || n < 0 {
+		return 0, false, ErrInvalidLimit
+	}
+	return n, true, nil
+}
+
+// String formats l as an Upload-Limit field value.
+func (l Limit) String() string {
+	var d sfv.Dictionary
+	add := func(key string, n int64) {
+		d = append(d, sfv.DictMember{Key: key, Value: sfv.Item{Value: n}})
+	}
+	if l.MaxSize >= 0 {
+		add("max-size", l.MaxSize)
+	}
+	if l.MinSize >= 0 {
+		add("min-size", l.MinSize)
+	}
+	if l.MaxAppendSize >= 0 {
+		add("max-append-size", l.MaxAppendSize)
+	}
+	if l.MinAppendSize >= 0 {
+		add("min-append-size", l.MinAppendSize)
+	}
+	if l.Expires > 0 {
+		add("expires", int64(l.Expires/time.Second))
+	}
+	s, _ := sfv.MarshalDictionary(d)
+	return s
+}
+
+func parseInteger(s string) (int64, error) {
+	it, err := sfv.ParseItem(s)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := it.Value.(int64)
+	if !ok {
+		return 0, errNotInteger
+	}
+	return n, nil
+}
+
+var errNotInteger = errors.New("not an integer")
+
+func parseBoolean(s string) (bool, error) {
+	it, err := sfv.ParseItem(s)
+	if err != nil {
+		return false, ErrInvalidComplete
+	}
+	b, ok := it.Value.(bool)
+	if !ok {
+		return false, ErrInvalidComplete
+	}
+	return b, nil
+}
+
+func formatBoolean(b bool) string {
+	if b {
+		return "?1"
+	}
+	return "?0"
+}
@@ -0,0 +1,156 @@
+package resumable
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestParseIETF(t *testing.T) {
+	tests := []struct {
+		name string
+		h    http.Header
+		want IETF
+		err  error
+	}{
+		{
+			name: "current draft",
+			h:    http.Header{"Upload-Draft-Interop-Version": {"6"}, "Upload-Complete": {"?1"}, "Upload-Length": {"100"}},
+			want: IETF{InteropVersion: 6, Complete: boolPtr(true), Offset: -1, Length: 100},
+		},
+		{
+			name: "incomplete append",
+			h:    http.Header{"Upload-Draft-Interop-Version": {"6"}, "Upload-Complete": {"?0"}, "Upload-Offset": {"50"}},
+			want: IETF{InteropVersion: 6, Complete: boolPtr(false), Offset: 50, Length: -1},
+		},
+		{
+			name: "Upload-Incomplete of version 3",
+			h:    http.Header{"Upload-Draft-Interop-Version": {"3"}, "Upload-Incomplete": {"?1"}},
+			want: IETF{InteropVersion: 3, Complete: boolPtr(false), Offset: -1, Length: -1},
+		},
+		{
+			name: "Upload-Incomplete false",
+			h:    http.Header{"Upload-Incomplete": {"?0"}},
+			want: IETF{Complete: boolPtr(true), Offset: -1, Length: -1},
+		},
+		{
+			name: "Upload-Complete preferred",
+			h:    http.Header{"Upload-Complete": {"?1"}, "Upload-Incomplete": {"?1"}},
+			want: IETF{Complete: boolPtr(true), Offset: -1, Length: -1},
+		},
+		{
+			name: "parameters ignored",
+			h:    http.Header{"Upload-Complete": {"?1;x=1"}, "Upload-Offset": {"7;y"}},
+			want: IETF{Complete: boolPtr(true), Offset: 7, Length: -1},
+		},
+		{name: "empty", h: http.Header{}, want: IETF{Offset: -1, Length: -1}},
+		{name: "tus boolean", h: http.Header{"Upload-Complete": {"1"}}, err: ErrInvalidComplete},
+		{name: "bad Upload-Incomplete", h: http.Header{"Upload-Incomplete": {"true"}}, err: ErrInvalidComplete},
+		{name: "version zero", h: http.Header{"Upload-Draft-Interop-Version": {"0"}}, err: ErrInvalidInteropVersion},
+		{name: "version string", h: http.Header{"Upload-Draft-Interop-Version": {`"6"`}}, err: ErrInvalidInteropVersion},
+		{name: "negative offset", h: http.Header{"Upload-Offset": {"-1"}}, err: ErrInvalidOffset},
+		{name: "decimal length", h: http.Header{"Upload-Length": {"1.0"}}, err: ErrInvalidLength},
+	}
+	for _, tt := range tests {
+		got, err := ParseIETF(tt.h)
+		if err != tt.err {
+			t.Errorf("%s: ParseIETF = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if tt.err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseIETF = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIETFSetHeader(t *testing.T) {
+	tests := []struct {
+		u    IETF
+		want http.Header
+	}{
+		{
+			IETF{InteropVersion: 6, Complete: boolPtr(false), Offset: 10, Length: -1},
+			http.Header{"Upload-Draft-Interop-Version": {"6"}, "Upload-Complete": {"?0"}, "Upload-Offset": {"10"}},
+		},
+		{
+			IETF{InteropVersion: 4, Complete: boolPtr(true), Offset: -1, Length: 20},
+			http.Header{"Upload-Draft-Interop-Version": {"4"}, "Upload-Complete": {"?1"}, "Upload-Length": {"20"}},
+		},
+		{
+			IETF{InteropVersion: 3, Complete: boolPtr(false), Offset: -1, Length: -1},
+			http.Header{"Upload-Draft-Interop-Version": {"3"}, "Upload-Incomplete": {"?1"}},
+		},
+		{
+			IETF{InteropVersion: 1, Complete: boolPtr(true), Offset: -1, Length: -1},
+			http.Header{"Upload-Draft-Interop-Version": {"1"}, "Upload-Incomplete": {"?0"}},
+		},
+		{
+			IETF{Complete: boolPtr(true), Offset: -1, Length: -1},
+			http.Header{"Upload-Complete": {"?1"}},
+		},
+		{
+			IETF{Offset: -1, Length: -1},
+			http.Header{},
+		},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		tt.u.SetHeader(h)
+		if !reflect.DeepEqual(h, tt.want) {
+			t.Errorf("SetHeader(%+v) = %v, want %v", tt.u, h, tt.want)
+		}
+		if back, err := ParseIETF(h); err != nil || !reflect.DeepEqual(back, tt.u) {
+			t.Errorf("ParseIETF(SetHeader(%+v)) = %+v, %v", tt.u, back, err)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+	}{
+		{"max-size=1000000000, min-append-size=1, expires=3600", Limit{
+			MaxSize: 1000000000, MinSize: -1, MaxAppendSize: -1, MinAppendSize: 1, Expires: time.Hour,
+		}},
+		{"min-size=0,max-append-size=5242880", Limit{
+			MaxSize: -1, MinSize: 0, MaxAppendSize: 5242880, MinAppendSize: -1,
+		}},
+		{"max-size=10, future-member=?1, other=(1 2)", Limit{
+			MaxSize: 10, MinSize: -1, MaxAppendSize: -1, MinAppendSize: -1,
+		}},
+		{"", Limit{MaxSize: -1, MinSize: -1, MaxAppendSize: -1, MinAppendSize: -1}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{
+		"max-size=-1",
+		"max-size=1.5",
+		`max-size="10"`,
+		"max-size=(1 2)",
+		"max-size",
+		"expires=-60",
+		"Max-Size=1",
+		"max-size=1,,",
+	} {
+		if _, err := ParseLimit(s); err != ErrInvalidLimit {
+			t.Errorf("ParseLimit(%q) = %v, want %v", s, err, ErrInvalidLimit)
+		}
+	}
+
+	l := Limit{MaxSize: 1 << 30, MinSize: -1, MaxAppendSize: -1, MinAppendSize: 1, Expires: 90 * time.Second}
+	if got := l.String(); got != "max-size=1073741824, min-append-size=1, expires=90" {
+		t.Errorf("String = %q", got)
+	}
+	if back, err := ParseLimit(l.String()); err != nil || back != l {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package resumable handles the fields of resumable upload protocols: tus
+// 1.0 and the IETF Resumable Uploads for HTTP draft, whose field names
+// changed between draft versions.
+//
+// Detect tells which protocol a request speaks so that a server can accept
+// both client generations on the same endpoint:
+//
+//	switch resumable.Detect(r.Header) {
+//	case resumable.ProtocolTus:
+//		req, err := resumable.ParseTus(r.Header)
+//	case resumable.ProtocolIETF:
+//		req, err := resumable.ParseIETF(r.Header)
+//	}
+package resumable
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// Field names shared by both protocols.
+const (
+	HeaderUploadOffset = "Upload-Offset"
+	HeaderUploadLength = "Upload-Length"
+)
+
+var (
+	// ErrInvalidOffset is returned for malformed Upload-Offset values.
+	ErrInvalidOffset = errors.New("resumable: invalid Upload-Offset")
+	// ErrInvalidLength is returned for malformed Upload-Length values.
+	ErrInvalidLength = errors.New("resumable: invalid Upload-Length")
+)
+
+// Protocol is a resumable upload protocol.
+type Protocol int
+
+// Protocols recognized by Detect.
+const (
+	ProtocolNone Protocol = iota
+	ProtocolTus
+	ProtocolIETF
+)
+
+func (p Protocol) String() string {
+	switch p {
+	case ProtocolTus:
+		return "tus"
+	case ProtocolIETF:
+		return "ietf"
+	}
+	return "none"
+}
+
+// Detect returns the protocol of a request: tus if it carries
+// Tus-Resumable, IETF if it carries Upload-Draft-Interop-Version,
+// Upload-Complete or Upload-Incomplete, and ProtocolNone otherwise.
+func Detect(h http.Header) Protocol {
+	if h.Get(HeaderTusResumable) != "" {
+		return ProtocolTus
+	}
+	if h.Get(HeaderInteropVersion) != "" || h.Get(HeaderUploadComplete) != "" ||
+		h.Get(HeaderUploadIncomplete) != "" {
+		return ProtocolIETF
+	}
+	return ProtocolNone
+}
+
+// parseSize parses a non-negative decimal integer.
+func parseSize(s string) (int64, bool) {
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
@@ -0,0 +1,48 @@
+package resumable
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		h    http.Header
+		want Protocol
+	}{
+		{http.Header{}, ProtocolNone},
+		{http.Header{"Upload-Offset": {"0"}}, ProtocolNone},
+		{http.Header{"Tus-Resumable": {"1.0.0"}}, ProtocolTus},
+		{http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Complete": {"?1"}}, ProtocolTus},
+		{http.Header{"Upload-Draft-Interop-Version": {"6"}}, ProtocolIETF},
+		{http.Header{"Upload-Complete": {"?0"}}, ProtocolIETF},
+		{http.Header{"Upload-Incomplete": {"?1"}}, ProtocolIETF},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.h); got != tt.want {
+			t.Errorf("Detect(%v) = %v, want %v", tt.h, got, tt.want)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"1048576", 1048576, true},
+		{"", 0, false},
+		{"-1", 0, false},
+		{"+1", 0, false},
+		{"1e3", 0, false},
+		{"99999999999999999999", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseSize(tt.in)
+		if ok != tt.ok || ok && got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package resumable
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// Fields specific to tus.
+const (
+	HeaderTusResumable      = "Tus-Resumable"
+	HeaderTusVersion        = "Tus-Version"
+	HeaderTusExtension      = "Tus-Extension"
+	HeaderTusMaxSize        = "Tus-Max-Size"
+	HeaderUploadMetadata    = "Upload-Metadata"
+	HeaderUploadDeferLength = "Upload-Defer-Length"
+)
+
+// TusVersion is the protocol version implemented by this package.
+const TusVersion = "1.0.0"
+
+var (
+	// ErrUnsupportedTusVersion is returned for Tus-Resumable values other
+	// than TusVersion. Servers answer with 412 (Precondition Failed) and
+	// list their versions in Tus-Version.
+	ErrUnsupportedTusVersion = errors.New("resumable: unsupported tus version")
+	// ErrInvalidMetadata is returned for malformed Upload-Metadata values.
+	ErrInvalidMetadata = errors.New("resumable: invalid Upload-Metadata")
+	// ErrInvalidDeferLength is returned for Upload-Defer-Length values
+	// other than 1, and when it is sent together with Upload-Length.
+	ErrInvalidDeferLength = errors.New("resumable: invalid Upload-Defer-Length")
+)
+
+// Metadata is the decoded content of an Upload-Metadata field. Keys sent
+// without a value map to the empty string.
+type Metadata map[string]string
+
+// ParseMetadata parses an Upload-Metadata field value: comma-separated
+// pairs of a key and an optional base64 encoded value. Keys must be unique
+// and must not contain spaces or commas.
+func ParseMetadata(s string) (Metadata, error) {
+	m := make(Metadata)
+	if httpfields.TrimOWS(s) == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pair = httpfields.TrimOWS(pair)
+		key, value := pair, ""
+		if i := strings.IndexByte(pair, ' '); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+		if key == "" {
+			return nil, ErrInvalidMetadata
+		}
+		if _, dup := m[key]; dup {
+			return nil, ErrInvalidMetadata
+		}
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, ErrInvalidMetadata
+		}
+		m[key] = string(b)
+	}
+	return m, nil
+}
+
+// String formats m as an Upload-Metadata field value with keys in sorted
+// order.
+func (m Metadata) String() string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k)
+		if v := m[k]; v != "" {
+			b.WriteByte(' ')
+			b.WriteString(base64.StdEncoding.EncodeToString([]byte(v)))
+		}
+	}
+	return b.String()
+}
+
+// Tus holds the tus fields of a request or response. Offset and Length are
+// -1 when absent.
+type Tus struct {
+	Version     string // Tus-Resumable
+	Offset      int64
+	Length      int64
+	DeferLength bool
+	Metadata    Metadata // nil when absent
+}
+
+// ParseTus parses the tus fields of a request header. It fails with
+// ErrUnsupportedTusVersion unless Tus-Resumable is TusVersion; the other
+// fields are still parsed in that case.
+func ParseTus(h http.Header) (Tus, error) {
+	t := Tus{Version: httpfields.TrimOWS(h.Get(HeaderTusResumable)), Offset: -1, Length: -1}
+	if v := h.Get(HeaderUploadOffset); v != "" {
+		n, ok := parseSize(httpfields.TrimOWS(v))
+		if !ok {
+			return t, ErrInvalidOffset
+		}
+		t.Offset = n
+	}
+	if v := h.Get(HeaderUploadLength); v != "" {
+		n, ok := parseSize(httpfields.TrimOWS(v))
+		if !ok {
+			return t, ErrInvalidLength
+		}
+		t.Length = n
+	}
+	if v := h.Get(HeaderUploadDeferLength); v != "" {
+		if httpfields.TrimOWS(v) != "1" || t.Length >= 0 {
+			return t, ErrInvalidDeferLength
+		}
+		t.DeferLength = true
+	}
+	if v, ok := h[HeaderUploadMetadata]; ok && len(v) > 0 {
+		m, err := ParseMetadata(v[0])
+		if err != nil {
+			return t, err
+		}
+		t.Metadata = m
+	}
+	if t.Version != TusVersion {
+		return t, ErrUnsupportedTusVersion
+	}
+	return t, nil
+}
+
+// SetHeader writes the fields of t to h. An empty Version is written as
+// TusVersion.
+func (t Tus) SetHeader(h http.Header) {
+	version := t.Version
+	if version == "" {
+		version = TusVersion
+	}
+	h.Set(HeaderTusResumable, version)
+	if t.Offset >= 0 {
+		h.Set(HeaderUploadOffset, strconv.FormatInt(t.Offset, 10))
+	}
+	if t.Length >= 0 {
+		h.Set(HeaderUploadLength, strconv.FormatInt(t.Length, 10))
+	} else if t.DeferLength {
+		h.Set(HeaderUploadDeferLength, "1")
+	}
+	if t.Metadata != nil {
+		h.Set(HeaderUploadMetadata, t.Metadata.String())
+	}
+}
+
+// SetOptions writes the fields of a response to an OPTIONS request:
+// Tus-Version, Tus-Extension when extensions are given and Tus-Max-Size
+// when maxSize is positive.
+func SetOptions(h http.Header, maxSize int64, extensions ...string) {
+	h.Set(HeaderTusResumable, TusVersion)
+	h.Set(HeaderTusVersion, TusVersion)
+	if len(extensions) > 0 {
+		h.Set(HeaderTusExtension, strings.Join(extensions, ","))
+	}
+	if maxSize > 0 {
+		h.Set(HeaderTusMaxSize, strconv.FormatInt(maxSize, 10))
+	}
+}
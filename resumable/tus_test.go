@@ -0,0 +1,165 @@
+package resumable
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Metadata
+	}{
+		// The example of the tus 1.0 specification.
+		{"filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential", Metadata{
+			"filename":        "world_domination_plan.pdf",
+			"is_confidential": "",
+		}},
+		{"", Metadata{}},
+		{" ", Metadata{}},
+		{" name bmFtZQ== , type dGV4dC9wbGFpbg== ", Metadata{"name": "name", "type": "text/plain"}},
+		{"empty ", Metadata{"empty": ""}},
+		{" bmFtZQ==", Metadata{"bmFtZQ==": ""}},
+		{"bin AP8=", Metadata{"bin": "\x00\xff"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMetadata(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMetadata(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	for _, s := range []string{
+		"name bmFtZQ==,name dHdv",
+		"name bmFtZQ",
+		"name bm FtZQ==",
+		"name bmFtZQ==,,type dGV4dA==",
+		"name !!!",
+	} {
+		if _, err := ParseMetadata(s); err != ErrInvalidMetadata {
+			t.Errorf("ParseMetadata(%q) = %v, want %v", s, err, ErrInvalidMetadata)
+		}
+	}
+
+	m := Metadata{"is_confidential": "", "filename": "world_domination_plan.pdf"}
+	if got := m.String(); got != "filename d29ybGRfZG9taW5hdGlvbl9wbGFuLnBkZg==,is_confidential" {
+		t.Errorf("String = %q", got)
+	}
+	if back, err := ParseMetadata(m.String()); err != nil || !reflect.DeepEqual(back, m) {
+		t.Errorf("round trip = %v, %v", back, err)
+	}
+}
+
+func TestParseTus(t *testing.T) {
+	tests := []struct {
+		name string
+		h    http.Header
+		want Tus
+		err  error
+	}{
+		{
+			name: "creation",
+			h:    http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Length": {"100"}, "Upload-Metadata": {"filename dGVzdA=="}},
+			want: Tus{Version: "1.0.0", Offset: -1, Length: 100, Metadata: Metadata{"filename": "test"}},
+		},
+		{
+			name: "deferred length",
+			h:    http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Defer-Length": {"1"}},
+			want: Tus{Version: "1.0.0", Offset: -1, Length: -1, DeferLength: true},
+		},
+		{
+			name: "append",
+			h:    http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Offset": {" 70 "}},
+			want: Tus{Version: "1.0.0", Offset: 70, Length: -1},
+		},
+		{
+			name: "empty metadata",
+			h:    http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Metadata": {""}},
+			want: Tus{Version: "1.0.0", Offset: -1, Length: -1, Metadata: Metadata{}},
+		},
+		{
+			name: "defer length with length",
+			h:    http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Length": {"100"}, "Upload-Defer-Length": {"1"}},
+			err:  ErrInvalidDeferLength,
+		},
+		{
+			name: "defer length with zero length",
+			h:    http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Length": {"0"}, "Upload-Defer-Length": {"1"}},
+			err:  ErrInvalidDeferLength,
+		},
+		{
+			name: "defer length other than 1",
+			h:    http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Defer-Length": {"0"}},
+			err:  ErrInvalidDeferLength,
+		},
+		{name: "bad offset", h: http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Offset": {"-1"}}, err: ErrInvalidOffset},
+		{name: "bad length", h: http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Length": {"ten"}}, err: ErrInvalidLength},
+		{name: "bad metadata", h: http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Metadata": {"a b,a c"}}, err: ErrInvalidMetadata},
+		{
+			name: "other version",
+			h:    http.Header{"Tus-Resumable": {"0.2.2"}, "Upload-Offset": {"5"}},
+			want: Tus{Version: "0.2.2", Offset: 5, Length: -1},
+			err:  ErrUnsupportedTusVersion,
+		},
+		{name: "no version", h: http.Header{}, want: Tus{Offset: -1, Length: -1}, err: ErrUnsupportedTusVersion},
+	}
+	for _, tt := range tests {
+		got, err := ParseTus(tt.h)
+		if err != tt.err {
+			t.Errorf("%s: ParseTus = %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if (tt.err == nil || tt.err == ErrUnsupportedTusVersion) && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ParseTus = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTusSetHeader(t *testing.T) {
+	tests := []struct {
+		t    Tus
+		want http.Header
+	}{
+		{
+			Tus{Offset: 0, Length: 100, Metadata: Metadata{"a": "b"}},
+			http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Offset": {"0"}, "Upload-Length": {"100"}, "Upload-Metadata": {"a Yg=="}},
+		},
+		{
+			Tus{Offset: -1, Length: -1, DeferLength: true},
+			http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Defer-Length": {"1"}},
+		},
+		{
+			// A known length takes precedence over deferring it.
+			Tus{Offset: -1, Length: 5, DeferLength: true},
+			http.Header{"Tus-Resumable": {"1.0.0"}, "Upload-Length": {"5"}},
+		},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		tt.t.SetHeader(h)
+		if !reflect.DeepEqual(h, tt.want) {
+			t.Errorf("SetHeader(%+v) = %v, want %v", tt.t, h, tt.want)
+		}
+		if back, err := ParseTus(h); err != nil || back.Length != tt.t.Length || back.DeferLength != (tt.t.Length < 0 && tt.t.DeferLength) {
+			t.Errorf("ParseTus(SetHeader(%+v)) = %+v, %v", tt.t, back, err)
+		}
+	}
+
+	h := http.Header{}
+	SetOptions(h, 1<<30, "creation", "termination")
+	want := http.Header{
+		"Tus-Resumable": {"1.0.0"},
+		"Tus-Version":   {"1.0.0"},
+		"Tus-Extension": {"creation,termination"},
+		"Tus-Max-Size":  {"1073741824"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("SetOptions = %v", h)
+	}
+	h = http.Header{}
+	SetOptions(h, 0)
+	if len(h) != 2 {
+		t.Errorf("SetOptions without extensions or limit = %v", h)
+	}
+}
@@ -0,0 +1,41 @@
+package retry
+
+import "sync"
+
+// Budget limits retries across requests to a fraction of the request volume,
+// so that a struggling server is not overwhelmed by retries. A Budget is safe
+// for concurrent use and is typically shared by all Transports talking to the
+// same service.
+type Budget struct {
+	ratio  float64
+	max    float64
+	mu     sync.Mutex
+	tokens float64
+}
+
+// NewBudget returns a Budget that allows ratio retries per request on
+// average, with up to burst retries banked. It starts full.
+func NewBudget(ratio float64, burst int) *Budget {
+	return &Budget{ratio: ratio, max: float64(burst), tokens: float64(burst)}
+}
+
+// deposit credits the budget for a new request.
+func (b *Budget) deposit() {
+	b.mu.Lock()
+	b.tokens += b.ratio
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.mu.Unlock()
+}
+
+// withdraw reports whether a retry is allowed and charges it.
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,216 @@
+// Package retry implements an http.RoundTripper that retries failed requests
+// when doing so is safe.
+//
+// A request is retried only if its method is idempotent (RFC 9110 section
+// 9.2.2) or it carries an Idempotency-Key field, and only if its body can be
+// replayed through Request.GetBody. Retry-After is honoured on 429 and 503
+// responses; otherwise attempts are spaced by exponential backoff with full
+// jitter.
+package retry
+
+import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// Defaults applied when the corresponding Transport field is zero.
+const (
+	DefaultMaxAttempts   = 3
+	DefaultBaseDelay     = 100 * time.Millisecond
+	DefaultMaxDelay      = 10 * time.Second
+	DefaultMaxRetryAfter = time.Minute
+)
+
+// Transport retries requests on network errors and retryable responses.
+type Transport struct {
+	// Transport is the underlying RoundTripper. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+
+	// BaseDelay and MaxDelay bound the exponential backoff. The delay
+	// before retry n is drawn uniformly from [0, min(MaxDelay,
+	// BaseDelay*2^n)).
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// MaxRetryAfter is the longest Retry-After delay honoured. Responses
+	// asking for longer are returned to the caller without retrying.
+	MaxRetryAfter time.Duration
+
+	// MaxElapsed, if positive, stops retrying once the next attempt would
+	// start more than MaxElapsed after the first.
+	MaxElapsed time.Duration
+
+	// Budget, if set, limits retries across requests.
+	Budget *Budget
+
+	// ShouldRetry, if set, replaces the default classification of
+	// responses, which retries 429, 502, 503 and 504.
+	ShouldRetry func(*http.Response) bool
+
+	// rand draws the jitter. It is seeded per Transport so that replicas
+	// do not share a backoff sequence.
+	randOnce sync.Once
+	randMu   sync.Mutex
+	rand     *rand.Rand
+}
+
+func (t *Transport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Budget != nil {
+		t.Budget.deposit()
+	}
+	if !Retryable(req) {
+		return t.transport().RoundTrip(req)
+	}
+	maxAttempts := t.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		areq := req
+		if attempt > 0 {
+			var err error
+			if areq, err = rewind(req); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := t.transport().RoundTrip(areq)
+		if attempt+1 >= maxAttempts {
+			return resp, err
+		}
+		delay, retry := t.delay(attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+		if t.MaxElapsed > 0 && time.Since(start)+delay > t.MaxElapsed {
+			return resp, err
+		}
+		if t.Budget != nil && !t.Budget.withdraw() {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// delay decides whether to retry after an attempt and how long to wait.
+func (t *Transport) delay(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if err != nil {
+		return t.backoff(attempt), true
+	}
+	shouldRetry := t.ShouldRetry
+	if shouldRetry == nil {
+		shouldRetry = defaultShouldRetry
+	}
+	if !shouldRetry(resp) {
+		return 0, false
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if v := resp.Header.Get("Retry-After"); v != "" {
+			d, err := httpfields.ParseRetryAfter(v, time.Now())
+			if err == nil {
+				limit := t.MaxRetryAfter
+				if limit <= 0 {
+					limit = DefaultMaxRetryAfter
+				}
+				return d, d <= limit
+			}
+		}
+	}
+	return t.backoff(attempt), true
+}
+
+func (t *Transport) backoff(attempt int) time.Duration {
+	base, maxDelay := t.BaseDelay, t.MaxDelay
+	if base <= 0 {
+		base = DefaultBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxDelay
+	}
+	d := maxDelay
+	if attempt < 32 && base<<uint(attempt) < maxDelay && base<<uint(attempt) > 0 {
+		d = base << uint(attempt)
+	}
+	return time.Duration(t.int63n(int64(d)))
+}
+
+func (t *Transport) int63n(n int64) int64 {
+	t.randOnce.Do(func() {
+		seed := time.Now().UnixNano()
+		var b [8]byte
+		if _, err := cryptorand.Read(b[:]); err == nil {
+			seed = int64(binary.LittleEndian.Uint64(b[:]))
+		}
+		t.rand = rand.New(rand.NewSource(seed))
+	})
+	t.randMu.Lock()
+	defer t.randMu.Unlock()
+	return t.rand.Int63n(n)
+}
+
+func defaultShouldRetry(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Retryable reports whether req may be sent more than once: its method is
+// idempotent or it carries an Idempotency-Key field, and its body, if any,
+// can be recreated with GetBody.
+func Retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// rewind returns a copy of req with a fresh body.
+func rewind(req *http.Request) (*http.Request, error) {
+	r := req.WithContext(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	return r, nil
+}
@@ -0,0 +1,232 @@
+package retry
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// script is a RoundTripper that replays responses in order and records the
+// bodies of the requests it receives.
+type script struct {
+	steps  []func(*http.Request) (*http.Response, error)
+	bodies []string
+}
+
+func (s *script) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, _ := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		body = string(b)
+	}
+	s.bodies = append(s.bodies, body)
+	step := s.steps[0]
+	if len(s.steps) > 1 {
+		s.steps = s.steps[1:]
+	}
+	return step(req)
+}
+
+func status(code int, header http.Header) func(*http.Request) (*http.Response, error) {
+	return func(req *http.Request) (*http.Response, error) {
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			StatusCode: code,
+			Header:     header,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, nil
+	}
+}
+
+func fail(req *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection reset")
+}
+
+func newTestTransport(steps ...func(*http.Request) (*http.Response, error)) (*Transport, *script) {
+	s := &script{steps: steps}
+	return &Transport{Transport: s, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}, s
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    []func(*http.Request) (*http.Response, error)
+		attempts int
+		status   int
+		err      bool
+	}{
+		{"success", []func(*http.Request) (*http.Response, error){status(200, nil)}, 1, 200, false},
+		{"network error then success", []func(*http.Request) (*http.Response, error){fail, status(200, nil)}, 2, 200, false},
+		{"retryable statuses", []func(*http.Request) (*http.Response, error){status(502, nil), status(504, nil), status(200, nil)}, 3, 200, false},
+		{"attempts exhausted", []func(*http.Request) (*http.Response, error){status(503, nil)}, 3, 503, false},
+		{"errors exhausted", []func(*http.Request) (*http.Response, error){fail}, 3, 0, true},
+		{"not retryable status", []func(*http.Request) (*http.Response, error){status(500, nil)}, 1, 500, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, s := newTestTransport(tt.steps...)
+			req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+			resp, err := tr.RoundTrip(req)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v", err)
+			}
+			if err == nil && resp.StatusCode != tt.status {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if len(s.bodies) != tt.attempts {
+				t.Errorf("%d attempts, want %d", len(s.bodies), tt.attempts)
+			}
+		})
+	}
+}
+
+func TestIdempotencyGating(t *testing.T) {
+	tests := []struct {
+		method   string
+		key      string
+		attempts int
+	}{
+		{http.MethodGet, "", 2},
+		{http.MethodPut, "", 2},
+		{http.MethodDelete, "", 2},
+		{http.MethodPost, "", 1},
+		{http.MethodPatch, "", 1},
+		{http.MethodPost, "8e03978e-40d5-43e8-bc93-6894a57f9324", 2},
+	}
+	for _, tt := range tests {
+		tr, s := newTestTransport(status(503, nil), status(200, nil))
+		req, _ := http.NewRequest(tt.method, "http://example.com/", bytes.NewReader([]byte("payload")))
+		if tt.key != "" {
+			req.Header.Set("Idempotency-Key", tt.key)
+		}
+		if _, err := tr.RoundTrip(req); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.bodies) != tt.attempts {
+			t.Errorf("%s (key %q): %d attempts, want %d", tt.method, tt.key, len(s.bodies), tt.attempts)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPut, "http://example.com/", ioutil.NopCloser(strings.NewReader("x")))
+	if Retryable(req) {
+		t.Error("request whose body cannot be replayed is retryable")
+	}
+	req, _ = http.NewRequest(http.MethodPut, "http://example.com/", strings.NewReader("x"))
+	if !Retryable(req) {
+		t.Error("PUT with GetBody is not retryable")
+	}
+	req, _ = http.NewRequest(http.MethodPost, "http://example.com/", http.NoBody)
+	if Retryable(req) {
+		t.Error("POST without Idempotency-Key is retryable")
+	}
+}
+
+func TestBodyRewinding(t *testing.T) {
+	tr, s := newTestTransport(fail, status(502, nil), status(200, nil))
+	req, _ := http.NewRequest(http.MethodPut, "http://example.com/", strings.NewReader("payload"))
+	if _, err := tr.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	for i, body := range s.bodies {
+		if body != "payload" {
+			t.Errorf("attempt %d sent body %q", i+1, body)
+		}
+	}
+	if len(s.bodies) != 3 {
+		t.Errorf("%d attempts, want 3", len(s.bodies))
+	}
+
+	tr, s = newTestTransport(fail)
+	req, _ = http.NewRequest(http.MethodPut, "http://example.com/", strings.NewReader("payload"))
+	getBodyErr := errors.New("body gone")
+	req.GetBody = func() (io.ReadCloser, error) { return nil, getBodyErr }
+	if _, err := tr.RoundTrip(req); err != getBodyErr {
+		t.Errorf("err = %v, want the GetBody error", err)
+	}
+	if len(s.bodies) != 1 {
+		t.Errorf("%d attempts, want 1", len(s.bodies))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tr, s := newTestTransport(status(429, http.Header{"Retry-After": {"0"}}), status(200, nil))
+	tr.BaseDelay, tr.MaxDelay = time.Hour, time.Hour
+	start := time.Now()
+	resp, err := tr.RoundTrip(mustRequest(t))
+	if err != nil || resp.StatusCode != 200 || len(s.bodies) != 2 {
+		t.Fatalf("Retry-After 0: %v, %d attempts", err, len(s.bodies))
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("Retry-After 0 waited %v", d)
+	}
+
+	// Delays beyond MaxRetryAfter are returned to the caller.
+	tr, s = newTestTransport(status(503, http.Header{"Retry-After": {"120"}}), status(200, nil))
+	resp, err = tr.RoundTrip(mustRequest(t))
+	if err != nil || resp.StatusCode != 503 || len(s.bodies) != 1 {
+		t.Errorf("Retry-After beyond limit: %v, %d attempts", err, len(s.bodies))
+	}
+	tr, s = newTestTransport(status(503, http.Header{"Retry-After": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}), status(200, nil))
+	resp, err = tr.RoundTrip(mustRequest(t))
+	if err != nil || resp.StatusCode != 503 || len(s.bodies) != 1 {
+		t.Errorf("Retry-After date beyond limit: %v, %d attempts", err, len(s.bodies))
+	}
+
+	// Retry-After on a 502 is ignored in favour of backoff.
+	tr, s = newTestTransport(status(502, http.Header{"Retry-After": {"120"}}), status(200, nil))
+	resp, err = tr.RoundTrip(mustRequest(t))
+	if err != nil || resp.StatusCode != 200 || len(s.bodies) != 2 {
+		t.Errorf("Retry-After on 502: %v, %d attempts", err, len(s.bodies))
+	}
+}
+
+func TestBudget(t *testing.T) {
+	b := NewBudget(0, 1)
+	tr, s := newTestTransport(status(503, nil))
+	tr.Budget = b
+	tr.RoundTrip(mustRequest(t))
+	if len(s.bodies) != 2 {
+		t.Errorf("%d attempts with one banked retry, want 2", len(s.bodies))
+	}
+	tr, s = newTestTransport(status(503, nil))
+	tr.Budget = b
+	tr.RoundTrip(mustRequest(t))
+	if len(s.bodies) != 1 {
+		t.Errorf("%d attempts with an empty budget, want 1", len(s.bodies))
+	}
+}
+
+func TestBackoffBounds(t *testing.T) {
+	tr := &Transport{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for attempt := 0; attempt < 40; attempt++ {
+		limit := 50 * time.Millisecond
+		if attempt < 3 {
+			limit = 10 * time.Millisecond << uint(attempt)
+		}
+		for i := 0; i < 20; i++ {
+			if d := tr.backoff(attempt); d < 0 || d >= limit {
+				t.Fatalf("backoff(%d) = %v, want in [0, %v)", attempt, d, limit)
+			}
+		}
+	}
+}
+
+func mustRequest(t *testing.T) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
@@ -0,0 +1,31 @@
+package httpfields
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var errBadRetryAfter = errors.New("expected delay-seconds or HTTP-date")
+
+// ParseRetryAfter parses a Retry-After field value (RFC 9110 section 10.2.3)
+// and returns the delay relative to now. Dates in the past yield a zero delay.
+func ParseRetryAfter(s string, now time.Time) (time.Duration, error) {
+	s = TrimOWS(s)
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return 0, &ParseError{Field: "Retry-After", Value: s, Err: errBadRetryAfter}
+		}
+		return time.Duration(n) * time.Second, nil
+	}
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return 0, &ParseError{Field: "Retry-After", Value: s, Err: errBadRetryAfter}
+	}
+	if d := t.Sub(now); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
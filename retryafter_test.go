@@ -0,0 +1,35 @@
+package httpfields
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2015, 10, 21, 7, 28, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"120", 2 * time.Minute, false},
+		{" 0 ", 0, false},
+		{"4294967295", 4294967295 * time.Second, false},
+		{"Wed, 21 Oct 2015 07:28:30 GMT", 30 * time.Second, false},
+		{"Wednesday, 21-Oct-15 07:29:00 GMT", time.Minute, false},
+		{"Wed Oct 21 07:28:05 2015", 5 * time.Second, false},
+		{"Wed, 21 Oct 2015 07:00:00 GMT", 0, false},
+		{"4294967296", 0, true},
+		{"1.5", 0, true},
+		{"12s", 0, true},
+		{"-1", 0, true},
+		{"", 0, true},
+		{"tomorrow", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRetryAfter(tt.in, now)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseRetryAfter(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package sfv
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type parser struct {
+	s   string
+	off int
+}
+
+func (p *parser) errorf(msg string) error {
+	return &SyntaxError{Offset: p.off, Msg: msg}
+}
+
+func (p *parser) eof() bool { return p.off >= len(p.s) }
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.off]
+}
+
+func (p *parser) skipSP() {
+	for !p.eof() && p.s[p.off] == ' ' {
+		p.off++
+	}
+}
+
+func (p *parser) skipOWS() {
+	for !p.eof() && (p.s[p.off] == ' ' || p.s[p.off] == '\t') {
+		p.off++
+	}
+}
+
+// ParseList parses a List field value. Multiple field lines should be joined
+// with commas before parsing.
+func ParseList(s string) (List, error) {
+	p := &parser{s: s}
+	p.skipSP()
+	l, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+	return l, p.finish()
+}
+
+// ParseDictionary parses a Dictionary field value.
+func ParseDictionary(s string) (Dictionary, error) {
+	p := &parser{s: s}
+	p.skipSP()
+	d, err := p.parseDictionary()
+	if err != nil {
+		return nil, err
+	}
+	return d, p.finish()
+}
+
+// ParseItem parses an Item field value.
+func ParseItem(s string) (Item, error) {
+	p := &parser{s: s}
+	p.skipSP()
+	it, err := p.parseItem()
+	if err != nil {
+		return Item{}, err
+	}
+	return it, p.finish()
+}
+
+func (p *parser) finish() error {
+	p.skipSP()
+	if !p.eof() {
+		return p.errorf("unexpected trailing characters")
+	}
+	return nil
+}
+
+func (p *parser) parseList() (List, error) {
+	var l List
+	for !p.eof() {
+		m, err := p.parseItemOrInnerList()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, m)
+		if err := p.parseSeparator(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (p *parser) parseDictionary() (Dictionary, error) {
+	var d Dictionary
+	for !p.eof() {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var m Member
+		if p.peek() == '=' {
+			p.off++
+			if m, err = p.parseItemOrInnerList(); err != nil {
+				return nil, err
+			}
+		} else {
+			params, err := p.parseParams()
+			if err != nil {
+				return nil, err
+			}
+			m = Item{Value: true, Params: params}
+		}
+		// Later members overwrite earlier ones but keep their position.
+		replaced := false
+		for i := range d {
+			if d[i].Key == key {
+				d[i].Value = m
+				replaced = true
+			}
+		}
+		if !replaced {
+			d = append(d, DictMember{Key: key, Value: m})
+		}
+		if err := p.parseSeparator(); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// parseSeparator consumes the comma between members.
+func (p *parser) parseSeparator() error {
+	p.skipOWS()
+	if p.eof() {
+		return nil
+	}
+	if p.s[p.off] != ',' {
+		return p.errorf("expected comma")
+	}
+	p.off++
+	p.skipOWS()
+	if p.eof() {
+		return p.errorf("trailing comma")
+	}
+	return nil
+}
+
+func (p *parser) parseItemOrInnerList() (Member, error) {
+	if p.peek() == '(' {
+		return p.parseInnerList()
+	}
+	return p.parseItem()
+}
+
+func (p *parser) parseInnerList() (InnerList, error) {
+	var il InnerList
+	p.off++ // '('
+	for !p.eof() {
+		p.skipSP()
+		if p.peek() == ')' {
+			p.off++
+			params, err := p.parseParams()
+			if err != nil {
+				return il, err
+			}
+			il.Params = params
+			return il, nil
+		}
+		it, err := p.parseItem()
+		if err != nil {
+			return il, err
+		}
+		il.Items = append(il.Items, it)
+		if c := p.peek(); c != ' ' && c != ')' {
+			return il, p.errorf("expected space or ')' in inner list")
+		}
+	}
+	return il, p.errorf("unterminated inner list")
+}
+
+func (p *parser) parseItem() (Item, error) {
+	v, err := p.parseBareItem()
+	if err != nil {
+		return Item{}, err
+	}
+	params, err := p.parseParams()
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{Value: v, Params: params}, nil
+}
+
+func (p *parser) parseParams() (Params, error) {
+	var params Params
+	for p.peek() == ';' {
+		p.off++
+		p.skipSP()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{} = true
+		if p.peek() == '=' {
+			p.off++
+			if v, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+		replaced := false
+		for i := range params {
+			if params[i].Key == key {
+				params[i].Value = v
+				replaced = true
+			}
+		}
+		if !replaced {
+			params = append(params, Param{Key: key, Value: v})
+		}
+	}
+	return params, nil
+}
+
+func isLcalpha(c byte) bool { return c >= 'a' && c <= 'z' }
+func isDigit(c byte) bool   { return c >= '0' && c <= '9' }
+func isAlpha(c byte) bool   { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+
+func (p *parser) parseKey() (string, error) {
+	start := p.off
+	if c := p.peek(); !isLcalpha(c) && c != '*' {
+		return "", p.errorf("expected key")
+	}
+	for !p.eof() {
+		c := p.s[p.off]
+		if !isLcalpha(c) && !isDigit(c) && c != '_' && c != '-' && c != '.' && c != '*' {
+			break
+		}
+		p.off++
+	}
+	return p.s[start:p.off], nil
+}
+
+func (p *parser) parseBareItem() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '-' || isDigit(c):
+		return p.parseNumber()
+	case c == '"':
+		return p.parseString()
+	case c == '*' || isAlpha(c):
+		return p.parseToken(), nil
+	case c == ':':
+		return p.parseByteSequence()
+	case c == '?':
+		return p.parseBoolean()
+	case c == '@':
+		return p.parseDate()
+	case c == '%':
+		return p.parseDisplayString()
+	}
+	return nil, p.errorf("unexpected character")
+}
+
+func (p *parser) parseNumber() (interface{}, error) {
+	start := p.off
+	neg := false
+	if p.peek() == '-' {
+		neg = true
+		p.off++
+	}
+	if !isDigit(p.peek()) {
+		return nil, p.errorf("expected digit")
+	}
+	digitsStart := p.off
+	decimal := false
+	for !p.eof() {
+		c := p.s[p.off]
+		if isDigit(c) {
+			p.off++
+		} else if c == '.' && !decimal {
+			if p.off-digitsStart > 12 {
+				return nil, p.errorf("decimal integer part too long")
+			}
+			decimal = true
+			p.off++
+		} else {
+			break
+		}
+		if !decimal && p.off-digitsStart > 15 {
+			return nil, p.errorf("integer too long")
+		}
+		if decimal && p.off-digitsStart > 16 {
+			return nil, p.errorf("decimal too long")
+		}
+	}
+	num := p.s[digitsStart:p.off]
+	if !decimal {
+		n, err := strconv.ParseInt(num, 10, 64)
+		if err != nil {
+			return nil, &SyntaxError{Offset: start, Msg: "invalid integer"}
+		}
+		if neg {
+			n = -n
+		}
+		return n, nil
+	}
+	if strings.HasSuffix(num, ".") {
+		return nil, &SyntaxError{Offset: start, Msg: "decimal ends with '.'"}
+	}
+	if len(num)-strings.IndexByte(num, '.')-1 > 3 {
+		return nil, &SyntaxError{Offset: start, Msg: "too many fractional digits"}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return nil, &SyntaxError{Offset: start, Msg: "invalid decimal"}
+	}
+	if neg {
+		f = -f
+	}
+	return f, nil
+}
+
+func (p *parser) parseString() (string, error) {
+	p.off++ // '"'
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.off]
+		p.off++
+		switch {
+		case c == '\\':
+			if p.eof() {
+				return "", p.errorf("unterminated string")
+			}
+			next := p.s[p.off]
+			if next != '"' && next != '\\' {
+				return "", p.errorf("invalid escape in string")
+			}
+			b.WriteByte(next)
+			p.off++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid character in string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func isTokenChar(c byte) bool {
+	if isAlpha(c) || isDigit(c) {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~:/", c) >= 0
+}
+
+func (p *parser) parseToken() Token {
+	start := p.off
+	p.off++
+	for !p.eof() && isTokenChar(p.s[p.off]) {
+		p.off++
+	}
+	return Token(p.s[start:p.off])
+}
+
+func (p *parser) parseByteSequence() ([]byte, error) {
+	p.off++ // ':'
+	end := strings.IndexByte(p.s[p.off:], ':')
+	if end < 0 {
+		return nil, p.errorf("unterminated byte sequence")
+	}
+	encoded := p.s[p.off : p.off+end]
+	for i := 0; i < len(encoded); i++ {
+		c := encoded[i]
+		if !isAlpha(c) && !isDigit(c) && c != '+' && c != '/' && c != '=' {
+			return nil, p.errorf("invalid character in byte sequence")
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		// Senders should pad, but recipients may accept unpadded input.
+		if b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "=")); err != nil {
+			return nil, p.errorf("invalid base64 in byte sequence")
+		}
+	}
+	p.off += end + 1
+	return b, nil
+}
+
+func (p *parser) parseBoolean() (bool, error) {
+	p.off++ // '?'
+	switch p.peek() {
+	case '1':
+		p.off++
+		return true, nil
+	case '0':
+		p.off++
+		return false, nil
+	}
+	return false, p.errorf("invalid boolean")
+}
+
+func (p *parser) parseDate() (time.Time, error) {
+	p.off++ // '@'
+	v, err := p.parseNumber()
+	if err != nil {
+		return time.Time{}, err
+	}
+	n, ok := v.(int64)
+	if !ok {
+		return time.Time{}, p.errorf("date must be an integer")
+	}
+	return time.Unix(n, 0).UTC(), nil
+}
+
+func (p *parser) parseDisplayString() (DisplayString, error) {
+	p.off++ // '%'
+	if p.peek() != '"' {
+		return "", p.errorf("expected '\"' after '%'")
+	}
+	p.off++
+	var b []byte
+	for !p.eof() {
+		c := p.s[p.off]
+		p.off++
+		switch {
+		case c < 0x20 || c > 0x7e:
+			return "", p.errorf("invalid character in display string")
+		case c == '%':
+			if p.off+2 > len(p.s) {
+				return "", p.errorf("truncated percent-encoding")
+			}
+			hi, ok1 := lowerHex(p.s[p.off])
+			lo, ok2 := lowerHex(p.s[p.off+1])
+			if !ok1 || !ok2 {
+				return "", p.errorf("invalid percent-encoding")
+			}
+			b = append(b, hi<<4|lo)
+			p.off += 2
+		case c == '"':
+			if !utf8.Valid(b) {
+				return "", p.errorf("invalid UTF-8 in display string")
+			}
+			return DisplayString(b), nil
+		default:
+			b = append(b, c)
+		}
+	}
+	return "", p.errorf("unterminated display string")
+}
+
+func lowerHex(c byte) (byte, bool) {
+	switch {
+	case isDigit(c):
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	}
+	return 0, false
+}
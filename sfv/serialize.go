@@ -0,0 +1,202 @@
+package sfv
+
+import (
+	"encoding/base64"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalList serializes a List.
+func MarshalList(l List) (string, error) {
+	var b strings.Builder
+	for i, m := range l {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := writeMember(&b, m); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// MarshalDictionary serializes a Dictionary. Members whose value is the
+// Boolean true are written without a value.
+func MarshalDictionary(d Dictionary) (string, error) {
+	var b strings.Builder
+	for i, m := range d {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := writeKey(&b, m.Key); err != nil {
+			return "", err
+		}
+		if it, ok := m.Value.(Item); ok && it.Value == true {
+			if err := writeParams(&b, it.Params); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte('=')
+		if err := writeMember(&b, m.Value); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// MarshalItem serializes an Item.
+func MarshalItem(it Item) (string, error) {
+	var b strings.Builder
+	if err := writeItem(&b, it); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeMember(b *strings.Builder, m Member) error {
+	switch m := m.(type) {
+	case Item:
+		return writeItem(b, m)
+	case InnerList:
+		b.WriteByte('(')
+		for i, it := range m.Items {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			if err := writeItem(b, it); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(')')
+		return writeParams(b, m.Params)
+	}
+	return errInvalidValue
+}
+
+func writeItem(b *strings.Builder, it Item) error {
+	if err := writeBareItem(b, it.Value); err != nil {
+		return err
+	}
+	return writeParams(b, it.Params)
+}
+
+func writeParams(b *strings.Builder, params Params) error {
+	for _, p := range params {
+		b.WriteByte(';')
+		if err := writeKey(b, p.Key); err != nil {
+			return err
+		}
+		if p.Value == true {
+			continue
+		}
+		b.WriteByte('=')
+		if err := writeBareItem(b, p.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeKey(b *strings.Builder, key string) error {
+	if key == "" || !isLcalpha(key[0]) && key[0] != '*' {
+		return errInvalidValue
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !isLcalpha(c) && !isDigit(c) && c != '_' && c != '-' && c != '.' && c != '*' {
+			return errInvalidValue
+		}
+	}
+	b.WriteString(key)
+	return nil
+}
+
+const maxInteger = 999999999999999
+
+func writeBareItem(b *strings.Builder, v interface{}) error {
+	switch v := v.(type) {
+	case int:
+		return writeBareItem(b, int64(v))
+	case int64:
+		if v > maxInteger || v < -maxInteger {
+			return errInvalidValue
+		}
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		return writeDecimal(b, v)
+	case string:
+		b.WriteByte('"')
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c < 0x20 || c > 0x7e {
+				return errInvalidValue
+			}
+			if c == '"' || c == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte('"')
+	case Token:
+		if v == "" || !isAlpha(v[0]) && v[0] != '*' {
+			return errInvalidValue
+		}
+		for i := 0; i < len(v); i++ {
+			if !isTokenChar(v[i]) {
+				return errInvalidValue
+			}
+		}
+		b.WriteString(string(v))
+	case []byte:
+		b.WriteByte(':')
+		b.WriteString(base64.StdEncoding.EncodeToString(v))
+		b.WriteByte(':')
+	case bool:
+		if v {
+			b.WriteString("?1")
+		} else {
+			b.WriteString("?0")
+		}
+	case time.Time:
+		b.WriteByte('@')
+		return writeBareItem(b, v.Unix())
+	case DisplayString:
+		const hex = "0123456789abcdef"
+		b.WriteString(`%"`)
+		for i := 0; i < len(v); i++ {
+			c := v[i]
+			if c == '%' || c == '"' || c < 0x20 || c > 0x7e {
+				b.WriteByte('%')
+				b.WriteByte(hex[c>>4])
+				b.WriteByte(hex[c&0xf])
+				continue
+			}
+			b.WriteByte(c)
+		}
+		b.WriteByte('"')
+	default:
+		return errInvalidValue
+	}
+	return nil
+}
+
+// writeDecimal rounds to three fractional digits, ties to even, as required
+// by RFC 9651 section 4.1.5.
+func writeDecimal(b *strings.Builder, f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return errInvalidValue
+	}
+	r := math.RoundToEven(f*1000) / 1000
+	if math.Abs(r) >= 1e12 {
+		return errInvalidValue
+	}
+	s := strconv.FormatFloat(r, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	b.WriteString(s)
+	return nil
+}
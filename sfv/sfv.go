@@ -0,0 +1,88 @@
+// Package sfv parses and serializes Structured Field Values for HTTP (RFC
+// 9651, which obsoletes RFC 8941).
+//
+// Bare item values are represented by the Go types int64 (Integer), float64
+// (Decimal), string (String), Token, []byte (Byte Sequence), bool (Boolean),
+// time.Time (Date) and DisplayString.
+package sfv
+
+import (
+	"errors"
+	"strconv"
+)
+
+// Token is a bare item of type Token.
+type Token string
+
+// DisplayString is a bare item of type Display String: Unicode text that is
+// percent-encoded on the wire.
+type DisplayString string
+
+// Item is a bare item with parameters.
+type Item struct {
+	Value  interface{}
+	Params Params
+}
+
+// InnerList is a parenthesized list of items with parameters.
+type InnerList struct {
+	Items  []Item
+	Params Params
+}
+
+// Member is a member of a List or Dictionary: an Item or an InnerList.
+type Member interface{}
+
+// List is a top-level List field value.
+type List []Member
+
+// DictMember is a single member of a Dictionary.
+type DictMember struct {
+	Key   string
+	Value Member
+}
+
+// Dictionary is a top-level Dictionary field value. Member order is kept.
+type Dictionary []DictMember
+
+// Get returns the value of the last member with the given key, which is the
+// one that counts when keys are repeated.
+func (d Dictionary) Get(key string) (Member, bool) {
+	for i := len(d) - 1; i >= 0; i-- {
+		if d[i].Key == key {
+			return d[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// Param is a single parameter.
+type Param struct {
+	Key   string
+	Value interface{}
+}
+
+// Params is an ordered list of parameters.
+type Params []Param
+
+// Get returns the value of the parameter with the given key.
+func (p Params) Get(key string) (interface{}, bool) {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].Key == key {
+			return p[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// A SyntaxError reports a field value that is not a valid structured field.
+type SyntaxError struct {
+	Offset int // byte offset in the input
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return "sfv: " + e.Msg + " at offset " + strconv.Itoa(e.Offset)
+}
+
+var errInvalidValue = errors.New("sfv: value cannot be serialized")
@@ -0,0 +1,275 @@
+package sfv
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Test cases follow the layout of the httpwg structured-field-tests suite:
+// a raw field value, and the canonical serialization of the parsed value or
+// "" if parsing must fail.
+
+func TestItemConformance(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		canonical string
+	}{
+		// Integers.
+		{"zero", "0", "0"},
+		{"negative zero", "-0", "0"},
+		{"leading zeros", "042", "42"},
+		{"long integer", "123456789012345", "123456789012345"},
+		{"long negative integer", "-123456789012345", "-123456789012345"},
+		{"too long integer", "1234567890123456", ""},
+		{"too long negative integer", "-1234567890123456", ""},
+		{"negative without digits", "-", ""},
+		{"space after minus", "- 42", ""},
+		{"hex", "0x2A", ""},
+
+		// Decimals.
+		{"decimal", "1.5", "1.5"},
+		{"negative decimal", "-1.5", "-1.5"},
+		{"decimal with trailing zeros", "1.500", "1.5"},
+		{"integral decimal", "2.0", "2.0"},
+		{"long decimal", "123456789012.123", "123456789012.123"},
+		{"too long integer part", "1234567890123.0", ""},
+		{"too many fractional digits", "1.1234", ""},
+		{"trailing dot", "1.", ""},
+		{"double dot", "1.2.3", ""},
+		{"leading dot", ".5", ""},
+
+		// Strings.
+		{"string", `"foo bar"`, `"foo bar"`},
+		{"escaped quote", `"a\"b"`, `"a\"b"`},
+		{"escaped backslash", `"a\\b"`, `"a\\b"`},
+		{"bad escape", `"a\b"`, ""},
+		{"non-ASCII string", "\"f\xc3\xbc\"", ""},
+		{"tab in string", "\"a\tb\"", ""},
+		{"unterminated string", `"abc`, ""},
+
+		// Tokens, byte sequences and booleans.
+		{"token", "a/b:c", "a/b:c"},
+		{"star token", "*foo", "*foo"},
+		{"token with digits", "abc123", "abc123"},
+		{"byte sequence", ":aGVsbG8=:", ":aGVsbG8=:"},
+		{"unpadded byte sequence", ":aGVsbG8:", ":aGVsbG8=:"},
+		{"empty byte sequence", "::", "::"},
+		{"bad byte sequence", ":aGVsb*8=:", ""},
+		{"unterminated byte sequence", ":aGVsbG8=", ""},
+		{"true", "?1", "?1"},
+		{"false", "?0", "?0"},
+		{"bad boolean", "?2", ""},
+
+		// Dates.
+		{"date", "@1659578233", "@1659578233"},
+		{"negative date", "@-1659578233", "@-1659578233"},
+		{"decimal date", "@1659578233.12", ""},
+		{"space in date", "@ 1659578233", ""},
+		{"long date", "@1234567890123456", ""},
+
+		// Display strings.
+		{"display string", `%"f%c3%bc%c3%bc"`, `%"f%c3%bc%c3%bc"`},
+		{"display string quote", `%"%22%25"`, `%"%22%25"`},
+		{"unencoded display string", `%"foo bar"`, `%"foo bar"`},
+		{"uppercase hex", `%"f%C3%BC"`, ""},
+		{"invalid UTF-8", `%"%c3"`, ""},
+		{"truncated escape", `%"%c"`, ""},
+		{"raw non-ASCII", "%\"f\xc3\xbc\"", ""},
+		{"missing quote", `%foo`, ""},
+		{"unterminated display string", `%"foo`, ""},
+
+		// Parameters and whitespace.
+		{"parameters", "1;a;b=?0;c=tok", "1;a;b=?0;c=tok"},
+		{"duplicate parameter", "1;a=1;b=2;a=3", "1;a=3;b=2"},
+		{"space before parameter", "1; a=1", "1;a=1"},
+		{"uppercase parameter key", "1;A=1", ""},
+		{"leading and trailing space", "  1  ", "1"},
+		{"leading tab", "\t1", ""},
+		{"trailing garbage", "1 2", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, err := ParseItem(tt.raw)
+			if tt.canonical == "" {
+				if err == nil {
+					t.Errorf("ParseItem(%q) = %#v, want error", tt.raw, it)
+				} else if _, ok := err.(*SyntaxError); !ok {
+					t.Errorf("error %T, want *SyntaxError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseItem(%q): %v", tt.raw, err)
+			}
+			got, err := MarshalItem(it)
+			if err != nil || got != tt.canonical {
+				t.Errorf("MarshalItem(ParseItem(%q)) = %q, %v; want %q", tt.raw, got, err, tt.canonical)
+			}
+		})
+	}
+}
+
+func TestItemValues(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want interface{}
+	}{
+		{"-0", int64(0)},
+		{"42", int64(42)},
+		{"-1.5", -1.5},
+		{`"a\"b"`, `a"b`},
+		{"tok", Token("tok")},
+		{":aGVsbG8=:", []byte("hello")},
+		{"?1", true},
+		{"@1659578233", time.Date(2022, 8, 4, 1, 57, 13, 0, time.UTC)},
+		{`%"f%c3%bc%c3%bc"`, DisplayString("füü")},
+	}
+	for _, tt := range tests {
+		it, err := ParseItem(tt.raw)
+		if err != nil {
+			t.Errorf("ParseItem(%q): %v", tt.raw, err)
+			continue
+		}
+		if !reflect.DeepEqual(it.Value, tt.want) {
+			t.Errorf("ParseItem(%q) = %#v, want %#v", tt.raw, it.Value, tt.want)
+		}
+	}
+}
+
+func TestListConformance(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		canonical string
+	}{
+		{"basic", "1, 42", "1, 42"},
+		{"no whitespace", "1,42", "1, 42"},
+		{"tabs around comma", "1 \t,\t 42", "1, 42"},
+		{"single", "1", "1"},
+		{"empty", "", ""},
+		{"leading comma", ",1", "error"},
+		{"trailing comma", "1, 42,", "error"},
+		{"empty member", "1,,42", "error"},
+		{"inner lists", `(1 2);a, ("x" tok);b=?0, ()`, `(1 2);a, ("x" tok);b=?0, ()`},
+		{"inner list spaces", "(  1   2  )", "(1 2)"},
+		{"inner list without space", "(1,2)", "error"},
+		{"unterminated inner list", "(1 2", "error"},
+		{"item parameters in inner list", "(1;a 2;b=3);c", "(1;a 2;b=3);c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := ParseList(tt.raw)
+			if tt.canonical == "error" {
+				if err == nil {
+					t.Errorf("ParseList(%q) = %#v, want error", tt.raw, l)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseList(%q): %v", tt.raw, err)
+			}
+			got, err := MarshalList(l)
+			if err != nil || got != tt.canonical {
+				t.Errorf("MarshalList(ParseList(%q)) = %q, %v; want %q", tt.raw, got, err, tt.canonical)
+			}
+		})
+	}
+}
+
+func TestDictionaryConformance(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		canonical string
+	}{
+		{"basic", `en="Applepie", da=:w4ZibGV0w6ZydGU=:`, `en="Applepie", da=:w4ZibGV0w6ZydGU=:`},
+		{"boolean members", "a, b;x=1, c=?0", "a, b;x=1, c=?0"},
+		{"explicit true", "a=?1", "a"},
+		{"inner list member", "a=(1 2), b=3", "a=(1 2), b=3"},
+		// A repeated key overwrites the value but keeps the position of
+		// its first occurrence.
+		{"duplicate key", "a=1, b=2, a=3", "a=3, b=2"},
+		{"duplicate key with parameters", "a;x=1, b, a=2;y", "a=2;y, b"},
+		{"uppercase key", "A=1", "error"},
+		{"key starting with digit", "1a=1", "error"},
+		{"star key", "*a=1", "*a=1"},
+		{"trailing comma", "a=1,", "error"},
+		{"space before equals", "a =1", "error"},
+		{"space after equals", "a= 1", "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := ParseDictionary(tt.raw)
+			if tt.canonical == "error" {
+				if err == nil {
+					t.Errorf("ParseDictionary(%q) = %#v, want error", tt.raw, d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDictionary(%q): %v", tt.raw, err)
+			}
+			got, err := MarshalDictionary(d)
+			if err != nil || got != tt.canonical {
+				t.Errorf("MarshalDictionary(ParseDictionary(%q)) = %q, %v; want %q", tt.raw, got, err, tt.canonical)
+			}
+		})
+	}
+
+	d, err := ParseDictionary("a=1, b=2, a=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := d.Get("a"); !ok || m.(Item).Value != int64(3) {
+		t.Errorf(`Get("a") = %v, %v`, m, ok)
+	}
+	if len(d) != 2 || d[0].Key != "a" || d[1].Key != "b" {
+		t.Errorf("members = %v", d)
+	}
+}
+
+func TestSerializeLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		it   Item
+		want string // "" means an error
+	}{
+		{"largest integer", Item{Value: int64(999999999999999)}, "999999999999999"},
+		{"integer too large", Item{Value: int64(1000000000000000)}, ""},
+		{"integer too small", Item{Value: int64(-1000000000000000)}, ""},
+		{"int", Item{Value: 7}, "7"},
+		{"decimal rounds half to even down", Item{Value: 1.0625}, "1.062"},
+		{"decimal rounds half to even up", Item{Value: 1.1875}, "1.188"},
+		{"decimal drops zeros", Item{Value: 1.5}, "1.5"},
+		{"integral decimal", Item{Value: 3.0}, "3.0"},
+		{"largest decimal", Item{Value: 999999999999.999}, "999999999999.999"},
+		{"decimal too large", Item{Value: 1e12}, ""},
+		{"NaN", Item{Value: math.NaN()}, ""},
+		{"infinity", Item{Value: math.Inf(1)}, ""},
+		{"date", Item{Value: time.Date(2022, 8, 4, 1, 57, 13, 0, time.UTC)}, "@1659578233"},
+		{"date too large", Item{Value: time.Unix(1000000000000000, 0)}, ""},
+		{"display string", Item{Value: DisplayString("füü \"%")}, `%"f%c3%bc%c3%bc %22%25"`},
+		{"non-ASCII string", Item{Value: "füü"}, ""},
+		{"token starting with digit", Item{Value: Token("1a")}, ""},
+		{"token with space", Item{Value: Token("a b")}, ""},
+		{"empty token", Item{Value: Token("")}, ""},
+		{"invalid parameter key", Item{Value: true, Params: Params{{Key: "A", Value: true}}}, ""},
+		{"unsupported type", Item{Value: uint8(1)}, ""},
+	}
+	for _, tt := range tests {
+		got, err := MarshalItem(tt.it)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%s: MarshalItem = %q, want error", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: MarshalItem = %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
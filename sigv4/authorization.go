@@ -0,0 +1,75 @@
+package sigv4
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// Authorization is a parsed Signature Version 4 Authorization field.
+type Authorization struct {
+	AccessKeyID   string
+	Scope         Scope
+	SignedHeaders []string // lowercase, sorted
+	Signature     []byte
+}
+
+// ParseAuthorization parses an Authorization field value of the form
+//
+//	AWS4-HMAC-SHA256 Credential=AKID/20130524/us-east-1/s3/aws4_request,
+//	SignedHeaders=host;x-amz-date, Signature=fe5f80f7...
+func ParseAuthorization(s string) (Authorization, error) {
+	var a Authorization
+	s = strings.TrimSpace(s)
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		return a, ErrMalformedAuthorization
+	}
+	if s[:i] != Algorithm {
+		return a, ErrUnsupportedAlgorithm
+	}
+	var hasCredential, hasSignedHeaders, hasSignature bool
+	for _, part := range strings.Split(s[i+1:], ",") {
+		part = strings.TrimSpace(part)
+		j := strings.IndexByte(part, '=')
+		if j < 0 {
+			return a, ErrMalformedAuthorization
+		}
+		k, v := part[:j], part[j+1:]
+		switch k {
+		case "Credential":
+			fields := strings.Split(v, "/")
+			if len(fields) != 5 || fields[4] != "aws4_request" || fields[0] == "" {
+				return a, ErrMalformedAuthorization
+			}
+			a.AccessKeyID = fields[0]
+			a.Scope = Scope{Date: fields[1], Region: fields[2], Service: fields[3]}
+			hasCredential = true
+		case "SignedHeaders":
+			if v == "" {
+				return a, ErrMalformedAuthorization
+			}
+			a.SignedHeaders = signedHeaderNames(strings.Split(v, ";"))
+			hasSignedHeaders = true
+		case "Signature":
+			sig, err := hex.DecodeString(v)
+			if err != nil || len(sig) != 32 {
+				return a, ErrMalformedAuthorization
+			}
+			a.Signature = sig
+			hasSignature = true
+		default:
+			return a, ErrMalformedAuthorization
+		}
+	}
+	if !hasCredential || !hasSignedHeaders || !hasSignature {
+		return a, ErrMalformedAuthorization
+	}
+	return a, nil
+}
+
+// String formats the Authorization field value.
+func (a Authorization) String() string {
+	return Algorithm + " Credential=" + a.AccessKeyID + "/" + a.Scope.String() +
+		", SignedHeaders=" + strings.Join(a.SignedHeaders, ";") +
+		", Signature=" + hex.EncodeToString(a.Signature)
+}
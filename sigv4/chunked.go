@@ -0,0 +1,217 @@
+package sigv4
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DefaultChunkSize is the size of the chunks written by Signer.SignStreaming
+// when Signer.ChunkSize is zero.
+const DefaultChunkSize = 64 << 10
+
+// maxChunkSize bounds the chunks accepted by the verifying reader, which
+// holds a chunk in memory until its signature is checked.
+const maxChunkSize = 16 << 20
+
+var (
+	// ErrMalformedChunk is returned when reading an aws-chunked body that
+	// is not correctly framed.
+	ErrMalformedChunk = errors.New("sigv4: malformed chunk")
+	// ErrChunkSignatureMismatch is returned when reading a chunk whose
+	// signature does not match.
+	ErrChunkSignatureMismatch = errors.New("sigv4: chunk signature mismatch")
+	// ErrPayloadHashMismatch is returned at the end of a body whose
+	// SHA-256 hash or length does not match the signed value.
+	ErrPayloadHashMismatch = errors.New("sigv4: payload hash mismatch")
+)
+
+const chunkSignaturePrefix = ";chunk-signature="
+
+// emptyHash is the SHA-256 hash of the empty string.
+var emptyHash = hashHex(nil)
+
+// ChunkSignature returns the signature of a chunk of an aws-chunked body.
+// prev is the signature of the previous chunk, or the seed signature from
+// the Authorization field for the first chunk.
+func ChunkSignature(key []byte, timestamp string, scope Scope, prev, data []byte) []byte {
+	return hmacSHA256(key, "AWS4-HMAC-SHA256-PAYLOAD\n"+timestamp+"\n"+scope.String()+"\n"+
+		hex.EncodeToString(prev)+"\n"+emptyHash+"\n"+hashHex(data))
+}
+
+// EncodedLength returns the length of the aws-chunked encoding of a body of
+// decodedLength bytes split into chunks of chunkSize bytes.
+func EncodedLength(decodedLength, chunkSize int64) int64 {
+	frame := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))+len(chunkSignaturePrefix)+64+2) + n + 2
+	}
+	full := decodedLength / chunkSize
+	n := full * frame(chunkSize)
+	if rest := decodedLength % chunkSize; rest > 0 {
+		n += frame(rest)
+	}
+	return n + frame(0)
+}
+
+type chunkSigner struct {
+	key       []byte
+	timestamp string
+	scope     Scope
+	prev      []byte
+}
+
+func (s *chunkSigner) sign(data []byte) []byte {
+	s.prev = ChunkSignature(s.key, s.timestamp, s.scope, s.prev, data)
+	return s.prev
+}
+
+// chunkEncoder produces the aws-chunked encoding of src.
+type chunkEncoder struct {
+	src    io.Reader
+	closer io.Closer
+	signer chunkSigner
+	chunk  []byte
+	buf    bytes.Buffer
+	done   bool
+}
+
+func (e *chunkEncoder) Read(p []byte) (int, error) {
+	for e.buf.Len() == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(e.src, e.chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		e.writeChunk(e.chunk[:n])
+		if n < len(e.chunk) {
+			if n > 0 {
+				e.writeChunk(nil)
+			}
+			e.done = true
+		}
+	}
+	return e.buf.Read(p)
+}
+
+func (e *chunkEncoder) writeChunk(data []byte) {
+	e.buf.WriteString(strconv.FormatInt(int64(len(data)), 16))
+	e.buf.WriteString(chunkSignaturePrefix)
+	e.buf.WriteString(hex.EncodeToString(e.signer.sign(data)))
+	e.buf.WriteString("\r\n")
+	e.buf.Write(data)
+	e.buf.WriteString("\r\n")
+}
+
+func (e *chunkEncoder) Close() error {
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
+
+// chunkDecoder decodes and verifies an aws-chunked body. A chunk is only
+// returned after its signature has been checked.
+type chunkDecoder struct {
+	src           *bufio.Reader
+	closer        io.Closer
+	signer        chunkSigner
+	decodedLength int64 // -1 if unknown
+	read          int64
+	chunk         []byte
+	err           error
+}
+
+func (d *chunkDecoder) Read(p []byte) (int, error) {
+	for len(d.chunk) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		d.err = d.next()
+	}
+	n := copy(p, d.chunk)
+	d.chunk = d.chunk[n:]
+	return n, nil
+}
+
+func (d *chunkDecoder) next() error {
+	line, err := d.src.ReadSlice('\n')
+	if err != nil {
+		if err == io.EOF || err == bufio.ErrBufferFull {
+			return ErrMalformedChunk
+		}
+		return err
+	}
+	header := strings.TrimSuffix(string(line), "\r\n")
+	i := strings.Index(header, chunkSignaturePrefix)
+	if i < 0 {
+		return ErrMalformedChunk
+	}
+	size, err := strconv.ParseInt(header[:i], 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return ErrMalformedChunk
+	}
+	sig, err := hex.DecodeString(header[i+len(chunkSignaturePrefix):])
+	if err != nil {
+		return ErrMalformedChunk
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(d.src, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrMalformedChunk
+		}
+		return err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return ErrMalformedChunk
+	}
+	data = data[:size]
+	if !hmac.Equal(d.signer.sign(data), sig) {
+		return ErrChunkSignatureMismatch
+	}
+	d.read += size
+	if size == 0 {
+		if d.decodedLength >= 0 && d.read != d.decodedLength {
+			return ErrPayloadHashMismatch
+		}
+		return io.EOF
+	}
+	d.chunk = data
+	return nil
+}
+
+func (d *chunkDecoder) Close() error {
+	return d.closer.Close()
+}
+
+// hashingReader checks the SHA-256 hash of a body once it is fully read.
+type hashingReader struct {
+	src    io.ReadCloser
+	hash   hash.Hash
+	want   []byte
+	failed bool
+}
+
+func (h *hashingReader) Read(p []byte) (int, error) {
+	if h.failed {
+		return 0, ErrPayloadHashMismatch
+	}
+	n, err := h.src.Read(p)
+	h.hash.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(h.hash.Sum(nil), h.want) {
+		h.failed = true
+		return n, ErrPayloadHashMismatch
+	}
+	return n, err
+}
+
+func (h *hashingReader) Close() error {
+	return h.src.Close()
+}
@@ -0,0 +1,192 @@
+package sigv4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// The "Example Calculations" of the Amazon S3 API reference,
+// "Signature Calculations for the Authorization Header: Transferring
+// Payload in Multiple Chunks": a 66560 byte object of 'a' sent in chunks of
+// 64 KiB.
+const (
+	streamingSeed   = "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9"
+	streamingChunk1 = "ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648"
+	streamingChunk2 = "0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497"
+	streamingFinal  = "b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9"
+)
+
+func streamingBody() string {
+	return "10000;chunk-signature=" + streamingChunk1 + "\r\n" + strings.Repeat("a", 65536) + "\r\n" +
+		"400;chunk-signature=" + streamingChunk2 + "\r\n" + strings.Repeat("a", 1024) + "\r\n" +
+		"0;chunk-signature=" + streamingFinal + "\r\n\r\n"
+}
+
+func streamingRequest(body string) *http.Request {
+	r := httptest.NewRequest("PUT", "http://s3.amazonaws.com/examplebucket/chunkObject.txt", strings.NewReader(body))
+	r.Header.Set("X-Amz-Storage-Class", "REDUCED_REDUNDANCY")
+	return r
+}
+
+func TestSignStreaming(t *testing.T) {
+	if got := EncodedLength(66560, 65536); got != 66824 {
+		t.Errorf("EncodedLength = %d, want 66824", got)
+	}
+	if got := EncodedLength(0, 65536); got != 86 {
+		t.Errorf("EncodedLength of an empty body = %d, want 86", got)
+	}
+
+	r := streamingRequest(strings.Repeat("a", 66560))
+	s := exampleSigner()
+	s.ChunkSize = 65536
+	if err := s.SignStreaming(r); err != nil {
+		t.Fatal(err)
+	}
+	if got := signature(t, r); got != streamingSeed {
+		t.Errorf("seed signature = %s, want %s", got, streamingSeed)
+	}
+	auth, _ := ParseAuthorization(r.Header.Get("Authorization"))
+	const wantSigned = "content-encoding;content-length;host;x-amz-content-sha256;x-amz-date;x-amz-decoded-content-length;x-amz-storage-class"
+	if got := strings.Join(auth.SignedHeaders, ";"); got != wantSigned {
+		t.Errorf("SignedHeaders = %s", got)
+	}
+	if r.ContentLength != 66824 || r.Header.Get(HeaderDecodedContentLength) != "66560" ||
+		r.Header.Get("Content-Encoding") != "aws-chunked" || r.Header.Get(HeaderContentSHA256) != StreamingPayload {
+		t.Errorf("ContentLength %d, fields %v", r.ContentLength, r.Header)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil || string(b) != streamingBody() {
+		t.Errorf("encoded body differs from the example (%d bytes, %v)", len(b), err)
+	}
+
+	r = httptest.NewRequest("PUT", "http://s3.amazonaws.com/b/k", nil)
+	r.ContentLength = -1
+	if err := s.SignStreaming(r); err != ErrUnknownLength {
+		t.Errorf("SignStreaming without length = %v", err)
+	}
+	if got := addCoding("gzip"); got != "aws-chunked,gzip" {
+		t.Errorf("addCoding = %q", got)
+	}
+}
+
+func TestVerifyStreaming(t *testing.T) {
+	request := func(body string, decodedLength string) *http.Request {
+		r := streamingRequest(body)
+		r.Header.Set("Content-Encoding", "aws-chunked")
+		r.Header.Set(HeaderDecodedContentLength, decodedLength)
+		r.Header.Set(HeaderContentSHA256, StreamingPayload)
+		r.Header.Set(HeaderDate, "20130524T000000Z")
+		r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+exampleKeyID+"/20130524/us-east-1/s3/aws4_request, "+
+			"SignedHeaders=content-encoding;content-length;host;x-amz-content-sha256;x-amz-date;x-amz-decoded-content-length;x-amz-storage-class, "+
+			"Signature="+streamingSeed)
+		// The announced length is signed; truncated bodies keep it.
+		r.ContentLength = 66824
+		return r
+	}
+	body := streamingBody()
+	if len(body) != 66824 {
+		t.Fatalf("example body has %d bytes", len(body))
+	}
+
+	r := request(body, "66560")
+	if _, err := exampleVerifier().Verify(r); err != nil {
+		t.Fatalf("Verify = %v", err)
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil || string(b) != strings.Repeat("a", 66560) {
+		t.Errorf("decoded %d bytes, %v", len(b), err)
+	}
+
+	// The decoded length is signed, so changing it breaks the seed.
+	if _, err := exampleVerifier().Verify(request(body, "66561")); err != ErrSignatureMismatch {
+		t.Errorf("altered decoded length: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		n    int // bytes readable before the error
+		err  error
+	}{
+		{"tampered first chunk", strings.Replace(body, "aaaa", "aaab", 1), 0, ErrChunkSignatureMismatch},
+		{"tampered second chunk", body[:66000] + "b" + body[66001:], 65536, ErrChunkSignatureMismatch},
+		{"swapped final signature", strings.Replace(body, streamingFinal, streamingChunk2, 1), 66560, ErrChunkSignatureMismatch},
+		{"missing final chunk", body[:strings.LastIndex(body, "0;chunk")], 66560, ErrMalformedChunk},
+		{"truncated chunk", body[:1000], 0, ErrMalformedChunk},
+		{"bad chunk size", strings.Replace(body, "400;", "4g0;", 1), 65536, ErrMalformedChunk},
+		{"missing CRLF", strings.Replace(body, "a\r\n400;", "aX\n400;", 1), 0, ErrMalformedChunk},
+	}
+	for _, tt := range tests {
+		r := request(tt.body, "66560")
+		if _, err := exampleVerifier().Verify(r); err != nil {
+			t.Errorf("%s: Verify = %v", tt.name, err)
+			continue
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if len(b) != tt.n || err != tt.err {
+			t.Errorf("%s: read %d bytes, %v; want %d, %v", tt.name, len(b), err, tt.n, tt.err)
+		}
+	}
+}
+
+func TestStreamingRoundTrip(t *testing.T) {
+	tests := []struct {
+		body      string
+		chunkSize int64
+	}{
+		{"", 8},
+		{"hello", 8},
+		{"exactly8", 8},
+		{"sixteen bytes!!!", 8},
+		{strings.Repeat("x", 100), 7},
+	}
+	for _, tt := range tests {
+		r := streamingRequest(tt.body)
+		s := exampleSigner()
+		s.ChunkSize = tt.chunkSize
+		if err := s.SignStreaming(r); err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := ioutil.ReadAll(r.Body)
+		if int64(len(encoded)) != r.ContentLength {
+			t.Errorf("%q: encoded %d bytes, ContentLength %d", tt.body, len(encoded), r.ContentLength)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(encoded))
+		if _, err := exampleVerifier().Verify(r); err != nil {
+			t.Fatalf("%q: Verify = %v", tt.body, err)
+		}
+		if b, err := ioutil.ReadAll(r.Body); string(b) != tt.body || err != nil {
+			t.Errorf("%q: decoded %q, %v", tt.body, b, err)
+		}
+	}
+
+	// A signer that announced more bytes than the body holds produces
+	// valid chunks whose total does not match the decoded length.
+	r := streamingRequest("hello")
+	r.ContentLength = 10
+	if err := exampleSigner().SignStreaming(r); err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := ioutil.ReadAll(r.Body)
+	r.Body = ioutil.NopCloser(bytes.NewReader(encoded))
+	if r.Header.Get(HeaderDecodedContentLength) != strconv.Itoa(10) {
+		t.Fatalf("decoded length %q", r.Header.Get(HeaderDecodedContentLength))
+	}
+	if _, err := exampleVerifier().Verify(r); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadAll(r.Body); string(b) != "hello" || err != ErrPayloadHashMismatch {
+		t.Errorf("decoded length mismatch: %q, %v", b, err)
+	}
+	if _, err := r.Body.Read(make([]byte, 1)); err != ErrPayloadHashMismatch {
+		t.Errorf("read after mismatch: %v", err)
+	}
+	if err := r.Body.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+}
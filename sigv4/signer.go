@@ -0,0 +1,125 @@
+package sigv4
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownLength is returned by SignStreaming for requests without a
+// known ContentLength.
+var ErrUnknownLength = errors.New("sigv4: streaming requires a known content length")
+
+// Signer signs requests with a set of credentials. Host, Content-Type,
+// Content-MD5 and every X-Amz-* field are always signed.
+type Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // sent as X-Amz-Security-Token when set
+
+	Region  string
+	Service string
+
+	// DisableURIPathEscaping signs the path as sent instead of escaping
+	// it again. S3 requires it.
+	DisableURIPathEscaping bool
+
+	// SignedHeaders lists additional fields to sign when present.
+	SignedHeaders []string
+
+	// ChunkSize is the chunk size of SignStreaming. Zero selects
+	// DefaultChunkSize.
+	ChunkSize int64
+
+	// Now returns the signing time. Nil selects time.Now.
+	Now func() time.Time
+}
+
+// Sign sets X-Amz-Date, X-Amz-Content-Sha256 and Authorization on r.
+// payloadHash is PayloadHash of the body or UnsignedPayload.
+func (s *Signer) Sign(r *http.Request, payloadHash string) {
+	s.sign(r, payloadHash)
+}
+
+// SignStreaming signs r with a streaming payload and replaces its body with
+// the aws-chunked encoding, signing each chunk as it is read. r's
+// ContentLength must hold the length of the original body.
+func (s *Signer) SignStreaming(r *http.Request) error {
+	if r.ContentLength < 0 || r.ContentLength > 0 && r.Body == nil {
+		return ErrUnknownLength
+	}
+	chunkSize := s.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	decoded := r.ContentLength
+	r.Header.Set(HeaderDecodedContentLength, strconv.FormatInt(decoded, 10))
+	r.Header.Set("Content-Encoding", addCoding(r.Header.Get("Content-Encoding")))
+	r.ContentLength = EncodedLength(decoded, chunkSize)
+	r.Header.Del("Content-Length")
+	signer := s.sign(r, StreamingPayload, "content-encoding", "content-length")
+
+	var src io.Reader = http.NoBody
+	var closer io.Closer
+	if r.Body != nil {
+		src, closer = r.Body, r.Body
+	}
+	r.Body = &chunkEncoder{src: src, closer: closer, signer: signer, chunk: make([]byte, chunkSize)}
+	r.GetBody = nil
+	return nil
+}
+
+// addCoding prepends aws-chunked to a Content-Encoding value.
+func addCoding(v string) string {
+	if v == "" {
+		return "aws-chunked"
+	}
+	if strings.HasPrefix(v, "aws-chunked") {
+		return v
+	}
+	return "aws-chunked," + v
+}
+
+func (s *Signer) sign(r *http.Request, payloadHash string, extra ...string) chunkSigner {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	timestamp := t.Format(TimeFormat)
+	r.Header.Del("Authorization")
+	r.Header.Set(HeaderDate, timestamp)
+	r.Header.Set(HeaderContentSHA256, payloadHash)
+	if s.SessionToken != "" {
+		r.Header.Set(HeaderSecurityToken, s.SessionToken)
+	}
+
+	names := append([]string{"host"}, extra...)
+	for name := range r.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "content-md5" {
+			names = append(names, lower)
+		}
+	}
+	for _, name := range s.SignedHeaders {
+		if len(headerValues(r, strings.ToLower(name))) > 0 {
+			names = append(names, name)
+		}
+	}
+	signed := signedHeaderNames(names)
+
+	scope := Scope{Date: t.Format(DateFormat), Region: s.Region, Service: s.Service}
+	key := SigningKey(s.SecretAccessKey, scope)
+	canonical := CanonicalRequest(r, signed, payloadHash, s.DisableURIPathEscaping)
+	sig := hmacSHA256(key, StringToSign(timestamp, scope, canonical))
+	r.Header.Set("Authorization", Authorization{
+		AccessKeyID:   s.AccessKeyID,
+		Scope:         scope,
+		SignedHeaders: signed,
+		Signature:     sig,
+	}.String())
+	return chunkSigner{key: key, timestamp: timestamp, scope: scope, prev: sig}
+}
@@ -0,0 +1,225 @@
+// Package sigv4 signs and verifies HTTP requests with AWS Signature Version
+// 4 carried in the Authorization field.
+//
+// Signer adds X-Amz-Date, X-Amz-Content-Sha256 and Authorization to
+// outgoing requests, optionally re-encoding the body with aws-chunked
+// streaming signatures. Verifier checks incoming requests the way S3 and
+// its compatible stand-ins do, including hashed, unsigned and streaming
+// payloads:
+//
+//	v := &sigv4.Verifier{Secret: lookup, DisableURIPathEscaping: true}
+//	auth, err := v.Verify(r)
+//
+// Presigned URLs, which carry the signature in the query, are not
+// supported.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/palsivertsen/gohttpfields/query"
+)
+
+// Algorithm is the signing algorithm named in Authorization fields.
+const Algorithm = "AWS4-HMAC-SHA256"
+
+// Special X-Amz-Content-Sha256 values.
+const (
+	// UnsignedPayload excludes the body from the signature.
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+	// StreamingPayload announces an aws-chunked body whose chunks carry
+	// their own signatures.
+	StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+// Field names used by Signature Version 4.
+const (
+	HeaderDate                 = "X-Amz-Date"
+	HeaderContentSHA256        = "X-Amz-Content-Sha256"
+	HeaderSecurityToken        = "X-Amz-Security-Token"
+	HeaderDecodedContentLength = "X-Amz-Decoded-Content-Length"
+)
+
+// Layouts of the request timestamp and the scope date.
+const (
+	TimeFormat = "20060102T150405Z"
+	DateFormat = "20060102"
+)
+
+var (
+	// ErrMissingAuthorization is returned for requests without an
+	// Authorization field.
+	ErrMissingAuthorization = errors.New("sigv4: missing authorization")
+	// ErrMalformedAuthorization is returned for Authorization fields that
+	// cannot be parsed.
+	ErrMalformedAuthorization = errors.New("sigv4: malformed authorization")
+	// ErrUnsupportedAlgorithm is returned for Authorization fields using
+	// an algorithm other than AWS4-HMAC-SHA256.
+	ErrUnsupportedAlgorithm = errors.New("sigv4: unsupported algorithm")
+	// ErrSignatureMismatch is returned when the signature does not match
+	// the request.
+	ErrSignatureMismatch = errors.New("sigv4: signature mismatch")
+)
+
+// Scope is the credential scope of a signature.
+type Scope struct {
+	Date    string // YYYYMMDD
+	Region  string
+	Service string
+}
+
+// String returns the scope as used in the string to sign.
+func (s Scope) String() string {
+	return s.Date + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+// SigningKey derives the signing key of secret for scope.
+func SigningKey(secret string, scope Scope) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), scope.Date)
+	k = hmacSHA256(k, scope.Region)
+	k = hmacSHA256(k, scope.Service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+// StringToSign returns the string to sign for a canonical request made at
+// timestamp, formatted with TimeFormat.
+func StringToSign(timestamp string, scope Scope, canonicalRequest string) string {
+	return Algorithm + "\n" + timestamp + "\n" + scope.String() + "\n" + hashHex([]byte(canonicalRequest))
+}
+
+// PayloadHash returns the X-Amz-Content-Sha256 value of body.
+func PayloadHash(body []byte) string {
+	return hashHex(body)
+}
+
+// CanonicalRequest returns the canonical request of r with the given
+// signed headers, which must be lowercase and sorted, and payload hash.
+// The path is URI-encoded a second time unless disablePathEscaping is
+// set, as S3 requires.
+func CanonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, disablePathEscaping bool) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	b.WriteString(canonicalURI(r, disablePathEscaping))
+	b.WriteByte('\n')
+	b.WriteString(CanonicalQuery(r.URL.RawQuery))
+	b.WriteByte('\n')
+	b.WriteString(CanonicalHeaders(r, signedHeaders))
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(signedHeaders, ";"))
+	b.WriteByte('\n')
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+func canonicalURI(r *http.Request, disablePathEscaping bool) string {
+	path := r.URL.EscapedPath()
+	if r.URL.Opaque != "" {
+		// Opaque holds "//host/path" for requests built with a
+		// pre-escaped path.
+		path = r.URL.Opaque
+		if strings.HasPrefix(path, "//") {
+			if i := strings.IndexByte(path[2:], '/'); i >= 0 {
+				path = path[2+i:]
+			} else {
+				path = "/"
+			}
+		}
+	}
+	if path == "" {
+		return "/"
+	}
+	if disablePathEscaping {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = query.SigV4Escaping.Escape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// CanonicalQuery returns the canonical query string of rawQuery. A '+' is
+// taken literally, and the X-Amz-Signature parameter is left out.
+func CanonicalQuery(rawQuery string) string {
+	q := query.ParseOptions{LiteralPlus: true}.Parse(rawQuery)
+	params := q.Params[:0:0]
+	for _, p := range q.Params {
+		if p.Key != "X-Amz-Signature" {
+			params = append(params, p)
+		}
+	}
+	return query.Encoder{Escaping: query.SigV4Escaping}.Encode(params)
+}
+
+// CanonicalHeaders returns the canonical headers block of r for the signed
+// header names: each lowercase name followed by its values with surrounding
+// whitespace removed and inner runs of spaces collapsed, one per line. Host
+// and Content-Length are taken from the request when not in its header.
+func CanonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var b strings.Builder
+	for _, name := range signedHeaders {
+		b.WriteString(name)
+		b.WriteByte(':')
+		values := headerValues(r, name)
+		for i, v := range values {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(strings.Join(strings.Fields(v), " "))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func headerValues(r *http.Request, name string) []string {
+	if values := r.Header[http.CanonicalHeaderKey(name)]; len(values) > 0 {
+		return values
+	}
+	switch name {
+	case "host":
+		if r.Host != "" {
+			return []string{r.Host}
+		}
+		return []string{r.URL.Host}
+	case "content-length":
+		if r.ContentLength >= 0 {
+			return []string{strconv.FormatInt(r.ContentLength, 10)}
+		}
+	}
+	return nil
+}
+
+// signedHeaderNames returns the lowercase, sorted and deduplicated names.
+func signedHeaderNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var out []string
+	for _, n := range names {
+		n = strings.ToLower(n)
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
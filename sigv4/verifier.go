@@ -0,0 +1,228 @@
+package sigv4
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults applied when the corresponding Verifier field is zero.
+const (
+	DefaultTolerance   = 15 * time.Minute
+	DefaultMaxBodySize = 10 << 20
+)
+
+var (
+	// ErrRequestTimeSkewed is returned when the request time is outside
+	// the tolerance window.
+	ErrRequestTimeSkewed = errors.New("sigv4: request time too skewed")
+	// ErrMissingDate is returned for requests without a valid X-Amz-Date
+	// or Date field.
+	ErrMissingDate = errors.New("sigv4: missing request date")
+	// ErrScopeMismatch is returned when the credential scope does not
+	// match the request date or the verifier's region and service.
+	ErrScopeMismatch = errors.New("sigv4: credential scope mismatch")
+	// ErrUnsignedField is returned when Host or X-Amz-Content-Sha256 is
+	// not among the signed headers.
+	ErrUnsignedField = errors.New("sigv4: required field not signed")
+	// ErrUnsupportedPayload is returned for X-Amz-Content-Sha256 values
+	// that are neither a hash, UNSIGNED-PAYLOAD nor
+	// STREAMING-AWS4-HMAC-SHA256-PAYLOAD.
+	ErrUnsupportedPayload = errors.New("sigv4: unsupported payload type")
+	// ErrBodyTooLarge is returned when a body that must be hashed exceeds
+	// MaxBodySize.
+	ErrBodyTooLarge = errors.New("sigv4: body too large")
+)
+
+// Verifier checks the signatures of incoming requests.
+type Verifier struct {
+	// Secret returns the secret access key of an access key id. Its
+	// errors are returned by Verify unchanged.
+	Secret func(accessKeyID string) (string, error)
+
+	// Region and Service, when set, must match the credential scope.
+	Region  string
+	Service string
+
+	// DisableURIPathEscaping must match the signer's setting; S3 clients
+	// set it.
+	DisableURIPathEscaping bool
+
+	// Tolerance is the maximum difference between the request time and
+	// the current time. Zero selects DefaultTolerance; a negative value
+	// disables the check.
+	Tolerance time.Duration
+
+	// MaxBodySize limits the bodies read to compute the payload hash of
+	// requests without X-Amz-Content-Sha256. Zero selects
+	// DefaultMaxBodySize; a negative value disables the limit.
+	MaxBodySize int64
+
+	// Now returns the current time. Nil selects time.Now.
+	Now func() time.Time
+}
+
+// Verify checks the Authorization field of r and returns it. The payload is
+// verified as it is read: r.Body is replaced by a reader that fails with
+// ErrPayloadHashMismatch at the end of a body not matching its hash, or
+// that decodes an aws-chunked body and fails with
+// ErrChunkSignatureMismatch at the first chunk with a bad signature.
+// Handlers must read the body to the end before acting on it.
+func (v *Verifier) Verify(r *http.Request) (Authorization, error) {
+	value := r.Header.Get("Authorization")
+	if value == "" {
+		return Authorization{}, ErrMissingAuthorization
+	}
+	auth, err := ParseAuthorization(value)
+	if err != nil {
+		return auth, err
+	}
+	if v.Region != "" && auth.Scope.Region != v.Region ||
+		v.Service != "" && auth.Scope.Service != v.Service {
+		return auth, ErrScopeMismatch
+	}
+	if !contains(auth.SignedHeaders, "host") ||
+		r.Header.Get(HeaderContentSHA256) != "" && !contains(auth.SignedHeaders, "x-amz-content-sha256") {
+		return auth, ErrUnsignedField
+	}
+
+	t, err := requestTime(r.Header)
+	if err != nil {
+		return auth, err
+	}
+	if auth.Scope.Date != t.Format(DateFormat) {
+		return auth, ErrScopeMismatch
+	}
+	if err := v.checkTime(t); err != nil {
+		return auth, err
+	}
+
+	secret, err := v.Secret(auth.AccessKeyID)
+	if err != nil {
+		return auth, err
+	}
+
+	payloadHash := r.Header.Get(HeaderContentSHA256)
+	if payloadHash == "" {
+		body, err := v.readBody(r)
+		if err != nil {
+			return auth, err
+		}
+		payloadHash = PayloadHash(body)
+	}
+
+	timestamp := t.Format(TimeFormat)
+	key := SigningKey(secret, auth.Scope)
+	canonical := CanonicalRequest(r, auth.SignedHeaders, payloadHash, v.DisableURIPathEscaping)
+	if !hmac.Equal(hmacSHA256(key, StringToSign(timestamp, auth.Scope, canonical)), auth.Signature) {
+		return auth, ErrSignatureMismatch
+	}
+
+	switch payloadHash {
+	case UnsignedPayload:
+	case StreamingPayload:
+		decoded := int64(-1)
+		if n, err := strconv.ParseInt(r.Header.Get(HeaderDecodedContentLength), 10, 64); err == nil && n >= 0 {
+			decoded = n
+		}
+		if r.Body == nil {
+			r.Body = http.NoBody
+		}
+		r.Body = &chunkDecoder{
+			src:           bufio.NewReader(r.Body),
+			closer:        r.Body,
+			signer:        chunkSigner{key: key, timestamp: timestamp, scope: auth.Scope, prev: auth.Signature},
+			decodedLength: decoded,
+		}
+	default:
+		want, err := hex.DecodeString(payloadHash)
+		if err != nil || len(want) != sha256.Size {
+			return auth, ErrUnsupportedPayload
+		}
+		if r.Body == nil {
+			r.Body = http.NoBody
+		}
+		r.Body = &hashingReader{src: r.Body, hash: sha256.New(), want: want}
+	}
+	return auth, nil
+}
+
+// requestTime returns the time of X-Amz-Date or, failing that, Date.
+func requestTime(h http.Header) (time.Time, error) {
+	if v := h.Get(HeaderDate); v != "" {
+		t, err := time.Parse(TimeFormat, v)
+		if err != nil {
+			return t, ErrMissingDate
+		}
+		return t, nil
+	}
+	t, err := http.ParseTime(h.Get("Date"))
+	if err != nil {
+		return t, ErrMissingDate
+	}
+	return t.UTC(), nil
+}
+
+func (v *Verifier) checkTime(t time.Time) error {
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	if tolerance < 0 {
+		return nil
+	}
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	d := now().Sub(t)
+	if d > tolerance || d < -tolerance {
+		return ErrRequestTimeSkewed
+	}
+	return nil
+}
+
+// readBody buffers the body of r and rewinds it.
+func (v *Verifier) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	limit := v.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxBodySize
+	}
+	var (
+		body []byte
+		err  error
+	)
+	if limit < 0 {
+		body, err = ioutil.ReadAll(r.Body)
+	} else {
+		body, err = ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, limit))
+		if err != nil && int64(len(body)) >= limit {
+			err = ErrBodyTooLarge
+		}
+	}
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
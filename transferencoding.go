@@ -0,0 +1,46 @@
+package httpfields
+
+import (
+	"errors"
+	"strings"
+)
+
+// ContentTransferEncoding is a Content-Transfer-Encoding field value (RFC 2045
+// section 6).
+type ContentTransferEncoding string
+
+// Content transfer encodings defined by RFC 2045.
+const (
+	Encoding7Bit            ContentTransferEncoding = "7bit"
+	Encoding8Bit            ContentTransferEncoding = "8bit"
+	EncodingBinary          ContentTransferEncoding = "binary"
+	EncodingQuotedPrintable ContentTransferEncoding = "quoted-printable"
+	EncodingBase64          ContentTransferEncoding = "base64"
+)
+
+var errUnknownEncoding = errors.New("unknown encoding")
+
+// ParseContentTransferEncoding parses a Content-Transfer-Encoding field value.
+// The standard mechanisms and "x-" extension tokens are accepted; the result
+// is lower-cased.
+func ParseContentTransferEncoding(s string) (ContentTransferEncoding, error) {
+	v := strings.ToLower(TrimOWS(s))
+	switch e := ContentTransferEncoding(v); e {
+	case Encoding7Bit, Encoding8Bit, EncodingBinary, EncodingQuotedPrintable, EncodingBase64:
+		return e, nil
+	}
+	if strings.HasPrefix(v, "x-") && IsToken(v) {
+		return ContentTransferEncoding(v), nil
+	}
+	return "", &ParseError{Field: "Content-Transfer-Encoding", Value: s, Err: errUnknownEncoding}
+}
+
+// IsIdentity reports whether e leaves the content unchanged, which is the case
+// for 7bit, 8bit, binary and the empty default.
+func (e ContentTransferEncoding) IsIdentity() bool {
+	switch e {
+	case "", Encoding7Bit, Encoding8Bit, EncodingBinary:
+		return true
+	}
+	return false
+}
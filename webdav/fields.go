@@ -0,0 +1,197 @@
+// Package webdav parses the request fields of WebDAV (RFC 4918): Depth,
+// Destination, Overwrite, Lock-Token, Timeout and If, and evaluates If
+// fields against the lock tokens and entity tags of the resources they
+// name.
+package webdav
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+var (
+	// ErrInvalidDepth is returned for Depth values other than 0, 1 and
+	// infinity.
+	ErrInvalidDepth = errors.New("webdav: invalid Depth")
+	// ErrInvalidDestination is returned for Destination values that are
+	// not an absolute URI or absolute path.
+	ErrInvalidDestination = errors.New("webdav: invalid Destination")
+	// ErrForeignDestination is returned by Destination for URIs naming
+	// another server.
+	ErrForeignDestination = errors.New("webdav: Destination on another server")
+	// ErrInvalidOverwrite is returned for Overwrite values other than T
+	// and F.
+	ErrInvalidOverwrite = errors.New("webdav: invalid Overwrite")
+	// ErrInvalidLockToken is returned for Lock-Token values that are not a
+	// Coded-URL.
+	ErrInvalidLockToken = errors.New("webdav: invalid Lock-Token")
+	// ErrInvalidTimeout is returned for malformed Timeout values.
+	ErrInvalidTimeout = errors.New("webdav: invalid Timeout")
+)
+
+// Depth is the value of a Depth field.
+type Depth int
+
+// Depth values. DepthInfinity applies a method to a collection and all of
+// its descendants.
+const (
+	Depth0        Depth = 0
+	Depth1        Depth = 1
+	DepthInfinity Depth = -1
+)
+
+// ParseDepth parses a Depth field value. The token "infinity" is matched
+// case-insensitively.
+func ParseDepth(s string) (Depth, error) {
+	switch s = httpfields.TrimOWS(s); {
+	case s == "0":
+		return Depth0, nil
+	case s == "1":
+		return Depth1, nil
+	case strings.EqualFold(s, "infinity"):
+		return DepthInfinity, nil
+	}
+	return 0, ErrInvalidDepth
+}
+
+// String formats d as a field value.
+func (d Depth) String() string {
+	if d == DepthInfinity {
+		return "infinity"
+	}
+	return strconv.Itoa(int(d))
+}
+
+// ParseDestination parses a Destination field value, an absolute URI or an
+// absolute path.
+func ParseDestination(s string) (*url.URL, error) {
+	u, err := url.Parse(httpfields.TrimOWS(s))
+	if err != nil || u.Fragment != "" || u.Opaque != "" {
+		return nil, ErrInvalidDestination
+	}
+	if u.IsAbs() {
+		if u.Host == "" {
+			return nil, ErrInvalidDestination
+		}
+		return u, nil
+	}
+	if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return nil, ErrInvalidDestination
+	}
+	return u, nil
+}
+
+// Destination returns the Destination of r resolved against the request
+// URL. Destinations on another host are rejected with
+// ErrForeignDestination, which servers answer with 502 (Bad Gateway).
+func Destination(r *http.Request) (*url.URL, error) {
+	d, err := ParseDestination(r.Header.Get("Destination"))
+	if err != nil {
+		return nil, err
+	}
+	if d.IsAbs() && !strings.EqualFold(d.Host, r.Host) {
+		return nil, ErrForeignDestination
+	}
+	return d, nil
+}
+
+// ParseOverwrite parses an Overwrite field value. Use Overwrite to apply
+// the default of a missing field.
+func ParseOverwrite(s string) (bool, error) {
+	switch httpfields.TrimOWS(s) {
+	case "T":
+		return true, nil
+	case "F":
+		return false, nil
+	}
+	return false, ErrInvalidOverwrite
+}
+
+// Overwrite returns the Overwrite field of r. A missing field means true
+// (RFC 4918 section 10.6).
+func Overwrite(r *http.Request) (bool, error) {
+	v, ok := r.Header["Overwrite"]
+	if !ok {
+		return true, nil
+	}
+	if len(v) != 1 {
+		return false, ErrInvalidOverwrite
+	}
+	return ParseOverwrite(v[0])
+}
+
+// ParseLockToken parses a Lock-Token field value and returns the lock
+// token URI without its angle brackets.
+func ParseLockToken(s string) (string, error) {
+	token, rest, ok := consumeCodedURL(httpfields.TrimOWS(s))
+	if !ok || rest != "" {
+		return "", ErrInvalidLockToken
+	}
+	return token, nil
+}
+
+// FormatLockToken formats a lock token URI as a Lock-Token field value.
+func FormatLockToken(token string) string {
+	return "<" + token + ">"
+}
+
+// consumeCodedURL consumes a Coded-URL, "<" absolute-URI ">".
+func consumeCodedURL(s string) (uri, rest string, ok bool) {
+	if !strings.HasPrefix(s, "<") {
+		return "", s, false
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 2 || strings.ContainsAny(s[1:end], " \t<") {
+		return "", s, false
+	}
+	return s[1:end], s[end+1:], true
+}
+
+// TimeoutInfinite is the Timeout returned for the Infinite time type.
+const TimeoutInfinite time.Duration = -1
+
+// maxTimeoutSeconds is the largest DAVTimeOutVal, 2^32 - 1.
+const maxTimeoutSeconds = 1<<32 - 1
+
+// ParseTimeout parses a Timeout field value, a list of "Second-n" and
+// "Infinite" entries in order of the client's preference.
+func ParseTimeout(s string) ([]time.Duration, error) {
+	var out []time.Duration
+	for _, v := range httpfields.SplitList(s) {
+		switch {
+		case strings.EqualFold(v, "Infinite"):
+			out = append(out, TimeoutInfinite)
+		case len(v) > 7 && strings.EqualFold(v[:7], "Second-"):
+			n, err := strconv.ParseUint(v[7:], 10, 64)
+			if err != nil {
+				return nil, ErrInvalidTimeout
+			}
+			if n > maxTimeoutSeconds {
+				n = maxTimeoutSeconds
+			}
+			out = append(out, time.Duration(n)*time.Second)
+		default:
+			return nil, ErrInvalidTimeout
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrInvalidTimeout
+	}
+	return out, nil
+}
+
+// FormatTimeout formats a lock timeout as a Timeout value, as used in the
+// timeout element of lock responses. Durations are rounded down to whole
+// seconds.
+func FormatTimeout(d time.Duration) string {
+	if d < 0 {
+		return "Infinite"
+	}
+	return "Second-" + strconv.FormatInt(int64(d/time.Second), 10)
+}
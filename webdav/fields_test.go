@@ -0,0 +1,163 @@
+package webdav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDepth(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Depth
+		err  error
+	}{
+		{"0", Depth0, nil},
+		{"1", Depth1, nil},
+		{"infinity", DepthInfinity, nil},
+		{" Infinity ", DepthInfinity, nil},
+		{"", 0, ErrInvalidDepth},
+		{"2", 0, ErrInvalidDepth},
+		{"inf", 0, ErrInvalidDepth},
+	}
+	for _, tt := range tests {
+		got, err := ParseDepth(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseDepth(%q) = %v, %v; want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+	if DepthInfinity.String() != "infinity" || Depth1.String() != "1" {
+		t.Errorf("String = %s, %s", DepthInfinity, Depth1)
+	}
+}
+
+func TestOverwrite(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+		err  error
+	}{
+		{"T", true, nil},
+		{"F", false, nil},
+		{" F ", false, nil},
+		{"", false, ErrInvalidOverwrite},
+		{"t", false, ErrInvalidOverwrite},
+		{"true", false, ErrInvalidOverwrite},
+	}
+	for _, tt := range tests {
+		got, err := ParseOverwrite(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseOverwrite(%q) = %v, %v; want %v, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+
+	request := func(values ...string) *http.Request {
+		r := httptest.NewRequest("COPY", "/a", nil)
+		for _, v := range values {
+			r.Header.Add("Overwrite", v)
+		}
+		return r
+	}
+	requests := []struct {
+		r    *http.Request
+		want bool
+		err  error
+	}{
+		{request(), true, nil},
+		{request("T"), true, nil},
+		{request("F"), false, nil},
+		{request(""), false, ErrInvalidOverwrite},
+		{request("F", "T"), false, ErrInvalidOverwrite},
+	}
+	for _, tt := range requests {
+		got, err := Overwrite(tt.r)
+		if got != tt.want || err != tt.err {
+			t.Errorf("Overwrite(%q) = %v, %v; want %v, %v", tt.r.Header["Overwrite"], got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"http://www.example.com/users/f/fielding/index.html", "http://www.example.com/users/f/fielding/index.html", nil},
+		{"/users/f/fielding/index.html", "/users/f/fielding/index.html", nil},
+		{"/a%20b", "/a%20b", nil},
+		{"http://www.example.com/a#frag", "", ErrInvalidDestination},
+		{"http:/a", "", ErrInvalidDestination},
+		{"relative/path", "", ErrInvalidDestination},
+		{"//www.example.com/a", "", ErrInvalidDestination},
+		{"mailto:a@example.com", "", ErrInvalidDestination},
+		{"", "", ErrInvalidDestination},
+	}
+	for _, tt := range tests {
+		u, err := ParseDestination(tt.in)
+		if err != tt.err || err == nil && u.String() != tt.want {
+			t.Errorf("ParseDestination(%q) = %v, %v; want %q, %v", tt.in, u, err, tt.want, tt.err)
+		}
+	}
+
+	hosts := []struct {
+		dest string
+		err  error
+	}{
+		{"http://www.example.com/b", nil},
+		{"http://WWW.EXAMPLE.COM/b", nil},
+		{"/b", nil},
+		{"http://other.example.com/b", ErrForeignDestination},
+		{"http://www.example.com:8080/b", ErrForeignDestination},
+	}
+	for _, tt := range hosts {
+		r := httptest.NewRequest("MOVE", "http://www.example.com/a", nil)
+		r.Header.Set("Destination", tt.dest)
+		if _, err := Destination(r); err != tt.err {
+			t.Errorf("Destination(%q) = %v, want %v", tt.dest, err, tt.err)
+		}
+	}
+}
+
+func TestLockToken(t *testing.T) {
+	const token = "urn:uuid:a515cfa4-5da4-22e1-f5b5-00a0451e6bf7"
+	if got, err := ParseLockToken(" <" + token + "> "); got != token || err != nil {
+		t.Errorf("ParseLockToken = %q, %v", got, err)
+	}
+	if got := FormatLockToken(token); got != "<"+token+">" {
+		t.Errorf("FormatLockToken = %q", got)
+	}
+	for _, s := range []string{"", token, "<>", "<" + token, "<" + token + "> x", "<a b>", "<<a>"} {
+		if _, err := ParseLockToken(s); err != ErrInvalidLockToken {
+			t.Errorf("ParseLockToken(%q) = %v, want %v", s, err, ErrInvalidLockToken)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []time.Duration
+	}{
+		{"Infinite, Second-4100000000", []time.Duration{TimeoutInfinite, 4100000000 * time.Second}},
+		{"Second-3600", []time.Duration{time.Hour}},
+		{"second-0, infinite", []time.Duration{0, TimeoutInfinite}},
+		{"Second-99999999999", []time.Duration{maxTimeoutSeconds * time.Second}},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeout(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseTimeout(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{"", "Second-", "Second--1", "Second-1.5", "Minute-1", "Infinite, x"} {
+		if _, err := ParseTimeout(s); err != ErrInvalidTimeout {
+			t.Errorf("ParseTimeout(%q) = %v, want %v", s, err, ErrInvalidTimeout)
+		}
+	}
+	if FormatTimeout(TimeoutInfinite) != "Infinite" || FormatTimeout(90500*time.Millisecond) != "Second-90" {
+		t.Errorf("FormatTimeout = %s, %s", FormatTimeout(TimeoutInfinite), FormatTimeout(90500*time.Millisecond))
+	}
+}
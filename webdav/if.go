@@ -0,0 +1,226 @@
+package webdav
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+// ErrInvalidIf is returned for If field values that do not follow the
+// grammar of RFC 4918 section 10.4.
+var ErrInvalidIf = errors.New("webdav: invalid If")
+
+// Condition is a single condition of an If list: a state token or an
+// entity tag, optionally negated.
+type Condition struct {
+	Not   bool
+	Token string                // state token URI; empty for entity tags
+	ETag  *httpfields.EntityTag // nil for state tokens
+}
+
+// List is a parenthesized list of conditions, all of which must hold.
+// Resource is the resource tag of a tagged list, or empty for a list that
+// applies to the request URI.
+type List struct {
+	Resource   string
+	Conditions []Condition
+}
+
+// If is a parsed If field. It holds either untagged lists only or tagged
+// lists only.
+type If struct {
+	Lists []List
+}
+
+// ParseIf parses an If field value.
+func ParseIf(s string) (If, error) {
+	var h If
+	s = skipLWS(s)
+	if s == "" {
+		return If{}, ErrInvalidIf
+	}
+	tagged := s[0] == '<'
+	var resource string
+	for s != "" {
+		if tagged && s[0] == '<' {
+			var ok bool
+			if resource, s, ok = consumeCodedURL(s); !ok {
+				return If{}, ErrInvalidIf
+			}
+			s = skipLWS(s)
+			if !strings.HasPrefix(s, "(") {
+				return If{}, ErrInvalidIf
+			}
+		}
+		if !strings.HasPrefix(s, "(") {
+			return If{}, ErrInvalidIf
+		}
+		l, rest, err := consumeList(s[1:])
+		if err != nil {
+			return If{}, err
+		}
+		l.Resource = resource
+		h.Lists = append(h.Lists, l)
+		s = skipLWS(rest)
+	}
+	return h, nil
+}
+
+// consumeList consumes the conditions and closing parenthesis of a list.
+func consumeList(s string) (List, string, error) {
+	var l List
+	for {
+		s = skipLWS(s)
+		if strings.HasPrefix(s, ")") {
+			if len(l.Conditions) == 0 {
+				return l, s, ErrInvalidIf
+			}
+			return l, s[1:], nil
+		}
+		var c Condition
+		if len(s) >= 3 && strings.EqualFold(s[:3], "Not") {
+			c.Not = true
+			s = skipLWS(s[3:])
+		}
+		switch {
+		case strings.HasPrefix(s, "<"):
+			var ok bool
+			if c.Token, s, ok = consumeCodedURL(s); !ok {
+				return l, s, ErrInvalidIf
+			}
+		case strings.HasPrefix(s, "["):
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return l, s, ErrInvalidIf
+			}
+			t, err := httpfields.ParseEntityTag(s[1:end])
+			if err != nil {
+				return l, s, ErrInvalidIf
+			}
+			c.ETag, s = &t, s[end+1:]
+		default:
+			return l, s, ErrInvalidIf
+		}
+		l.Conditions = append(l.Conditions, c)
+	}
+}
+
+func skipLWS(s string) string {
+	return strings.TrimLeft(s, " \t\r\n")
+}
+
+// String formats h as a field value.
+func (h If) String() string {
+	var b strings.Builder
+	resource := ""
+	for i, l := range h.Lists {
+		if l.Resource != "" && (i == 0 || l.Resource != resource) {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString("<" + l.Resource + ">")
+		}
+		resource = l.Resource
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteByte('(')
+		for j, c := range l.Conditions {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			if c.Not {
+				b.WriteString("Not ")
+			}
+			if c.ETag != nil {
+				b.WriteString("[" + c.ETag.String() + "]")
+			} else {
+				b.WriteString("<" + c.Token + ">")
+			}
+		}
+		b.WriteByte(')')
+	}
+	return b.String()
+}
+
+// Tokens returns the state tokens the client submits with the field, those
+// in conditions that are not negated. Servers check them against the locks
+// that protect the resources a request modifies.
+func (h If) Tokens() []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	for _, l := range h.Lists {
+		for _, c := range l.Conditions {
+			if c.ETag == nil && !c.Not && !seen[c.Token] {
+				seen[c.Token] = true
+				tokens = append(tokens, c.Token)
+			}
+		}
+	}
+	return tokens
+}
+
+// State is the current state of a resource: its entity tag, nil if it has
+// none or does not exist, and the tokens of the locks on it.
+type State struct {
+	ETag       *httpfields.EntityTag
+	LockTokens []string
+}
+
+// Lookup returns the state of the resource at path, the decoded path of a
+// resource tag or of the request URI.
+type Lookup func(path string) (State, error)
+
+// Evaluate evaluates h for a request to requestPath. The field holds if
+// any of its lists holds, and a list holds if all of its conditions do: a
+// state token must be among the resource's lock tokens and an entity tag
+// must strongly match the resource's. Resource tags are reduced to their
+// path; their host is not checked. Errors of lookup are returned unchanged.
+func (h If) Evaluate(requestPath string, lookup Lookup) (bool, error) {
+	states := make(map[string]State)
+	for _, l := range h.Lists {
+		path := requestPath
+		if l.Resource != "" {
+			u, err := url.Parse(l.Resource)
+			if err != nil {
+				continue
+			}
+			path = u.Path
+		}
+		st, ok := states[path]
+		if !ok {
+			var err error
+			if st, err = lookup(path); err != nil {
+				return false, err
+			}
+			states[path] = st
+		}
+		if l.holds(st) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (l List) holds(st State) bool {
+	for _, c := range l.Conditions {
+		if c.holds(st) == c.Not {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Condition) holds(st State) bool {
+	if c.ETag != nil {
+		return st.ETag != nil && c.ETag.StrongMatch(*st.ETag)
+	}
+	for _, t := range st.LockTokens {
+		if t == c.Token {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package webdav
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	httpfields "github.com/palsivertsen/gohttpfields"
+)
+
+const (
+	token1 = "urn:uuid:181d4fae-7d8c-11d0-a765-00a0c91e6bf2"
+	token2 = "urn:uuid:58f202ac-22cf-11d1-b12d-002035b29092"
+)
+
+func tag(weak bool, opaque string) *httpfields.EntityTag {
+	return &httpfields.EntityTag{Weak: weak, Opaque: opaque}
+}
+
+func TestParseIf(t *testing.T) {
+	tests := []struct {
+		in   string
+		want If
+		out  string // String of the result, if different from in
+	}{
+		// RFC 4918 section 10.4.6. The examples of the RFC put spaces in
+		// their entity tags, which the entity-tag grammar does not allow.
+		{
+			in: `(<` + token1 + `> ["I-am-an-ETag"]) (["I-am-another-ETag"])`,
+			want: If{Lists: []List{
+				{Conditions: []Condition{{Token: token1}, {ETag: tag(false, "I-am-an-ETag")}}},
+				{Conditions: []Condition{{ETag: tag(false, "I-am-another-ETag")}}},
+			}},
+		},
+		// Section 10.4.7.
+		{
+			in: `(Not <` + token1 + `> <` + token2 + `>)`,
+			want: If{Lists: []List{
+				{Conditions: []Condition{{Not: true, Token: token1}, {Token: token2}}},
+			}},
+		},
+		// Section 10.4.8.
+		{
+			in: `(<` + token1 + `>) (Not <DAV:no-lock>)`,
+			want: If{Lists: []List{
+				{Conditions: []Condition{{Token: token1}}},
+				{Conditions: []Condition{{Not: true, Token: "DAV:no-lock"}}},
+			}},
+		},
+		// Section 10.4.9.
+		{
+			in: `</resource1> (<` + token1 + `> [W/"A-weak-ETag"]) (["strong-ETag"])`,
+			want: If{Lists: []List{
+				{Resource: "/resource1", Conditions: []Condition{{Token: token1}, {ETag: tag(true, "A-weak-ETag")}}},
+				{Resource: "/resource1", Conditions: []Condition{{ETag: tag(false, "strong-ETag")}}},
+			}},
+		},
+		// Section 10.4.10.
+		{
+			in: `<http://www.example.com/specs/> (<` + token1 + `>)`,
+			want: If{Lists: []List{
+				{Resource: "http://www.example.com/specs/", Conditions: []Condition{{Token: token1}}},
+			}},
+		},
+		// Several tagged resources, odd whitespace and case.
+		{
+			in: "\t</a> (<" + token1 + ">)\r\n </b>(NOT\t<" + token2 + "> )",
+			want: If{Lists: []List{
+				{Resource: "/a", Conditions: []Condition{{Token: token1}}},
+				{Resource: "/b", Conditions: []Condition{{Not: true, Token: token2}}},
+			}},
+			out: "</a> (<" + token1 + ">) </b> (Not <" + token2 + ">)",
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseIf(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIf(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+			continue
+		}
+		out := tt.out
+		if out == "" {
+			out = tt.in
+		}
+		if s := got.String(); s != out {
+			t.Errorf("String = %q, want %q", s, out)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"()",
+		"(",
+		"(<" + token1 + ">",
+		"<" + token1 + ">",
+		"(" + token1 + ")",
+		"(<>)",
+		"([\"x\"",
+		"([x])",
+		"(Not)",
+		"(<a>) </r> (<b>)", // untagged and tagged lists mixed
+		"</r> (<a>) x",
+		"</r>",
+		"</r> </s> (<a>)",
+		`(["I am an ETag"])`,
+	} {
+		if _, err := ParseIf(s); err != ErrInvalidIf {
+			t.Errorf("ParseIf(%q) = %v, want %v", s, err, ErrInvalidIf)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	h, err := ParseIf(`</a> (<` + token1 + `> ["x"]) (Not <` + token2 + `>) </b> (<` + token1 + `>) (<` + token2 + `>)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := h.Tokens(), []string{token1, token2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens = %q, want %q", got, want)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	states := map[string]State{
+		"/locked":   {ETag: tag(false, "v1"), LockTokens: []string{token1}},
+		"/weak":     {ETag: tag(true, "v1")},
+		"/unlocked": {ETag: tag(false, "v2")},
+		"/missing":  {},
+	}
+	errLookup := errors.New("lookup failed")
+	lookup := func(path string) (State, error) {
+		if path == "/error" {
+			return State{}, errLookup
+		}
+		return states[path], nil
+	}
+	tests := []struct {
+		name string
+		path string
+		in   string
+		want bool
+		err  error
+	}{
+		{"token held", "/locked", `(<` + token1 + `>)`, true, nil},
+		{"token not held", "/locked", `(<` + token2 + `>)`, false, nil},
+		{"token and etag", "/locked", `(<` + token1 + `> ["v1"])`, true, nil},
+		{"token and wrong etag", "/locked", `(<` + token1 + `> ["v2"])`, false, nil},
+		{"second list", "/locked", `(<` + token2 + `>) (["v1"])`, true, nil},
+		{"negated token", "/locked", `(Not <` + token2 + `>)`, true, nil},
+		{"negated held token", "/locked", `(Not <` + token1 + `>)`, false, nil},
+		{"no-lock trick", "/unlocked", `(<` + token1 + `>) (Not <DAV:no-lock>)`, true, nil},
+		{"weak etag in field", "/locked", `([W/"v1"])`, false, nil},
+		{"weak etag of resource", "/weak", `(["v1"])`, false, nil},
+		{"negated weak comparison", "/weak", `(Not ["v1"])`, true, nil},
+		{"no etag", "/missing", `(["v1"])`, false, nil},
+		{"tagged path", "/other", `</locked> (<` + token1 + `>)`, true, nil},
+		{"tagged absolute URI", "/other", `<http://www.example.com/locked> (<` + token1 + `>)`, true, nil},
+		{"tagged other resource", "/locked", `</unlocked> (<` + token1 + `>)`, false, nil},
+		{"tagged escaped path", "/other", `</lock%65d> (<` + token1 + `>)`, true, nil},
+		{"lookup error", "/error", `(<` + token1 + `>)`, false, errLookup},
+		{"lookup error after a hold", "/locked", `</locked> (<` + token1 + `>) </error> (<` + token1 + `>)`, true, nil},
+	}
+	for _, tt := range tests {
+		h, err := ParseIf(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := h.Evaluate(tt.path, lookup)
+		if got != tt.want || err != tt.err {
+			t.Errorf("%s: Evaluate(%q) = %v, %v; want %v, %v", tt.name, tt.in, got, err, tt.want, tt.err)
+		}
+	}
+
+	// Each resource is looked up once.
+	calls := 0
+	h, _ := ParseIf(`</a> (<x:1>) (<x:2>) (<x:3>) </b> (<x:4>)`)
+	h.Evaluate("/", func(string) (State, error) { calls++; return State{}, nil })
+	if calls != 2 {
+		t.Errorf("%d lookups, want 2", calls)
+	}
+}
@@ -0,0 +1,247 @@
+package webhook
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Field names used by the supported providers.
+const (
+	HeaderStripeSignature    = "Stripe-Signature"
+	HeaderGitHubSignature    = "X-Hub-Signature-256"
+	HeaderSlackSignature     = "X-Slack-Signature"
+	HeaderSlackTimestamp     = "X-Slack-Request-Timestamp"
+	HeaderStandardID         = "Webhook-Id"
+	HeaderStandardTimestamp  = "Webhook-Timestamp"
+	HeaderStandardSignatures = "Webhook-Signature"
+)
+
+// StripeSignature is a parsed Stripe-Signature field.
+type StripeSignature struct {
+	Timestamp time.Time
+	V1        [][]byte // signatures of the v1 scheme; other schemes are dropped
+}
+
+// ParseStripeSignature parses a Stripe-Signature field value of the form
+// "t=1492774577,v1=5257a8...,v1=...". Signatures that are not valid hex
+// are skipped.
+func ParseStripeSignature(s string) (StripeSignature, error) {
+	var sig StripeSignature
+	hasTimestamp := false
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			return sig, ErrMalformedSignature
+		}
+		k, v := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+		switch k {
+		case "t":
+			t, err := parseUnix(v)
+			if err != nil {
+				return sig, err
+			}
+			sig.Timestamp, hasTimestamp = t, true
+		case "v1":
+			if b, err := hex.DecodeString(v); err == nil {
+				sig.V1 = append(sig.V1, b)
+			}
+		}
+	}
+	if !hasTimestamp {
+		return sig, ErrMalformedSignature
+	}
+	return sig, nil
+}
+
+// SignStripe returns a Stripe-Signature field value for payload.
+func SignStripe(payload []byte, secret string, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := sign([]byte(secret), []byte(ts), []byte("."), payload)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac)
+}
+
+// Stripe verifies a Stripe-Signature field value against payload. The
+// signed content is the timestamp, a period and the payload; any of the v1
+// signatures may match.
+func (v *Verifier) Stripe(header string, payload []byte, secret string) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	sig, err := ParseStripeSignature(header)
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(sig.Timestamp.Unix(), 10)
+	if !matchAny(sign([]byte(secret), []byte(ts), []byte("."), payload), sig.V1) {
+		return ErrSignatureMismatch
+	}
+	return v.checkTimestamp(sig.Timestamp)
+}
+
+// StripeFunc returns a Func verifying Stripe deliveries for Middleware.
+func (v *Verifier) StripeFunc(secret string) Func {
+	return func(h http.Header, payload []byte) error {
+		return v.Stripe(h.Get(HeaderStripeSignature), payload, secret)
+	}
+}
+
+// ParseGitHubSignature parses an X-Hub-Signature-256 field value of the
+// form "sha256=<hex>".
+func ParseGitHubSignature(s string) ([]byte, error) {
+	const prefix = "sha256="
+	if !strings.HasPrefix(s, prefix) {
+		return nil, ErrMalformedSignature
+	}
+	b, err := hex.DecodeString(s[len(prefix):])
+	if err != nil {
+		return nil, ErrMalformedSignature
+	}
+	return b, nil
+}
+
+// SignGitHub returns an X-Hub-Signature-256 field value for payload.
+func SignGitHub(payload []byte, secret string) string {
+	return "sha256=" + hex.EncodeToString(sign([]byte(secret), payload))
+}
+
+// GitHub verifies an X-Hub-Signature-256 field value against payload.
+// GitHub signatures carry no timestamp, so the tolerance does not apply.
+func (v *Verifier) GitHub(header string, payload []byte, secret string) error {
+	if header == "" {
+		return ErrMissingSignature
+	}
+	sig, err := ParseGitHubSignature(header)
+	if err != nil {
+		return err
+	}
+	if !matchAny(sign([]byte(secret), payload), [][]byte{sig}) {
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// GitHubFunc returns a Func verifying GitHub deliveries for Middleware.
+func (v *Verifier) GitHubFunc(secret string) Func {
+	return func(h http.Header, payload []byte) error {
+		return v.GitHub(h.Get(HeaderGitHubSignature), payload, secret)
+	}
+}
+
+// SignSlack sets the X-Slack-Signature and X-Slack-Request-Timestamp
+// fields for payload in h.
+func SignSlack(h http.Header, payload []byte, secret string, t time.Time) {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := sign([]byte(secret), []byte("v0:"+ts+":"), payload)
+	h.Set(HeaderSlackTimestamp, ts)
+	h.Set(HeaderSlackSignature, "v0="+hex.EncodeToString(mac))
+}
+
+// Slack verifies the X-Slack-Signature field in h against payload. The
+// signed content is "v0:", the X-Slack-Request-Timestamp value, a colon
+// and the payload.
+func (v *Verifier) Slack(h http.Header, payload []byte, secret string) error {
+	header, ts := h.Get(HeaderSlackSignature), h.Get(HeaderSlackTimestamp)
+	if header == "" || ts == "" {
+		return ErrMissingSignature
+	}
+	t, err := parseUnix(ts)
+	if err != nil {
+		return err
+	}
+	const prefix = "v0="
+	if !strings.HasPrefix(header, prefix) {
+		return ErrMalformedSignature
+	}
+	sig, err := hex.DecodeString(header[len(prefix):])
+	if err != nil {
+		return ErrMalformedSignature
+	}
+	if !matchAny(sign([]byte(secret), []byte("v0:"+ts+":"), payload), [][]byte{sig}) {
+		return ErrSignatureMismatch
+	}
+	return v.checkTimestamp(t)
+}
+
+// SlackFunc returns a Func verifying Slack deliveries for Middleware.
+func (v *Verifier) SlackFunc(secret string) Func {
+	return func(h http.Header, payload []byte) error {
+		return v.Slack(h, payload, secret)
+	}
+}
+
+// standardSecret decodes a Standard Webhooks secret, which is base64
+// encoded with an optional "whsec_" prefix.
+func standardSecret(secret string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return nil, ErrMalformedSignature
+	}
+	return key, nil
+}
+
+// ParseStandardSignatures parses a webhook-signature field value: a
+// space-separated list of "version,base64" signatures. Only v1 (symmetric)
+// signatures are returned; undecodable ones are skipped.
+func ParseStandardSignatures(s string) [][]byte {
+	var sigs [][]byte
+	for _, f := range strings.Fields(s) {
+		i := strings.IndexByte(f, ',')
+		if i < 0 || f[:i] != "v1" {
+			continue
+		}
+		if b, err := base64.StdEncoding.DecodeString(f[i+1:]); err == nil {
+			sigs = append(sigs, b)
+		}
+	}
+	return sigs
+}
+
+// SignStandard sets the webhook-id, webhook-timestamp and
+// webhook-signature fields for payload in h.
+func SignStandard(h http.Header, id string, payload []byte, secret string, t time.Time) error {
+	key, err := standardSecret(secret)
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := sign(key, []byte(id+"."+ts+"."), payload)
+	h.Set(HeaderStandardID, id)
+	h.Set(HeaderStandardTimestamp, ts)
+	h.Set(HeaderStandardSignatures, "v1,"+base64.StdEncoding.EncodeToString(mac))
+	return nil
+}
+
+// Standard verifies the Standard Webhooks fields in h against payload. The
+// signed content is the message id, the timestamp and the payload joined by
+// periods; secret is the base64 key with or without its "whsec_" prefix.
+// Asymmetric (v1a) signatures are not supported.
+func (v *Verifier) Standard(h http.Header, payload []byte, secret string) error {
+	id, ts, header := h.Get(HeaderStandardID), h.Get(HeaderStandardTimestamp), h.Get(HeaderStandardSignatures)
+	if id == "" || ts == "" || header == "" {
+		return ErrMissingSignature
+	}
+	t, err := parseUnix(ts)
+	if err != nil {
+		return err
+	}
+	key, err := standardSecret(secret)
+	if err != nil {
+		return err
+	}
+	if !matchAny(sign(key, []byte(id+"."+ts+"."), payload), ParseStandardSignatures(header)) {
+		return ErrSignatureMismatch
+	}
+	return v.checkTimestamp(t)
+}
+
+// StandardFunc returns a Func verifying Standard Webhooks deliveries for
+// Middleware.
+func (v *Verifier) StandardFunc(secret string) Func {
+	return func(h http.Header, payload []byte) error {
+		return v.Standard(h, payload, secret)
+	}
+}
@@ -0,0 +1,161 @@
+// Package webhook verifies the signature fields that webhook providers
+// attach to their deliveries: Stripe-Signature, GitHub's
+// X-Hub-Signature-256, Slack's X-Slack-Signature and the webhook-signature
+// field of Standard Webhooks.
+//
+// All signatures are HMAC-SHA256 over the raw request body and are compared
+// in constant time. Timestamped schemes are checked against a tolerance
+// window to limit replays.
+//
+// Use a Verifier method directly on a buffered body, or let Middleware
+// buffer and verify the body before the handler runs:
+//
+//	v := &webhook.Verifier{}
+//	h := v.Middleware(v.StripeFunc(secret), handler)
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Defaults applied when the corresponding Verifier field is zero.
+const (
+	DefaultTolerance   = 5 * time.Minute
+	DefaultMaxBodySize = 1 << 20
+)
+
+var (
+	// ErrMissingSignature is returned when a signature or timestamp field
+	// is absent.
+	ErrMissingSignature = errors.New("webhook: missing signature")
+	// ErrMalformedSignature is returned for signature or timestamp fields
+	// that cannot be parsed.
+	ErrMalformedSignature = errors.New("webhook: malformed signature")
+	// ErrSignatureMismatch is returned when no signature matches the
+	// payload.
+	ErrSignatureMismatch = errors.New("webhook: signature mismatch")
+	// ErrTimestampOutOfRange is returned when a signature's timestamp is
+	// outside the tolerance window.
+	ErrTimestampOutOfRange = errors.New("webhook: timestamp outside tolerance")
+	// ErrBodyTooLarge is returned by Middleware's body buffering when the
+	// body exceeds MaxBodySize.
+	ErrBodyTooLarge = errors.New("webhook: body too large")
+)
+
+// A Func verifies a delivery given its header and raw body.
+type Func func(h http.Header, payload []byte) error
+
+// Verifier holds the verification settings. The zero value uses
+// DefaultTolerance, DefaultMaxBodySize and the system clock.
+type Verifier struct {
+	// Tolerance is the maximum difference between a signature's timestamp
+	// and the current time. Zero selects DefaultTolerance; a negative
+	// value disables the check.
+	Tolerance time.Duration
+	// MaxBodySize limits the bodies buffered by Middleware. Zero selects
+	// DefaultMaxBodySize; a negative value disables the limit.
+	MaxBodySize int64
+	// Now returns the current time. Nil selects time.Now.
+	Now func() time.Time
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now != nil {
+		return v.Now()
+	}
+	return time.Now()
+}
+
+// checkTimestamp enforces the tolerance window around t.
+func (v *Verifier) checkTimestamp(t time.Time) error {
+	tolerance := v.Tolerance
+	if tolerance == 0 {
+		tolerance = DefaultTolerance
+	}
+	if tolerance < 0 {
+		return nil
+	}
+	d := v.now().Sub(t)
+	if d > tolerance || d < -tolerance {
+		return ErrTimestampOutOfRange
+	}
+	return nil
+}
+
+// Middleware reads the request body, verifies it with verify and passes
+// the request with a rewound body to next. Failed verifications are
+// answered with 401 (Unauthorized), oversized bodies with 413 (Payload Too
+// Large).
+func (v *Verifier) Middleware(verify Func, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := v.readBody(r)
+		if err == ErrBodyTooLarge {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if err := verify(r.Header, body); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *Verifier) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	defer r.Body.Close()
+	limit := v.MaxBodySize
+	if limit == 0 {
+		limit = DefaultMaxBodySize
+	}
+	if limit < 0 {
+		return ioutil.ReadAll(r.Body)
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, limit))
+	if err != nil && int64(len(body)) >= limit {
+		return nil, ErrBodyTooLarge
+	}
+	return body, err
+}
+
+func sign(secret []byte, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	for _, p := range parts {
+		mac.Write(p)
+	}
+	return mac.Sum(nil)
+}
+
+// matchAny reports whether any of sigs equals want, comparing each in
+// constant time.
+func matchAny(want []byte, sigs [][]byte) bool {
+	found := false
+	for _, sig := range sigs {
+		if hmac.Equal(want, sig) {
+			found = true
+		}
+	}
+	return found
+}
+
+func parseUnix(s string) (time.Time, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, ErrMalformedSignature
+	}
+	return time.Unix(n, 0), nil
+}
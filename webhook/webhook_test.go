@@ -0,0 +1,297 @@
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(unix int64) *Verifier {
+	return &Verifier{Now: func() time.Time { return time.Unix(unix, 0) }}
+}
+
+// The example request of Slack's "Verifying requests from Slack" guide.
+const (
+	slackSecret    = "8f742231b10e8888abcd99yyyzzz85a5"
+	slackTimestamp = "1531420618"
+	slackSignature = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+	slackBody      = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+)
+
+// The example of the Standard Webhooks reference implementations.
+const (
+	standardKey       = "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	standardID        = "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	standardTimestamp = "1614265330"
+	standardSignature = "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+	standardBody      = `{"test": 2432232314}`
+)
+
+const (
+	stripeSecret    = "whsec_test_secret"
+	stripeBody      = `{"id":"evt_1"}`
+	stripeSignature = "799c4ba7bb339f3c8601adfd112f0e477c930a268be1243d341abb8286501c2f"
+)
+
+func TestStripe(t *testing.T) {
+	v := at(1492774577)
+	tests := []struct {
+		name   string
+		header string
+		err    error
+	}{
+		{"valid", "t=1492774577,v1=" + stripeSignature, nil},
+		{"spaces", "t=1492774577, v1=" + stripeSignature, nil},
+		{"second of several v1", "t=1492774577,v1=" + strings.Repeat("00", 32) + ",v1=" + stripeSignature, nil},
+		{"first of several v1", "t=1492774577,v1=" + stripeSignature + ",v1=" + strings.Repeat("00", 32), nil},
+		{"with v0", "t=1492774577,v0=" + strings.Repeat("ab", 32) + ",v1=" + stripeSignature, nil},
+		{"invalid hex skipped", "t=1492774577,v1=zz,v1=" + stripeSignature, nil},
+		{"only v0", "t=1492774577,v0=" + stripeSignature, ErrSignatureMismatch},
+		{"wrong signature", "t=1492774577,v1=" + strings.Repeat("00", 32), ErrSignatureMismatch},
+		{"other timestamp", "t=1492774578,v1=" + stripeSignature, ErrSignatureMismatch},
+		{"empty", "", ErrMissingSignature},
+		{"no timestamp", "v1=" + stripeSignature, ErrMalformedSignature},
+		{"bad timestamp", "t=abc,v1=" + stripeSignature, ErrMalformedSignature},
+		{"negative timestamp", "t=-1,v1=" + stripeSignature, ErrMalformedSignature},
+		{"no equals", "t=1492774577,v1", ErrMalformedSignature},
+	}
+	for _, tt := range tests {
+		if err := v.Stripe(tt.header, []byte(stripeBody), stripeSecret); err != tt.err {
+			t.Errorf("%s: Stripe(%q) = %v, want %v", tt.name, tt.header, err, tt.err)
+		}
+	}
+	if err := v.Stripe("t=1492774577,v1="+stripeSignature, []byte(stripeBody+" "), stripeSecret); err != ErrSignatureMismatch {
+		t.Errorf("tampered payload: %v", err)
+	}
+	if err := v.Stripe("t=1492774577,v1="+stripeSignature, []byte(stripeBody), "other"); err != ErrSignatureMismatch {
+		t.Errorf("wrong secret: %v", err)
+	}
+
+	got := SignStripe([]byte(stripeBody), stripeSecret, time.Unix(1492774577, 0))
+	if got != "t=1492774577,v1="+stripeSignature {
+		t.Errorf("SignStripe = %q", got)
+	}
+	sig, err := ParseStripeSignature("t=1492774577,v1=0a0b,v1=ff")
+	if err != nil || sig.Timestamp.Unix() != 1492774577 || len(sig.V1) != 2 || sig.V1[1][0] != 0xff {
+		t.Errorf("ParseStripeSignature = %+v, %v", sig, err)
+	}
+}
+
+func TestGitHub(t *testing.T) {
+	// The example of GitHub's "Validating webhook deliveries" guide.
+	const (
+		secret  = "It's a Secret to Everybody"
+		payload = "Hello, World!"
+		header  = "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"
+	)
+	v := &Verifier{}
+	if err := v.GitHub(header, []byte(payload), secret); err != nil {
+		t.Errorf("GitHub = %v", err)
+	}
+	if got := SignGitHub([]byte(payload), secret); got != header {
+		t.Errorf("SignGitHub = %q", got)
+	}
+	tests := []struct {
+		header  string
+		payload string
+		err     error
+	}{
+		{header, "Hello, World?", ErrSignatureMismatch},
+		{"sha256=" + strings.Repeat("00", 32), payload, ErrSignatureMismatch},
+		{"sha256=757107", payload, ErrSignatureMismatch},
+		{"", payload, ErrMissingSignature},
+		{"sha1=757107ea0eb2509fc211221cce984b8a37570b6d", payload, ErrMalformedSignature},
+		{"sha256=xyz", payload, ErrMalformedSignature},
+	}
+	for _, tt := range tests {
+		if err := v.GitHub(tt.header, []byte(tt.payload), secret); err != tt.err {
+			t.Errorf("GitHub(%q, %q) = %v, want %v", tt.header, tt.payload, err, tt.err)
+		}
+	}
+}
+
+func TestSlack(t *testing.T) {
+	header := func(sig, ts string) http.Header {
+		h := http.Header{}
+		if sig != "" {
+			h.Set(HeaderSlackSignature, sig)
+		}
+		if ts != "" {
+			h.Set(HeaderSlackTimestamp, ts)
+		}
+		return h
+	}
+	v := at(1531420618)
+	tests := []struct {
+		name string
+		h    http.Header
+		body string
+		err  error
+	}{
+		{"valid", header(slackSignature, slackTimestamp), slackBody, nil},
+		{"tampered body", header(slackSignature, slackTimestamp), slackBody + "&x=1", ErrSignatureMismatch},
+		{"other timestamp", header(slackSignature, "1531420619"), slackBody, ErrSignatureMismatch},
+		{"missing signature", header("", slackTimestamp), slackBody, ErrMissingSignature},
+		{"missing timestamp", header(slackSignature, ""), slackBody, ErrMissingSignature},
+		{"bad timestamp", header(slackSignature, "soon"), slackBody, ErrMalformedSignature},
+		{"wrong version", header("v1="+slackSignature[3:], slackTimestamp), slackBody, ErrMalformedSignature},
+		{"bad hex", header("v0=xyz", slackTimestamp), slackBody, ErrMalformedSignature},
+	}
+	for _, tt := range tests {
+		if err := v.Slack(tt.h, []byte(tt.body), slackSecret); err != tt.err {
+			t.Errorf("%s: Slack = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	h := http.Header{}
+	SignSlack(h, []byte(slackBody), slackSecret, time.Unix(1531420618, 0))
+	if h.Get(HeaderSlackSignature) != slackSignature || h.Get(HeaderSlackTimestamp) != slackTimestamp {
+		t.Errorf("SignSlack = %v", h)
+	}
+}
+
+func TestStandard(t *testing.T) {
+	header := func(id, ts, sig string) http.Header {
+		return http.Header{
+			HeaderStandardID:         {id},
+			HeaderStandardTimestamp:  {ts},
+			HeaderStandardSignatures: {sig},
+		}
+	}
+	v := at(1614265330)
+	tests := []struct {
+		name   string
+		h      http.Header
+		secret string
+		err    error
+	}{
+		{"valid", header(standardID, standardTimestamp, standardSignature), standardKey, nil},
+		{"secret without prefix", header(standardID, standardTimestamp, standardSignature), strings.TrimPrefix(standardKey, "whsec_"), nil},
+		{"one of several", header(standardID, standardTimestamp, "v1,AAAA v1a,abc "+standardSignature), standardKey, nil},
+		{"only v1a", header(standardID, standardTimestamp, "v1a"+standardSignature[2:]), standardKey, ErrSignatureMismatch},
+		{"other id", header("msg_other", standardTimestamp, standardSignature), standardKey, ErrSignatureMismatch},
+		{"other timestamp", header(standardID, "1614265331", standardSignature), standardKey, ErrSignatureMismatch},
+		{"missing id", header("", standardTimestamp, standardSignature), standardKey, ErrMissingSignature},
+		{"missing signature", header(standardID, standardTimestamp, ""), standardKey, ErrMissingSignature},
+		{"bad timestamp", header(standardID, "1.5", standardSignature), standardKey, ErrMalformedSignature},
+		{"bad secret", header(standardID, standardTimestamp, standardSignature), "whsec_!!", ErrMalformedSignature},
+	}
+	for _, tt := range tests {
+		if err := v.Standard(tt.h, []byte(standardBody), tt.secret); err != tt.err {
+			t.Errorf("%s: Standard = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+
+	h := http.Header{}
+	if err := SignStandard(h, standardID, []byte(standardBody), standardKey, time.Unix(1614265330, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if h.Get(HeaderStandardSignatures) != standardSignature || h.Get(HeaderStandardTimestamp) != standardTimestamp {
+		t.Errorf("SignStandard = %v", h)
+	}
+}
+
+func TestTolerance(t *testing.T) {
+	const signed = 1614265330
+	h := http.Header{
+		HeaderStandardID:         {standardID},
+		HeaderStandardTimestamp:  {standardTimestamp},
+		HeaderStandardSignatures: {standardSignature},
+	}
+	tests := []struct {
+		tolerance time.Duration
+		now       int64
+		err       error
+	}{
+		{0, signed + 300, nil},
+		{0, signed - 300, nil},
+		{0, signed + 301, ErrTimestampOutOfRange},
+		{0, signed - 301, ErrTimestampOutOfRange},
+		{time.Minute, signed + 60, nil},
+		{time.Minute, signed + 61, ErrTimestampOutOfRange},
+		{-1, signed + 365*24*3600, nil},
+	}
+	for _, tt := range tests {
+		v := at(tt.now)
+		v.Tolerance = tt.tolerance
+		if err := v.Standard(h, []byte(standardBody), standardKey); err != tt.err {
+			t.Errorf("tolerance %v, %+ds: %v, want %v", tt.tolerance, tt.now-signed, err, tt.err)
+		}
+	}
+
+	// A mismatch is reported before the timestamp is judged.
+	if err := at(signed+3600).Standard(h, []byte("{}"), standardKey); err != ErrSignatureMismatch {
+		t.Errorf("stale and tampered: %v", err)
+	}
+	// GitHub has no timestamp to check.
+	if err := at(0).GitHub(SignGitHub([]byte("x"), "s"), []byte("x"), "s"); err != nil {
+		t.Errorf("GitHub with a stale clock: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = string(b)
+	})
+	post := func(v *Verifier, body string) int {
+		r := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+		r.Header.Set(HeaderGitHubSignature, SignGitHub([]byte(body), "s"))
+		rec := httptest.NewRecorder()
+		v.Middleware(v.GitHubFunc("s"), next).ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	if code := post(&Verifier{}, "payload"); code != 200 || got != "payload" {
+		t.Errorf("valid delivery: %d, handler read %q", code, got)
+	}
+	tests := []struct {
+		limit int64
+		size  int
+		code  int
+	}{
+		{16, 16, 200},
+		{16, 17, 413},
+		{0, DefaultMaxBodySize, 200},
+		{0, DefaultMaxBodySize + 1, 413},
+		{-1, DefaultMaxBodySize + 1, 200},
+	}
+	for _, tt := range tests {
+		got = ""
+		body := strings.Repeat("x", tt.size)
+		if code := post(&Verifier{MaxBodySize: tt.limit}, body); code != tt.code {
+			t.Errorf("limit %d, %d bytes: %d, want %d", tt.limit, tt.size, code, tt.code)
+		}
+		if tt.code == 200 && got != body {
+			t.Errorf("limit %d, %d bytes: handler read %d bytes", tt.limit, tt.size, len(got))
+		}
+	}
+
+	got = ""
+	r := httptest.NewRequest("POST", "/hook", strings.NewReader("payload"))
+	r.Header.Set(HeaderGitHubSignature, SignGitHub([]byte("other"), "s"))
+	rec := httptest.NewRecorder()
+	v := &Verifier{}
+	v.Middleware(v.GitHubFunc("s"), next).ServeHTTP(rec, r)
+	if rec.Code != 401 || got != "" {
+		t.Errorf("bad signature: %d, handler read %q", rec.Code, got)
+	}
+
+	// Each provider's Func reads its own fields.
+	h := http.Header{}
+	SignSlack(h, []byte(slackBody), slackSecret, time.Unix(1531420618, 0))
+	h.Set(HeaderStripeSignature, "t=1492774577,v1="+stripeSignature)
+	if err := at(1531420618).SlackFunc(slackSecret)(h, []byte(slackBody)); err != nil {
+		t.Errorf("SlackFunc = %v", err)
+	}
+	if err := at(1492774577).StripeFunc(stripeSecret)(h, []byte(stripeBody)); err != nil {
+		t.Errorf("StripeFunc = %v", err)
+	}
+	if err := at(1492774577).StandardFunc(standardKey)(h, []byte(standardBody)); err != ErrMissingSignature {
+		t.Errorf("StandardFunc without fields = %v", err)
+	}
+}